@@ -3,106 +3,199 @@
 package main
 
 /* main.sgo:3 */ import (
-/* main.sgo:4 */ 	"fmt"
-/* main.sgo:5 */ 	"io"
-/* main.sgo:6 */ 	"os"
-/* main.sgo:7 */ 	"os/exec"
-
-/* main.sgo:9 */ 	"github.com/tcard/sgo/sgo"
-/* main.sgo:10 */ 	"github.com/tcard/sgo/sgo/scanner"
-/* main.sgo:11 */ )
-
-/* main.sgo:13 */ func main() {
-/* main.sgo:14 */ 	if len(os.Args) == 1 {
-/* main.sgo:15 */ 		fmt.Print(helpMsg)
-/* main.sgo:16 */ 		return
-/* main.sgo:17 */ 	}
-
-/* main.sgo:19 */ 	var buildFlags []string
-/* main.sgo:20 */ 	var extraArgs []string
-/* main.sgo:21 */ 	for i, arg := range os.Args[2:] {
-/* main.sgo:22 */ 		if arg[0] == '-' {
-/* main.sgo:23 */ 			buildFlags = append(buildFlags, arg)
-/* main.sgo:24 */ 		} else {
-/* main.sgo:25 */ 			extraArgs = os.Args[i+2:]
-/* main.sgo:26 */ 			break
-/* main.sgo:27 */ 		}
-/* main.sgo:28 */ 	}
-
-/* main.sgo:30 */ 	switch os.Args[1] {
-/* main.sgo:31 */ 	case "version":
-/* main.sgo:32 */ 		fmt.Println("sgo version 0.7 (compatible with go1.7)")
-/* main.sgo:33 */ 		return
-/* main.sgo:34 */ 	case "run":
-/* main.sgo:35 */ 		if len(extraArgs) == 0 {
-/* main.sgo:36 */ 			fmt.Fprintln(os.Stderr, "sgo run: no files listed")
-/* main.sgo:37 */ 			os.Exit(1)
-/* main.sgo:38 */ 		}
-/* main.sgo:39 */ 		created, errs := sgo.TranslateFilePaths(extraArgs...)
-/* main.sgo:40 */ 		reportErrs(errs...)
-/* main.sgo:41 */ 		if len(errs) > 0 {
-/* main.sgo:42 */ 			os.Exit(1)
-/* main.sgo:43 */ 		}
-/* main.sgo:44 */ 		runGoCommand("run", buildFlags, created...)
-/* main.sgo:45 */ 		return
-/* main.sgo:46 */ 	case "help":
-/* main.sgo:47 */ 		if len(extraArgs) == 0 {
-/* main.sgo:48 */ 			fmt.Print(helpMsg)
-/* main.sgo:49 */ 		} else {
-/* main.sgo:50 */ 			switch extraArgs[0] {
-/* main.sgo:51 */ 			case "translate":
-/* main.sgo:52 */ 				fmt.Print(translateHelpMsg)
-/* main.sgo:53 */ 				return
-/* main.sgo:54 */ 			case "version":
-/* main.sgo:55 */ 				fmt.Print(versionHelpMsg)
+/* main.sgo:4 */ 	"bufio"
+/* main.sgo:5 */ 	"fmt"
+/* main.sgo:6 */ 	"io"
+/* main.sgo:7 */ 	"io/ioutil"
+/* main.sgo:8 */ 	"os"
+/* main.sgo:9 */ 	"os/exec"
+
+/* main.sgo:11 */ 	"github.com/tcard/sgo/sgo"
+/* main.sgo:12 */ 	"github.com/tcard/sgo/sgo/importer"
+/* main.sgo:13 */ 	"github.com/tcard/sgo/sgo/scanner"
+/* main.sgo:14 */ )
+
+/* main.sgo:16 */ func main() {
+/* main.sgo:17 */ 	if len(os.Args) == 1 {
+/* main.sgo:18 */ 		fmt.Print(helpMsg)
+/* main.sgo:19 */ 		return
+/* main.sgo:20 */ 	}
+
+/* main.sgo:22 */ 	var buildFlags []string
+/* main.sgo:23 */ 	var extraArgs []string
+/* main.sgo:24 */ 	for i, arg := range os.Args[2:] {
+/* main.sgo:25 */ 		if arg[0] == '-' {
+/* main.sgo:26 */ 			buildFlags = append(buildFlags, arg)
+/* main.sgo:27 */ 		} else {
+/* main.sgo:28 */ 			extraArgs = os.Args[i+2:]
+/* main.sgo:29 */ 			break
+/* main.sgo:30 */ 		}
+/* main.sgo:31 */ 	}
+
+/* main.sgo:33 */ 	switch os.Args[1] {
+/* main.sgo:34 */ 	case "version":
+/* main.sgo:35 */ 		fmt.Println("sgo version 0.7 (compatible with go1.7)")
+/* main.sgo:36 */ 		return
+/* main.sgo:37 */ 	case "run":
+/* main.sgo:38 */ 		if len(extraArgs) == 0 {
+/* main.sgo:39 */ 			fmt.Fprintln(os.Stderr, "sgo run: no files listed")
+/* main.sgo:40 */ 			os.Exit(1)
+/* main.sgo:41 */ 		}
+/* main.sgo:42 */ 		created, errs := sgo.TranslateFilePaths(extraArgs...)
+/* main.sgo:43 */ 		reportErrs(errs...)
+/* main.sgo:44 */ 		if len(errs) > 0 {
+/* main.sgo:45 */ 			os.Exit(1)
+/* main.sgo:46 */ 		}
+/* main.sgo:47 */ 		runGoCommand("run", buildFlags, created...)
+/* main.sgo:48 */ 		return
+/* main.sgo:49 */ 	case "help":
+/* main.sgo:50 */ 		if len(extraArgs) == 0 {
+/* main.sgo:51 */ 			fmt.Print(helpMsg)
+/* main.sgo:52 */ 		} else {
+/* main.sgo:53 */ 			switch extraArgs[0] {
+/* main.sgo:54 */ 			case "translate":
+/* main.sgo:55 */ 				fmt.Print(translateHelpMsg)
 /* main.sgo:56 */ 				return
-/* main.sgo:57 */ 			}
-/* main.sgo:58 */ 			runGoCommand("help", buildFlags, extraArgs...)
-/* main.sgo:59 */ 		}
-/* main.sgo:60 */ 		return
-/* main.sgo:61 */ 	case "translate":
-/* main.sgo:62 */ 		errs := sgo.TranslateFile(func() (io.Writer, error) { return os.Stdout, nil }, os.Stdin, "stdin.sgo")
-/* main.sgo:63 */ 		if len(errs) > 0 {
-/* main.sgo:64 */ 			reportErrs(errs...)
-/* main.sgo:65 */ 			os.Exit(1)
-/* main.sgo:66 */ 		}
-/* main.sgo:67 */ 		return
-/* main.sgo:68 */ 	}
-
-/* main.sgo:70 */ 	if len(extraArgs) == 0 {
-/* main.sgo:71 */ 		extraArgs = append(extraArgs, ".")
-/* main.sgo:72 */ 	}
-/* main.sgo:73 */ 	_, warnings, errs := sgo.TranslatePaths(extraArgs)
-/* main.sgo:74 */ 	reportErrs(warnings...)
-/* main.sgo:75 */ 	reportErrs(errs...)
-/* main.sgo:76 */ 	if len(errs) > 0 {
-/* main.sgo:77 */ 		os.Exit(1)
-/* main.sgo:78 */ 	}
-
-/* main.sgo:80 */ 	runGoCommand(os.Args[1], buildFlags, extraArgs...)
-/* main.sgo:81 */ }
-
-/* main.sgo:83 */ func reportErrs(errs ...error) {
-/* main.sgo:84 */ 	for _, err := range errs {
-/* main.sgo:85 */ 		if errs, ok := err.(scanner.ErrorList); ok {
-/* main.sgo:86 */ 			for _, err := range errs {
-/* main.sgo:87 */ 				fmt.Fprintln(os.Stderr, err)
-/* main.sgo:88 */ 			}
-/* main.sgo:89 */ 		} else {
-/* main.sgo:90 */ 			fmt.Fprintln(os.Stderr, err)
-/* main.sgo:91 */ 		}
-/* main.sgo:92 */ 	}
-/* main.sgo:93 */ }
-
-/* main.sgo:95 */ func runGoCommand(cmd string, buildFlags []string, extraArgs ...string) {
-/* main.sgo:96 */ 	c := exec.Command("go", append(append([]string{cmd}, buildFlags...), extraArgs...)...)
-/* main.sgo:97 */ 	c.Stdin = os.Stdin
-/* main.sgo:98 */ 	c.Stdout = os.Stdout
-/* main.sgo:99 */ 	c.Stderr = os.Stderr
-/* main.sgo:100 */ 	c.Run()
-/* main.sgo:101 */ }
-
-/* main.sgo:103 */ const helpMsg = `sgo is a tool for managing SGo source code.
+/* main.sgo:57 */ 			case "version":
+/* main.sgo:58 */ 				fmt.Print(versionHelpMsg)
+/* main.sgo:59 */ 				return
+/* main.sgo:60 */ 			case "upgrade-annotations":
+/* main.sgo:61 */ 				fmt.Print(upgradeAnnotationsHelpMsg)
+/* main.sgo:62 */ 				return
+/* main.sgo:63 */ 			case "selftest":
+/* main.sgo:64 */ 				fmt.Print(selftestHelpMsg)
+/* main.sgo:65 */ 				return
+/* main.sgo:66 */ 			case "annotation-coverage":
+/* main.sgo:67 */ 				fmt.Print(annotationCoverageHelpMsg)
+/* main.sgo:68 */ 				return
+/* main.sgo:69 */ 			case "repl":
+/* main.sgo:70 */ 				fmt.Print(replHelpMsg)
+/* main.sgo:71 */ 				return
+/* main.sgo:72 */ 			case "graph":
+/* main.sgo:73 */ 				fmt.Print(graphHelpMsg)
+/* main.sgo:74 */ 				return
+/* main.sgo:75 */ 			}
+/* main.sgo:76 */ 			runGoCommand("help", buildFlags, extraArgs...)
+/* main.sgo:77 */ 		}
+/* main.sgo:78 */ 		return
+/* main.sgo:79 */ 	case "translate":
+/* main.sgo:80 */ 		errs := sgo.TranslateFile(func() (io.Writer, error) { return os.Stdout, nil }, os.Stdin, "stdin.sgo")
+/* main.sgo:81 */ 		if len(errs) > 0 {
+/* main.sgo:82 */ 			reportErrs(errs...)
+/* main.sgo:83 */ 			os.Exit(1)
+/* main.sgo:84 */ 		}
+/* main.sgo:85 */ 		return
+/* main.sgo:86 */ 	case "upgrade-annotations":
+/* main.sgo:87 */ 		if len(extraArgs) != 2 {
+/* main.sgo:88 */ 			fmt.Fprint(os.Stderr, upgradeAnnotationsHelpMsg)
+/* main.sgo:89 */ 			os.Exit(2)
+/* main.sgo:90 */ 		}
+/* main.sgo:91 */ 		pkgs, err := importer.DiffDefaultAnnotations(extraArgs[0], extraArgs[1])
+/* main.sgo:92 */ 		if err != nil {
+/* main.sgo:93 */ 			reportErrs(err)
+/* main.sgo:94 */ 			os.Exit(1)
+/* main.sgo:95 */ 		}
+/* main.sgo:96 */ 		for _, pkg := range pkgs {
+/* main.sgo:97 */ 			for _, change := range pkg.Changes {
+/* main.sgo:98 */ 				fmt.Printf("%s: %v\n", pkg.Path, change)
+/* main.sgo:99 */ 			}
+/* main.sgo:100 */ 		}
+/* main.sgo:101 */ 		return
+/* main.sgo:102 */ 	case "selftest":
+/* main.sgo:103 */ 		errs := importer.CheckDefaultAnnotations()
+/* main.sgo:104 */ 		if len(errs) > 0 {
+/* main.sgo:105 */ 			reportErrs(errs...)
+/* main.sgo:106 */ 			os.Exit(1)
+/* main.sgo:107 */ 		}
+/* main.sgo:108 */ 		return
+/* main.sgo:109 */ 	case "annotation-coverage":
+/* main.sgo:110 */ 		dir := "."
+/* main.sgo:111 */ 		if len(extraArgs) > 0 {
+/* main.sgo:112 */ 			dir = extraArgs[0]
+/* main.sgo:113 */ 		}
+/* main.sgo:114 */ 		covs, errs := sgo.AnnotationCoverage(dir)
+/* main.sgo:115 */ 		if len(errs) > 0 {
+/* main.sgo:116 */ 			reportErrs(errs...)
+/* main.sgo:117 */ 			os.Exit(1)
+/* main.sgo:118 */ 		}
+/* main.sgo:119 */ 		var covered, total int
+/* main.sgo:120 */ 		for _, cov := range covs {
+/* main.sgo:121 */ 			fmt.Printf("%5.1f%%  %d/%d  %s\n", cov.Percent(), len(cov.Covered), len(cov.Covered)+len(cov.Uncovered), cov.Path)
+/* main.sgo:122 */ 			for _, name := range cov.Uncovered {
+/* main.sgo:123 */ 				fmt.Printf("\t%s\n", name)
+/* main.sgo:124 */ 			}
+/* main.sgo:125 */ 			covered += len(cov.Covered)
+/* main.sgo:126 */ 			total += len(cov.Covered) + len(cov.Uncovered)
+/* main.sgo:127 */ 		}
+/* main.sgo:128 */ 		if total > 0 {
+/* main.sgo:129 */ 			fmt.Printf("%5.1f%%  %d/%d  total\n", 100*float64(covered)/float64(total), covered, total)
+/* main.sgo:130 */ 		}
+/* main.sgo:131 */ 		return
+/* main.sgo:132 */ 	case "graph":
+/* main.sgo:133 */ 		funcName := ""
+/* main.sgo:134 */ 		if len(extraArgs) > 0 {
+/* main.sgo:135 */ 			funcName = extraArgs[0]
+/* main.sgo:136 */ 		}
+/* main.sgo:137 */ 		src, err := ioutil.ReadAll(os.Stdin)
+/* main.sgo:138 */ 		if err != nil {
+/* main.sgo:139 */ 			reportErrs(err)
+/* main.sgo:140 */ 			os.Exit(1)
+/* main.sgo:141 */ 		}
+/* main.sgo:142 */ 		graph, err := sgo.EntanglementGraph(string(src), funcName)
+/* main.sgo:143 */ 		if err != nil {
+/* main.sgo:144 */ 			reportErrs(err)
+/* main.sgo:145 */ 			os.Exit(1)
+/* main.sgo:146 */ 		}
+/* main.sgo:147 */ 		fmt.Print(graph)
+/* main.sgo:148 */ 		return
+/* main.sgo:149 */ 	case "repl":
+/* main.sgo:150 */ 		session := &sgo.Session{}
+/* main.sgo:151 */ 		in := bufio.NewScanner(os.Stdin)
+/* main.sgo:152 */ 		fmt.Print("> ")
+/* main.sgo:153 */ 		for in.Scan() {
+/* main.sgo:154 */ 			output, errs := session.Eval(in.Text())
+/* main.sgo:155 */ 			os.Stdout.Write(output)
+/* main.sgo:156 */ 			reportErrs(errs...)
+/* main.sgo:157 */ 			fmt.Print("> ")
+/* main.sgo:158 */ 		}
+/* main.sgo:159 */ 		fmt.Println()
+/* main.sgo:160 */ 		return
+/* main.sgo:161 */ 	}
+
+/* main.sgo:163 */ 	if len(extraArgs) == 0 {
+/* main.sgo:164 */ 		extraArgs = append(extraArgs, ".")
+/* main.sgo:165 */ 	}
+/* main.sgo:166 */ 	_, warnings, errs := sgo.TranslatePaths(extraArgs)
+/* main.sgo:167 */ 	reportErrs(warnings...)
+/* main.sgo:168 */ 	reportErrs(errs...)
+/* main.sgo:169 */ 	if len(errs) > 0 {
+/* main.sgo:170 */ 		os.Exit(1)
+/* main.sgo:171 */ 	}
+
+/* main.sgo:173 */ 	runGoCommand(os.Args[1], buildFlags, extraArgs...)
+/* main.sgo:174 */ }
+
+/* main.sgo:176 */ func reportErrs(errs ...error) {
+/* main.sgo:177 */ 	for _, err := range errs {
+/* main.sgo:178 */ 		if errs, ok := err.(scanner.ErrorList); ok {
+/* main.sgo:179 */ 			for _, err := range errs {
+/* main.sgo:180 */ 				fmt.Fprintln(os.Stderr, err)
+/* main.sgo:181 */ 			}
+/* main.sgo:182 */ 		} else {
+/* main.sgo:183 */ 			fmt.Fprintln(os.Stderr, err)
+/* main.sgo:184 */ 		}
+/* main.sgo:185 */ 	}
+/* main.sgo:186 */ }
+
+/* main.sgo:188 */ func runGoCommand(cmd string, buildFlags []string, extraArgs ...string) {
+/* main.sgo:189 */ 	c := exec.Command("go", append(append([]string{cmd}, buildFlags...), extraArgs...)...)
+/* main.sgo:190 */ 	c.Stdin = os.Stdin
+/* main.sgo:191 */ 	c.Stdout = os.Stdout
+/* main.sgo:192 */ 	c.Stderr = os.Stderr
+/* main.sgo:193 */ 	c.Run()
+/* main.sgo:194 */ }
+
+/* main.sgo:196 */ const helpMsg = `sgo is a tool for managing SGo source code.
 
 Usage:
 
@@ -117,15 +210,20 @@ To see a list of those commands:
 
 Additionally, SGo supports or overrides the following commands:
 	
-	translate   read SGo code, print the resulting Go code
-	version     print SGo version, and the Go version it works with
+	translate             read SGo code, print the resulting Go code
+	upgrade-annotations   report built-in annotations changed between Go versions
+	selftest              check that the built-in annotations are well-formed
+	annotation-coverage   report which used Go symbols lack annotations
+	repl                  read SGo lines, run them, and print their results
+	graph                 print a Graphviz graph of how nil checks flow through SGo code
+	version               print SGo version, and the Go version it works with
 
 Use "sgo help [command]" for more information about a command.
 
 Use "go help" to see a complete list of help topics.
 `
 
-/* main.sgo:126 */ const translateHelpMsg = `usage: sgo translate
+/* main.sgo:224 */ const translateHelpMsg = `usage: sgo translate
 
 Translate reads SGo code from the standard input, and prints the resulting Go
 code to the standard output.
@@ -134,9 +232,84 @@ If there are errors in the provided SGo code, they will be reported to the
 standard error and the command will exit with a non-zero exit code.
 `
 
-/* main.sgo:135 */ const versionHelpMsg = `usage: sgo version
+/* main.sgo:233 */ const versionHelpMsg = `usage: sgo version
 
 Version prints the SGo version. It also reports the Go version it is compatible
 with. "Compatible" means that SGo compiles to this Go version, and is able to
 import all the packages that this Go version is able to.
 `
+
+/* main.sgo:240 */ const upgradeAnnotationsHelpMsg = `usage: sgo upgrade-annotations oldgoroot newgoroot
+
+Upgrade-annotations compares the packages that SGo has built-in annotations for
+as found in two Go SDKs, rooted at oldgoroot and newgoroot. It prints, for each
+annotated identifier whose Go declaration changed from one to the other, its
+old and new Go types and its current annotation, which may need to be updated.
+
+This is meant to help maintain SGo when the Go version it is compatible with is
+upgraded.
+`
+
+/* main.sgo:251 */ const selftestHelpMsg = `usage: sgo selftest
+
+Selftest checks the annotations SGo has built in for the standard library. For
+each annotated identifier, it checks that its type is a valid SGo type, and
+that its definition is kept as is when the package's annotations are written
+back in .sgoann format and parsed again. It reports the package and identifier
+of each one that fails, and exits with a non-zero exit code if any does.
+
+This is meant to catch mistakes when editing the built-in annotations.
+`
+
+/* main.sgo:262 */ const annotationCoverageHelpMsg = `usage: sgo annotation-coverage [dir]
+
+Annotation-coverage reports which of the symbols that the SGo package in dir,
+or the current directory, uses from Go packages have SGo annotations, either
+built in or in a sgovendor directory. The SGo packages it imports, directly or
+not, are checked too.
+
+Unannotated symbols are converted to SGo conservatively: all their pointers,
+maps, interfaces, etc. are optional, so values from them must be checked for
+nil. Symbols whose types have nothing that can be nil aren't counted.
+
+For each Go package, sorted by import path, it prints the percentage and number
+of its used symbols that are annotated, followed by a line for each that isn't.
+A last line shows the total.
+`
+
+/* main.sgo:278 */ const replHelpMsg = `usage: sgo repl
+
+Repl reads SGo code from the standard input one line at a time, and runs it as
+if each line were appended to the body of a main function. The values of lines
+that are expressions are printed. Lines can also be imports, and type and named
+function declarations, which are added at the package level.
+
+Each line is checked along with the previous ones, so what is known about a
+variable, like whether it can be nil, carries on to the next lines. Lines that
+have errors, or whose code fails when run, are reported and then discarded.
+
+The whole session is translated and run again with "go run" for each line, and
+only the output that the last line added is printed. So only lines whose output
+doesn't change from one run to the next are printed correctly.
+
+This is meant for experimenting with SGo.
+`
+
+/* main.sgo:296 */ const graphHelpMsg = `usage: sgo graph [func]
+
+Graph reads SGo code from the standard input, and prints to the standard output
+a graph, in Graphviz's DOT language, of how values that can be nil, and the
+checks that make them usable, flow through each of its functions, or only
+through the function or method named func.
+
+Variables that can be nil are dashed ellipses, and the others are solid ones.
+Calls with entangled results are boxes, with edges to the variables they assign.
+If conditions are diamonds, with edges from the variables they check and to the
+variables they unwrap or collapse.
+
+To render it as an image, pipe it to Graphviz's dot command:
+
+	sgo graph < file.sgo | dot -Tpng -o graph.png
+
+This is meant for learning and debugging how SGo checks for nil.
+`
@@ -13,9 +13,18 @@ type Annotation struct {
 	cursor string
 	typ    string
 	anns   map[string]string
+	// pos has the positions of the definitions' names in the source they
+	// were parsed from, if any.
+	pos map[string]Token
+	// raw has the definitions' Types as written in that source, if any.
+	raw map[string]string
+	// docs has the comments documenting the definitions in that source, if
+	// any.
+	docs map[string]string
 }
 
-// NewAnnotation returns an Annotation for a map from
+// NewAnnotation returns an Annotation for a map from identifiers' full names,
+// like "(*File).Read", to their definitions. For a nil map it returns nil.
 func NewAnnotation(anns map[string]string) *Annotation {
 	if anns == nil {
 		return nil
@@ -23,19 +32,147 @@ func NewAnnotation(anns map[string]string) *Annotation {
 	return &Annotation{anns: anns}
 }
 
+// Merge returns a package's Annotation with the definitions in all the given
+// ones, as if they were parsed from the concatenation of their sources.
+func Merge(anns ...*Annotation) *Annotation {
+	merged := map[string]string{}
+	pos := map[string]Token{}
+	raw := map[string]string{}
+	docs := map[string]string{}
+	for _, a := range anns {
+		if a != nil {
+			addDefs(merged, a.anns)
+			for name, tk := range a.pos {
+				if _, ok := pos[name]; !ok {
+					pos[name] = tk
+				}
+			}
+			addDefs(raw, a.raw)
+			for name, doc := range a.docs {
+				if _, ok := docs[name]; !ok {
+					docs[name] = doc
+				}
+			}
+		}
+	}
+	ann := NewAnnotation(merged)
+	ann.pos = pos
+	ann.raw = raw
+	ann.docs = docs
+	return ann
+}
+
+// MergeAnnotations returns a package's Annotation with the definitions in
+// override, and those in base for identifiers that override doesn't annotate.
+func MergeAnnotations(base, override *Annotation) *Annotation {
+	anns := map[string]string{}
+	pos := map[string]Token{}
+	raw := map[string]string{}
+	docs := map[string]string{}
+	for _, a := range []*Annotation{base, override} {
+		if a == nil {
+			continue
+		}
+		for name, def := range a.anns {
+			anns[name] = def
+			if tk, ok := a.pos[name]; ok {
+				pos[name] = tk
+			} else {
+				delete(pos, name)
+			}
+			if r, ok := a.raw[name]; ok {
+				raw[name] = r
+			} else {
+				delete(raw, name)
+			}
+			if doc, ok := a.docs[name]; ok {
+				docs[name] = doc
+			} else {
+				delete(docs, name)
+			}
+		}
+	}
+	ann := NewAnnotation(anns)
+	ann.pos = pos
+	ann.raw = raw
+	ann.docs = docs
+	return ann
+}
+
+// Filter returns a package's Annotation with only the definitions in a for
+// which pred returns true, given each identifier's full name and definition.
+func (a *Annotation) Filter(pred func(name, def string) bool) *Annotation {
+	if a == nil {
+		return nil
+	}
+	anns := map[string]string{}
+	for name, def := range a.anns {
+		if pred(name, def) {
+			anns[name] = def
+		}
+	}
+	return &Annotation{cursor: a.cursor, typ: a.typ, anns: anns, pos: a.pos, raw: a.raw, docs: a.docs}
+}
+
 // Cursor returns the cursor, or path, from the package's Annotation to the
 // receiver Annotation, separated by '.'.
 func (a *Annotation) Cursor() string {
+	if a == nil {
+		return ""
+	}
 	return a.cursor
 }
 
 // Type returns the SGo type annotation for package or identifier referred to by
-// Cursor, if it exists.
+// Cursor, if it exists, without any directives.
 func (a *Annotation) Type() (string, bool) {
-	if a == nil || a.typ == "" {
+	if a == nil {
 		return "", false
 	}
-	return a.typ, true
+	typ, _ := splitDirectives(alternatives(a.typ)[0])
+	if typ == "" {
+		return "", false
+	}
+	return typ, true
+}
+
+// Directives returns the directives that follow the type annotation for the
+// identifier referred to by Cursor.
+func (a *Annotation) Directives() []Directive {
+	if a == nil {
+		return nil
+	}
+	_, dirs := splitDirectives(alternatives(a.typ)[0])
+	return dirs
+}
+
+// A Directive is an extra fact about an annotated identifier that can't be
+// expressed by its type alone.
+type Directive struct {
+	Name string
+	Args []string
+}
+
+// String implements fmt.Stringer for Directive.
+func (d Directive) String() string {
+	return strings.Join(append([]string{"@" + d.Name}, d.Args...), " ")
+}
+
+func splitDirectives(def string) (string, []Directive) {
+	i := strings.IndexByte(def, '@')
+	if i == -1 {
+		return def, nil
+	}
+	typ := strings.TrimSpace(def[:i])
+	var dirs []Directive
+	for _, s := range strings.Split(def[i+1:], "@") {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			continue
+		}
+		dirs = append(dirs, Directive{Name: fields[0], Args: fields[1:]})
+	}
+	return typ, dirs
 }
 
 // String implements fmt.Stringer for Annotation.
@@ -44,16 +181,19 @@ func (a *Annotation) String() string {
 		return "type: " + typ
 	}
 	var ks []string
-	for k := range a.anns {
-		ks = append(ks, k)
+	if a != nil {
+		for k := range a.anns {
+			ks = append(ks, k)
+		}
 	}
-	return a.cursor + " -> [" + strings.Join(ks, ", ") + "]"
+	return a.Cursor() + " -> [" + strings.Join(ks, ", ") + "]"
 }
 
 // Lookup finds a child Annotation of the receiver with the given identifier.
+// It's never nil, even if the identifier or the receiver aren't annotated.
 func (a *Annotation) Lookup(name string) *Annotation {
-	if a == nil || a.anns == nil {
-		return nil
+	if a == nil {
+		return &Annotation{cursor: name}
 	}
 	cursor := name
 	if a.cursor != "" {
@@ -61,7 +201,126 @@ func (a *Annotation) Lookup(name string) *Annotation {
 	}
 	v, ok := a.anns[cursor]
 	if ok {
-		return &Annotation{typ: v}
+		return &Annotation{cursor: cursor, typ: v, anns: a.anns, pos: a.pos, raw: a.raw, docs: a.docs}
+	}
+	return &Annotation{cursor: cursor, anns: a.anns, pos: a.pos, raw: a.raw, docs: a.docs}
+}
+
+// Sub returns an Annotation with only the members of the child identifier with
+// the given name, named relative to it, as if it were a package's Annotation.
+func (a *Annotation) Sub(name string) *Annotation {
+	if a == nil {
+		return nil
+	}
+	if a.cursor != "" {
+		name = a.cursor + "." + name
+	}
+	prefix := name + "."
+	var sub *Annotation
+	for k, def := range a.anns {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if sub == nil {
+			sub = NewAnnotation(map[string]string{})
+		}
+		rel := k[len(prefix):]
+		sub.anns[rel] = def
+		end := -1
+		for _, part := range splitName(rel) {
+			end += 1 + len(part)
+			pickMember(sub, a, prefix, rel[:end])
+		}
+	}
+	return sub
+}
+
+// pickMember copies the position, raw Type and doc of the member of a with the
+// given name, relative to prefix, to sub, if it has them.
+func pickMember(sub, a *Annotation, prefix, name string) {
+	if tk, ok := a.pos[prefix+name]; ok {
+		if sub.pos == nil {
+			sub.pos = map[string]Token{}
+		}
+		sub.pos[name] = tk
+	}
+	if raw, ok := a.raw[prefix+name]; ok {
+		if sub.raw == nil {
+			sub.raw = map[string]string{}
+		}
+		sub.raw[name] = raw
+	}
+	if doc, ok := a.docs[prefix+name]; ok {
+		if sub.docs == nil {
+			sub.docs = map[string]string{}
+		}
+		sub.docs[name] = doc
+	}
+}
+
+// Method returns the name the method of the type named recv is annotated
+// under: "(*File).Read", "(File).Read" or "File.Read", tried in that order.
+func (a *Annotation) Method(recv, method string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	for _, name := range []string{"(*" + recv + ")." + method, "(" + recv + ")." + method, recv + "." + method} {
+		full := name
+		if a.cursor != "" {
+			full = a.cursor + "." + name
+		}
+		if _, ok := a.anns[full]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Pos returns the position of the Name of the child identifier with the given
+// name in the source the Annotation was parsed from.
+func (a *Annotation) Pos(name string) (Token, bool) {
+	if a == nil {
+		return Token{}, false
+	}
+	if a.cursor != "" {
+		name = a.cursor + "." + name
+	}
+	tk, ok := a.pos[name]
+	return tk, ok
+}
+
+// RawType returns the Type of the child identifier with the given name exactly
+// as written in the source the Annotation was parsed from.
+func (a *Annotation) RawType(name string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	if a.cursor != "" {
+		name = a.cursor + "." + name
+	}
+	raw, ok := a.raw[name]
+	return raw, ok
+}
+
+// Doc returns the comments documenting the child identifier with the given
+// name in the source the Annotation was parsed from, without their markers.
+func (a *Annotation) Doc(name string) string {
+	if a == nil {
+		return ""
+	}
+	if a.cursor != "" {
+		name = a.cursor + "." + name
+	}
+	return a.docs[name]
+}
+
+// Default returns the policy, "?" or "!", set with a default item for the
+// unannotated members of the type the Annotation refers to.
+func (a *Annotation) Default() (string, bool) {
+	for _, dir := range a.Directives() {
+		if dir.Name == "default" && len(dir.Args) == 1 {
+			return dir.Args[0], true
+		}
 	}
-	return &Annotation{cursor: cursor, anns: a.anns}
+	return "", false
 }
@@ -0,0 +1,125 @@
+package annotations
+
+import (
+	"go/build"
+	"go/build/constraint"
+	"strings"
+)
+
+// Alternative definitions for an identifier, each marked with a @build
+// directive, are stored one per line in the same definition.
+
+// ForContext returns a copy of the package's Annotation in which each
+// identifier keeps only the alternative definition ctx chooses.
+func (a *Annotation) ForContext(ctx *build.Context) *Annotation {
+	if a == nil {
+		return nil
+	}
+	anns := make(map[string]string, len(a.anns))
+	for name, def := range a.anns {
+		if def, ok := chooseAlternative(ctx, def); ok {
+			anns[name] = def
+		}
+	}
+	var raw map[string]string
+	if a.raw != nil {
+		raw = make(map[string]string, len(a.raw))
+		for name, def := range a.raw {
+			if def, ok := chooseAlternative(ctx, def); ok {
+				raw[name] = def
+			}
+		}
+	}
+	return &Annotation{cursor: a.cursor, typ: a.typ, anns: anns, pos: a.pos, raw: raw, docs: a.docs}
+}
+
+func chooseAlternative(ctx *build.Context, def string) (string, bool) {
+	var fallback string
+	found := false
+	for _, alt := range alternatives(def) {
+		constraint, ok := buildConstraint(alt)
+		if !ok {
+			if !found {
+				fallback, found = alt, true
+			}
+			continue
+		}
+		if matchConstraint(ctx, constraint) {
+			return alt, true
+		}
+	}
+	return fallback, found
+}
+
+func alternatives(def string) []string {
+	return strings.Split(def, "\n")
+}
+
+func hasBuildConstraint(def string) bool {
+	for _, alt := range alternatives(def) {
+		if _, ok := buildConstraint(alt); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func buildConstraint(def string) ([]string, bool) {
+	_, dirs := splitDirectives(def)
+	for _, dir := range dirs {
+		if dir.Name == "build" {
+			return dir.Args, true
+		}
+	}
+	return nil, false
+}
+
+// matchConstraint reports whether ctx satisfies a constraint in the "+build"
+// syntax, evaluating its tags as go/build does. A malformed one never is.
+func matchConstraint(ctx *build.Context, options []string) bool {
+	expr, err := constraint.Parse("// +build " + strings.Join(options, " "))
+	if err != nil {
+		return false
+	}
+	return expr.Eval(func(tag string) bool { return matchTag(ctx, tag) })
+}
+
+// unixOS is the set of GOOS values matched by the "unix" tag.
+var unixOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"linux":     true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"solaris":   true,
+}
+
+func matchTag(ctx *build.Context, tag string) bool {
+	if ctx.CgoEnabled && tag == "cgo" {
+		return true
+	}
+	if tag == ctx.GOOS || tag == ctx.GOARCH || tag == ctx.Compiler {
+		return true
+	}
+	switch {
+	case ctx.GOOS == "android" && tag == "linux",
+		ctx.GOOS == "illumos" && tag == "solaris",
+		ctx.GOOS == "ios" && tag == "darwin",
+		tag == "unix" && unixOS[ctx.GOOS]:
+		return true
+	}
+	for _, tags := range [][]string{ctx.BuildTags, ctx.ToolTags, ctx.ReleaseTags} {
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
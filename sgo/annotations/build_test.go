@@ -0,0 +1,100 @@
+package annotations
+
+import (
+	"go/build"
+	"testing"
+)
+
+func TestForContext(t *testing.T) {
+	ann, err := ParseWith(ParseOptions{AllowDuplicates: true}, `
+Open func(name string) (*File \ error) @build linux darwin
+Open func(name string) (?*File \ error) @build windows,!cgo
+Open func(name string) ?*File
+Close func() \ error @build linux
+Stdin *File
+Stdin ?*File
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		goos   string
+		cgo    bool
+		open   string
+		close  string
+		closes bool
+	}{
+		{"linux", false, `func(name string) (*File \ error)`, `func() \ error`, true},
+		{"darwin", true, `func(name string) (*File \ error)`, "", false},
+		{"windows", false, `func(name string) (?*File \ error)`, "", false},
+		{"windows", true, `func(name string) ?*File`, "", false},
+	}
+	for _, c := range cases {
+		ctx := build.Default
+		ctx.GOOS = c.goos
+		ctx.CgoEnabled = c.cgo
+		ctxAnn := ann.ForContext(&ctx)
+
+		if open, _ := ctxAnn.Lookup("Open").Type(); open != c.open {
+			t.Errorf("%s, cgo %v: expected Open %q, got %q", c.goos, c.cgo, c.open, open)
+		}
+		close, ok := ctxAnn.Lookup("Close").Type()
+		if ok != c.closes || close != c.close {
+			t.Errorf("%s, cgo %v: expected Close %q, got %q", c.goos, c.cgo, c.close, close)
+		}
+		// Not gated, so the last one wins, as allowed.
+		if stdin, _ := ctxAnn.Lookup("Stdin").Type(); stdin != "?*File" {
+			t.Errorf("%s, cgo %v: expected Stdin %q, got %q", c.goos, c.cgo, "?*File", stdin)
+		}
+	}
+
+	if open, _ := ann.Lookup("Open").Type(); open != `func(name string) (*File \ error)` {
+		t.Errorf("expected the first alternative without a context, got %q", open)
+	}
+}
+
+func TestForContextTags(t *testing.T) {
+	ann, err := ParseWith(ParseOptions{AllowDuplicates: true}, `
+Getpid func() int @build unix
+Getpid func() (int \ error) @build windows
+Fast func() @build goexperiment.fast
+Newer func() @build go1.99,!plan9
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		goos, getpid string
+	}{
+		{"linux", "func() int"},
+		{"darwin", "func() int"},
+		{"windows", `func() (int \ error)`},
+		{"plan9", ""},
+	}
+	for _, c := range cases {
+		ctx := build.Default
+		ctx.GOOS = c.goos
+		if getpid, _ := ann.ForContext(&ctx).Lookup("Getpid").Type(); getpid != c.getpid {
+			t.Errorf("%s: expected Getpid %q, got %q", c.goos, c.getpid, getpid)
+		}
+	}
+
+	ctx := build.Default
+	ctx.GOOS = "linux"
+	ctx.ToolTags = []string{"goexperiment.fast"}
+	ctx.ReleaseTags = []string{"go1.98", "go1.99"}
+	ctxAnn := ann.ForContext(&ctx)
+	if _, ok := ctxAnn.Lookup("Fast").Type(); !ok {
+		t.Errorf("expected Fast with a tool tag")
+	}
+	if _, ok := ctxAnn.Lookup("Newer").Type(); !ok {
+		t.Errorf("expected Newer with a release tag")
+	}
+
+	ctx.ToolTags = nil
+	if _, ok := ann.ForContext(&ctx).Lookup("Fast").Type(); ok {
+		t.Errorf("expected no Fast without the tool tag")
+	}
+}
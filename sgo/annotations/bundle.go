@@ -0,0 +1,192 @@
+package annotations
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MaxBundleSize is the largest archive ParseBundle reads, and the largest
+// file in it once uncompressed, in bytes.
+const MaxBundleSize = 16 << 20
+
+// ParseBundle reads a zip or tar (optionally gzipped) archive of .sgoann files
+// and returns the parsed Annotation for each package in it, by directory.
+func ParseBundle(r io.Reader) (map[string]*Annotation, error) {
+	data, err := readBundleData(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations bundle: %v", err)
+	}
+
+	var srcs map[string][]bundleFile
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		srcs, err = readZipBundle(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("reading annotations bundle: %v", err)
+		}
+		srcs, err = readTarBundle(gz)
+	default:
+		srcs, err = readTarBundle(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations bundle: %v", err)
+	}
+	return parseBundleFiles(srcs)
+}
+
+// ParseFS is like ParseBundle, but reads the .sgoann files from a file system,
+// like an embed.FS, instead of an archive.
+func ParseFS(fsys fs.FS) (map[string]*Annotation, error) {
+	srcs, err := readFSBundle(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations: %v", err)
+	}
+	return parseBundleFiles(srcs)
+}
+
+func parseBundleFiles(srcs map[string][]bundleFile) (map[string]*Annotation, error) {
+	anns := map[string]*Annotation{}
+	for pkgPath, files := range srcs {
+		sort.Sort(bundleFiles(files))
+		var parsed []*Annotation
+		for _, f := range files {
+			ann, err := Parse(f.src)
+			if err != nil {
+				return nil, fmt.Errorf("parsing annotations for %s: %s: %v", pkgPath, f.name, err)
+			}
+			parsed = append(parsed, ann)
+		}
+		anns[pkgPath] = Merge(parsed...)
+	}
+	return anns, nil
+}
+
+type bundleFile struct {
+	name string
+	src  string
+}
+
+type bundleFiles []bundleFile
+
+func (fs bundleFiles) Len() int           { return len(fs) }
+func (fs bundleFiles) Less(i, j int) bool { return fs[i].name < fs[j].name }
+func (fs bundleFiles) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
+
+func readZipBundle(data []byte) (map[string][]bundleFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	srcs := map[string][]bundleFile{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		pkgPath, ok, err := bundlePkgPath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		src, err := readBundleData(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		srcs[pkgPath] = append(srcs[pkgPath], bundleFile{f.Name, string(src)})
+	}
+	return srcs, nil
+}
+
+func readTarBundle(r io.Reader) (map[string][]bundleFile, error) {
+	tr := tar.NewReader(r)
+	srcs := map[string][]bundleFile{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		pkgPath, ok, err := bundlePkgPath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		src, err := readBundleData(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", hdr.Name, err)
+		}
+		srcs[pkgPath] = append(srcs[pkgPath], bundleFile{hdr.Name, string(src)})
+	}
+	return srcs, nil
+}
+
+func readFSBundle(fsys fs.FS) (map[string][]bundleFile, error) {
+	srcs := map[string][]bundleFile{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		pkgPath, ok, err := bundlePkgPath(name)
+		if err != nil || !ok {
+			return err
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		srcs[pkgPath] = append(srcs[pkgPath], bundleFile{name, string(src)})
+		return nil
+	})
+	return srcs, err
+}
+
+// readBundleData reads all of r, failing if it's larger than MaxBundleSize.
+func readBundleData(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxBundleSize {
+		return nil, fmt.Errorf("larger than %d bytes", MaxBundleSize)
+	}
+	return data, nil
+}
+
+// bundlePkgPath returns the package path a file in a bundle holds annotations
+// for, and whether the file is a .sgoann file at all.
+func bundlePkgPath(name string) (string, bool, error) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if path.Ext(name) != ".sgoann" {
+		return "", false, nil
+	}
+	pkgPath := path.Dir(name)
+	if pkgPath == "." || pkgPath == "/" || strings.HasPrefix(pkgPath, "../") || strings.HasPrefix(pkgPath, "/") {
+		return "", false, fmt.Errorf("%s: can't determine package path for file", name)
+	}
+	return pkgPath, true, nil
+}
@@ -0,0 +1,172 @@
+package annotations
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var bundleTestFiles = []struct {
+	name, src string
+}{
+	{"os/os.sgoann", "Create func(name string) (*File \\ error)\n"},
+	{"net/http/client.sgoann", "(*Client) {\n\tDo (*Client) func(req *Request) (*Response \\ error)\n}\n"},
+	{"net/http/server.sgoann", "HandleFunc func(pattern string, handler func(ResponseWriter, *Request))\n"},
+	{"README", "not an annotation"},
+}
+
+var bundleTestExpected = map[string]map[string]string{
+	"os": {
+		"Create": `func(name string) (*File \ error)`,
+	},
+	"net/http": {
+		"(*Client).Do": `(*Client) func(req *Request) (*Response \ error)`,
+		"HandleFunc":   `func(pattern string, handler func(ResponseWriter, *Request))`,
+	},
+}
+
+func TestParseBundleZip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range bundleTestFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.src))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	anns, err := ParseBundle(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testBundleAnns(t, anns)
+}
+
+func TestParseBundleTar(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range bundleTestFiles {
+		err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.src)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.src))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	anns, err := ParseBundle(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testBundleAnns(t, anns)
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, f := range bundleTestFiles {
+		fsys[f.name] = &fstest.MapFile{Data: []byte(f.src)}
+	}
+
+	anns, err := ParseFS(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testBundleAnns(t, anns)
+}
+
+func TestParseBundleMalformed(t *testing.T) {
+	cases := []string{
+		"PK\x03\x04 definitely not a zip file",
+		"\x1f\x8b not gzipped either",
+		strings.Repeat("x", 1024),
+	}
+	for i, c := range cases {
+		_, err := ParseBundle(strings.NewReader(c))
+		if err == nil {
+			t.Errorf("case %d: expected error, got nil", i)
+		}
+	}
+}
+
+func TestParseBundleFilePositions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"os/a.sgoann": &fstest.MapFile{Data: []byte("Create func(name string) (*File \\ error)\n")},
+		"os/b.sgoann": &fstest.MapFile{Data: []byte("\nOpen func(name string) (*File \\ error)\n")},
+	}
+	anns, err := ParseFS(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Positions are relative to the file each identifier is in.
+	for name, line := range map[string]int{"Create": 1, "Open": 2} {
+		if tk, ok := anns["os"].Pos(name); !ok || tk.Line != line {
+			t.Errorf("%s: expected line %d, got %+v, %v", name, line, tk, ok)
+		}
+	}
+
+	fsys["os/b.sgoann"] = &fstest.MapFile{Data: []byte("Open func(name string) (*File \\ error)\n?? int\n")}
+	_, err = ParseFS(fsys)
+	if expected := "parsing annotations for os: os/b.sgoann: unexpected token at 2:1: '?'"; err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+func testBundleAnns(t *testing.T, anns map[string]*Annotation) {
+	if len(anns) != len(bundleTestExpected) {
+		t.Errorf("expected %d packages, got %d: %v", len(bundleTestExpected), len(anns), anns)
+	}
+	for pkgPath, expected := range bundleTestExpected {
+		ann, ok := anns[pkgPath]
+		if !ok {
+			t.Errorf("%s: missing package", pkgPath)
+			continue
+		}
+		if !mapEqual(expected, ann.anns) {
+			t.Errorf("%s: expected %v, got %v", pkgPath, expected, ann.anns)
+		}
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestParseBundleTooLarge(t *testing.T) {
+	_, err := ParseBundle(io.LimitReader(zeroReader{}, MaxBundleSize+1))
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("archive: expected a size error, got %v", err)
+	}
+
+	// A small zip archive with a huge file in it.
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("os/os.sgoann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, io.LimitReader(zeroReader{}, MaxBundleSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseBundle(buf)
+	if err == nil || !strings.Contains(err.Error(), "os/os.sgoann: larger than") {
+		t.Errorf("file: expected a size error, got %v", err)
+	}
+}
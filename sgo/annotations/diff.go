@@ -0,0 +1,132 @@
+package annotations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Change tells that the Go declaration of an annotated identifier is not the
+// one the annotation was written for anymore, so the annotation may need to be
+// updated.
+type Change struct {
+	// Name is the annotated identifier, as it appears in the annotations; for
+	// example, "(*File).Read".
+	Name string
+	// Annotation is the annotation's definition for Name.
+	Annotation string
+	// Old and New are the Go types of the identifier before and after the
+	// change. An empty type means the identifier isn't declared.
+	Old, New string
+}
+
+// Removed reports whether the annotated identifier isn't declared anymore.
+func (c Change) Removed() bool {
+	return c.New == ""
+}
+
+// String implements fmt.Stringer for Change.
+func (c Change) String() string {
+	if c.Removed() {
+		return fmt.Sprintf("%s: removed\n\told: %s\n\tannotation: %s", c.Name, c.Old, c.Annotation)
+	}
+	return fmt.Sprintf("%s: changed\n\told: %s\n\tnew: %s\n\tannotation: %s", c.Name, c.Old, c.New, c.Annotation)
+}
+
+// Diff returns a Change, sorted by name, for each identifier annotated in ann
+// whose Go type differs between the declarations in old and new.
+func Diff(ann *Annotation, old, new map[string]string) []Change {
+	if ann == nil {
+		return nil
+	}
+	var changes []Change
+	for name, def := range ann.anns {
+		if old[name] == new[name] {
+			continue
+		}
+		changes = append(changes, Change{
+			Name:       name,
+			Annotation: def,
+			Old:        old[name],
+			New:        new[name],
+		})
+	}
+	sort.Sort(changesByName(changes))
+	return changes
+}
+
+// Equal reports whether the packages' Annotations a and b annotate the same
+// identifiers with the same definitions. Where they were parsed from, as Pos,
+// RawType and Doc tell, isn't compared.
+func (a *Annotation) Equal(b *Annotation) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for _, name := range a.Names() {
+		def, _ := a.Definition(name)
+		if bDef, ok := b.Definition(name); !ok || bDef != def {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns a "-", "+" or "~" line for each identifier that the packages'
+// Annotations a and b annotate differently, sorted by name, for test failures.
+func (a *Annotation) Diff(b *Annotation) []string {
+	names := a.Names()
+	for _, name := range b.Names() {
+		if _, ok := a.Definition(name); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		def, inA := a.Definition(name)
+		bDef, inB := b.Definition(name)
+		switch {
+		case !inB:
+			lines = append(lines, "- "+name+": "+diffDef(def))
+		case !inA:
+			lines = append(lines, "+ "+name+": "+diffDef(bDef))
+		case def != bDef:
+			lines = append(lines, "~ "+name+": "+diffDef(def)+" => "+diffDef(bDef))
+		}
+	}
+	return lines
+}
+
+func diffDef(def string) string {
+	return strings.Join(alternatives(def), "; ")
+}
+
+// Names returns the identifiers annotated in the package's Annotation, sorted,
+// by their full names, like "Request.URL" or "(*File).Read".
+func (a *Annotation) Names() []string {
+	if a == nil {
+		return nil
+	}
+	var names []string
+	for name := range a.anns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of identifiers annotated in the package's Annotation,
+// as in len(a.Names()).
+func (a *Annotation) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.anns)
+}
+
+type changesByName []Change
+
+func (cs changesByName) Len() int           { return len(cs) }
+func (cs changesByName) Less(i, j int) bool { return cs[i].Name < cs[j].Name }
+func (cs changesByName) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
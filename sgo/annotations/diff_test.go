@@ -0,0 +1,134 @@
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	ann := NewAnnotation(map[string]string{
+		"Open":         `func(name string) (*File \ error)`,
+		"Create":       `func(name string) (*File \ error)`,
+		"(*File).Read": `(*File) func(b []byte) (n int, err ?error)`,
+		"Stdin":        `*File`,
+	})
+	old := map[string]string{
+		"Open":         `func(name string) (*File, error)`,
+		"Create":       `func(name string) (*File, error)`,
+		"(*File).Read": `func(b []byte) (n int, err error)`,
+		"Stdin":        `*File`,
+		"NotAnnotated": `int`,
+	}
+	new := map[string]string{
+		"Open":         `func(name string) (*File, error)`,
+		"(*File).Read": `func(b []byte, flags int) (n int, err error)`,
+		"Stdin":        `*File`,
+		"NotAnnotated": `string`,
+	}
+
+	expected := []Change{
+		{
+			Name:       "(*File).Read",
+			Annotation: `(*File) func(b []byte) (n int, err ?error)`,
+			Old:        `func(b []byte) (n int, err error)`,
+			New:        `func(b []byte, flags int) (n int, err error)`,
+		},
+		{
+			Name:       "Create",
+			Annotation: `func(name string) (*File \ error)`,
+			Old:        `func(name string) (*File, error)`,
+		},
+	}
+	changes := Diff(ann, old, new)
+	if !reflect.DeepEqual(expected, changes) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	if changes[0].Removed() || !changes[1].Removed() {
+		t.Errorf("wrong Removed for %v", changes)
+	}
+}
+
+func TestAnnotationDiff(t *testing.T) {
+	a, err := Parse(`Open func(name string) (*File \ error)
+Create func(name string) (*File \ error)
+(*File) {
+	Read func(b []byte) (int, error)
+	Close func() error
+}
+Pipe func() (r, w *File, err error) @build unix
+Empty {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse(`// Positions and docs don't count.
+
+Open func(name string) (*File \ error)
+(*File) {
+	Close func() error
+	Read func(b []byte) (int, ?error)
+}
+Pipe func() (r, w *File, err error) @build unix
+Pipe func() (r, w *File, err error) @build windows
+OpenFile func(name string, flag int, perm FileMode) (*File \ error)
+Empty {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.Equal(a) || !a.Equal(MergeAnnotations(nil, a)) {
+		t.Error("expected an Annotation to equal itself and its copy")
+	}
+	if a.Equal(b) || b.Equal(a) {
+		t.Error("expected different Annotations not to be equal")
+	}
+	if lines := a.Diff(a); lines != nil {
+		t.Errorf("expected no lines for equal Annotations, got %q", lines)
+	}
+
+	expected := []string{
+		`~ (*File).Read: func(b []byte) (int, error) => func(b []byte) (int, ?error)`,
+		`- Create: func(name string) (*File \ error)`,
+		`+ OpenFile: func(name string, flag int, perm FileMode) (*File \ error)`,
+		`~ Pipe: func() (r, w *File, err error) @build unix => func() (r, w *File, err error) @build unix; func() (r, w *File, err error) @build windows`,
+	}
+	if lines := a.Diff(b); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+
+	var none *Annotation
+	if !none.Equal(NewAnnotation(map[string]string{})) {
+		t.Error("expected a nil Annotation to equal an empty one")
+	}
+	if lines := none.Diff(NewAnnotation(map[string]string{"Empty": ""})); !reflect.DeepEqual(lines, []string{"+ Empty: "}) {
+		t.Errorf("expected an added empty definition, got %q", lines)
+	}
+}
+
+func TestNames(t *testing.T) {
+	ann, err := Parse(`Open func(name string) (*File \ error)
+(*File) {
+	Read (*File) func(b []byte) (n int, err ?error)
+	Close (*File) func() error
+}
+Request {
+	URL *url.URL
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"(*File).Close", "(*File).Read", "Open", "Request.URL"}
+	if names := ann.Names(); !reflect.DeepEqual(expected, names) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	if n := ann.Len(); n != len(expected) {
+		t.Errorf("expected length %d, got %d", len(expected), n)
+	}
+
+	var nilAnn *Annotation
+	if names, n := nilAnn.Names(), nilAnn.Len(); names != nil || n != 0 {
+		t.Errorf("nil: expected no names, got %v and length %d", names, n)
+	}
+}
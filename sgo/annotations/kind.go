@@ -0,0 +1,83 @@
+package annotations
+
+import "strings"
+
+// A Kind is the kind of declaration an annotated identifier is, as told by how
+// it's annotated.
+type Kind int
+
+// Kinds of annotated identifiers.
+const (
+	// NoKind is for identifiers that aren't annotated.
+	NoKind Kind = iota
+	Func
+	Var
+	Const
+	Method
+	Type
+	Field
+)
+
+var kindNames = [...]string{
+	NoKind: "none",
+	Func:   "func",
+	Var:    "var",
+	Const:  "const",
+	Method: "method",
+	Type:   "type",
+	Field:  "field",
+}
+
+// String implements fmt.Stringer for Kind.
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// Kind returns the kind of declaration of the child identifier with the given
+// name, going by how it's annotated.
+func (a *Annotation) Kind(name string) Kind {
+	if a == nil {
+		return NoKind
+	}
+	if a.cursor != "" {
+		name = a.cursor + "." + name
+	}
+	def, annotated := a.anns[name]
+	hasMembers := false
+	for k := range a.anns {
+		if strings.HasPrefix(k, name+".") {
+			hasMembers = true
+			break
+		}
+	}
+	if !annotated && !hasMembers {
+		return NoKind
+	}
+
+	ann := &Annotation{cursor: name, typ: def}
+	for _, dir := range ann.Directives() {
+		if dir.Name == "const" {
+			return Const
+		}
+	}
+	typ, _ := ann.Type()
+
+	parts := splitName(name)
+	if len(parts) == 1 {
+		_, hasDefault := ann.Default()
+		switch {
+		case strings.HasPrefix(name, "(") || hasMembers || hasDefault || def == "":
+			return Type
+		case strings.HasPrefix(typ, "func"):
+			return Func
+		}
+		return Var
+	}
+	if strings.HasPrefix(parts[len(parts)-2], "(") || strings.HasPrefix(typ, "(") || strings.HasPrefix(typ, "func") {
+		return Method
+	}
+	return Field
+}
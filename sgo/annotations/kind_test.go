@@ -0,0 +1,119 @@
+package annotations
+
+import "testing"
+
+func TestKind(t *testing.T) {
+	ann, err := Parse(`ModePerm FileMode @const
+ErrNotExist ?error
+Open func(name string) (*File \ error)
+(*File) {
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+(*Dir) {
+	Close (*Dir) func() error
+}
+(Reader) {
+	Read (Reader) func(p []byte) (n int, err ?error)
+}
+Request {
+	URL *url.URL
+	Write (Request) func(w io.Writer) error
+}
+FileInfo {
+	Sys func() ?interface{}
+}
+Config default !
+Empty {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		kind Kind
+	}{
+		{"ModePerm", Const},
+		{"ErrNotExist", Var},
+		{"Open", Func},
+		{"(*File)", Type},
+		{"(*File).Read", Method},
+		{"(*Dir)", Type},
+		{"(*Dir).Close", Method},
+		{"(Reader)", Type},
+		{"(Reader).Read", Method},
+		{"Request", Type},
+		{"Request.URL", Field},
+		{"Request.Write", Method},
+		{"FileInfo.Sys", Method},
+		{"Config", Type},
+		{"Empty", Type},
+		{"Missing", NoKind},
+		{"Request.Missing", NoKind},
+	} {
+		if kind := ann.Kind(c.name); kind != c.kind {
+			t.Errorf("%s: expected %v, got %v", c.name, c.kind, kind)
+		}
+	}
+
+	// Names are relative to a looked up annotation.
+	if kind := ann.Lookup("Request").Kind("URL"); kind != Field {
+		t.Errorf("Request, URL: expected field, got %v", kind)
+	}
+
+	// Annotations built from a map have kinds too.
+	built := NewAnnotation(map[string]string{"MaxSize": "int @const", "(*T).M": "(*T) func()"})
+	if kind := built.Kind("MaxSize"); kind != Const {
+		t.Errorf("MaxSize: expected const, got %v", kind)
+	}
+	if kind := built.Kind("(*T).M"); kind != Method {
+		t.Errorf("(*T).M: expected method, got %v", kind)
+	}
+
+	if s := Kind(42).String(); s != "unknown" {
+		t.Errorf("expected unknown for an invalid kind, got %q", s)
+	}
+}
+
+func TestMethod(t *testing.T) {
+	ann, err := Parse(`(*File) {
+	Read func(b []byte) (n int, err ?error)
+	Close func() error
+}
+(File) {
+	Close func() error
+	Name func() string
+}
+Reader {
+	Read func(p []byte) (n int, err ?error)
+}
+(*Reader) {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		recv, method string
+		expected     string
+	}{
+		{"File", "Read", "(*File).Read"},
+		{"File", "Close", "(*File).Close"},
+		{"File", "Name", "(File).Name"},
+		{"Reader", "Read", "Reader.Read"},
+		{"File", "Missing", ""},
+		{"Missing", "Read", ""},
+	} {
+		name, ok := ann.Method(c.recv, c.method)
+		if name != c.expected || ok != (c.expected != "") {
+			t.Errorf("%s, %s: expected %q, got %q, %v", c.recv, c.method, c.expected, name, ok)
+		}
+	}
+
+	// Names are relative to a looked up annotation.
+	sub := NewAnnotation(map[string]string{"io.(Reader).Read": "func(p []byte) (int, ?error)"}).Lookup("io")
+	if name, ok := sub.Method("Reader", "Read"); !ok || name != "(Reader).Read" {
+		t.Errorf("expected (Reader).Read, got %q, %v", name, ok)
+	}
+	if name, ok := (*Annotation)(nil).Method("File", "Read"); ok {
+		t.Errorf("expected nothing for a nil Annotation, got %q", name)
+	}
+}
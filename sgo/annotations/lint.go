@@ -0,0 +1,126 @@
+package annotations
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LintDir checks every .sgoann file under the directory root, and returns an
+// ErrorList of FileErrors with every problem found.
+func LintDir(root string) error {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".sgoann" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	var errs ErrorList
+	// The first file and position each identifier in a directory is annotated
+	// at, except those gated on build constraints.
+	type annotated struct {
+		path string
+		tk   Token
+	}
+	firsts := map[string]map[string]annotated{}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ann, err := ParseAllWith(ParseOptions{ValidateTypes: true}, string(src))
+		if list, ok := err.(ErrorList); ok {
+			for _, err := range list {
+				errs = append(errs, newFileError(path, string(src), err))
+			}
+		} else if err != nil {
+			errs = append(errs, newFileError(path, string(src), err))
+		}
+
+		dir := filepath.Dir(path)
+		if firsts[dir] == nil {
+			firsts[dir] = map[string]annotated{}
+		}
+		for _, name := range ann.Names() {
+			raw, ok := ann.RawType(name)
+			tk, _ := ann.Pos(name)
+			if !ok || hasBuildConstraint(raw) {
+				continue
+			}
+			if first, ok := firsts[dir][name]; ok {
+				errs = append(errs, FileError{
+					File: path,
+					Line: tk.Line,
+					Col:  tk.Col,
+					Err:  fmt.Errorf("%s annotated again; first annotated at %s:%d:%d", name, first.path, first.tk.Line, first.tk.Col),
+				})
+				continue
+			}
+			firsts[dir][name] = annotated{path, tk}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// A FileError is an error found in the .sgoann file named File, at the given
+// position.
+type FileError struct {
+	File string
+	Line int
+	Col  int
+	Err  error
+}
+
+// Error implements the error interface.
+func (err FileError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", err.File, err.Line, err.Col, err.Err)
+}
+
+// newFileError returns a FileError for err, found while parsing src from the
+// file at path. Errors that don't tell where they were found, like EOF, are
+// at the end of src.
+func newFileError(path, src string, err error) FileError {
+	fileErr := FileError{File: path, Err: err}
+	switch err := err.(type) {
+	case UnexpectedTokenError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case InvalidTypeError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case OptionalTypeError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case DuplicateError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case DefaultError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case DepthError:
+		fileErr.Line, fileErr.Col = err.Token.Line, err.Token.Col
+	case UTF8Error:
+		fileErr.Line, fileErr.Col = err.Line, err.Col
+	case UnterminatedCommentError:
+		fileErr.Line, fileErr.Col = err.Line, err.Col
+	case PanicError:
+		fileErr.Line, fileErr.Col = err.Line, err.Col
+	default:
+		src = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(src)
+		last := strings.LastIndexByte(src, '\n')
+		fileErr.Line = strings.Count(src, "\n") + 1
+		fileErr.Col = utf8.RuneCountInString(src[last+1:]) + 1
+	}
+	return fileErr
+}
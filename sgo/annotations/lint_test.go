@@ -0,0 +1,72 @@
+package annotations
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintDir(t *testing.T) {
+	root := t.TempDir()
+	for name, src := range map[string]string{
+		"os/os.sgoann":           "Open func(name string) (*File \\ error)\nGetenv func(key string) ?int\n",
+		"os/file_unix.sgoann":    "Open func(name string) (*File \\ error)\nPipe func() (r *File, w *File, err error) @build unix\n",
+		"os/file_windows.sgoann": "Pipe func() (r *File, w *File, err error) @build windows\n",
+		"io/io.sgoann":           "Reader {\n\tRead func(p []byte) (n int, err ?error)\n}\nEOF ?error\nEOF error\n",
+		"io/ioutil/bad.sgoann":   "Config default maybe\nReadAll func(r io.Reader) (\n",
+		"net/ok.sgoann":          "Dial func(network, address string) (Conn \\ error)\n",
+		"README":                 "Open is not an annotation: ",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := LintDir(root)
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %#v", err)
+	}
+	expected := []struct {
+		file      string
+		line, col int
+		msg       string
+	}{
+		{"io/io.sgoann", 5, 1, "EOF"},
+		{"io/ioutil/bad.sgoann", 1, 1, "invalid default for Config"},
+		{"io/ioutil/bad.sgoann", 2, 1, "invalid type for ReadAll"},
+		{"os/os.sgoann", 2, 25, "int can't be nil"},
+		{"os/os.sgoann", 1, 1, "first annotated at " + filepath.Join(root, "os", "file_unix.sgoann") + ":1:1"},
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(list), []error(list))
+	}
+	for i, e := range expected {
+		fileErr, ok := list[i].(FileError)
+		if !ok {
+			t.Errorf("%d: expected a FileError, got %#v", i, list[i])
+			continue
+		}
+		if rel, _ := filepath.Rel(root, fileErr.File); filepath.ToSlash(rel) != e.file || fileErr.Line != e.line || fileErr.Col != e.col {
+			t.Errorf("%d: expected error at %s:%d:%d, got %v", i, e.file, e.line, e.col, fileErr)
+		}
+		if !strings.Contains(fileErr.Error(), e.msg) {
+			t.Errorf("%d: expected error containing %q, got %v", i, e.msg, fileErr)
+		}
+	}
+
+	if err := LintDir(filepath.Join(root, "net")); err != nil {
+		t.Errorf("expected no errors for a clean directory, got %v", err)
+	}
+	if err := LintDir(filepath.Join(root, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	} else if _, ok := err.(ErrorList); ok {
+		t.Errorf("expected the error walking the directory, got %v", err)
+	}
+}
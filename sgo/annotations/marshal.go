@@ -0,0 +1,101 @@
+package annotations
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// Marshal returns source in .sgoann format that Parse turns back into the
+// package's Annotation a.
+func (a *Annotation) Marshal() string {
+	var b strings.Builder
+	marshalTree(&b, makeDefTree(a.Names(), func(name string) string { return a.anns[name] }), "")
+	return b.String()
+}
+
+// MarshalTo writes a.Marshal() to w.
+func (a *Annotation) MarshalTo(w io.Writer) error {
+	_, err := io.WriteString(w, a.Marshal())
+	return err
+}
+
+// Definition returns the whole definition for an identifier in the package's
+// Annotation, including directives and alternatives.
+func (a *Annotation) Definition(name string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	def, ok := a.anns[name]
+	return def, ok
+}
+
+type defTree struct {
+	def      string
+	children map[string]*defTree
+}
+
+func makeDefTree(names []string, def func(string) string) *defTree {
+	root := &defTree{}
+	for _, name := range names {
+		node := root
+		for _, part := range splitName(name) {
+			if node.children == nil {
+				node.children = map[string]*defTree{}
+			}
+			child, ok := node.children[part]
+			if !ok {
+				child = &defTree{}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.def = def(name)
+	}
+	return root
+}
+
+// splitName splits a full name like "(*File).Read" into its parts, keeping
+// dots inside a receiver, as in "(*json.Decoder)", in its part.
+func splitName(name string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range name {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '.':
+			if depth == 0 {
+				parts = append(parts, name[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, name[start:])
+}
+
+func marshalTree(b *strings.Builder, t *defTree, indent string) {
+	var names []string
+	for name := range t.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := t.children[name]
+		if child.def != "" {
+			for _, alt := range alternatives(child.def) {
+				b.WriteString(indent + name + " " + alt + "\n")
+			}
+		}
+		if len(child.children) > 0 {
+			b.WriteString(indent + name + " {\n")
+			marshalTree(b, child, indent+"\t")
+			b.WriteString(indent + "}\n")
+		} else if child.def == "" {
+			b.WriteString(indent + name + " {}\n")
+		}
+	}
+}
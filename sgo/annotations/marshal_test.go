@@ -0,0 +1,184 @@
+package annotations
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	ann := NewAnnotation(map[string]string{
+		"Open":          `func(name string) (*File \ error)`,
+		"Exit":          `func(code int) @noreturn process`,
+		"TempDir":       `@nonempty`,
+		"Getenv":        "func(key string) string @build !windows\nfunc(key string) ?*string @build windows",
+		"(*File).Read":  `(*File) func(b []byte) (n int, err ?error)`,
+		"(*File).Close": `(*File) func() \ error`,
+		"Request":       `struct{}`,
+		"Request.URL":   `*url.URL`,
+		"Request.TLS.A": `?*T`,
+		"Empty":         "",
+	})
+
+	expected := `(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+Empty {}
+Exit func(code int) @noreturn process
+Getenv func(key string) string @build !windows
+Getenv func(key string) ?*string @build windows
+Open func(name string) (*File \ error)
+Request struct{}
+Request {
+	TLS {
+		A ?*T
+	}
+	URL *url.URL
+}
+TempDir @nonempty
+`
+	src := ann.Marshal()
+	if src != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, src)
+	}
+
+	parsed, err := Parse(src)
+	if err != nil {
+		t.Fatalf("parsing marshaled annotations: %v", err)
+	}
+	if patch := MakePatch(ann, parsed); len(patch) > 0 {
+		t.Errorf("round trip changed definitions: %v", patch)
+	}
+
+	if src := (*Annotation)(nil).Marshal(); src != "" {
+		t.Errorf("nil: expected no source, got %q", src)
+	}
+
+	var buf bytes.Buffer
+	if err := ann.MarshalTo(&buf); err != nil {
+		t.Fatalf("MarshalTo: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("MarshalTo: expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestMarshalConstraints(t *testing.T) {
+	// Definitions are kept as written, so constraint syntax survives a round
+	// trip even though SGo can't typecheck type parameters yet.
+	ann := NewAnnotation(map[string]string{
+		"Index": `func[S ~[]E, E comparable](s S, v E) int`,
+		"Max":   `func[T ~int | ~float64 | string](x T, y ...T) T`,
+	})
+	parsed, err := Parse(ann.Marshal())
+	if err != nil {
+		t.Fatalf("parsing marshaled annotations: %v", err)
+	}
+	if patch := MakePatch(ann, parsed); len(patch) > 0 {
+		t.Errorf("round trip changed definitions: %v", patch)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	ann := NewAnnotation(map[string]string{
+		"Open":          `func(name string) (*File \ error)`,
+		"Exit":          `func(code int) @noreturn process`,
+		"(*File).Read":  `(*File) func(b []byte) (n int, err ?error)`,
+		"(*File).Close": `(*File) func() \ error`,
+		"(*Dir).Close":  `(*Dir) func() ?error`,
+	})
+
+	for _, c := range []struct {
+		name     string
+		pred     func(name, def string) bool
+		expected string
+	}{{
+		"receiver",
+		func(name, def string) bool { return strings.HasPrefix(name, "(*File).") },
+		`(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+`,
+	}, {
+		"entangled",
+		func(name, def string) bool { return strings.Contains(def, `\`) },
+		`(*File) {
+	Close (*File) func() \ error
+}
+Open func(name string) (*File \ error)
+`,
+	}, {
+		"none",
+		func(name, def string) bool { return false },
+		"",
+	}} {
+		filtered := ann.Filter(c.pred)
+		if src := filtered.Marshal(); src != c.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", c.name, c.expected, src)
+		}
+	}
+
+	if len(ann.Names()) != 5 {
+		t.Errorf("filtering modified the filtered Annotation: %v", ann.Names())
+	}
+	if filtered := (*Annotation)(nil).Filter(func(string, string) bool { return true }); filtered != nil {
+		t.Errorf("nil: expected nil, got %v", filtered)
+	}
+}
+
+func TestSub(t *testing.T) {
+	ann, err := Parse(`Open func(name string) (*File \ error)
+(*File) {
+	Read (*File) func(b []byte) (n int, err ?error)
+	Close (*File) func() \ error
+}
+File {
+	Name (File) func() string
+	Info {
+		Sys ?interface{}
+	}
+}
+(*Dir).Close (*Dir) func() ?error
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := ann.Sub("File")
+	if expected := []string{"Info.Sys", "Name"}; !reflect.DeepEqual(file.Names(), expected) {
+		t.Errorf("File: expected names %v, got %v", expected, file.Names())
+	}
+	if typ, ok := file.Lookup("Name").Type(); !ok || typ != "(File) func() string" {
+		t.Errorf("File.Name: unexpected type %q", typ)
+	}
+	if tk, ok := file.Pos("Info.Sys"); !ok || tk.Line != 9 {
+		t.Errorf("File.Info.Sys: unexpected position %v", tk)
+	}
+	if tk, ok := file.Pos("Info"); !ok || tk.Line != 8 {
+		t.Errorf("File.Info: unexpected position %v", tk)
+	}
+	if raw, ok := file.RawType("Name"); !ok || raw != "(File) func() string" {
+		t.Errorf("File.Name: unexpected raw type %q", raw)
+	}
+
+	// On a receiver, and on an already looked up Annotation.
+	if src := ann.Sub("(*File)").Marshal(); src != "Close (*File) func() \\ error\nRead (*File) func(b []byte) (n int, err ?error)\n" {
+		t.Errorf("(*File): unexpected source %q", src)
+	}
+	if expected := []string{"Sys"}; !reflect.DeepEqual(ann.Lookup("File").Sub("Info").Names(), expected) {
+		t.Errorf("File, Info: expected names %v, got %v", expected, ann.Lookup("File").Sub("Info").Names())
+	}
+
+	if sub := ann.Sub("Open"); sub != nil {
+		t.Errorf("Open: expected nil, got %v", sub)
+	}
+	if sub := (*Annotation)(nil).Sub("File"); sub != nil {
+		t.Errorf("nil: expected nil, got %v", sub)
+	}
+	if ann.Len() != 6 {
+		t.Errorf("Sub modified the Annotation: %v", ann.Names())
+	}
+}
@@ -0,0 +1,82 @@
+package annotations
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Versions of the .sgoann grammar, as told apart by DetectVersion.
+const (
+	// FlatVersion is the earliest grammar, in which subidentifiers were
+	// annotated with their full dotted name, as in "(*File).Read func()",
+	// instead of in a block under their parent.
+	FlatVersion = 1
+	// CurrentVersion is the grammar that Parse accepts.
+	CurrentVersion = 2
+)
+
+// flatName matches an item whose name has subidentifiers spelled out after
+// dots, in FlatVersion.
+var flatName = regexp.MustCompile(`^(\(\s*\*?\s*(?:[\pL_][\pL\pN_]*\.)?[\pL_][\pL\pN_]*\s*\)|[\pL_][\pL\pN_]*)((?:\.[\pL_][\pL\pN_]*)+)([ \t]|$)`)
+
+// DetectVersion returns the version of the grammar that source in .sgoann
+// format is written in.
+func DetectVersion(src string) int {
+	version := CurrentVersion
+	eachItem(src, func(item string) string {
+		if flatName.MatchString(item) {
+			version = FlatVersion
+		}
+		return item
+	})
+	return version
+}
+
+// Migrate upgrades source in .sgoann format written in any version of the
+// grammar to CurrentVersion, and returns it as formatted by Marshal.
+func Migrate(src string) (string, error) {
+	ann, err := Parse(eachItem(src, blockFlatName))
+	if err != nil {
+		return "", err
+	}
+	return ann.Marshal(), nil
+}
+
+// blockFlatName rewrites an item with a FlatVersion name into nested blocks,
+// one per subidentifier, all in the same line; for example,
+// "(*File).Read func()" becomes "(*File) { Read func(); }".
+func blockFlatName(item string) string {
+	m := flatName.FindStringSubmatchIndex(item)
+	if m == nil {
+		return item
+	}
+	subs := strings.Split(item[m[4]+1:m[5]], ".")
+	rewritten := item[m[2]:m[3]]
+	for _, sub := range subs[:len(subs)-1] {
+		rewritten += " { " + sub
+	}
+	rewritten += " { " + subs[len(subs)-1] + item[m[5]:] + ";"
+	rewritten += strings.Repeat(" };", len(subs)-1) + " }"
+	return rewritten
+}
+
+// eachItem returns src with each line or ';'-separated part, without its
+// leading whitespace, replaced by what f returns for it.
+func eachItem(src string, f func(item string) string) string {
+	var b strings.Builder
+	for {
+		end := strings.IndexAny(src, "\r\n;")
+		if end == -1 {
+			end = len(src)
+		}
+		part := src[:end]
+		item := strings.TrimLeft(part, " \t\r")
+		b.WriteString(part[:len(part)-len(item)])
+		b.WriteString(f(item))
+		if end == len(src) {
+			return b.String()
+		}
+		b.WriteByte(src[end])
+		src = src[end+1:]
+	}
+}
@@ -0,0 +1,96 @@
+package annotations
+
+import (
+	"strings"
+	"testing"
+)
+
+const testFlatSrc = `Open func(name string) (*File \ error)
+( * File ).Read (*File) func(b []byte) (n int, err ?error)
+(*File).Close (*File) func() \ error
+(*json.Decoder).Decode (*json.Decoder) func(v interface{}) error
+(Reader).Read (Reader) func(p []byte) (n int, err ?error)
+Getenv func(key string) string @build !windows
+Getenv func(key string) ?*string @build windows
+Request.URL *url.URL; Request.TLS.A ?*T
+Request {
+	Header.Get (*Header) func(key string) string
+}
+`
+
+func TestMigrate(t *testing.T) {
+	if v := DetectVersion(testFlatSrc); v != FlatVersion {
+		t.Errorf("expected version %d, got %d", FlatVersion, v)
+	}
+
+	expected := `(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+(*json.Decoder) {
+	Decode (*json.Decoder) func(v interface{}) error
+}
+(Reader) {
+	Read (Reader) func(p []byte) (n int, err ?error)
+}
+Getenv func(key string) string @build !windows
+Getenv func(key string) ?*string @build windows
+Open func(name string) (*File \ error)
+Request {
+	Header {
+		Get (*Header) func(key string) string
+	}
+	TLS {
+		A ?*T
+	}
+	URL *url.URL
+}
+`
+	migrated, err := Migrate(testFlatSrc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if migrated != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, migrated)
+	}
+	if v := DetectVersion(migrated); v != CurrentVersion {
+		t.Errorf("migrated: expected version %d, got %d", CurrentVersion, v)
+	}
+
+	for _, eol := range []string{"\r\n", "\r"} {
+		migrated, err := Migrate(strings.Replace(testFlatSrc, "\n", eol, -1))
+		if err != nil {
+			t.Fatalf("%q line endings: unexpected error: %v", eol, err)
+		}
+		if migrated != expected {
+			t.Errorf("%q line endings: expected:\n%s\ngot:\n%s", eol, expected, migrated)
+		}
+	}
+
+	again, err := Migrate(migrated)
+	if err != nil {
+		t.Fatalf("migrating again: unexpected error: %v", err)
+	}
+	if again != migrated {
+		t.Errorf("migrating again changed the source:\n%s", again)
+	}
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	src := "( * T ) {\n\tM func() @narrows $1\n}\nA int\n"
+	if v := DetectVersion(src); v != CurrentVersion {
+		t.Errorf("expected version %d, got %d", CurrentVersion, v)
+	}
+	migrated, err := Migrate(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "(*T) {\n\tM func() @narrows $1\n}\nA int\n"
+	if migrated != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, migrated)
+	}
+
+	if _, err := Migrate("A.B\n"); err == nil {
+		t.Errorf("expected error for an item without a definition")
+	}
+}
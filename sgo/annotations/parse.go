@@ -2,6 +2,7 @@
 package annotations
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -13,65 +14,452 @@ import (
 // Parse parses source in .sgoann format and returns an Annotation you can
 // navigate as you walk through a Go AST for a package.
 //
-// The source must conform to this grammar:
+// The source must conform to this grammar, explained further in README.md:
 //
 // 	List -> Item*
 // 	Item -> Name Def /[\n;]*/
-// 	Name -> Ident | Receiver
-// 	Receiver -> "(" "*" Ident ")"
+// 	Name -> Ident [ TypeParams ] | Receiver | Embedded
+// 	Receiver -> "(" [ "*" ] [ Ident "." ] Ident [ TypeParams ] ")"
+// 	TypeParams -> "[" (Go type parameters, in one line) "]"
+// 	Embedded -> Ident "." Ident
 // 	Ident -> (Go identifier)
 // 	Def -> Type | "{" List "}"
-// 	Type -> /[^{][^\n;]*/
+// 	Type -> /[^{][^\n;]*/ (with brackets, new lines and ';' in them)
 func Parse(src string) (*Annotation, error) {
-	anns, err := parseList(NewTokenizer(src))
-	return NewAnnotation(anns), err
+	return ParseReader(strings.NewReader(src))
 }
 
-func parseList(src *Tokenizer) (map[string]string, error) {
+// ParseReader is like Parse, but reads the source from r as it's parsed.
+//
+// For SGo: func(r io.Reader) (*Annotation, error)
+func ParseReader(r io.Reader) (*Annotation, error) {
+	return ParseReaderWith(ParseOptions{}, r)
+}
+
+// ParseOptions configures ParseWith and ParseReaderWith.
+type ParseOptions struct {
+	// ValidateTypes makes an Item whose Type isn't a valid SGo type, possibly
+	// with a receiver in front, fail with an InvalidTypeError.
+	ValidateTypes bool
+	// AllowDuplicates makes an identifier annotated more than once keep its
+	// last definition, instead of failing with a DuplicateError.
+	AllowDuplicates bool
+	// MaxDepth limits how deeply blocks, and brackets in a Type, may be
+	// nested, failing with a DepthError. If 0, DefaultMaxDepth is used.
+	MaxDepth int
+}
+
+// DefaultMaxDepth is the ParseOptions.MaxDepth used if none is set: far deeper
+// than any real annotation needs.
+const DefaultMaxDepth = 128
+
+// ParseWith is like Parse, configured by opts.
+//
+// For SGo: func(opts ParseOptions, src string) (*Annotation, error)
+func ParseWith(opts ParseOptions, src string) (*Annotation, error) {
+	return ParseReaderWith(opts, strings.NewReader(src))
+}
+
+// ParseReaderWith is like ParseReader, configured by opts.
+//
+// For SGo: func(opts ParseOptions, r io.Reader) (*Annotation, error)
+func ParseReaderWith(opts ParseOptions, r io.Reader) (*Annotation, error) {
+	p := newParseState(opts)
+	anns := map[string]string{}
+	err := p.parse(NewReaderTokenizer(r), func(name, def string, pos Token) error {
+		addDefs(anns, map[string]string{name: def})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.annotation(anns), nil
+}
+
+// A Parser parses .sgoann sources as Parse does, but hands each Item to a
+// function as it's parsed instead of collecting them.
+type Parser struct {
+	opts ParseOptions
+}
+
+// NewParser returns a Parser configured by opts. The zero Parser is configured
+// by the zero ParseOptions.
+func NewParser(opts ParseOptions) *Parser {
+	return &Parser{opts: opts}
+}
+
+// Parse parses src and calls visit, in order, with the full name, definition
+// and position of each Item with a Def. If visit returns an error, parsing
+// stops and Parse returns it.
+func (p *Parser) Parse(src string, visit func(name, def string, pos Token) error) error {
+	return p.ParseReader(strings.NewReader(src), visit)
+}
+
+// ParseReader is like Parse, but reads the source from r as it's parsed.
+func (p *Parser) ParseReader(r io.Reader, visit func(name, def string, pos Token) error) error {
+	return newParseState(p.opts).parse(NewReaderTokenizer(r), visit)
+}
+
+// A visitError wraps an error returned by a Parser's visit function, to tell
+// it apart from parsing errors until Parse returns it.
+type visitError struct {
+	err error
+}
+
+func (err visitError) Error() string {
+	return err.err.Error()
+}
+
+// ParseAll is like Parse, but skips malformed Items instead of stopping at the
+// first one. The error, if any, is an ErrorList with every error found.
+//
+// For SGo: func(src string) (*Annotation, error)
+func ParseAll(src string) (*Annotation, error) {
+	return ParseAllWith(ParseOptions{}, src)
+}
+
+// ParseAllWith is like ParseAll, configured by opts.
+//
+// For SGo: func(opts ParseOptions, src string) (*Annotation, error)
+func ParseAllWith(opts ParseOptions, src string) (ann *Annotation, err error) {
+	tkr := NewTokenizer(src)
+	defer recoverPanic(tkr, &err)
+	p := newParseState(opts)
+	p.recover = true
+	anns := map[string]string{}
+	for {
+		listAnns, err := parseList(tkr, p, false)
+		addDefs(anns, listAnns)
+		if err != nil {
+			p.errs = append(p.errs, err)
+			break
+		}
+		// The list ends at something that can't start an Item.
+		tk, err := tkr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.errs = append(p.errs, err)
+			break
+		}
+		p.errs = append(p.errs, NewUnexpectedTokenError(tk))
+		skipItem(tkr)
+	}
+	if len(p.errs) > 0 {
+		return p.annotation(anns), p.errs
+	}
+	return p.annotation(anns), nil
+}
+
+// recoverPanic turns a panic while parsing src into a PanicError at its
+// position, unless built with the sgoannpanic tag.
+func recoverPanic(src *Tokenizer, err *error) {
+	if !recoverPanics {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = PanicError{Line: src.line, Col: src.col(), BytePos: src.bytePos, Value: r}
+	}
+}
+
+// A parseState is what's kept while parsing a .sgoann source, other than the
+// Tokenizer's state. A nil *parseState keeps nothing.
+type parseState struct {
+	// recover is set to collect malformed Items into errs and skip them,
+	// instead of stopping at the first one.
+	recover bool
+	errs    ErrorList
+	// validate is set to check that Types are valid SGo types.
+	validate bool
+	// duplicates is set to let repeated Items replace earlier ones.
+	duplicates bool
+	// pos has the positions of the Names of the Items parsed, by full name.
+	pos map[string]Token
+	// raw has the Types of the Items parsed as written, by full name, with
+	// alternatives one per line as in their definitions.
+	raw map[string]string
+	// docs has the comments documenting the Items parsed, by full name.
+	docs map[string]string
+	// names are the Names of the Items whose blocks are being parsed.
+	names []string
+	// visit, if set, is called for each Item with a Def as it's parsed.
+	visit func(name, def string, pos Token) error
+	// maxDepth is the deepest that blocks, and brackets in a Type, may
+	// be nested.
+	maxDepth int
+}
+
+func newParseState(opts ParseOptions) *parseState {
+	maxDepth := opts.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	return &parseState{validate: opts.ValidateTypes, duplicates: opts.AllowDuplicates, maxDepth: maxDepth, pos: map[string]Token{}, raw: map[string]string{}, docs: map[string]string{}}
+}
+
+// parse parses a whole source from src, calling visit for each Item with a
+// Def.
+func (p *parseState) parse(src *Tokenizer, visit func(name, def string, pos Token) error) (err error) {
+	defer recoverPanic(src, &err)
+	p.visit = visit
+	_, err = parseFile(src, p)
+	if err, ok := err.(visitError); ok {
+		return err.err
+	}
+	return err
+}
+
+func (p *parseState) recovering() bool {
+	return p != nil && p.recover
+}
+
+// depthLimit returns the deepest that blocks, and brackets in a Type, may be
+// nested.
+func (p *parseState) depthLimit() int {
+	if p == nil || p.maxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return p.maxDepth
+}
+
+func (p *parseState) fullName(name string) string {
+	if p == nil {
+		return name
+	}
+	return strings.Join(append(append([]string(nil), p.names...), name), ".")
+}
+
+func (p *parseState) enter(name string) {
+	if p != nil {
+		p.names = append(p.names, name)
+	}
+}
+
+func (p *parseState) leave() {
+	if p != nil {
+		p.names = p.names[:len(p.names)-1]
+	}
+}
+
+// parsed records the position, raw Type and doc of a parsed Item's Name,
+// keeping the first position and doc if the Name is repeated.
+func (p *parseState) parsed(name string, tk Token, raw, doc string) {
+	if p == nil {
+		return
+	}
+	if _, ok := p.pos[p.fullName(name)]; !ok {
+		p.pos[p.fullName(name)] = tk
+	}
+	if _, ok := p.docs[p.fullName(name)]; !ok && doc != "" {
+		p.docs[p.fullName(name)] = doc
+	}
+	if raw != "" {
+		addDefs(p.raw, map[string]string{p.fullName(name): raw})
+	}
+}
+
+// dropped forgets what was recorded for the children of an Item that turned
+// out to be malformed.
+func (p *parseState) dropped(name string) {
+	if p == nil {
+		return
+	}
+	prefix := p.fullName(name) + "."
+	for k := range p.pos {
+		if strings.HasPrefix(k, prefix) {
+			delete(p.pos, k)
+		}
+	}
+	for k := range p.raw {
+		if strings.HasPrefix(k, prefix) {
+			delete(p.raw, k)
+		}
+	}
+	for k := range p.docs {
+		if strings.HasPrefix(k, prefix) {
+			delete(p.docs, k)
+		}
+	}
+}
+
+func (p *parseState) annotation(anns map[string]string) *Annotation {
+	ann := NewAnnotation(anns)
+	if ann != nil && p != nil {
+		ann.pos = p.pos
+		ann.raw = p.raw
+		ann.docs = p.docs
+	}
+	return ann
+}
+
+// parseList parses Items until something that can't start one. If p is
+// recovering, malformed Items are added to its errs and skipped.
+func parseList(src *Tokenizer, p *parseState, inBlock bool) (map[string]string, error) {
 	anns := map[string]string{}
 	for {
 		src.SkipWhite()
 		tk, err := src.Peek()
 		if err != nil && err != io.EOF {
-			return nil, err
+			return partial(anns, p), err
 		}
 
 		if err == io.EOF || tk.Lexeme != '(' && tk.Lexeme != '_' && !unicode.IsLetter(tk.Lexeme) {
 			return anns, nil
 		}
 
-		itemAnns, err := parseItem(src)
+		itemAnns, err := parseItem(src, p, inBlock)
 		if err != nil {
 			if err == io.EOF {
-				return nil, EOF
+				return partial(anns, p), EOF
+			}
+			switch err.(type) {
+			case UTF8Error, UnterminatedCommentError:
+				return partial(anns, p), err
+			}
+			if p.recovering() {
+				p.errs = append(p.errs, err)
+				skipItem(src)
+				continue
 			}
 			return nil, err
 		}
-		for k, v := range itemAnns {
-			anns[k] = v
+		addDefs(anns, itemAnns)
+	}
+}
+
+// parseFile parses a whole source as a top-level List. Unlike in a block, where
+// it's the closing '}', whatever stops the List before the end of the source,
+// like a stray '}', is an UnexpectedTokenError.
+func parseFile(src *Tokenizer, p *parseState) (map[string]string, error) {
+	anns, err := parseList(src, p, false)
+	if err != nil {
+		return anns, err
+	}
+	tk, err := src.Next()
+	if err == io.EOF {
+		return anns, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, NewUnexpectedTokenError(tk)
+}
+
+// partial returns the Items parsed before an error, if they're being
+// collected.
+func partial(anns map[string]string, p *parseState) map[string]string {
+	if !p.recovering() {
+		return nil
+	}
+	return anns
+}
+
+// skipItem skips the rest of a malformed Item, up to the new line or ';' that
+// ends it, unless it was just consumed.
+func skipItem(src *Tokenizer) {
+	if src.bytePos > 0 && !src.newline && (src.prev == '\n' || src.prev == '\r' || src.prev == ';') {
+		return
+	}
+	for {
+		tk, err := src.Next()
+		if err != nil || tk.Lexeme == '\n' || tk.Lexeme == ';' {
+			return
+		}
+	}
+}
+
+// skipBlock skips the block starting at the next token, and all blocks within
+// it, without parsing them, so that ParseAll goes on after a block nested too
+// deep.
+func skipBlock(src *Tokenizer) {
+	depth := 0
+	for {
+		tk, err := src.Next()
+		if err != nil {
+			return
+		}
+		switch tk.Lexeme {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// addDefs adds the definitions in src to dst. Repeated items are alternatives
+// to each other if gated on build constraints; otherwise, the last one wins.
+func addDefs(dst, src map[string]string) {
+	for k, v := range src {
+		if prev, ok := dst[k]; ok && (hasBuildConstraint(prev) || hasBuildConstraint(v)) {
+			v = prev + "\n" + v
 		}
+		dst[k] = v
 	}
 }
 
-func parseItem(src *Tokenizer) (map[string]string, error) {
-	name, err := parseName(src)
+func parseItem(src *Tokenizer, p *parseState, inBlock bool) (map[string]string, error) {
+	nameTk, err := src.Peek()
+	if err != nil {
+		return nil, err
+	}
+	doc := src.docComment(nameTk.Line)
+	name, err := parseName(src, inBlock)
 	if err != nil {
 		return nil, err
 	}
 
 	src.SkipWhiteUntilLine()
-	def, err := parseDef(src)
+	p.enter(name)
+	def, raw, err := parseDef(src, p, inBlock)
+	p.leave()
 	if err != nil {
+		p.dropped(name)
 		return nil, err
 	}
 
 	src.SkipWhiteUntilLine()
-	tk, err := src.Next()
+	if trailing := src.trailingComment(); trailing != "" {
+		doc = strings.TrimPrefix(doc+"\n"+trailing, "\n")
+	}
+	tk, err := src.Peek()
 	if err != nil && err != io.EOF {
+		p.dropped(name)
 		return nil, err
 	}
-	if err != io.EOF && tk.Lexeme != ';' && tk.Lexeme != '\n' {
-		return nil, NewUnexpectedTokenError(tk)
+	// The '}' that closes a block is left for parseDef.
+	if err != io.EOF && !(inBlock && tk.Lexeme == '}') {
+		src.Next()
+		if tk.Lexeme != ';' && tk.Lexeme != '\n' {
+			p.dropped(name)
+			return nil, NewUnexpectedTokenError(tk)
+		}
+	}
+
+	if fields := strings.Fields(def[""]); len(fields) > 0 && fields[0] == "default" {
+		policy := strings.Join(fields[1:], " ")
+		if policy != "?" && policy != "!" {
+			p.dropped(name)
+			return nil, DefaultError{Token: nameTk, Name: name, Policy: policy}
+		}
+		def[""] = "@default " + policy
+	}
+
+	if typ, _ := splitDirectives(def[""]); typ != "" && p != nil && p.validate {
+		if err := validateType(typ); err != nil {
+			p.dropped(name)
+			return nil, InvalidTypeError{Token: nameTk, Name: p.fullName(name), Type: typ, Err: err}
+		}
+	}
+
+	if p != nil && !p.duplicates && raw != "" {
+		if prev, ok := p.raw[p.fullName(name)]; ok && !hasBuildConstraint(prev) && !hasBuildConstraint(raw) {
+			return nil, DuplicateError{Name: p.fullName(name), First: p.pos[p.fullName(name)], Token: nameTk}
+		}
 	}
 
 	ret := map[string]string{}
@@ -82,10 +470,34 @@ func parseItem(src *Tokenizer) (map[string]string, error) {
 		}
 		ret[k] = subDef
 	}
+	p.parsed(name, nameTk, raw, doc)
+	if d, ok := def[""]; ok && p != nil && p.visit != nil {
+		if err := p.visit(p.fullName(name), d, nameTk); err != nil {
+			return nil, visitError{err}
+		}
+	}
+
+	// Any more terminators, spaced or not, as in "A int ; ;", end it too.
+	skipTerminators(src)
 	return ret, nil
 }
 
-func parseName(src *Tokenizer) (string, error) {
+// skipTerminators skips the spaces, new lines and ';' after the one that ends
+// an Item. Errors are left for the next Peek to find.
+func skipTerminators(src *Tokenizer) {
+	for {
+		src.SkipWhite()
+		tk, err := src.Peek()
+		if err != nil || tk.Lexeme != ';' {
+			return
+		}
+		src.Next()
+	}
+}
+
+// parseName parses a Name. In a block, it may also be a qualified identifier,
+// as in "io.Reader", for an embedded field; otherwise, it may have TypeParams.
+func parseName(src *Tokenizer, inBlock bool) (string, error) {
 	tk, err := src.Peek()
 	if err != nil {
 		return "", err
@@ -93,7 +505,26 @@ func parseName(src *Tokenizer) (string, error) {
 	if tk.Lexeme == '(' {
 		return parseReceiver(src)
 	} else if tk.Lexeme == '_' || unicode.IsLetter(tk.Lexeme) {
-		return parseIdent(src)
+		id, err := parseIdent(src)
+		if err != nil {
+			return "", err
+		}
+		if !inBlock {
+			return id, skipTypeParams(src)
+		}
+		tk, err := src.Peek()
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if err == io.EOF || tk.Lexeme != '.' {
+			return id, nil
+		}
+		src.Next()
+		typeName, err := parseIdent(src)
+		if err != nil {
+			return "", err
+		}
+		return id + "." + typeName, nil
 	} else {
 		return "", NewUnexpectedTokenError(tk)
 	}
@@ -102,103 +533,385 @@ func parseName(src *Tokenizer) (string, error) {
 func parseReceiver(src *Tokenizer) (string, error) {
 	src.Next() // We know it's '('
 
+	// A value receiver, as in (Reader), has no *.
 	src.SkipWhite()
-	err := expect('*', src)
+	tk, err := src.Peek()
 	if err != nil {
 		return "", err
 	}
+	star := ""
+	if tk.Lexeme == '*' {
+		src.Next()
+		star = "*"
+
+		// Go doesn't allow methods on pointers to pointers, so (**T) can't
+		// annotate anything.
+		src.SkipWhite()
+		tk, err := src.Peek()
+		if err != nil {
+			return "", err
+		}
+		if tk.Lexeme == '*' {
+			return "", NewUnexpectedTokenError(tk)
+		}
+	}
 
-	src.SkipWhite()
 	id, err := parseIdent(src)
 	if err != nil {
 		return "", err
 	}
 
+	// A type from another package, as in (*json.Decoder).
+	tk, err = src.Peek()
+	if err != nil {
+		return "", err
+	}
+	if tk.Lexeme == '.' {
+		src.Next()
+		typeName, err := parseIdent(src)
+		if err != nil {
+			return "", err
+		}
+		id += "." + typeName
+	}
+
+	// A generic type, as in (*List[T]).
+	if err := skipTypeParams(src); err != nil {
+		return "", err
+	}
+
 	src.SkipWhite()
 	err = expect(')', src)
 	if err != nil {
 		return "", err
 	}
 
-	return "(*" + id + ")", nil
+	return "(" + star + id + ")", nil
 }
 
-func parseIdent(src *Tokenizer) (string, error) {
-	tk, err := src.Next()
+// skipTypeParams skips the TypeParams right after an Ident, if any. They can
+// have any Tokens but new lines and ';', with their brackets balanced.
+func skipTypeParams(src *Tokenizer) error {
+	tk, err := src.Peek()
+	if err == io.EOF || err == nil && tk.Lexeme != '[' {
+		return nil
+	}
 	if err != nil {
-		return "", err
+		return err
 	}
-	id := string(tk.Lexeme)
-
-	for {
-		tk, err := src.Peek()
+	src.Next()
+	empty := true
+	for depth := 1; depth > 0; {
+		tk, err := src.Next()
+		if err == io.EOF {
+			return EOF
+		}
 		if err != nil {
-			return "", err
+			return err
 		}
-		if !unicode.IsLetter(tk.Lexeme) && !unicode.IsDigit(tk.Lexeme) {
-			break
+		switch tk.Lexeme {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 && empty {
+				// An empty list, as in "T[]".
+				return NewUnexpectedTokenError(tk)
+			}
+		case '\n', ';':
+			return NewUnexpectedTokenError(tk)
+		}
+		if !unicode.IsSpace(tk.Lexeme) {
+			empty = false
 		}
-		src.Next()
-		id += string(tk.Lexeme)
 	}
+	return nil
+}
 
+func parseIdent(src *Tokenizer) (string, error) {
+	first := true
+	id, tk, err := src.NextWhile(func(r rune) bool {
+		if first {
+			first = false
+			return r == '_' || unicode.IsLetter(r)
+		}
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	})
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		src.Next()
+		return "", NewUnexpectedTokenError(tk)
+	}
 	return id, nil
 }
 
-func parseDef(src *Tokenizer) (map[string]string, error) {
+// parseDef parses a Def. If it's a Type, it's also returned as written.
+// inBlock is set for a Def in a block.
+func parseDef(src *Tokenizer, p *parseState, inBlock bool) (map[string]string, string, error) {
 	tk, err := src.Peek()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if tk.Lexeme == '{' {
+		// p.names has this block's Name, and those of the blocks it's in.
+		if p != nil && len(p.names) > p.depthLimit() {
+			skipBlock(src)
+			return nil, "", DepthError{Token: tk, Max: p.depthLimit()}
+		}
 		src.Next()
 		src.SkipWhite()
-		anns, err := parseList(src)
+		anns, err := parseList(src, p, true)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		src.SkipWhite()
 		err = expect('}', src)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		return anns, nil
+		if len(anns) == 0 {
+			// An empty block still annotates its Name, as a type with
+			// nothing else to say about it.
+			anns[""] = ""
+		}
+		return anns, "", nil
 	} else {
-		typ, err := parseType(src)
+		raw, err := parseType(src, p.depthLimit(), inBlock)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		return map[string]string{"": typ}, nil
+		return map[string]string{"": strings.TrimSpace(raw)}, raw, nil
 	}
 }
 
-func parseType(src *Tokenizer) (string, error) {
+// parseType parses a Type, and returns it with each new line in brackets
+// written as Go would read it. inBlock makes a '}' out of brackets end it.
+func parseType(src *Tokenizer, maxDepth int, inBlock bool) (string, error) {
 	tk, err := src.Next()
 	if err != nil {
 		return "", err
 	}
-	if tk.Lexeme == '{' || tk.Lexeme == '\n' || tk.Lexeme == ';' {
+	if tk.Lexeme == '{' || tk.Lexeme == '\n' || tk.Lexeme == ';' || inBlock && tk.Lexeme == '}' {
 		return "", NewUnexpectedTokenError(tk)
 	}
-	typ := string(tk.Lexeme)
+	var typ strings.Builder
+	// Spaces are written only before something else, or at the end, so that
+	// those before a new line in brackets are dropped.
+	var spaces []rune
+	// The last Lexeme written, other than spaces.
+	var last rune
+	write := func(r rune) {
+		if r == ' ' || r == '\t' {
+			spaces = append(spaces, r)
+			return
+		}
+		typ.WriteString(string(spaces))
+		spaces = spaces[:0]
+		typ.WriteRune(r)
+		last = r
+	}
+	write(tk.Lexeme)
+	// newline writes a new line in brackets, dropping the spaces before it.
+	newline := func(semicolon bool) {
+		spaces = spaces[:0]
+		if semicolon {
+			write(';')
+		}
+		write(' ')
+	}
 
+	// The number of \ seen in each of the enclosing parentheses, innermost
+	// last. A result list can be split by just one.
+	entangled := []int{0}
+	// The brackets enclosing the last Lexeme, innermost last.
+	var brackets []rune
+	// The ? before the directives, if any, with where their operands start in
+	// typ, in bytes.
+	var optionals []Token
+	var operands []int
+	inDirectives := false
+	// The quote of the string literal the last Lexeme is in, if any, and
+	// whether it's escaped by a \ in an interpreted one.
+	var quote rune
+	escaped := false
 	for {
-		tk, err := src.Peek()
-		if err != nil && err != io.EOF {
+		switch {
+		case quote == 0 && (tk.Lexeme == '"' || tk.Lexeme == '`'):
+			quote = tk.Lexeme
+		case quote == '"' && tk.Lexeme == '\\' && !escaped:
+			escaped = true
+		case quote != 0:
+			if tk.Lexeme == quote && !escaped {
+				quote = 0
+			}
+			escaped = false
+		}
+
+		switch {
+		case quote != 0:
+			// In a string literal, as in a struct tag, nothing is special.
+		case tk.Lexeme == '@':
+			inDirectives = true
+		case tk.Lexeme == '?':
+			if !inDirectives {
+				optionals = append(optionals, tk)
+				operands = append(operands, typ.Len())
+			}
+		case (tk.Lexeme == '(' || tk.Lexeme == '[' || tk.Lexeme == '{') && len(brackets) == maxDepth:
+			return "", DepthError{Token: tk, Max: maxDepth}
+		case tk.Lexeme == '(':
+			entangled = append(entangled, 0)
+			brackets = append(brackets, '(')
+		case tk.Lexeme == ')':
+			if len(entangled) > 1 {
+				entangled = entangled[:len(entangled)-1]
+			}
+			brackets = closeBracket(brackets)
+		case tk.Lexeme == '[' || tk.Lexeme == '{':
+			brackets = append(brackets, tk.Lexeme)
+		case tk.Lexeme == ']' || tk.Lexeme == '}':
+			brackets = closeBracket(brackets)
+		case tk.Lexeme == '\\':
+			entangled[len(entangled)-1]++
+			if entangled[len(entangled)-1] > 1 {
+				return "", NewUnexpectedTokenError(tk)
+			}
+		}
+
+		end, err := skipInBrackets(src, brackets, quote, last, inBlock, newline)
+		if err != nil {
 			return "", err
 		}
-		if tk.Lexeme == '\n' || tk.Lexeme == ';' || err == io.EOF {
+		if end {
+			typ.WriteString(string(spaces))
 			break
 		}
-		src.Next()
-		typ += string(tk.Lexeme)
+		tk, _ = src.Next()
+		write(tk.Lexeme)
+	}
+
+	for i, tk := range optionals {
+		if operand, ok := nonNilable(typ.String()[operands[i]:]); ok {
+			return "", NewOptionalTypeError(tk, operand)
+		}
+	}
+
+	return typ.String(), nil
+}
+
+// skipInBrackets skips a comment or new line in src, writing the latter with
+// newline, given the brackets and string literal it's in and the last Lexeme
+// written. It reports whether the Type ends there instead.
+func skipInBrackets(src *Tokenizer, brackets []rune, quote, last rune, inBlock bool, newline func(semicolon bool)) (bool, error) {
+	for {
+		tk, err := src.Peek()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case quote != 0:
+			return tk.Lexeme == '\n' || tk.Lexeme == ';' && len(brackets) == 0, nil
+		case tk.Lexeme == '/' && strings.HasPrefix(src.ensure(len("//")), "//"):
+			if len(brackets) == 0 {
+				return true, nil
+			}
+			if _, _, err := src.NextWhile(func(r rune) bool { return r != '\n' }); err != nil && err != io.EOF {
+				return false, err
+			}
+		case tk.Lexeme == '/' && strings.HasPrefix(src.ensure(len("/*")), "/*"):
+			if len(brackets) == 0 {
+				return true, nil
+			}
+			if !src.skipComment() {
+				// It's unterminated.
+				return false, src.err
+			}
+			// A comment spanning lines is left as a new line.
+			if !src.newline {
+				if _, _, err := src.NextWhile(func(r rune) bool { return r == ' ' || r == '\t' }); err != nil && err != io.EOF {
+					return false, err
+				}
+			}
+		case tk.Lexeme == ';':
+			return len(brackets) == 0, nil
+		case tk.Lexeme == '}' && inBlock && len(brackets) == 0:
+			return true, nil
+		case tk.Lexeme == '\n':
+			inBraces := len(brackets) > 0 && brackets[len(brackets)-1] == '{'
+			if len(brackets) == 0 || !inBraces && insertsSemicolon(last) {
+				return true, nil
+			}
+			src.Next()
+			newline(inBraces && insertsSemicolon(last))
+			if inBraces && insertsSemicolon(last) {
+				last = ';'
+			}
+			if _, _, err := src.NextWhile(func(r rune) bool { return r == ' ' || r == '\t' }); err != nil && err != io.EOF {
+				return false, err
+			}
+		default:
+			return false, nil
+		}
+	}
+}
+
+// closeBracket pops the innermost of brackets, if any.
+func closeBracket(brackets []rune) []rune {
+	if len(brackets) == 0 {
+		return brackets
 	}
+	return brackets[:len(brackets)-1]
+}
+
+// insertsSemicolon tells whether Go would insert a ';' at a line ending after
+// last, the last rune in the line, other than spaces: after an identifier or
+// keyword, a literal, or a closing bracket.
+func insertsSemicolon(last rune) bool {
+	switch last {
+	case ')', ']', '}', '"', '`', '\'':
+		return true
+	}
+	return last == '_' || unicode.IsLetter(last) || unicode.IsDigit(last)
+}
 
-	return strings.TrimSpace(typ), nil
+var nonNilableIdents = map[string]bool{
+	"bool": true, "string": true, "byte": true, "rune": true, "uintptr": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+	"struct": true,
+}
+
+// nonNilable tells whether the type at the start of src obviously can't be
+// nil, and returns how it starts, for errors.
+func nonNilable(src string) (string, bool) {
+	src = strings.TrimLeft(src, " \t")
+	if strings.HasPrefix(src, "[") && !strings.HasPrefix(strings.TrimLeft(src[1:], " \t"), "]") {
+		end := strings.IndexByte(src, ']')
+		if end == -1 {
+			return "", false
+		}
+		return src[:end+1], true
+	}
+	end := strings.IndexFunc(src, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if end == -1 {
+		end = len(src)
+	}
+	if id := src[:end]; nonNilableIdents[id] && !strings.HasPrefix(src[end:], ".") {
+		return id, true
+	}
+	return "", false
 }
 
 func expect(r rune, src *Tokenizer) error {
@@ -207,19 +920,51 @@ func expect(r rune, src *Tokenizer) error {
 		return err
 	}
 	if tk.Lexeme != r {
-		return NewUnexpectedTokenError(tk)
+		return NewExpectedTokenError(r, tk)
 	}
 	return nil
 }
 
-// A Tokenizer produces Tokens from a .sgoann source.
+// A Tokenizer produces Tokens from a .sgoann source. Once it's done with a
+// source, it can't be used for another one unless it's Reset.
 type Tokenizer struct {
+	// src is the source from byte offset base on. What's consumed of a source
+	// read from r is moved to past, which starts at byte offset pastBase.
 	src         string
+	base        int
+	r           *bufio.Reader
+	past        []byte
+	pastBase    int
 	bytePos     int
 	runePos     int
 	lastLinePos int
 	line        int
 	lookahead   Token
+	// newline is set when a comment spanning lines was skipped as a new line
+	// that is yet to be consumed.
+	newline bool
+	// prev is the last byte consumed.
+	prev byte
+	err  error
+	// comments are those skipped since the last Token other than whitespace
+	// was consumed, which was in line tokenLine.
+	comments  []comment
+	tokenLine int
+	// lines are the byte offsets where the last keptLines lines consumed so
+	// far start, after the first line and the droppedLines after it.
+	lines        []int
+	droppedLines int
+}
+
+// keptLines is how many of the lines it has moved past a Tokenizer reading from
+// a reader keeps for LineText.
+const keptLines = 16
+
+// A comment is one skipped by a Tokenizer, as written, starting at line and
+// ending at endLine.
+type comment struct {
+	text          string
+	line, endLine int
 }
 
 // NewTokenizer returns a Tokenizer for the given .sgoann source.
@@ -227,9 +972,89 @@ func NewTokenizer(src string) *Tokenizer {
 	return &Tokenizer{src: src, line: 1}
 }
 
-// SkipWhite skips until the next non-whitespace character.
+// Reset makes t produce Tokens from src, from the start, as if it were
+// returned by NewTokenizer(src), so that Tokenizers can be reused. Anything
+// left from the previous source, including its errors, is discarded.
+func (t *Tokenizer) Reset(src string) {
+	*t = Tokenizer{src: src, line: 1}
+}
+
+// NewReaderTokenizer returns a Tokenizer for the .sgoann source read from r,
+// which is read as Tokens are needed, through a bufio.Reader unless it is one
+// already.
+func NewReaderTokenizer(r io.Reader) *Tokenizer {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Tokenizer{r: br, line: 1}
+}
+
+// rest returns the source read so far that isn't consumed yet.
+func (t *Tokenizer) rest() string {
+	return t.src[t.bytePos-t.base:]
+}
+
+// more reads more of the source, if it's read from a reader that isn't
+// exhausted yet, and reports whether it did. A reading error other than
+// io.EOF is kept in t.err.
+func (t *Tokenizer) more() bool {
+	if t.r == nil {
+		return false
+	}
+	t.past = append(t.past, t.src[:t.bytePos-t.base]...)
+	if t.droppedLines > 0 && t.lines[0] > t.pastBase {
+		t.past = append([]byte(nil), t.past[t.lines[0]-t.pastBase:]...)
+		t.pastBase = t.lines[0]
+	}
+	t.src = t.rest()
+	t.base = t.bytePos
+	var buf [4096]byte
+	for {
+		n, err := t.r.Read(buf[:])
+		t.src += string(buf[:n])
+		if err != nil {
+			t.r = nil
+			if err != io.EOF {
+				t.err = err
+			}
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+// ensure reads the source until at least n bytes of it aren't consumed, or
+// it ends, and returns the rest.
+func (t *Tokenizer) ensure(n int) string {
+	for len(t.rest()) < n && t.more() {
+	}
+	return t.rest()
+}
+
+// restUntil reads the source until the rest contains sep, or it ends, and
+// returns the rest and the index of sep in it, or -1.
+func (t *Tokenizer) restUntil(sep string, from int) (string, int) {
+	for {
+		rest := t.rest()
+		if i := strings.Index(rest[from:], sep); i >= 0 {
+			return rest, from + i
+		}
+		if !t.more() {
+			return t.rest(), -1
+		}
+	}
+}
+
+// SkipWhite skips until the next non-whitespace character. Comments are
+// skipped too.
 func (t *Tokenizer) SkipWhite() {
 	for {
+		if t.skipComment() {
+			continue
+		}
 		tk, err := t.Peek()
 		if err != nil || !unicode.IsSpace(tk.Lexeme) {
 			return
@@ -238,9 +1063,13 @@ func (t *Tokenizer) SkipWhite() {
 	}
 }
 
-// SkipWhite until the next new line or non-whitespace character.
+// SkipWhite until the next new line or non-whitespace character. Comments are
+// skipped too; one that spans lines stops like a new line would.
 func (t *Tokenizer) SkipWhiteUntilLine() {
 	for {
+		if t.skipComment() {
+			continue
+		}
 		tk, err := t.Peek()
 		if err != nil || tk.Lexeme == '\n' || !unicode.IsSpace(tk.Lexeme) {
 			return
@@ -249,21 +1078,204 @@ func (t *Tokenizer) SkipWhiteUntilLine() {
 	}
 }
 
-func (t *Tokenizer) empty() bool {
-	return t.bytePos >= len(t.src)
+// skipComment skips the comment starting at the current position, if any, and
+// reports whether it did.
+func (t *Tokenizer) skipComment() bool {
+	if t.newline || t.err != nil {
+		return false
+	}
+	rest := t.ensure(len("//"))
+	var end int
+	switch {
+	case strings.HasPrefix(rest, "//"):
+		rest, end = t.restUntil("\n", len("//"))
+		if end < 0 {
+			end = len(rest)
+		}
+		if i := strings.IndexByte(rest[:end], '\r'); i >= 0 {
+			end = i
+		}
+	case strings.HasPrefix(rest, "/*"):
+		rest, end = t.restUntil("*/", len("/*"))
+		if end < 0 {
+			if t.err == nil {
+				t.err = NewUnterminatedCommentError(t.line, t.col())
+			}
+			return false
+		}
+		end += len("*/")
+	default:
+		return false
+	}
+	t.lookahead = Token{}
+	line := t.line
+	t.advance(rest[:end])
+	t.comments = append(t.comments, comment{text: rest[:end], line: line, endLine: t.line})
+	t.newline = strings.ContainsAny(rest[:end], "\r\n") && strings.HasPrefix(rest, "/*")
+	return true
+}
+
+// consumed forgets the comments skipped so far, once a Token other than
+// whitespace is consumed.
+func (t *Tokenizer) consumed() {
+	t.comments = nil
+	t.tokenLine = t.line
+}
+
+// docComment returns the text of the unconsumed comments, on lines of their
+// own, that end at the given line or the one before.
+func (t *Tokenizer) docComment(line int) string {
+	start := len(t.comments)
+	for next := line; start > 0; start-- {
+		c := t.comments[start-1]
+		if c.line == t.tokenLine || c.endLine < next-1 {
+			break
+		}
+		next = c.line
+	}
+	return commentText(t.comments[start:])
+}
+
+// trailingComment returns the text of the comments skipped after the last
+// Token consumed, in its same line.
+func (t *Tokenizer) trailingComment() string {
+	var trailing []comment
+	for _, c := range t.comments {
+		if c.line == t.tokenLine {
+			trailing = append(trailing, c)
+		}
+	}
+	return commentText(trailing)
+}
+
+// commentText returns the text of the given comments, one after the other,
+// without their // or /* */, the space after //, the spaces around the text
+// in /* */ and those at the end of each line.
+func commentText(comments []comment) string {
+	var lines []string
+	for _, c := range comments {
+		text := c.text
+		if strings.HasPrefix(text, "//") {
+			text = strings.TrimPrefix(text[len("//"):], " ")
+		} else {
+			text = strings.TrimSpace(strings.TrimSuffix(text[len("/*"):], "*/"))
+		}
+		text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// advance moves the current position past s, which must be next in the
+// source. Lines may end with "\n", "\r\n" or "\r"; s must not split a
+// "\r\n".
+func (t *Tokenizer) advance(s string) {
+	for i, r := range s {
+		t.runePos++
+		if r == '\n' || r == '\r' && !strings.HasPrefix(s[i+1:], "\n") {
+			t.line++
+			t.lastLinePos = t.runePos
+			t.lines = append(t.lines, t.bytePos+i+1)
+			if len(t.lines) > keptLines {
+				t.lines = t.lines[1:]
+				t.droppedLines++
+			}
+		}
+	}
+	t.bytePos += len(s)
+	if len(s) > 0 {
+		t.prev = s[len(s)-1]
+	}
+}
+
+// LineText returns the text of the given line of the source, numbered from 1,
+// for showing where an error is. It returns "" for lines out of the source, and
+// in one read from a reader, for those before the last 16 moved past.
+func (t *Tokenizer) LineText(line int) string {
+	if line < 1 {
+		return ""
+	}
+	start, n := 0, 1
+	if k := line - 2 - t.droppedLines; k >= 0 && len(t.lines) > 0 {
+		if k >= len(t.lines) {
+			k = len(t.lines) - 1
+		}
+		start, n = t.lines[k], k+2+t.droppedLines
+	} else if k < 0 && t.pastBase > 0 {
+		return ""
+	}
+	for {
+		text := t.readFrom(start)
+		end := strings.IndexAny(text, "\r\n")
+		if end == -1 || text[end] == '\r' && end+1 == len(text) {
+			// The line, or its "\r\n", may go on past what's read.
+			if t.more() {
+				continue
+			}
+		}
+		if end == -1 {
+			if n == line {
+				return text
+			}
+			return ""
+		}
+		if n == line {
+			return text[:end]
+		}
+		start += end + 1
+		if strings.HasPrefix(text[end:], "\r\n") {
+			start++
+		}
+		n++
+	}
+}
+
+// readFrom returns the source read so far from byte offset start on.
+func (t *Tokenizer) readFrom(start int) string {
+	if start >= t.base {
+		return t.src[start-t.base:]
+	}
+	return string(t.past[start-t.pastBase:]) + t.src
 }
 
 // Peek returns the next Token without consuming it.
 func (t *Tokenizer) Peek() (Token, error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+	if t.newline {
+		// A skipped comment spanning lines; it has no size, as it's already
+		// consumed.
+		return Token{
+			Lexeme:  '\n',
+			BytePos: t.bytePos,
+			RunePos: t.runePos,
+			Line:    t.line,
+			Col:     t.col(),
+		}, nil
+	}
 	if t.lookahead.Size > 0 {
 		return t.lookahead, nil
 	}
-	if t.empty() {
+	rest := t.ensure(utf8.UTFMax)
+	if len(rest) == 0 {
+		if t.err != nil {
+			return Token{}, t.err
+		}
 		return Token{}, io.EOF
 	}
-	r, size := utf8.DecodeRuneInString(t.src[t.bytePos:])
+	r, size := utf8.DecodeRuneInString(rest)
 	if r == utf8.RuneError {
-		return Token{}, NewUTF8Error(t.line, t.col())
+		return Token{}, NewUTF8Error(t.line, t.col(), t.bytePos)
+	}
+	if r == '\r' {
+		// "\r\n" and a lone "\r" end a line just like "\n".
+		r = '\n'
+		if strings.HasPrefix(rest[size:], "\n") {
+			size++
+		}
 	}
 	tk := Token{
 		Lexeme:  r,
@@ -287,21 +1299,78 @@ func (t *Tokenizer) Next() (Token, error) {
 	if err != nil {
 		return Token{}, err
 	}
+	t.newline = false
 	if t.lookahead.Size > 0 {
 		t.lookahead = Token{}
 	}
 	if tk.Size > 0 {
-		t.runePos++
-		t.bytePos += tk.Size
-		if tk.Lexeme == '\n' {
-			t.line++
-			t.lastLinePos = t.runePos
-		}
+		t.advance(t.rest()[:tk.Size])
+	}
+	if !unicode.IsSpace(tk.Lexeme) {
+		t.consumed()
 	}
 	return tk, nil
 }
 
-// A Token is a .sgoann token from a source.
+// NextWhile consumes Tokens while pred returns true for their Lexemes, and
+// returns those as a string, along with the first Token it stopped at.
+func (t *Tokenizer) NextWhile(pred func(rune) bool) (string, Token, error) {
+	first, err := t.Peek()
+	if err != nil {
+		return "", Token{}, err
+	}
+	var lexemes strings.Builder
+	for {
+		// Runes that are Tokens by themselves, as all but line endings and
+		// encoding errors are, are consumed in bulk.
+		if !t.newline && t.err == nil {
+			rest := t.rest()
+			n, done := 0, false
+			for n < len(rest) && utf8.FullRuneInString(rest[n:]) {
+				r, size := utf8.DecodeRuneInString(rest[n:])
+				if r == utf8.RuneError || r == '\r' {
+					break
+				}
+				if !pred(r) {
+					done = true
+					break
+				}
+				n += size
+			}
+			if n > 0 {
+				t.lookahead = Token{}
+				lexemes.WriteString(rest[:n])
+				if tokens := strings.TrimRightFunc(rest[:n], unicode.IsSpace); tokens != "" {
+					t.advance(tokens)
+					t.consumed()
+					t.advance(rest[len(tokens):n])
+				} else {
+					t.advance(rest[:n])
+				}
+			}
+			if done {
+				break
+			}
+		}
+
+		tk, err := t.Peek()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", Token{}, err
+		}
+		if !pred(tk.Lexeme) {
+			break
+		}
+		t.Next()
+		lexemes.WriteRune(tk.Lexeme)
+	}
+	return lexemes.String(), first, nil
+}
+
+// A Token is a .sgoann token from a source. Line endings, either "\n", "\r\n"
+// or "\r", are all Tokens whose Lexeme is '\n'.
 type Token struct {
 	Lexeme  rune
 	Line    int
@@ -311,35 +1380,164 @@ type Token struct {
 	RunePos int
 }
 
-// UTF8Error is a UTF-8 encoding error at the given position.
+// PanicError reports a panic while parsing a .sgoann source, at the given
+// position, which is a bug. Value is what was passed to panic.
+type PanicError struct {
+	Line    int
+	Col     int
+	BytePos int
+	Value   interface{}
+}
+
+// Error implements the error interface.
+func (err PanicError) Error() string {
+	return fmt.Sprintf("internal error parsing at %d:%d (byte offset %d): %v", err.Line, err.Col, err.BytePos, err.Value)
+}
+
+// UTF8Error is a UTF-8 encoding error at the given position. BytePos is the
+// offset of the invalid byte in the source.
 type UTF8Error struct {
-	Line int
-	Col  int
+	Line    int
+	Col     int
+	BytePos int
 }
 
 // NewUTF8Error returns a UTF8Error.
-func NewUTF8Error(line, col int) UTF8Error {
-	return UTF8Error{line, col}
+func NewUTF8Error(line, col, bytePos int) UTF8Error {
+	return UTF8Error{line, col, bytePos}
 }
 
 // Error implements the error interface.
 func (err UTF8Error) Error() string {
-	return fmt.Sprintf("invalid UTF-8 character starting at %d:%d", err.Line, err.Col)
+	return fmt.Sprintf("invalid UTF-8 character starting at %d:%d (byte offset %d)", err.Line, err.Col, err.BytePos)
+}
+
+// UnterminatedCommentError is a /* comment without its closing */, starting
+// at the given position.
+type UnterminatedCommentError struct {
+	Line int
+	Col  int
+}
+
+// NewUnterminatedCommentError returns an UnterminatedCommentError.
+func NewUnterminatedCommentError(line, col int) UnterminatedCommentError {
+	return UnterminatedCommentError{line, col}
+}
+
+// Error implements the error interface.
+func (err UnterminatedCommentError) Error() string {
+	return fmt.Sprintf("comment starting at %d:%d not terminated", err.Line, err.Col)
+}
+
+// DefaultError reports an Item with a default other than "default ?" or
+// "default !". Token is the Item's Name, and Name is as written.
+type DefaultError struct {
+	Token  Token
+	Name   string
+	Policy string
+}
+
+// Error implements the error interface.
+func (err DefaultError) Error() string {
+	return fmt.Sprintf("invalid default for %s: %q; must be ? or !", err.Name, err.Policy)
+}
+
+// DepthError reports a block, or a bracket in a Type, nested deeper than Max
+// levels. Token is its opening '{', '(' or '['.
+type DepthError struct {
+	Token Token
+	Max   int
+}
+
+// Error implements the error interface.
+func (err DepthError) Error() string {
+	return fmt.Sprintf("nested deeper than %d levels at %d:%d", err.Max, err.Token.Line, err.Token.Col)
+}
+
+// An ErrorList is a list of errors found while parsing a .sgoann source, in
+// the order they were found.
+type ErrorList []error
+
+// Error implements the error interface.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// InvalidTypeError reports an Item whose Type isn't a valid SGo type. Token is
+// the Item's Name.
+type InvalidTypeError struct {
+	Token Token
+	Name  string
+	Type  string
+	Err   error
+}
+
+// Error implements the error interface.
+func (err InvalidTypeError) Error() string {
+	return fmt.Sprintf("invalid type for %s at %d:%d: %q: %v", err.Name, err.Token.Line, err.Token.Col, err.Type, err.Err)
+}
+
+// DuplicateError reports an identifier annotated more than once, without build
+// constraints to choose between its definitions. First is the Name of its first
+// Item, and Token that of the repeated one.
+type DuplicateError struct {
+	Name  string
+	First Token
+	Token Token
+}
+
+// Error implements the error interface.
+func (err DuplicateError) Error() string {
+	return fmt.Sprintf("%s annotated again at %d:%d; first annotated at %d:%d", err.Name, err.Token.Line, err.Token.Col, err.First.Line, err.First.Col)
+}
+
+// OptionalTypeError reports a ? on a type that can't be nil, as in ?int.
+// Token is the ?, and Type is how the type starts, like "int" or "[4]".
+type OptionalTypeError struct {
+	Token Token
+	Type  string
+}
+
+// NewOptionalTypeError returns an OptionalTypeError.
+func NewOptionalTypeError(tk Token, typ string) OptionalTypeError {
+	return OptionalTypeError{tk, typ}
+}
+
+// Error implements the error interface.
+func (err OptionalTypeError) Error() string {
+	return fmt.Sprintf("optional type at %d:%d: %s can't be nil", err.Token.Line, err.Token.Col, err.Type)
 }
 
 // UnexpectedTokenError reports an unexpected token while parsing a .sgoann
-// source.
+// source. If a specific token was expected instead, like the ')' closing a
+// Receiver, it's Expected; otherwise, Expected is 0.
 type UnexpectedTokenError struct {
-	Token Token
+	Token    Token
+	Expected rune
 }
 
 // NewUnexpectedTokenError returns an UnexpectedTokenError.
 func NewUnexpectedTokenError(tk Token) UnexpectedTokenError {
-	return UnexpectedTokenError{tk}
+	return UnexpectedTokenError{Token: tk}
+}
+
+// NewExpectedTokenError returns an UnexpectedTokenError for tk, found where
+// expected was.
+func NewExpectedTokenError(expected rune, tk Token) UnexpectedTokenError {
+	return UnexpectedTokenError{Token: tk, Expected: expected}
 }
 
 // Error implements the error interface.
 func (err UnexpectedTokenError) Error() string {
+	if err.Expected != 0 {
+		return fmt.Sprintf("expected '%v' but found '%v' at %d:%d", string(err.Expected), string(err.Token.Lexeme), err.Token.Line, err.Token.Col)
+	}
 	return fmt.Sprintf("unexpected token at %d:%d: '%v'", err.Token.Line, err.Token.Col, string(err.Token.Lexeme))
 }
 
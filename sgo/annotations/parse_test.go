@@ -1,6 +1,15 @@
 package annotations
 
-import "testing"
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
 
 func TestParse(t *testing.T) {
 	type testCase struct {
@@ -9,16 +18,70 @@ func TestParse(t *testing.T) {
 	}
 	cases := []testCase{
 		{
-			input: "  foo  xyz  ;  ( *  bar ) {  ab c \n qux { ñandú poqe{ñ..asd(oan); }\n } \n ",
+			input: "  foo  xyz  ;  ( *  bar ) {  ab c \n qux { ñandú poqe{ñ..asd(oan)}; }\n } \n ",
 			output: map[string]string{
 				"foo":              "xyz",
 				"(*bar).ab":        "c",
-				"(*bar).qux.ñandú": "poqe{ñ..asd(oan)",
+				"(*bar).qux.ñandú": "poqe{ñ..asd(oan)}",
+			},
+		},
+		{
+			input: `// Package notes.
+/* A block
+   comment. */
+Open /* inline */ func(name string) (*File \ error)
+Get func(url string) *string // a // here ends the type
+Tag struct{ URL string "url:\"http://x\"" } // a tag
+(*File) { // trailing
+	// Before a member.
+	Close func() error
+	/* Last. */
+} /* after
+the block */ Name string
+`,
+			output: map[string]string{
+				"Open":          `func(name string) (*File \ error)`,
+				"Get":           "func(url string) *string",
+				"Tag":           `struct{ URL string "url:\"http://x\"" }`,
+				"(*File).Close": "func() error",
+				"Name":          "string",
+			},
+		},
+		{
+			input: `Outer {
+	Inner {
+		Method func()
+		Deeper { Field *T }
+	}
+}
+Empty {}
+Nested {
+	Blank { }
+}
+`,
+			output: map[string]string{
+				"Outer.Inner.Method":       "func()",
+				"Outer.Inner.Deeper.Field": "*T",
+				"Empty":                    "",
+				"Nested.Blank":             "",
+			},
+		},
+		{
+			input: "Outer { Inner { Method func() } }\n",
+			output: map[string]string{
+				"Outer.Inner.Method": "func()",
+			},
+		},
+		{
+			input: "A { B { C { D int } }; E { } }\n",
+			output: map[string]string{
+				"A.B.C.D": "int",
+				"A.E":     "",
 			},
 		},
 	}
 	for i, c := range cases {
-		anns, err := parseList(NewTokenizer(c.input))
+		anns, err := parseList(NewTokenizer(c.input), nil, false)
 		if err != nil {
 			t.Errorf("case %d: unexpected error: %v", i, err)
 		} else if !mapEqual(c.output, anns) {
@@ -27,6 +90,1072 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseStrayBrace(t *testing.T) {
+	for _, c := range []struct {
+		src       string
+		line, col int
+	}{
+		{"(*File) {\n\tClose func() error\n}\n}\nOpen func() *File\n", 4, 1},
+		{"A int\n  }", 2, 3},
+		{"A int\n123 x\n", 2, 1},
+	} {
+		_, err := Parse(c.src)
+		if tkErr, ok := err.(UnexpectedTokenError); !ok || tkErr.Token.Line != c.line || tkErr.Token.Col != c.col {
+			t.Errorf("%q: expected unexpected token at %d:%d, got %v", c.src, c.line, c.col, err)
+		}
+	}
+
+	// A block still ends at its '}'.
+	ann, err := Parse("(*File) { Close func() error; }\nOpen func() *File\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ann.Lookup("Open").Type(); !ok {
+		t.Errorf("expected Open after the block to be annotated")
+	}
+}
+
+func TestParseTrailingBlockComments(t *testing.T) {
+	ann, err := Parse(`A int /* one */
+B string /* multi
+line */ C bool
+T {
+	D int /* in a block */
+	E *T /* spanning
+	lines */ F error
+	G struct{ x int /* skipped */ }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]struct{ def, doc string }{
+		"A":   {"int", "one"},
+		"B":   {"string", "multi\nline"},
+		"C":   {"bool", ""},
+		"T.D": {"int", "in a block"},
+		"T.E": {"*T", "spanning\n\tlines"},
+		"T.F": {"error", ""},
+		"T.G": {"struct{ x int }", ""},
+	} {
+		if def, _ := ann.Definition(name); def != expected.def {
+			t.Errorf("%s: expected %q, got %q", name, expected.def, def)
+		}
+		if doc := ann.Doc(name); doc != expected.doc {
+			t.Errorf("%s: expected doc %q, got %q", name, expected.doc, doc)
+		}
+	}
+
+	// As after a new line, anything but a comment in the same line is an
+	// error.
+	_, err = Parse("A int /* one */ B int\n")
+	if tkErr, ok := err.(UnexpectedTokenError); !ok || tkErr.Token.Line != 1 || tkErr.Token.Col != 17 {
+		t.Errorf("expected unexpected token at 1:17, got %v", err)
+	}
+	if _, err := Parse("A struct{ x int /* never\nclosed\n"); err != NewUnterminatedCommentError(1, 17) {
+		t.Errorf("expected unterminated comment error at 1:17, got %v", err)
+	}
+}
+
+func TestParseUnterminatedComment(t *testing.T) {
+	_, err := Parse("A string\n/* never\nclosed\n")
+	if err != NewUnterminatedCommentError(2, 1) {
+		t.Errorf("expected unterminated comment error at 2:1, got %v", err)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	ann, err := ParseAll(`A string
+(*B x) func()
+C
+D int; (**E) x; F bool
+T {
+	Ok *int
+	Bad default nil
+	Also *string
+}
+} G float64
+H uint
+`)
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %v", err)
+	}
+	var positions []string
+	for _, err := range errs {
+		if tkErr, ok := err.(UnexpectedTokenError); ok {
+			positions = append(positions, fmt.Sprintf("%d:%d", tkErr.Token.Line, tkErr.Token.Col))
+		} else {
+			positions = append(positions, err.Error())
+		}
+	}
+	expected := []string{"2:5", "3:2", "4:10", `invalid default for Bad: "nil"; must be ? or !`, "10:1"}
+	if !reflect.DeepEqual(positions, expected) {
+		t.Errorf("expected errors at %v, got %v", expected, positions)
+	}
+	if !strings.HasSuffix(err.Error(), "(and 4 more errors)") {
+		t.Errorf("unexpected message: %v", err)
+	}
+
+	var names []string
+	for _, name := range ann.Names() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if expected := []string{"A", "D", "F", "H", "T.Also", "T.Ok"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected items %v, got %v", expected, names)
+	}
+
+	if _, err := ParseAll("A string\nB int\n"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseAll("A string\nB { C int\n"); err == nil || !strings.Contains(err.Error(), EOF.Error()) {
+		t.Errorf("expected unexpected end of file, got %v", err)
+	}
+}
+
+func TestParsePos(t *testing.T) {
+	ann, err := Parse(`Open func(name string) (*File \ error)
+  (*File) {
+	Close func() error
+	Stat func() (FileInfo \ error) @build !windows
+	Stat func() (?FileInfo \ error) @build windows
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name      string
+		line, col int
+	}{
+		{"Open", 1, 1},
+		{"(*File)", 2, 3},
+		{"(*File).Close", 3, 2},
+		{"(*File).Stat", 4, 2},
+	} {
+		tk, ok := ann.Pos(c.name)
+		if !ok || tk.Line != c.line || tk.Col != c.col {
+			t.Errorf("%s: expected position %d:%d, got %d:%d (found: %v)", c.name, c.line, c.col, tk.Line, tk.Col, ok)
+		}
+	}
+	if tk, ok := ann.Lookup("(*File)").Pos("Close"); !ok || tk.Line != 3 {
+		t.Errorf("expected Close at line 3 from (*File), got %+v (found: %v)", tk, ok)
+	}
+	if _, ok := ann.Pos("Missing"); ok {
+		t.Errorf("expected no position for a missing name")
+	}
+
+	// Malformed items have no positions.
+	ann, _ = ParseAll("A string\nB {\n\tC int\n\tD\n} x\n")
+	if _, ok := ann.Pos("A"); !ok {
+		t.Errorf("expected position for A")
+	}
+	for _, name := range []string{"B", "B.C", "B.D"} {
+		if tk, ok := ann.Pos(name); ok {
+			t.Errorf("expected no position for %s, got %+v", name, tk)
+		}
+	}
+}
+
+func TestParseRawType(t *testing.T) {
+	ann, err := Parse("Open func (name string)  (*File \\ error)  \n" +
+		"T default ?\n" +
+		"(*File) {\n\tClose   func() error\t;\n}\n" +
+		"Getenv func(key string) string @build !windows \n" +
+		"Getenv func(key string) ?*string @build windows\n" +
+		"Empty {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, raw, def string
+	}{
+		{"Open", "func (name string)  (*File \\ error)  ", "func (name string)  (*File \\ error)"},
+		{"T", "default ?", "@default ?"},
+		{"(*File).Close", "func() error\t", "func() error"},
+		{"Getenv", "func(key string) string @build !windows \nfunc(key string) ?*string @build windows", "func(key string) string @build !windows\nfunc(key string) ?*string @build windows"},
+	} {
+		if raw, ok := ann.RawType(c.name); !ok || raw != c.raw {
+			t.Errorf("%s: expected raw type %q, got %q (found: %v)", c.name, c.raw, raw, ok)
+		}
+		if def, _ := ann.Definition(c.name); def != c.def {
+			t.Errorf("%s: expected definition %q, got %q", c.name, c.def, def)
+		}
+	}
+	if raw, ok := ann.Lookup("(*File)").RawType("Close"); !ok || raw != "func() error\t" {
+		t.Errorf("expected raw type for Close from (*File), got %q (found: %v)", raw, ok)
+	}
+	for _, name := range []string{"Empty", "(*File)", "Missing"} {
+		if raw, ok := ann.RawType(name); ok {
+			t.Errorf("%s: expected no raw type, got %q", name, raw)
+		}
+	}
+	if _, ok := NewAnnotation(map[string]string{"A": "int"}).RawType("A"); ok {
+		t.Errorf("expected no raw type for an annotation that wasn't parsed")
+	}
+
+	ctx := build.Default
+	ctx.GOOS = "windows"
+	if raw, _ := ann.ForContext(&ctx).RawType("Getenv"); raw != "func(key string) ?*string @build windows" {
+		t.Errorf("expected the windows alternative, got %q", raw)
+	}
+	merged := MergeAnnotations(ann, NewAnnotation(map[string]string{"Open": "func() *File"}))
+	if _, ok := merged.RawType("Open"); ok {
+		t.Errorf("expected no raw type for an overridden definition")
+	}
+	if raw, _ := merged.RawType("T"); raw != "default ?" {
+		t.Errorf("expected raw type for T to be kept, got %q", raw)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	srcs := []string{
+		"Open func(name string) (*File \\ error) // ñandú\n(*File) {\n\tClose func() error /* a\nb */\n\tRead func(b []byte) (int, ?error)\n}\n",
+		strings.Repeat("A string; ", 1000) + "/* " + strings.Repeat("long comment ", 1000) + "*/ B int\n",
+		"A string\n(*B x) func()\n",
+		"A string\n/* never closed\n",
+		"A \xff\n",
+		"A {\n",
+	}
+	for i, src := range srcs {
+		p := &parseState{pos: map[string]Token{}, raw: map[string]string{}, docs: map[string]string{}}
+		expected, expectedErr := parseList(NewTokenizer(src), p, false)
+
+		ann, err := ParseReader(iotest.OneByteReader(strings.NewReader(src)))
+		if err != expectedErr {
+			t.Errorf("case %d: expected error %v, got %v", i, expectedErr, err)
+		}
+		var got map[string]string
+		var pos map[string]Token
+		if ann != nil {
+			got, pos = ann.anns, ann.pos
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("case %d: expected %v, got %v", i, expected, got)
+		}
+		if expected != nil && !reflect.DeepEqual(pos, p.pos) {
+			t.Errorf("case %d: expected positions %v, got %v", i, p.pos, pos)
+		}
+	}
+
+	_, err := ParseReaderWith(ParseOptions{AllowDuplicates: true}, iotest.TimeoutReader(strings.NewReader(strings.Repeat("A string\n", 1000))))
+	if err != iotest.ErrTimeout {
+		t.Errorf("expected reading error, got %v", err)
+	}
+}
+
+type panicReader struct {
+	r io.Reader
+}
+
+func (r panicReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		panic("read past the end")
+	}
+	return n, err
+}
+
+func TestParsePanic(t *testing.T) {
+	if !recoverPanics {
+		t.Skip("built with sgoannpanic")
+	}
+	ann, err := ParseReader(panicReader{iotest.OneByteReader(strings.NewReader("A string\nB int"))})
+	panicErr, ok := err.(PanicError)
+	if !ok || ann != nil {
+		t.Fatalf("expected a PanicError, got %v, %v", ann, err)
+	}
+	if panicErr.Line != 2 || panicErr.Col != 3 || panicErr.Value != "read past the end" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if msg := "internal error parsing at 2:3 (byte offset 11): read past the end"; err.Error() != msg {
+		t.Errorf("expected message %q, got %q", msg, err.Error())
+	}
+}
+
+func TestParseMaxDepth(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("A {\n", n) + strings.Repeat("}\n", n)
+	}
+	bracketed := func(n int) string {
+		return "A " + strings.Repeat("[]func(", n) + strings.Repeat(")", n) + "\n"
+	}
+
+	for _, c := range []struct {
+		src       string
+		max       int
+		line, col int
+	}{
+		{nested(DefaultMaxDepth + 1), 0, DefaultMaxDepth + 1, 3},
+		{nested(3), 2, 3, 3},
+		{bracketed(DefaultMaxDepth + 1), 0, 1, 3 + 7*DefaultMaxDepth},
+		{bracketed(3), 2, 1, 3 + 7*2},
+		{"A func(func([]int))", 2, 1, 13},
+	} {
+		_, err := ParseWith(ParseOptions{MaxDepth: c.max}, c.src)
+		depthErr, ok := err.(DepthError)
+		if !ok {
+			t.Errorf("%.20q: expected a DepthError, got %v", c.src, err)
+			continue
+		}
+		max := c.max
+		if max == 0 {
+			max = DefaultMaxDepth
+		}
+		if depthErr.Max != max || depthErr.Token.Line != c.line || depthErr.Token.Col != c.col {
+			t.Errorf("%.20q: unexpected error: %v", c.src, err)
+		}
+	}
+
+	for _, src := range []string{nested(DefaultMaxDepth), bracketed(DefaultMaxDepth), "A func([]int)"} {
+		if _, err := ParseWith(ParseOptions{ValidateTypes: true}, src); err != nil {
+			t.Errorf("%.20q: %v", src, err)
+		}
+	}
+	if _, err := ParseWith(ParseOptions{MaxDepth: 2}, nested(2)); err != nil {
+		t.Error(err)
+	}
+
+	// The Items after too deep ones are still parsed.
+	ann, err := ParseAllWith(ParseOptions{MaxDepth: 2}, "A func(func([]int))\nB func([]int)\nC {\n\tD {\n\t\tE { F int }\n\t\tH int\n\t}\n}\nG int\n")
+	if list, ok := err.(ErrorList); !ok || len(list) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if expected := map[string]string{"B": "func([]int)", "C.D.H": "int", "G": "int"}; !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, src := range []string{
+		"Open func(name string) (*File \\ error)\nGetenv func(key string) ?string\n",
+		"(*File) {\n\tRead func(b []byte) (n int, err ?error) // Read.\n}\n",
+		"// Config.\nConfig default ?\nP { A int ; B string }\n",
+		"Ordered interface {\n\t~int | ~string\n}\nMax func(x, y T) T @build linux\n",
+		"A [...]int\nB map[string]struct{ C ?*int }\nC /* unterminated",
+		"A {\n\tB {\n\t\tC int\n\t}\n}\n}\n",
+		"A int\r\nB \"quoted\\\"\"\r\n",
+	} {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		for _, opts := range []ParseOptions{{}, {ValidateTypes: true}, {MaxDepth: 4}} {
+			ann, err := ParseWith(opts, src)
+			if _, ok := err.(PanicError); ok {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+			_, allErr := ParseAllWith(opts, src)
+			list, _ := allErr.(ErrorList)
+			for _, err := range list {
+				if _, ok := err.(PanicError); ok {
+					t.Fatalf("%+v, all: %v", opts, err)
+				}
+			}
+			if err == nil && allErr != nil {
+				t.Errorf("%+v: Parse succeeded but ParseAll failed: %v", opts, allErr)
+			}
+			if err == nil && opts.ValidateTypes {
+				// Marshal and PrettyType must cope with anything that's
+				// parsed.
+				ann.Marshal()
+				for _, name := range ann.Names() {
+					if typ, ok := ann.Lookup(name).Type(); ok {
+						PrettyType(typ)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestParseValidateTypes(t *testing.T) {
+	src := `Open func(name string) (*File \ error)
+(*File) {
+	Read (*File) func(b []byte) (n int, err ?error)
+	Stat func() (FileInfo \ error) @build !windows
+	Fd uintptr
+	Next ?*File
+	Sync @nonempty
+}
+Files map[string][]?*os.File
+Ch <-chan struct{ X int }
+(*Dir) default !
+Ordered interface{ ~int | ~int8 | ~float64 | ~string }
+Bytes interface{ ~[]byte | ~string }
+Command func(name string, arg ...string) *Cmd
+(*Logger) {
+	Logf (*Logger) func(format string, args ...interface{})
+}
+Apply func(fs ...func(x int, opts ...?*Option)) [...]int
+`
+	if _, err := ParseWith(ParseOptions{ValidateTypes: true}, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range []struct {
+		src  string
+		name string
+		line int
+	}{
+		{"A int\nB func(x int\n", "B", 2},
+		{"T {\n\tA int\n\tB 1 + 2\n}\n", "T.B", 3},
+		{"(*T) {\n\tM (*T) func( \\ error\n}\n", "(*T).M", 2},
+		{"Number interface{ ~int | ~ }\n", "Number", 1},
+		{"A int\nNilable interface{ ~int | ?*T }\n", "Nilable", 2},
+		{"Command func(arg ...string, name string) *Cmd\n", "Command", 1},
+		{"Join func(a, b ...string) string\n", "Join", 1},
+		{"Apply func(f func(xs ...int, y int))\n", "Apply", 1},
+		{"(*T) {\n\tM (*T) func(\n\t\targs ...int,\n\t\tlast bool,\n\t)\n}\n", "(*T).M", 2},
+		{"Fields struct{ X ...int }\n", "Fields", 1},
+	} {
+		// Without validation, anything goes.
+		if _, err := Parse(c.src); err != nil {
+			t.Errorf("%q: unexpected error without validation: %v", c.src, err)
+		}
+		_, err := ParseWith(ParseOptions{ValidateTypes: true}, c.src)
+		typeErr, ok := err.(InvalidTypeError)
+		if !ok {
+			t.Errorf("%q: expected InvalidTypeError, got %v", c.src, err)
+			continue
+		}
+		if typeErr.Name != c.name || typeErr.Token.Line != c.line {
+			t.Errorf("%q: expected error for %s at line %d, got %v", c.src, c.name, c.line, typeErr)
+		}
+	}
+}
+
+func TestParseLineEndings(t *testing.T) {
+	src := "// Mixed.\r\nOpen func(name string) (*File \\ error)\r\n(*File) {\r\tClose func() error // note\r\n\t/* a\rb */\n\tRead func(b []byte) (int, ?error)\r}\r\nBad x y\n"
+	ann, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Open":          `func(name string) (*File \ error)`,
+		"(*File).Close": "func() error",
+		"(*File).Read":  "func(b []byte) (int, ?error)",
+		"Bad":           "x y",
+	}
+	if !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+	for name, line := range map[string]int{"Open": 2, "(*File)": 3, "(*File).Close": 4, "(*File).Read": 7, "Bad": 9} {
+		if tk, _ := ann.Pos(name); tk.Line != line {
+			t.Errorf("%s: expected line %d, got %d", name, line, tk.Line)
+		}
+	}
+
+	if ann, err := Parse("A func( \r\n"); err != nil || ann.anns["A"] != "func(" {
+		t.Errorf("expected type without the line ending, got %q (error: %v)", ann.anns["A"], err)
+	}
+	_, err = Parse("A int\r\n(**T) x\r\n")
+	if tkErr, ok := err.(UnexpectedTokenError); !ok || tkErr.Token.Line != 2 || tkErr.Token.Col != 3 {
+		t.Errorf("expected error at 2:3, got %v", err)
+	}
+}
+
+func TestParser(t *testing.T) {
+	src := `Open func(name string) (*File \ error)
+(*File) {
+	Read func(b []byte) (n int, err ?error)
+	Empty {}
+}
+Pipe func() (r, w *File, err error) @build unix
+Pipe func() (r, w *File, err error) @build windows
+Config default ?
+`
+	type visited struct {
+		name, def string
+		line, col int
+	}
+	var got []visited
+	err := NewParser(ParseOptions{ValidateTypes: true}).Parse(src, func(name, def string, pos Token) error {
+		got = append(got, visited{name, def, pos.Line, pos.Col})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []visited{
+		{"Open", `func(name string) (*File \ error)`, 1, 1},
+		{"(*File).Read", "func(b []byte) (n int, err ?error)", 3, 2},
+		{"(*File).Empty", "", 4, 2},
+		{"Pipe", "func() (r, w *File, err error) @build unix", 6, 1},
+		{"Pipe", "func() (r, w *File, err error) @build windows", 7, 1},
+		{"Config", "@default ?", 8, 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// Parse collects the same Items.
+	ann, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anns := map[string]string{}
+	for _, v := range got {
+		addDefs(anns, map[string]string{v.name: v.def})
+	}
+	if !mapEqual(anns, ann.anns) {
+		t.Errorf("expected %q, got %q", ann.anns, anns)
+	}
+
+	// An error from visit stops parsing, and is returned as is.
+	calls := 0
+	err = (&Parser{}).Parse(src, func(name, def string, pos Token) error {
+		calls++
+		if name == "(*File).Read" {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != io.EOF || calls != 2 {
+		t.Errorf("expected io.EOF after 2 calls, got %v after %d", err, calls)
+	}
+
+	// Parsing errors are returned as Parse returns them, after visiting the
+	// Items before them.
+	calls = 0
+	err = (&Parser{}).Parse("A int\nB int\nA string\n(**T) x\n", func(name, def string, pos Token) error {
+		calls++
+		return nil
+	})
+	if _, ok := err.(DuplicateError); !ok || calls != 2 {
+		t.Errorf("expected a DuplicateError after 2 calls, got %v after %d", err, calls)
+	}
+	err = NewParser(ParseOptions{AllowDuplicates: true}).ParseReader(strings.NewReader("A int\nA string\n(**T) x\n"), func(name, def string, pos Token) error {
+		return nil
+	})
+	if tkErr, ok := err.(UnexpectedTokenError); !ok || tkErr.Token.Line != 3 || tkErr.Token.Col != 3 {
+		t.Errorf("expected an error at 3:3, got %v", err)
+	}
+}
+
+func TestParseBrackets(t *testing.T) {
+	ann, err := ParseWith(ParseOptions{ValidateTypes: true}, `Key [16]byte; Vals [...]int
+Size [unsafe.Sizeof(struct{ a int; b string }{})]byte; Next int
+Config struct {
+	Name string // Not part of the type.
+
+	OnChange func(old, new ?*Config) \ error
+	Tags map[string][]string "sep:\";\""
+} @nonempty
+Walk func(
+	root string,
+	fn func(path string, err ?error) error,
+) error
+Table {
+	Rows [2]struct{ Cells []struct {
+		Format func() (string \ error) }
+	}
+	Last int
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Key":        "[16]byte",
+		"Vals":       "[...]int",
+		"Size":       "[unsafe.Sizeof(struct{ a int; b string }{})]byte",
+		"Next":       "int",
+		"Config":     `struct { Name string; OnChange func(old, new ?*Config) \ error; Tags map[string][]string "sep:\";\""; } @nonempty`,
+		"Walk":       "func( root string, fn func(path string, err ?error) error, ) error",
+		"Table.Rows": `[2]struct{ Cells []struct { Format func() (string \ error) }; }`,
+		"Table.Last": "int",
+	}
+	if !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+	for name, line := range map[string]int{"Config": 3, "Walk": 9, "Table.Last": 17} {
+		if tk, _ := ann.Pos(name); tk.Line != line {
+			t.Errorf("%s: expected line %d, got %d", name, line, tk.Line)
+		}
+	}
+
+	// Inline struct and interface literals with ';' in them, as in config
+	// constructors.
+	ann, err = ParseWith(ParseOptions{ValidateTypes: true}, `New func(opts struct{ Timeout int; Retries int }) *Client; Close func() error
+Dial func(c interface{ Addr() string; Conn() ?net.Conn }) (*Client \ error)
+(*Cmd) {
+	Run func() ?error
+	Start func(hooks struct{ Pre func() error; Post func(err ?error) }) error
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]string{
+		"New":          "func(opts struct{ Timeout int; Retries int }) *Client",
+		"Close":        "func() error",
+		"Dial":         `func(c interface{ Addr() string; Conn() ?net.Conn }) (*Client \ error)`,
+		"(*Cmd).Run":   "func() ?error",
+		"(*Cmd).Start": "func(hooks struct{ Pre func() error; Post func(err ?error) }) error",
+	}
+	if !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+	if parsed, err := Parse(ann.Marshal()); err != nil || !parsed.Equal(ann) {
+		t.Errorf("expected the same after a round trip through Marshal, got %q (error: %v)", parsed.Diff(ann), err)
+	}
+
+	// Where Go would insert a ';' in parentheses or square brackets, the new
+	// line still ends the Type, instead of the rest of the source.
+	ann, err = Parse("Open func(name string\nClose func() error\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"Open": "func(name string", "Close": "func() error"}; !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+	if _, err := Parse("T {\n\tA struct {\n}\n"); err != EOF {
+		t.Errorf("expected unexpected end of file for an unclosed block, got %v", err)
+	}
+}
+
+func TestParseSemicolons(t *testing.T) {
+	for _, src := range []string{
+		"A int; B string",
+		"A int ;B string",
+		"A int ; B string",
+		"A int\t;\tB string\t",
+		"A int;B string;",
+		"A int ; B string ; ",
+		"A int;;B string",
+		"A int ; ; B string",
+		"A int ;\n\n; B string ;\n",
+		"A int  ;  \n  B string",
+		"A int; /* b */ B string",
+	} {
+		ann, err := ParseWith(ParseOptions{ValidateTypes: true}, src)
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if expected := map[string]string{"A": "int", "B": "string"}; !mapEqual(expected, ann.anns) {
+			t.Errorf("%q: expected %q, got %q", src, expected, ann.anns)
+		}
+	}
+
+	ann, err := Parse("P {\n\tA int ; ;B string ;\n\tC default ? ; }\n(*T) {} ; D bool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"P.A": "int", "P.B": "string", "P.C": "@default ?", "(*T)": "", "D": "bool"}
+	if !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %q, got %q", expected, ann.anns)
+	}
+	for name, col := range map[string]int{"P.A": 2, "P.B": 11, "D": 11} {
+		if tk, _ := ann.Pos(name); tk.Col != col {
+			t.Errorf("%s: expected column %d, got %d", name, col, tk.Col)
+		}
+	}
+
+	// A terminator alone doesn't start an Item.
+	if _, err := Parse("; A int"); err == nil {
+		t.Error("expected an error for a leading ';'")
+	}
+}
+
+func TestParseDocs(t *testing.T) {
+	ann, err := Parse(`// Package notes, not attached.
+
+// Open opens a file.
+/* It may fail. */
+Open func(name string) (*File \ error)
+(*File) { // Not attached either.
+	// Read reads up to len(b) bytes.
+	Read func(b []byte) (n int, err ?error) // err is nil only at EOF.
+	Close func() error; Sync func() error // Only Sync's.
+
+	// Not attached, as Stat's is closer.
+	//
+	// Stat's.
+	Stat func() ?FileInfo   // twice
+	// Dangling.
+} // After the block.
+Getenv func(key string) string @build !windows // Not on Windows.
+Getenv func(key string) ?*string @build windows
+Name string
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"Open":          "Open opens a file.\nIt may fail.",
+		"(*File)":       "After the block.",
+		"(*File).Read":  "Read reads up to len(b) bytes.\nerr is nil only at EOF.",
+		"(*File).Close": "",
+		"(*File).Sync":  "Only Sync's.",
+		"(*File).Stat":  "Not attached, as Stat's is closer.\n\nStat's.\ntwice",
+		"Getenv":        "Not on Windows.",
+		"Name":          "",
+		"Missing":       "",
+	} {
+		if doc := ann.Doc(name); doc != expected {
+			t.Errorf("%s: expected doc %q, got %q", name, expected, doc)
+		}
+	}
+	if typ, _ := ann.Lookup("(*File)").Lookup("Read").Type(); typ != "func(b []byte) (n int, err ?error)" {
+		t.Errorf("unexpected type with a trailing comment: %q", typ)
+	}
+	if doc := ann.Lookup("(*File)").Doc("Sync"); doc != "Only Sync's." {
+		t.Errorf("looked up: unexpected doc %q", doc)
+	}
+	if doc := ann.Sub("(*File)").Doc("Read"); doc != ann.Doc("(*File).Read") {
+		t.Errorf("Sub: unexpected doc %q", doc)
+	}
+	if doc := (*Annotation)(nil).Doc("Open"); doc != "" {
+		t.Errorf("nil: unexpected doc %q", doc)
+	}
+}
+
+func TestParseEntangledResults(t *testing.T) {
+	for _, src := range []string{
+		"F func() (int \\ error)\n",
+		"F func(f func() (a \\ error)) (b \\ error)\n",
+		"F func() (func() (int \\ error) \\ error)\n",
+		"F func() (a, b int \\ c, d ?error)\n",
+		"F func() (*T \\ ?error)\n",
+	} {
+		if _, err := Parse(src); err != nil {
+			t.Errorf("%q: unexpected error: %v", src, err)
+		}
+	}
+
+	for _, c := range []struct {
+		src       string
+		line, col int
+	}{
+		{"F func() (int \\ error \\ bool)\n", 1, 23},
+		{"A int\nF func(f func() (a \\ b \\ c)) error\n", 2, 24},
+		{"F func() (func() (int \\ error) \\ error \\ bool)\n", 1, 40},
+	} {
+		_, err := Parse(c.src)
+		if tkErr, ok := err.(UnexpectedTokenError); !ok || tkErr.Token.Lexeme != '\\' || tkErr.Token.Line != c.line || tkErr.Token.Col != c.col {
+			t.Errorf("%q: expected error for \\ at %d:%d, got %v", c.src, c.line, c.col, err)
+		}
+	}
+}
+
+func TestParseOptionalTypes(t *testing.T) {
+	for _, src := range []string{
+		"F func(p ?*T, s ?[]int, m ?map[string]int, c ?chan int, f ?func()) ?error\n",
+		"F func(i ?interface{}, r ?io.Reader, t ?T, x ?int64er) (?*T \\ error)\n",
+		"T default ?\n",
+		"F func() *T @default ?\n",
+		"F func() (*T \\ ?error)\n",
+		"F func() \\ ?error\n",
+	} {
+		if _, err := Parse(src); err != nil {
+			t.Errorf("%q: unexpected error: %v", src, err)
+		}
+	}
+
+	// Channels can be nil whatever their direction, which is kept as written.
+	for _, typ := range []string{
+		"func(d Duration) <-chan Time",
+		"func(c chan<- Time, r ?<-chan Time) ?chan<- Time",
+		"func(c ?chan<- <-chan int) ?chan (<-chan int)",
+		"?<-chan Time",
+	} {
+		ann, err := Parse("F " + typ + "\n")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", typ, err)
+		} else if got := ann.anns["F"]; got != typ {
+			t.Errorf("expected %q, got %q", typ, got)
+		}
+	}
+
+	for _, c := range []struct {
+		src       string
+		typ       string
+		line, col int
+	}{
+		{"N ?int\n", "int", 1, 3},
+		{"A int\nF func(s ? string) bool\n", "string", 2, 10},
+		{"F func() (?[4]byte \\ error)\n", "[4]", 1, 11},
+		{"F func(v ?struct{ X int })\n", "struct", 1, 10},
+		{"F func(p ?*T, b ?bool)\n", "bool", 1, 17},
+		{"F func() (?*T \\ ?int)\n", "int", 1, 17},
+	} {
+		_, err := Parse(c.src)
+		if optErr, ok := err.(OptionalTypeError); !ok || optErr.Type != c.typ || optErr.Token.Lexeme != '?' || optErr.Token.Line != c.line || optErr.Token.Col != c.col {
+			t.Errorf("%q: expected error for ?%s at %d:%d, got %v", c.src, c.typ, c.line, c.col, err)
+		}
+	}
+}
+
+func TestParseEmbedded(t *testing.T) {
+	ann, err := Parse(`ReadCloser {
+	io.Reader ?io.Reader
+	Closer Closer
+	Inner {
+		sync.Mutex *sync.Mutex
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"ReadCloser.io.Reader":        "?io.Reader",
+		"ReadCloser.Closer":           "Closer",
+		"ReadCloser.Inner.sync.Mutex": "*sync.Mutex",
+	}
+	if !mapEqual(expected, ann.anns) {
+		t.Errorf("expected %v, got %v", expected, ann.anns)
+	}
+	if typ, _ := ann.Lookup("ReadCloser").Lookup("io.Reader").Type(); typ != "?io.Reader" {
+		t.Errorf("expected annotation for the embedded io.Reader, got %q", typ)
+	}
+	if kind := ann.Kind("ReadCloser.io.Reader"); kind != Field {
+		t.Errorf("expected the embedded io.Reader to be a field, got %v", kind)
+	}
+
+	parsed, err := Parse(ann.Marshal())
+	if err != nil {
+		t.Fatalf("parsing marshaled annotations: %v", err)
+	}
+	if !mapEqual(expected, parsed.anns) {
+		t.Errorf("round trip: expected %v, got %v", expected, parsed.anns)
+	}
+
+	if _, err := Parse("T {\n\tio. x\n}\n"); err == nil {
+		t.Errorf("expected error for a qualifier without a type name")
+	}
+}
+
+func TestParseTypeParams(t *testing.T) {
+	anns, err := parseList(NewTokenizer(`Map[K comparable, V any] func(m map[K]V) []K
+Sum[T interface{ ~int | ~float64 }] func(xs ...T) T
+Number interface{ ~int | ~float64 }
+Pair[K comparable, V any] {
+	Key *K
+}
+(*List[T]) {
+	Push func(v ?*T)
+}
+(*atomic.Pointer[T any]) {
+	Load func() ?*T
+}
+Nested[S ~[]E, E any] func(s S) Map[E, []E]
+Buf [16]byte
+`), nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"Map":                    "func(m map[K]V) []K",
+		"Sum":                    "func(xs ...T) T",
+		"Number":                 "interface{ ~int | ~float64 }",
+		"Pair.Key":               "*K",
+		"(*List).Push":           "func(v ?*T)",
+		"(*atomic.Pointer).Load": "func() ?*T",
+		"Nested":                 "func(s S) Map[E, []E]",
+		"Buf":                    "[16]byte",
+	}
+	if !mapEqual(expected, anns) {
+		t.Errorf("expected %v, got %v", expected, anns)
+	}
+
+	// As in Parse's doc, the '}' closing a block in the same line ends the
+	// Type before it, unless it's in brackets.
+	for input, expected := range map[string]map[string]string{
+		"(*List[T]) { Push func(v T) }":         {"(*List).Push": "func(v T)"},
+		"T { A struct{ x int }; B map[K]V }":    {"T.A": "struct{ x int }", "T.B": "map[K]V"},
+		"T { A struct{ x int `json:\"}\"` } }": {"T.A": "struct{ x int `json:\"}\"` }"},
+	} {
+		ann, err := Parse(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+			continue
+		}
+		for name, def := range expected {
+			if got, _ := ann.Definition(name); got != def {
+				t.Errorf("%q: %s: expected %q, got %q", input, name, def, got)
+			}
+		}
+	}
+
+	for i, c := range []struct {
+		input     string
+		line, col int
+	}{
+		{"T { A }\n", 1, 7},
+		{"T { A int } }\n", 1, 13},
+		{"T[] int\n", 1, 3},
+		{"T[ ] int\n", 1, 4},
+		{"Map[K any func()\n", 1, 17},
+		{"Map[K any; V any] func()\n", 1, 10},
+		{"(*List[T) {\n\tM func()\n}\n", 1, 12},
+	} {
+		_, err := parseList(NewTokenizer(c.input), nil, false)
+		tkErr, ok := err.(UnexpectedTokenError)
+		if !ok {
+			t.Errorf("case %d: expected UnexpectedTokenError, got %v", i, err)
+			continue
+		}
+		if tkErr.Token.Line != c.line || tkErr.Token.Col != c.col {
+			t.Errorf("case %d: expected error at %d:%d, got %v", i, c.line, c.col, err)
+		}
+	}
+	if _, err := parseList(NewTokenizer("Map[K any"), nil, false); err != EOF {
+		t.Errorf("expected EOF error, got %v", err)
+	}
+}
+
+func TestParseExpectedToken(t *testing.T) {
+	for _, c := range []struct {
+		src      string
+		expected rune
+		msg      string
+	}{
+		{"A int\n(T x) {\n}\n", ')', "expected ')' but found 'x' at 2:4"},
+		{"(*T x) func()\n", ')', "expected ')' but found 'x' at 1:5"},
+	} {
+		_, err := Parse(c.src)
+		tkErr, ok := err.(UnexpectedTokenError)
+		if !ok || tkErr.Expected != c.expected || err.Error() != c.msg {
+			t.Errorf("%q: expected error %q, got %v", c.src, c.msg, err)
+		}
+	}
+
+	// Tokens that are wrong for no specific expected one are reported as
+	// before.
+	if _, err := Parse("A int\n(**T) x\n"); err == nil || err.Error() != "unexpected token at 2:3: '*'" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseDuplicates(t *testing.T) {
+	src := `Create func(name string) (*File \ error)
+(*File) {
+	Close (*File) func() \ error
+}
+Getenv func(key string) string @build !windows
+Getenv func(key string) ?*string @build windows
+(*File) {
+	Close (*File) func() error
+}
+Create func(name string) ?*File
+`
+	_, err := Parse(src)
+	dupErr, ok := err.(DuplicateError)
+	if !ok || dupErr.Name != "(*File).Close" || dupErr.First.Line != 3 || dupErr.Token.Line != 8 {
+		t.Fatalf("expected duplicate error for (*File).Close at lines 3 and 8, got %v", err)
+	}
+	if msg := err.Error(); msg != "(*File).Close annotated again at 8:2; first annotated at 3:2" {
+		t.Errorf("unexpected message: %q", msg)
+	}
+
+	ann, err := ParseAll(src)
+	errs, _ := err.(ErrorList)
+	if len(errs) != 2 {
+		t.Fatalf("expected two duplicate errors, got %v", err)
+	}
+	if dupErr, ok := errs[1].(DuplicateError); !ok || dupErr.Name != "Create" || dupErr.First.Line != 1 || dupErr.Token.Line != 10 {
+		t.Errorf("expected duplicate error for Create at lines 1 and 10, got %v", errs[1])
+	}
+	// The first definitions are kept.
+	if typ, _ := ann.Lookup("Create").Type(); typ != `func(name string) (*File \ error)` {
+		t.Errorf("expected the first Create, got %q", typ)
+	}
+
+	ann, err = ParseWith(ParseOptions{AllowDuplicates: true}, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"Create":        "func(name string) ?*File",
+		"(*File).Close": "(*File) func() error",
+	} {
+		if def, _ := ann.Definition(name); def != expected {
+			t.Errorf("%s: expected the last definition %q, got %q", name, expected, def)
+		}
+	}
+}
+
+func TestParseReceivers(t *testing.T) {
+	anns, err := parseList(NewTokenizer("( * T ) {\n\tM func(p **T) *?*T\n}\n"), nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"(*T).M": "func(p **T) *?*T"}
+	if !mapEqual(expected, anns) {
+		t.Errorf("expected %v, got %v", expected, anns)
+	}
+
+	anns, err = parseList(NewTokenizer("( * json.Decoder ) {\n\tDecode (*json.Decoder) func(v interface{}) error\n}\n"), nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = map[string]string{"(*json.Decoder).Decode": "(*json.Decoder) func(v interface{}) error"}
+	if !mapEqual(expected, anns) {
+		t.Errorf("expected %v, got %v", expected, anns)
+	}
+	if src := NewAnnotation(anns).Marshal(); src != "(*json.Decoder) {\n\tDecode (*json.Decoder) func(v interface{}) error\n}\n" {
+		t.Errorf("unexpected marshaled source for a qualified receiver: %q", src)
+	}
+
+	// Value receivers have no *.
+	anns, err = parseList(NewTokenizer("(Reader) {\n\tRead (Reader) func(p []byte) (n int, err ?error)\n}\n( json.Number ) {\n\tString func() string\n}\n"), nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = map[string]string{
+		"(Reader).Read":        "(Reader) func(p []byte) (n int, err ?error)",
+		"(json.Number).String": "func() string",
+	}
+	if !mapEqual(expected, anns) {
+		t.Errorf("expected %v, got %v", expected, anns)
+	}
+	if src := NewAnnotation(anns).Marshal(); src != "(Reader) {\n\tRead (Reader) func(p []byte) (n int, err ?error)\n}\n(json.Number) {\n\tString func() string\n}\n" {
+		t.Errorf("unexpected marshaled source for value receivers: %q", src)
+	}
+
+	// Receiver blocks can be in one line.
+	anns, err = parseList(NewTokenizer("(Reader) { Read func() }\n(*json.Decoder) { Decode func(v interface{}) \\ error }\n( json.Number ) { String func() string; Int64 func() (int64 \\ error) }\n"), nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = map[string]string{
+		"(Reader).Read":          "func()",
+		"(*json.Decoder).Decode": `func(v interface{}) \ error`,
+		"(json.Number).String":   "func() string",
+		"(json.Number).Int64":    `func() (int64 \ error)`,
+	}
+	if !mapEqual(expected, anns) {
+		t.Errorf("expected %v, got %v", expected, anns)
+	}
+
+	for i, c := range []struct {
+		input     string
+		line, col int
+	}{
+		{"(**T) {\n\tM func()\n}\n", 1, 3},
+		{"(* *T) {\n\tM func()\n}\n", 1, 4},
+		{"(*?T) {\n\tM func()\n}\n", 1, 3},
+		{"(*json.) {\n\tM func()\n}\n", 1, 8},
+		{"(*a.b.T) {\n\tM func()\n}\n", 1, 6},
+		{"(?T) {\n\tM func()\n}\n", 1, 2},
+		{"() {\n\tM func()\n}\n", 1, 2},
+		{"(a.b.T) {\n\tM func()\n}\n", 1, 5},
+	} {
+		_, err := parseList(NewTokenizer(c.input), nil, false)
+		tkErr, ok := err.(UnexpectedTokenError)
+		if !ok {
+			t.Errorf("case %d: expected UnexpectedTokenError, got %v", i, err)
+			continue
+		}
+		if tkErr.Token.Line != c.line || tkErr.Token.Col != c.col {
+			t.Errorf("case %d: expected error at %d:%d, got %v", i, c.line, c.col, err)
+		}
+	}
+}
+
 func mapEqual(a, b map[string]string) bool {
 	if (a == nil && b != nil) || (b == nil && a != nil) {
 		return false
@@ -41,3 +1170,235 @@ func mapEqual(a, b map[string]string) bool {
 	}
 	return true
 }
+
+func TestDirectives(t *testing.T) {
+	type testCase struct {
+		def  string
+		typ  string
+		dirs []Directive
+	}
+	cases := []testCase{
+		{
+			def: `func(x ?*T) bool`,
+			typ: `func(x ?*T) bool`,
+		},
+		{
+			def:  `func(x, y ?*T) bool @narrows $1 $2`,
+			typ:  `func(x, y ?*T) bool`,
+			dirs: []Directive{{Name: "narrows", Args: []string{"$1", "$2"}}},
+		},
+		{
+			def:  `@foo @bar baz`,
+			dirs: []Directive{{Name: "foo"}, {Name: "bar", Args: []string{"baz"}}},
+		},
+	}
+	for i, c := range cases {
+		ann := NewAnnotation(map[string]string{"F": c.def}).Lookup("F")
+		typ, _ := ann.Type()
+		if typ != c.typ {
+			t.Errorf("case %d: type: expected %q, got %q", i, c.typ, typ)
+		}
+		dirs := ann.Directives()
+		if len(dirs) != len(c.dirs) {
+			t.Errorf("case %d: directives: expected %v, got %v", i, c.dirs, dirs)
+			continue
+		}
+		for j := range dirs {
+			if dirs[j].String() != c.dirs[j].String() {
+				t.Errorf("case %d: directives: expected %v, got %v", i, c.dirs, dirs)
+			}
+		}
+	}
+}
+
+func TestParseDefault(t *testing.T) {
+	ann, err := Parse(`(*Client) default !
+(*Client) {
+	Do (*Client) func(req *Request) (*Response \ error)
+}
+Request default ?
+Config @default !
+Options *defaults.Options
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name   string
+		policy string
+	}{
+		{"(*Client)", "!"},
+		{"Request", "?"},
+		{"Config", "!"},
+		{"Options", ""},
+		{"Missing", ""},
+	} {
+		if policy, _ := ann.Lookup(c.name).Default(); policy != c.policy {
+			t.Errorf("%s: expected default %q, got %q", c.name, c.policy, policy)
+		}
+	}
+	// A default doesn't hide the annotations of the type's members.
+	if typ, _ := ann.Lookup("(*Client)").Lookup("Do").Type(); typ != `(*Client) func(req *Request) (*Response \ error)` {
+		t.Errorf("expected annotation for (*Client).Do, got %q", typ)
+	}
+
+	if _, err := Parse("T default nil\n"); err == nil || !strings.Contains(err.Error(), "invalid default for T") {
+		t.Errorf("expected error for an invalid default, got %v", err)
+	}
+}
+
+func TestParseCommentsOnly(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"\n\n",
+		" \t\r\n",
+		"// Package os.",
+		"// Package os.\n",
+		"/* Package os. */",
+		"/* Package\n   os. */\n\n// More.\r\n\n",
+		"// A {\n\t// B int\n// }\n",
+	} {
+		for _, parse := range []func(string) (*Annotation, error){Parse, ParseAll} {
+			ann, err := parse(src)
+			if err != nil {
+				t.Errorf("%q: %v", src, err)
+				continue
+			}
+			if ann == nil || ann.Len() != 0 {
+				t.Errorf("%q: expected an empty Annotation, got %#v", src, ann)
+			}
+		}
+	}
+}
+
+func TestEmptyAnnotation(t *testing.T) {
+	// describe calls every method that reads an Annotation.
+	describe := func(a *Annotation) string {
+		var buf strings.Builder
+		typ, hasType := a.Type()
+		def, hasDef := a.Definition("A")
+		raw, hasRaw := a.RawType("A")
+		pos, hasPos := a.Pos("A")
+		method, hasMethod := a.Method("T", "M")
+		policy, hasDefault := a.Default()
+		elem, variadic := a.Variadic("A")
+		fmt.Fprintln(&buf, a.Cursor(), a.String(), typ, hasType, a.Directives(), a.Names(), a.Len())
+		fmt.Fprintln(&buf, def, hasDef, raw, hasRaw, pos, hasPos, a.Doc("A"), a.Kind("A"))
+		fmt.Fprintln(&buf, method, hasMethod, policy, hasDefault, elem, variadic)
+		fmt.Fprintf(&buf, "%q %v %v\n", a.Marshal(), a.Sub("A").Len(), a.Diff(nil))
+		fmt.Fprintln(&buf, a.Filter(func(string, string) bool { return true }).Len(), a.ForContext(&build.Default).Len())
+		fmt.Fprintln(&buf, a.Lookup("A").Cursor(), a.Lookup("A").Len(), a.Lookup("A").Kind("B"))
+		return buf.String()
+	}
+
+	parsed, err := Parse("// Nothing here.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := NewAnnotation(map[string]string{})
+	expected := describe(empty)
+	for i, a := range []*Annotation{NewAnnotation(nil), parsed, Merge(), Merge(nil, empty)} {
+		if got := describe(a); got != expected {
+			t.Errorf("%d: expected\n%s\ngot\n%s", i, expected, got)
+		}
+		if !a.Equal(empty) || !empty.Equal(a) {
+			t.Errorf("%d: expected to equal an empty Annotation", i)
+		}
+		var buf strings.Builder
+		if err := a.MarshalTo(&buf); err != nil || buf.Len() != 0 {
+			t.Errorf("%d: expected nothing marshaled, got %q, %v", i, buf.String(), err)
+		}
+		if patch := MakePatch(a, empty); len(patch) != 0 {
+			t.Errorf("%d: expected no patch, got %v", i, patch)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	srcs := []string{
+		"A a1\nB b1 @build linux\n",
+		"A a2\nB b2 @build windows\nC {\n\tD d\n}\n",
+	}
+	expected, err := parseList(NewTokenizer(srcs[0]+"\n"+srcs[1]), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var anns []*Annotation
+	for _, src := range srcs {
+		ann, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anns = append(anns, ann)
+	}
+	merged := Merge(append(anns, nil)...)
+	if !mapEqual(expected, merged.anns) {
+		t.Errorf("expected %v, got %v", expected, merged.anns)
+	}
+	if anns[0].anns["A"] != "a1" {
+		t.Errorf("merging modified a merged Annotation: %v", anns[0].anns)
+	}
+}
+
+func TestMergeAnnotations(t *testing.T) {
+	base, err := Parse(`Open func(name string) (*File \ error)
+Getenv func(key string) string @build !windows
+Getenv func(key string) ?*string @build windows
+(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := Parse(`(*File) {
+	Read (*File) func(b []byte) (int, error)
+}
+Getenv func(key string) ?*string
+Exit func(code int) @noreturn process
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := MergeAnnotations(base, override)
+	expected := map[string]string{
+		"Open":          `func(name string) (*File \ error)`,
+		"Getenv":        "func(key string) ?*string",
+		"(*File).Close": `(*File) func() \ error`,
+		"(*File).Read":  "(*File) func(b []byte) (int, error)",
+		"Exit":          "func(code int) @noreturn process",
+	}
+	if !mapEqual(expected, merged.anns) {
+		t.Errorf("expected %v, got %v", expected, merged.anns)
+	}
+	if tk, ok := merged.Pos("(*File).Read"); !ok || tk.Line != 2 {
+		t.Errorf("expected position from override for (*File).Read, got %+v (found: %v)", tk, ok)
+	}
+	if tk, ok := merged.Pos("(*File).Close"); !ok || tk.Line != 5 {
+		t.Errorf("expected position from base for (*File).Close, got %+v (found: %v)", tk, ok)
+	}
+	if base.anns["(*File).Read"] == expected["(*File).Read"] {
+		t.Errorf("merging modified base: %v", base.anns)
+	}
+
+	if merged := MergeAnnotations(nil, override); !mapEqual(override.anns, merged.anns) {
+		t.Errorf("expected %v with no base, got %v", override.anns, merged.anns)
+	}
+}
+
+func BenchmarkParseLongType(b *testing.B) {
+	var params []string
+	for i := 0; i < 5000; i++ {
+		params = append(params, fmt.Sprintf("p%d map[K]?*Pair[K, V]", i))
+	}
+	src := "Join func[K comparable, V any](" + strings.Join(params, ", ") + ") ?*Pair[K, V]\n"
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package annotations
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Patch is a list of edits to the definitions in a package's Annotation, as
+// an automated tool would make when upgrading annotations to a new version of
+// the annotated package.
+type Patch []Edit
+
+// An Edit adds, removes or changes the definition of an annotated identifier.
+type Edit struct {
+	// Name is the identifier's full name, like "(*File).Read".
+	Name string
+	// Old is the definition Name is expected to have before the edit. If
+	// empty, Name is expected not to be annotated, and the edit adds it.
+	Old string
+	// New is the definition Name has after the edit. If empty, the edit
+	// removes it.
+	New string
+}
+
+// String implements fmt.Stringer for Edit.
+func (e Edit) String() string {
+	switch {
+	case e.Old == "":
+		return fmt.Sprintf("%s: add %s", e.Name, e.New)
+	case e.New == "":
+		return fmt.Sprintf("%s: remove %s", e.Name, e.Old)
+	}
+	return fmt.Sprintf("%s: change %s to %s", e.Name, e.Old, e.New)
+}
+
+// ConflictError reports that an Edit expected a different definition than the
+// one found in the Annotation it was applied to.
+type ConflictError struct {
+	Edit Edit
+	// Found is the definition found for the edited identifier; empty if it
+	// isn't annotated.
+	Found string
+}
+
+// Error implements the error interface.
+func (err ConflictError) Error() string {
+	found := err.Found
+	if found == "" {
+		found = "no annotation"
+	}
+	expected := err.Edit.Old
+	if expected == "" {
+		expected = "no annotation"
+	}
+	return fmt.Sprintf("conflict applying %v: expected %s, found %s", err.Edit, expected, found)
+}
+
+// ApplyPatch returns a copy of the package's Annotation a with the edits in
+// patch applied, in order. If any edit doesn't find the definition it expects,
+// it returns a ConflictError, and nothing is applied.
+func ApplyPatch(a *Annotation, patch Patch) (*Annotation, error) {
+	anns := map[string]string{}
+	if a != nil {
+		for name, def := range a.anns {
+			anns[name] = def
+		}
+	}
+	for _, edit := range patch {
+		if found := anns[edit.Name]; found != edit.Old {
+			return nil, ConflictError{Edit: edit, Found: found}
+		}
+		if edit.New == "" {
+			delete(anns, edit.Name)
+		} else {
+			anns[edit.Name] = edit.New
+		}
+	}
+	return NewAnnotation(anns), nil
+}
+
+// MakePatch returns the Patch that turns the package's Annotation old into
+// new, with its edits sorted by name.
+func MakePatch(old, new *Annotation) Patch {
+	var patch Patch
+	for _, name := range old.Names() {
+		newDef, _ := new.Definition(name)
+		if oldDef := old.anns[name]; oldDef != newDef {
+			patch = append(patch, Edit{Name: name, Old: oldDef, New: newDef})
+		}
+	}
+	for _, name := range new.Names() {
+		if _, ok := old.Definition(name); !ok {
+			patch = append(patch, Edit{Name: name, New: new.anns[name]})
+		}
+	}
+	sort.Sort(editsByName(patch))
+	return patch
+}
+
+type editsByName []Edit
+
+func (es editsByName) Len() int           { return len(es) }
+func (es editsByName) Less(i, j int) bool { return es[i].Name < es[j].Name }
+func (es editsByName) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
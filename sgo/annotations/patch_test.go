@@ -0,0 +1,75 @@
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	ann := NewAnnotation(map[string]string{
+		"Open":         `func(name string) (*File \ error)`,
+		"Create":       `func(name string) (*File \ error)`,
+		"(*File).Read": `(*File) func(b []byte) (n int, err ?error)`,
+	})
+	patch := Patch{
+		{Name: "(*File).Read", Old: `(*File) func(b []byte) (n int, err ?error)`, New: `(*File) func(b []byte, flags int) (n int, err ?error)`},
+		{Name: "Create", Old: `func(name string) (*File \ error)`},
+		{Name: "Stdin", New: `*File`},
+	}
+
+	patched, err := ApplyPatch(ann, patch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"Open":         `func(name string) (*File \ error)`,
+		"(*File).Read": `(*File) func(b []byte, flags int) (n int, err ?error)`,
+		"Stdin":        `*File`,
+	}
+	if !mapEqual(expected, patched.anns) {
+		t.Errorf("expected %v, got %v", expected, patched.anns)
+	}
+	if _, ok := ann.anns["Create"]; !ok {
+		t.Errorf("ApplyPatch modified its argument")
+	}
+
+	if got := MakePatch(ann, patched); !reflect.DeepEqual(got, patch) {
+		t.Errorf("MakePatch: expected %v, got %v", patch, got)
+	}
+
+	patched, err = ApplyPatch(nil, Patch{{Name: "Stdin", New: `*File`}})
+	if err != nil || !mapEqual(map[string]string{"Stdin": `*File`}, patched.anns) {
+		t.Errorf("applying to nil: unexpected %v, %v", patched, err)
+	}
+}
+
+func TestApplyPatchConflicts(t *testing.T) {
+	ann := NewAnnotation(map[string]string{
+		"Open":  `func(name string) (*File \ error)`,
+		"Stdin": `*File`,
+	})
+	cases := []struct {
+		patch Patch
+		found string
+	}{
+		// Changes a different definition.
+		{Patch{{Name: "Open", Old: `func(name string) (*File, error)`, New: `func(name string) (?*File \ error)`}}, `func(name string) (*File \ error)`},
+		// Removes something that isn't there.
+		{Patch{{Name: "Create", Old: `func(name string) (*File \ error)`}}, ""},
+		// Adds something that is there.
+		{Patch{{Name: "Stdin", New: `?*File`}}, `*File`},
+		// Conflicts with an earlier edit in the same patch.
+		{Patch{{Name: "Stdin", Old: `*File`}, {Name: "Stdin", Old: `*File`, New: `?*File`}}, ""},
+	}
+	for i, c := range cases {
+		patched, err := ApplyPatch(ann, c.patch)
+		conflict, ok := err.(ConflictError)
+		if !ok {
+			t.Errorf("case %d: expected ConflictError, got %v, %v", i, patched, err)
+			continue
+		}
+		if conflict.Found != c.found {
+			t.Errorf("case %d: expected to find %q, got %q", i, c.found, conflict.Found)
+		}
+	}
+}
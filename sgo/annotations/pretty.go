@@ -0,0 +1,422 @@
+package annotations
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/printer"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+// PrettyType formats the SGo type in an annotation definition the way gofmt
+// would, for display.
+//
+// For SGo: func(def string) (string \ error)
+func PrettyType(def string) (string, error) {
+	var ret []string
+	for _, alt := range alternatives(def) {
+		typ, dirs := splitDirectives(alt)
+		if typ == "" && len(dirs) > 0 {
+			var ds []string
+			for _, dir := range dirs {
+				ds = append(ds, dir.String())
+			}
+			ret = append(ret, strings.Join(ds, " "))
+			continue
+		}
+		d, err := parseDefType(typ)
+		if err != nil {
+			return "", err
+		}
+
+		var s string
+		if d.recv != nil {
+			s = "(" + d.print(d.recv) + ") " + d.print(d.fun)
+		} else {
+			s = d.print(d.e)
+		}
+		for _, dir := range dirs {
+			s += " " + dir.String()
+		}
+		ret = append(ret, s)
+	}
+	return strings.Join(ret, "\n"), nil
+}
+
+// HumanType is like PrettyType, but describes the type in a short human form,
+// like "returns *File or error".
+//
+// For SGo: func(def string) (string \ error)
+func HumanType(def string) (string, error) {
+	var ret []string
+	for _, alt := range alternatives(def) {
+		typ, dirs := splitDirectives(alt)
+		var d defType
+		if typ != "" || len(dirs) == 0 {
+			var err error
+			d, err = parseDefType(typ)
+			if err != nil {
+				return "", err
+			}
+			if d.fun == nil {
+				d.fun, _ = d.e.(*ast.FuncType)
+			}
+		}
+
+		var s string
+		if d.e == nil && d.fun == nil {
+			s = "as declared in Go"
+		} else if d.fun != nil {
+			s = "returns " + humanResults(d, d.fun.Results)
+		} else if opt, ok := d.e.(*ast.OptionalType); ok {
+			s = d.print(opt.Elt) + " or nil"
+		} else {
+			s = d.print(d.e)
+		}
+		if constraint, ok := buildConstraint(alt); ok {
+			s += " (build " + strings.Join(constraint, " ") + ")"
+		}
+		ret = append(ret, s)
+	}
+	return strings.Join(ret, "\n"), nil
+}
+
+func humanResults(d defType, results *ast.FieldList) string {
+	var types []string
+	if results != nil {
+		for _, f := range results.List {
+			typ := d.print(f.Type)
+			for i := 0; i < len(f.Names) || i == 0; i++ {
+				types = append(types, typ)
+			}
+		}
+	}
+
+	var s string
+	switch len(types) {
+	case 0:
+		s = "nothing"
+	case 1:
+		s = types[0]
+	default:
+		s = strings.Join(types[:len(types)-1], ", ") + " and " + types[len(types)-1]
+	}
+
+	if results != nil && results.Entangled != nil {
+		entangled := d.print(results.Entangled.Type)
+		if len(types) > 1 {
+			s += ","
+		}
+		s += " or " + entangled
+	}
+	return s
+}
+
+// Variadic returns the element type of the variadic final parameter of the
+// child identifier with the given name, if it's annotated with a func type.
+func (a *Annotation) Variadic(name string) (string, bool) {
+	typ, ok := a.Lookup(name).Type()
+	if !ok {
+		return "", false
+	}
+	d, err := parseDefType(typ)
+	if err != nil {
+		return "", false
+	}
+	fun := d.fun
+	if fun == nil {
+		fun, _ = d.e.(*ast.FuncType)
+	}
+	if fun == nil || fun.Params == nil || len(fun.Params.List) == 0 {
+		return "", false
+	}
+	e, ok := fun.Params.List[len(fun.Params.List)-1].Type.(*ast.Ellipsis)
+	if !ok {
+		return "", false
+	}
+	return d.print(e.Elt), true
+}
+
+// A defType is an annotation's type, as parsed by parseDefType: either an
+// expression e or, for methods, a receiver recv followed by a function type
+// fun.
+type defType struct {
+	fset *token.FileSet
+	fun  *ast.FuncType
+	recv ast.Expr
+	e    ast.Expr
+	// hidden turns the placeholders for what's hidden from the SGo parser
+	// back into it; see hideUnions.
+	hidden *strings.Replacer
+}
+
+// parseDefType parses an annotation's type.
+func parseDefType(typ string) (defType, error) {
+	var hidden []string
+	typ, err := hideUnions(typ, &hidden)
+	if err != nil {
+		return defType{}, err
+	}
+	d := defType{fset: token.NewFileSet(), hidden: strings.NewReplacer(hidden...)}
+	d.e, err = parser.ParseExprFrom(d.fset, "", []byte(typ), 0)
+	if err == nil {
+		return d, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(typ), "(") {
+		fun, recv, mErr := parser.ParseMethodExprsFrom(d.fset, "", []byte(typ), 0)
+		if mErr == nil {
+			d.fun, d.recv = fun, recv
+			return d, nil
+		}
+	}
+	return defType{}, err
+}
+
+// print formats a part of d the way gofmt would.
+func (d defType) print(n ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, d.fset, n)
+	return d.hidden.Replace(buf.String())
+}
+
+// unionPrefix and unionSuffix enclose the index of a union element in the
+// placeholders hideUnions replaces them with, and ellipsisLen is the one for
+// the length of an array written as [...].
+const (
+	unionPrefix = "_sgoUnion"
+	unionSuffix = "_"
+	ellipsisLen = "_sgoEllipsis_"
+)
+
+// hideUnions replaces the union elements of the interfaces in typ, and the
+// ... in array types, which the SGo parser doesn't know, with placeholders,
+// appending to unions each placeholder and what it replaces.
+func hideUnions(typ string, unions *[]string) (string, error) {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(typ); i++ {
+		c := typ[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < len(typ) {
+				b.WriteByte(c)
+				i++
+				c = typ[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '[' && strings.HasPrefix(strings.TrimLeft(typ[i+1:], " \t"), "..."):
+			rest := strings.TrimLeft(strings.TrimLeft(typ[i+1:], " \t")[len("..."):], " \t")
+			if !strings.HasPrefix(rest, "]") {
+				break
+			}
+			if !hasEllipsisLen(*unions) {
+				*unions = append(*unions, ellipsisLen, "...")
+			}
+			b.WriteString("[" + ellipsisLen + "]")
+			i = len(typ) - len(rest)
+			continue
+		case strings.HasPrefix(typ[i:], "interface") && (i == 0 || !isIdentByte(typ[i-1])):
+			j := i + len("interface")
+			open := j + len(typ[j:]) - len(strings.TrimLeft(typ[j:], " \t"))
+			if open == len(typ) || typ[open] != '{' || j < len(typ) && isIdentByte(typ[j]) {
+				break
+			}
+			end := closing(typ, open)
+			if end == -1 {
+				break
+			}
+			body, err := hideUnionElems(typ[open+1:end], unions)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("interface{" + body + "}")
+			i = end
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+func hasEllipsisLen(hidden []string) bool {
+	for i := 0; i < len(hidden); i += 2 {
+		if hidden[i] == ellipsisLen {
+			return true
+		}
+	}
+	return false
+}
+
+// hideUnionElems hides the union elements in the body of an interface; see
+// hideUnions.
+func hideUnionElems(body string, unions *[]string) (string, error) {
+	elems := splitTopLevel(body, ';')
+	for i, elem := range elems {
+		terms := splitTopLevel(elem, '|')
+		if len(terms) == 1 && !strings.HasPrefix(strings.TrimSpace(elem), "~") {
+			var err error
+			elems[i], err = hideUnions(elem, unions)
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		for j, term := range terms {
+			term = strings.TrimSpace(term)
+			tilde := ""
+			if strings.HasPrefix(term, "~") {
+				tilde = "~"
+				term = strings.TrimSpace(term[1:])
+			}
+			if term == "" {
+				return "", fmt.Errorf("missing union term in %q", strings.TrimSpace(elem))
+			}
+			d, err := parseDefType(term)
+			if err != nil {
+				return "", fmt.Errorf("union term %q: %v", term, err)
+			}
+			if d.e == nil || !isTypeExpr(d.e) {
+				return "", fmt.Errorf("union term %q is not a type", term)
+			}
+			if _, ok := d.e.(*ast.OptionalType); ok {
+				return "", fmt.Errorf("union term %q can't be optional", term)
+			}
+			terms[j] = tilde + d.print(d.e)
+		}
+		placeholder := unionPrefix + strconv.Itoa(len(*unions)/2) + unionSuffix
+		*unions = append(*unions, placeholder, strings.Join(terms, " | "))
+		elems[i] = " " + placeholder + " "
+	}
+	return strings.Join(elems, ";"), nil
+}
+
+// splitTopLevel splits s at each sep that isn't in brackets or a string
+// literal.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closing returns the index of the '}' closing the '{' at open in s, or -1 if
+// there's none.
+func closing(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// validateType checks that typ, from an annotation definition without its
+// directives, is an SGo type, or a method's.
+func validateType(typ string) error {
+	d, err := parseDefType(typ)
+	if err != nil {
+		return err
+	}
+	if d.e != nil && !isTypeExpr(d.e) {
+		return errors.New("not a type")
+	}
+	return checkEllipses(d)
+}
+
+// checkEllipses checks that every ... in d, other than in [...], is on the
+// final parameter of a func type, as Go requires.
+func checkEllipses(d defType) error {
+	final := map[*ast.Ellipsis]bool{}
+	var err error
+	visit := func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType:
+			if n.Params != nil && len(n.Params.List) > 0 {
+				last := n.Params.List[len(n.Params.List)-1]
+				if e, ok := last.Type.(*ast.Ellipsis); ok && len(last.Names) <= 1 {
+					final[e] = true
+				}
+			}
+		case *ast.Ellipsis:
+			if n.Elt != nil && !final[n] && err == nil {
+				err = errors.New("can only use ... with final parameter")
+			}
+		}
+		return err == nil
+	}
+	if d.e != nil {
+		ast.Inspect(d.e, visit)
+	} else if d.fun != nil {
+		ast.Inspect(d.recv, visit)
+		ast.Inspect(d.fun, visit)
+	}
+	return err
+}
+
+func isTypeExpr(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident, *ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := e.X.(*ast.Ident)
+		return ok
+	case *ast.ParenExpr:
+		return isTypeExpr(e.X)
+	case *ast.StarExpr:
+		return isTypeExpr(e.X)
+	case *ast.OptionalType:
+		return isTypeExpr(e.Elt)
+	}
+	return false
+}
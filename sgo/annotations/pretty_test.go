@@ -0,0 +1,170 @@
+package annotations
+
+import "testing"
+
+func TestPrettyType(t *testing.T) {
+	for _, c := range []struct {
+		def, pretty, human string
+	}{
+		{
+			`func(name string)(*File\error)`,
+			`func(name string) (*File \ error)`,
+			`returns *File or error`,
+		},
+		{
+			`func( )\  error`,
+			`func() \ error`,
+			`returns nothing or error`,
+		},
+		{
+			`func(b []byte) (n int, err ?error)`,
+			`func(b []byte) (n int, err ?error)`,
+			`returns int and ?error`,
+		},
+		{
+			`func(a, b int) (x, y int \ err error)`,
+			`func(a, b int) (x, y int \ err error)`,
+			`returns int and int, or error`,
+		},
+		{
+			`func (name string, arg ...string) *Cmd`,
+			`func(name string, arg ...string) *Cmd`,
+			`returns *Cmd`,
+		},
+		{
+			`func(x  ?*T) bool   @narrows $1`,
+			`func(x ?*T) bool @narrows $1`,
+			`returns bool`,
+		},
+		{
+			`(* File)   func() \ error`,
+			`(*File) func() \ error`,
+			`returns nothing or error`,
+		},
+		{
+			`func(key string)`,
+			`func(key string)`,
+			`returns nothing`,
+		},
+		{
+			`?*File`,
+			`?*File`,
+			`*File or nil`,
+		},
+		{
+			`map[string]  []?*T`,
+			`map[string][]?*T`,
+			`map[string][]?*T`,
+		},
+		{
+			"func(key string) *Value @build !windows\nfunc(key string, fallback ?*Value) ?*Value @build windows",
+			"func(key string) *Value @build !windows\nfunc(key string, fallback ?*Value) ?*Value @build windows",
+			"returns *Value (build !windows)\nreturns ?*Value (build windows)",
+		},
+		{
+			`interface{~int|~int8 |  float64}`,
+			`interface{ ~int | ~int8 | float64 }`,
+			`interface{ ~int | ~int8 | float64 }`,
+		},
+		{
+			`map[string]interface{ ~string | interface{ ~[]byte|~[]rune } }`,
+			`map[string]interface{ ~string | interface{ ~[]byte | ~[]rune } }`,
+			`map[string]interface{ ~string | interface{ ~[]byte | ~[]rune } }`,
+		},
+		{
+			`?interface{ ~int; String() string }`,
+			"?interface {\n\t~int\n\tString() string\n}",
+			"interface {\n\t~int\n\tString() string\n} or nil",
+		},
+		{
+			`struct{ A int "interface{ ~x }" }`,
+			"struct {\n\tA int \"interface{ ~x }\"\n}",
+			"struct {\n\tA int \"interface{ ~x }\"\n}",
+		},
+		{
+			`map[string][ ... ]?*T`,
+			`map[string][...]?*T`,
+			`map[string][...]?*T`,
+		},
+		{
+			`@nonempty`,
+			`@nonempty`,
+			`as declared in Go`,
+		},
+		{
+			"@noreturn  process @build !plan9\nfunc(code int) @build plan9",
+			"@noreturn process @build !plan9\nfunc(code int) @build plan9",
+			"as declared in Go (build !plan9)\nreturns nothing (build plan9)",
+		},
+	} {
+		pretty, err := PrettyType(c.def)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.def, err)
+			continue
+		}
+		if pretty != c.pretty {
+			t.Errorf("%q: expected pretty form %q, got %q", c.def, c.pretty, pretty)
+		}
+		human, err := HumanType(c.def)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.def, err)
+			continue
+		}
+		if human != c.human {
+			t.Errorf("%q: expected human form %q, got %q", c.def, c.human, human)
+		}
+	}
+
+	for _, def := range []string{`func(`, `(*File) func(`, `*`, `interface{ ~int | }`, `interface{ ~?*T }`, `interface{ int | (*T) func() }`, `interfaces{ ~int }`} {
+		if _, err := PrettyType(def); err == nil {
+			t.Errorf("%q: expected error", def)
+		}
+		if _, err := HumanType(def); err == nil {
+			t.Errorf("%q: expected error", def)
+		}
+	}
+}
+
+func TestVariadic(t *testing.T) {
+	ann, err := Parse(`Command func (name string, arg ...string) *Cmd
+Args func(args []string) *Cmd
+Join func(sep string, elems ...?*T) string
+Wrap func(f func(xs ...int)) func(ys ...*int) @build linux
+Fn func(...[]byte)
+Sum func() int
+N int
+(*Logger) {
+	Logf (*Logger) func(format string, args ...interface{})
+}
+Handler {
+	ServeHTTP func(w ResponseWriter, rs ...*Request)
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, elem string
+	}{
+		{"Command", "string"},
+		{"Args", ""},
+		{"Join", "?*T"},
+		{"Wrap", ""},
+		{"Fn", "[]byte"},
+		{"Sum", ""},
+		{"N", ""},
+		{"(*Logger).Logf", "interface{}"},
+		{"Handler.ServeHTTP", "*Request"},
+		{"Missing", ""},
+	} {
+		elem, ok := ann.Variadic(c.name)
+		if elem != c.elem || ok != (c.elem != "") {
+			t.Errorf("%s: expected %q, got %q, %v", c.name, c.elem, elem, ok)
+		}
+	}
+
+	// Names are relative to a looked up annotation.
+	if elem, ok := ann.Lookup("Handler").Variadic("ServeHTTP"); !ok || elem != "*Request" {
+		t.Errorf("Handler, ServeHTTP: expected *Request, got %q, %v", elem, ok)
+	}
+}
@@ -0,0 +1,7 @@
+// +build !sgoannpanic
+
+package annotations
+
+// recoverPanics is unset by the sgoannpanic build tag, to let panics while
+// parsing crash with their stack traces, for debugging.
+const recoverPanics = true
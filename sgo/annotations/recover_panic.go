@@ -0,0 +1,5 @@
+// +build sgoannpanic
+
+package annotations
+
+const recoverPanics = false
@@ -0,0 +1,65 @@
+package annotations
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggest returns the annotated identifier closest to name, for tools to ask
+// "did you mean (*File).Read?" when name isn't annotated.
+func (a *Annotation) Suggest(name string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	prefix := ""
+	if a.cursor != "" {
+		prefix = a.cursor + "."
+	}
+	if _, ok := a.anns[prefix+name]; ok {
+		return name, true
+	}
+
+	var names []string
+	for k := range a.anns {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, k[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+
+	folded := []rune(strings.ToLower(name))
+	best, bestDist := "", len(folded)/3+2
+	for _, k := range names {
+		if dist := editDistance(folded, []rune(strings.ToLower(k))); dist < bestDist {
+			best, bestDist = k, dist
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b: the fewest
+// runes to insert, delete or replace to turn one into the other.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cur[j] = prev[j-1]
+			if a[i-1] != b[j-1] {
+				cur[j]++
+			}
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,71 @@
+package annotations
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	ann, err := Parse(`Stdin *File
+Stdout *File
+Open func(name string) (*File \ error)
+OpenFile func(name string, flag int, perm FileMode) (*File \ error)
+(*File) {
+	Read func(b []byte) (n int, err ?error)
+	ReadAt func(b []byte, off int64) (n int, err ?error)
+}
+ErrNotExist ?error
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, expected string
+	}{
+		{"Stdin", "Stdin"},
+		{"Stdín", "Stdin"},
+		{"stdin", "Stdin"},
+		{"STDOUT", "Stdout"},
+		{"Opne", "Open"},
+		{"openfile", "OpenFile"},
+		{"(*File).read", "(*File).Read"},
+		{"(File).Read", "(*File).Read"},
+		{"(*File).Raed", "(*File).Read"},
+		{"(*File).ReadAtt", "(*File).ReadAt"},
+		{"ErrNotExists", "ErrNotExist"},
+		{"Close", ""},
+		{"Getenv", ""},
+		{"", ""},
+	} {
+		got, ok := ann.Suggest(c.name)
+		if got != c.expected || ok != (c.expected != "") {
+			t.Errorf("%q: expected %q, got %q, %v", c.name, c.expected, got, ok)
+		}
+	}
+
+	// Names are relative to a looked up annotation.
+	if got, ok := ann.Lookup("(*File)").Suggest("Reed"); !ok || got != "Read" {
+		t.Errorf("expected Read, got %q, %v", got, ok)
+	}
+	if got, ok := ann.Lookup("(*File)").Suggest("Open"); ok {
+		t.Errorf("expected nothing outside (*File), got %q", got)
+	}
+	if got, ok := (*Annotation)(nil).Suggest("Stdin"); ok {
+		t.Errorf("expected nothing for a nil Annotation, got %q", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		dist int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"Stdín", "Stdin", 1},
+		{"flaw", "lawn", 2},
+	} {
+		if dist := editDistance([]rune(c.a), []rune(c.b)); dist != c.dist {
+			t.Errorf("%q, %q: expected %d, got %d", c.a, c.b, c.dist, dist)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package annotations
 
 import (
+	"fmt"
 	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unicode"
 )
 
 func TestTokenizerSkipWhite(t *testing.T) {
@@ -31,6 +36,14 @@ func TestTokenizerSkipWhite(t *testing.T) {
 			input: "",
 			line:  1, col: 1, bytePos: 0, runePos: 0,
 		},
+		{
+			input: " // x\n/* ñ */ end",
+			line:  2, col: 9, bytePos: 15, runePos: 14,
+		},
+		{
+			input: "/* a\nb */",
+			line:  2, col: 5, bytePos: 9, runePos: 9,
+		},
 	}
 
 	for i, c := range cases {
@@ -109,6 +122,9 @@ var parseTestCases = []parseTestCase{
 			if uerr.Col != c.col {
 				t.Errorf("case %d: error col: expected %d, got %d", i, c.col, uerr.Col)
 			}
+			if uerr.BytePos != c.bytePos {
+				t.Errorf("case %d: error byte offset: expected %d, got %d", i, c.bytePos, uerr.BytePos)
+			}
 		},
 		line: 2, col: 2, bytePos: 8, runePos: 6,
 		newLine: 2, newCol: 2, newBytePos: 8, newRunePos: 6,
@@ -226,3 +242,179 @@ func testTokenizerToken(t *testing.T, i int, c parseTestCase, tk Token) {
 		t.Errorf("case %d: token runePos: expected %d, got %d", i, c.runePos, tk.RunePos)
 	}
 }
+
+func TestTokenizerLineEndings(t *testing.T) {
+	tkr := NewTokenizer("a\r\nb\rc\nd\r\r\ne")
+	type pos struct {
+		lexeme    rune
+		line, col int
+		size      int
+	}
+	expected := []pos{
+		{'a', 1, 1, 1}, {'\n', 1, 2, 2},
+		{'b', 2, 1, 1}, {'\n', 2, 2, 1},
+		{'c', 3, 1, 1}, {'\n', 3, 2, 1},
+		{'d', 4, 1, 1}, {'\n', 4, 2, 1}, {'\n', 5, 1, 2},
+		{'e', 6, 1, 1},
+	}
+	for i, e := range expected {
+		tk, err := tkr.Next()
+		if err != nil {
+			t.Fatalf("token %d: unexpected error: %v", i, err)
+		}
+		if got := (pos{tk.Lexeme, tk.Line, tk.Col, tk.Size}); got != e {
+			t.Errorf("token %d: expected %+v, got %+v", i, e, got)
+		}
+	}
+	if _, err := tkr.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestTokenizerLineText(t *testing.T) {
+	src := "a\r\nbb\rñcc\n\n/* x\ny */ e\r"
+	expected := []string{"", "a", "bb", "ñcc", "", "/* x", "y */ e", "", ""}
+	check := func(name string, tkr *Tokenizer) {
+		for line, text := range expected {
+			if got := tkr.LineText(line); got != text {
+				t.Errorf("%s: line %d: expected %q, got %q", name, line, text, got)
+			}
+		}
+	}
+	for name, newTkr := range map[string]func() *Tokenizer{
+		"string": func() *Tokenizer { return NewTokenizer(src) },
+		"reader": func() *Tokenizer { return NewReaderTokenizer(iotest.OneByteReader(strings.NewReader(src))) },
+	} {
+		// Lines ahead, before anything is consumed.
+		tkr := newTkr()
+		check(name+", at start", tkr)
+		tk, err := tkr.Next()
+		if err != nil || tk.Lexeme != 'a' {
+			t.Fatalf("%s: expected 'a' after LineText, got %q (error: %v)", name, tk.Lexeme, err)
+		}
+
+		// Lines left behind, halfway and at the end.
+		tkr = newTkr()
+		for i := 0; i < 6; i++ {
+			tkr.Next()
+		}
+		check(name+", halfway", tkr)
+		for {
+			if _, err := tkr.Next(); err != nil {
+				break
+			}
+			tkr.SkipWhite()
+		}
+		check(name+", at end", tkr)
+	}
+
+	// Only the last lines consumed from a reader are kept.
+	var long strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	tkr := NewReaderTokenizer(strings.NewReader(long.String()))
+	for {
+		if _, err := tkr.Next(); err != nil {
+			break
+		}
+	}
+	for line, text := range map[int]string{1: "", 900: "", 990: "line 990", 1000: "line 1000", 1001: ""} {
+		if got := tkr.LineText(line); got != text {
+			t.Errorf("long source: line %d: expected %q, got %q", line, text, got)
+		}
+	}
+	if n := len(tkr.past); n > keptLines*len("line 1000\n") {
+		t.Errorf("long source: expected only the last lines kept, got %d bytes", n)
+	}
+
+	// The line of an error, from the Token's position.
+	src = "Open func(name string) (*File \\ error)\nCreate func(name string) (*File \\ error \\ bool)\n"
+	_, err := Parse(src)
+	tkErr, ok := err.(UnexpectedTokenError)
+	if !ok {
+		t.Fatalf("expected an UnexpectedTokenError, got %v", err)
+	}
+	line := NewTokenizer(src).LineText(tkErr.Token.Line)
+	if expected := "Create func(name string) (*File \\ error \\ bool)"; line != expected || []rune(line)[tkErr.Token.Col-1] != '\\' {
+		t.Errorf("expected %q with the error at column %d, got %q", expected, tkErr.Token.Col, line)
+	}
+}
+
+func TestTokenizerReset(t *testing.T) {
+	tkr := NewTokenizer("a\n/* unterminated")
+	tkr.Next()
+	tkr.SkipWhite()
+	if _, err := tkr.Peek(); err == nil {
+		t.Fatalf("expected an error before Reset")
+	}
+
+	tkr.Reset("ñb")
+	if !reflect.DeepEqual(*tkr, *NewTokenizer("ñb")) {
+		t.Errorf("expected a fresh Tokenizer, got %+v", *tkr)
+	}
+	for _, r := range "ñb" {
+		tk, err := tkr.Next()
+		if err != nil || tk.Lexeme != r {
+			t.Fatalf("expected %q, got %q (error: %v)", r, tk.Lexeme, err)
+		}
+	}
+	if _, err := tkr.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestTokenizerNextWhile(t *testing.T) {
+	notSpace := func(r rune) bool { return !unicode.IsSpace(r) }
+	for _, newTokenizer := range []func(string) *Tokenizer{
+		NewTokenizer,
+		func(src string) *Tokenizer { return NewReaderTokenizer(iotest.OneByteReader(strings.NewReader(src))) },
+	} {
+		tkr := newTokenizer("  ñandú(x)\r\ny\rz")
+		tkr.SkipWhite()
+		s, tk, err := tkr.NextWhile(notSpace)
+		if err != nil || s != "ñandú(x)" || tk.Lexeme != 'ñ' || tk.Col != 3 || tk.BytePos != 2 {
+			t.Errorf("expected ñandú(x) from 1:3, got %q from %+v (error: %v)", s, tk, err)
+		}
+		if next, _ := tkr.Peek(); next.Lexeme != '\n' || next.Col != 11 || next.BytePos != 12 {
+			t.Errorf("expected the line ending next at 1:11, got %+v", next)
+		}
+
+		// Line endings are '\n', and are consumed like any other Lexeme.
+		s, _, err = tkr.NextWhile(func(r rune) bool { return r != 'z' })
+		if err != nil || s != "\ny\n" {
+			t.Errorf("expected line endings as new lines, got %q (error: %v)", s, err)
+		}
+		if next, _ := tkr.Peek(); next.Lexeme != 'z' || next.Line != 3 || next.Col != 1 {
+			t.Errorf("expected z next at 3:1, got %+v", next)
+		}
+
+		// Nothing consumed; the first Token is still next.
+		s, tk, err = tkr.NextWhile(unicode.IsDigit)
+		if err != nil || s != "" || tk.Lexeme != 'z' {
+			t.Errorf("expected nothing before z, got %q, %+v (error: %v)", s, tk, err)
+		}
+		if s, _, err = tkr.NextWhile(notSpace); err != nil || s != "z" {
+			t.Errorf("expected z until the end, got %q (error: %v)", s, err)
+		}
+		if _, _, err := tkr.NextWhile(notSpace); err != io.EOF {
+			t.Errorf("expected EOF at the end, got %v", err)
+		}
+	}
+
+	// pred sees each Lexeme once, up to the one it rejects.
+	var seen []rune
+	tkr := NewTokenizer("ab1c")
+	tkr.NextWhile(func(r rune) bool {
+		seen = append(seen, r)
+		return unicode.IsLetter(r)
+	})
+	if string(seen) != "ab1" {
+		t.Errorf("expected pred called for ab1, got %q", string(seen))
+	}
+
+	tkr = NewTokenizer("ab\xffc")
+	if _, _, err := tkr.NextWhile(notSpace); err != NewUTF8Error(1, 3, 2) {
+		t.Errorf("expected UTF-8 error at 1:3, got %v", err)
+	}
+}
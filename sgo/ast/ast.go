@@ -5,6 +5,12 @@
 // Package ast declares the types used to represent syntax trees for Go
 // packages.
 //
+// SGo syntax is represented by OptionalType nodes for ?T types, and by the
+// EntangledPos of ExprList and IdentList and the Entangled field of FieldList
+// for what follows a '\' in assignments, returns, declarations and result
+// lists. Walk and Inspect visit all of these, but a '\' in a list isn't a
+// node of its own; check the list's EntangledPos in the node that holds it.
+//
 package ast
 
 import (
@@ -83,6 +83,9 @@ func Walk(v Visitor, node Node) {
 		for _, f := range n.List {
 			Walk(v, f)
 		}
+		if n.Entangled != nil {
+			Walk(v, n.Entangled)
+		}
 
 	// Expressions
 	case *BadExpr, *Ident, *BasicLit:
@@ -0,0 +1,80 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+const walkSrc = `package p
+
+type T struct {
+	next ?*T
+	m    ?map[string]?func()
+}
+
+func find(key string) (*T \ error) {
+	var t \ err = find(key)
+	t, err = find(key)
+	if err != nil {
+		return \ err
+	}
+	return t \
+}
+
+func both() (t *T, ok bool \ err ?error)
+`
+
+func TestInspectSGoNodes(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.sgo", walkSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var optionals, entangledFields, entangledLists int
+	var idents []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.OptionalType:
+			optionals++
+		case *ast.FieldList:
+			if n.Entangled != nil {
+				entangledFields++
+			}
+		case *ast.AssignStmt:
+			if n.Lhs.EntangledPos > 0 || n.Rhs.EntangledPos > 0 {
+				entangledLists++
+			}
+		case *ast.ReturnStmt:
+			if n.Results.EntangledPos > 0 {
+				entangledLists++
+			}
+		case *ast.ValueSpec:
+			if n.Names.EntangledPos > 0 {
+				entangledLists++
+			}
+		case *ast.Ident:
+			if n.Name == "error" {
+				idents = append(idents, fset.Position(n.Pos()).String())
+			}
+		}
+		return true
+	})
+
+	if optionals != 4 {
+		t.Errorf("expected 4 optional types, got %d", optionals)
+	}
+	if entangledFields != 2 {
+		t.Errorf("expected 2 entangled fields, got %d", entangledFields)
+	}
+	if entangledLists != 3 {
+		t.Errorf("expected 3 entangled lists, got %d", entangledLists)
+	}
+	// The error types are only reachable through FieldList.Entangled.
+	if len(idents) != 2 {
+		t.Errorf("expected to visit 2 entangled error types, got %v", idents)
+	}
+}
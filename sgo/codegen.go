@@ -149,6 +149,58 @@ func TranslateFiles(files ...NamedFile) ([][]byte, []error) {
 //
 // For SGo: func(whence string, files ...NamedFile) ([][]byte, []error)
 func TranslateFilesFrom(whence string, files ...NamedFile) ([][]byte, []error) {
+	return translateFiles(whence, TranslateOptions{}, files...)
+}
+
+// TranslateOptions configures how SGo code is translated.
+type TranslateOptions struct {
+	// Importer resolves the packages imported by the translated files. The
+	// packages it returns must already have the SGo types their annotations
+	// describe. If nil, packages are found in the GOPATH and converted with
+	// the default and sgovendored annotations.
+	//
+	// For SGo: ?types.Importer
+	Importer types.Importer
+	// BuildContext is used by the default importer to find the imported
+	// packages' files and to pick their annotations gated on build
+	// constraints. If nil, go/build's default context is used.
+	//
+	// For SGo: ?*build.Context
+	BuildContext *build.Context
+	// GoVersion, if not empty, is the Go version the generated code must
+	// compile with, like "go1.21". Translating code that uses constructs that
+	// need a later version, like ranging over a function, is an error.
+	GoVersion string
+	// InsertNilAssertions makes the generated code check at run time, with a
+	// panic, that each variable that SGo proved not to be nil by an if
+	// condition really isn't, where the if narrows it. It is meant for
+	// testing SGo itself, and code whose annotations may be wrong; leave it
+	// unset for release builds.
+	InsertNilAssertions bool
+
+	// DualBuild makes TranslateFileWith write two Go files, for code that
+	// must build both with and without the sgo tool, like a package whose
+	// generated Go files are committed for plain Go users. The first, built
+	// with "-tags sgo", is the usual translation. The second, built
+	// otherwise, is translated without types, as by Strip: entangled returns
+	// are lowered to plain ones with zero values told from the function's
+	// result types, and forces and type assertions don't check for nils.
+	DualBuild bool
+
+	// allowUseUninitializedVars is set by a Session to translate code that
+	// has already been checked without it.
+	allowUseUninitializedVars bool
+}
+
+// TranslateFilesWith translates SGo code from the given files, configured by
+// opts. It returns the contents of the generated Go files.
+//
+// For SGo: func(opts TranslateOptions, files ...NamedFile) ([][]byte, []error)
+func TranslateFilesWith(opts TranslateOptions, files ...NamedFile) ([][]byte, []error) {
+	return translateFiles("", opts, files...)
+}
+
+func translateFiles(whence string, opts TranslateOptions, files ...NamedFile) ([][]byte, []error) {
 	var errs []error
 	fset := token.NewFileSet()
 
@@ -182,13 +234,27 @@ func TranslateFilesFrom(whence string, files ...NamedFile) ([][]byte, []error) {
 		return nil, errs
 	}
 
-	info, typeErrs := typecheck("translate", fset, whence, parsed...)
+	var paths []string
+	for _, named := range files {
+		path, err := filepath.Abs(named.Path)
+		if err != nil {
+			path = named.Path
+		}
+		paths = append(paths, path)
+	}
+
+	info, typeErrs := typecheck("translate", fset, whence, opts, paths, parsed...)
 	if len(typeErrs) > 0 {
 		errs = append(errs, makeErrList(fset, typeErrs))
 		return nil, errs
 	}
 
-	return translate(info, srcs, parsed, fset), errs
+	if opts.GoVersion != "" {
+		if versionErrs := checkGoVersion(opts.GoVersion, info, fset, parsed); len(versionErrs) > 0 {
+			return nil, versionErrs
+		}
+	}
+	return translate(info, srcs, parsed, fset, opts), errs
 }
 
 // TranslateFile translates SGo code from the given io.Reader to the io.Writer
@@ -196,27 +262,84 @@ func TranslateFilesFrom(whence string, files ...NamedFile) ([][]byte, []error) {
 //
 // For SGo: func(w func() (io.Writer \ error), r io.Reader, filename string) []error
 func TranslateFile(w func() (io.Writer, error), r io.Reader, filename string) []error {
-	gen, errs := TranslateFiles(NamedFile{filename, r})
+	return TranslateFileWith(TranslateOptions{}, w, r, filename)
+}
+
+// TranslateFileWith is like TranslateFile, configured by opts.
+//
+// If opts.DualBuild is set, w is called twice: first for the file built with
+// the sgo build tag, and then for the one built without it.
+//
+// For SGo: func(opts TranslateOptions, w func() (io.Writer \ error), r io.Reader, filename string) []error
+func TranslateFileWith(opts TranslateOptions, w func() (io.Writer, error), r io.Reader, filename string) []error {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []error{err}
+	}
+
+	gen, errs := TranslateFilesWith(opts, NamedFile{filename, bytes.NewReader(src)})
 	if len(errs) > 0 {
 		return errs
 	}
+	outputs := [][]byte{gen[0]}
 
-	to, err := w()
-	if err != nil {
-		return []error{err}
+	if opts.DualBuild {
+		// Name the file as translateFiles does, for the line comments.
+		name := filename
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, filename); err == nil {
+				name = rel
+			}
+		}
+		plain, err := strip(name, string(src))
+		if err != nil {
+			return []error{err}
+		}
+		outputs = [][]byte{
+			withBuildTag("sgo", gen[0]),
+			withBuildTag("!sgo", []byte(plain)),
+		}
 	}
 
-	_, err = to.Write(gen[0])
-	if err != nil {
-		return []error{err}
+	for _, output := range outputs {
+		to, err := w()
+		if err != nil {
+			return []error{err}
+		}
+
+		_, err = to.Write(output)
+		if err != nil {
+			return []error{err}
+		}
 	}
 
 	return nil
 }
 
+// withBuildTag adds to the Go source src a build constraint for tag. Existing
+// constraints in src still apply: a //go:build line is joined with tag by &&,
+// and +build lines must all be satisfied anyway.
+func withBuildTag(tag string, src []byte) []byte {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	for i, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if bytes.HasPrefix(trimmed, []byte("package ")) {
+			break
+		}
+		if expr := bytes.TrimPrefix(trimmed, []byte("//go:build ")); len(expr) < len(trimmed) {
+			lines[i] = []byte("//go:build " + tag + " && (" + string(expr) + ")\n")
+			return append([]byte("// +build "+tag+"\n\n"), bytes.Join(lines, nil)...)
+		}
+	}
+	return append([]byte("//go:build "+tag+"\n// +build "+tag+"\n\n"), src...)
+}
+
 func makeErrList(fset *token.FileSet, errs []error) scanner.ErrorList {
 	var errList scanner.ErrorList
 	for _, err := range errs {
+		if v, ok := err.(types.Error); ok {
+			err = &v
+		}
 		if v, ok := err.(*types.Error); ok {
 			errList = append(errList, &scanner.Error{
 				Pos: fset.Position(v.Pos),
@@ -232,17 +355,28 @@ func makeErrList(fset *token.FileSet, errs []error) scanner.ErrorList {
 	return errList
 }
 
-func typecheck(path string, fset *token.FileSet, whence string, sgoFiles ...*ast.File) (*types.Info, []error) {
+// typecheck typechecks sgoFiles as package path. If paths, the files' paths,
+// are given, the packages they import are recorded for importer.Dependents.
+func typecheck(path string, fset *token.FileSet, whence string, opts TranslateOptions, paths []string, sgoFiles ...*ast.File) (*types.Info, []error) {
 	var errors []error
-	imp, err := importer.DefaultFrom(sgoFiles, whence)
-	if err != nil {
-		return nil, []error{err}
+	imp := opts.Importer
+	if imp == nil {
+		ctx := opts.BuildContext
+		if ctx == nil {
+			ctx = &build.Default
+		}
+		var err error
+		imp, err = importer.DefaultForFiles(ctx, sgoFiles, paths, whence)
+		if err != nil {
+			return nil, []error{err}
+		}
 	}
 	cfg := &types.Config{
 		Error: func(err error) {
 			errors = append(errors, err)
 		},
-		Importer: imp,
+		Importer:                  imp,
+		AllowUseUninitializedVars: opts.allowUseUninitializedVars,
 	}
 	info := &types.Info{
 		Types:      map[ast.Expr]types.TypeAndValue{},
@@ -252,18 +386,19 @@ func typecheck(path string, fset *token.FileSet, whence string, sgoFiles ...*ast
 		Selections: map[*ast.SelectorExpr]*types.Selection{},
 		Scopes:     map[ast.Node]*types.Scope{},
 		InitOrder:  []*types.Initializer{},
+		Narrowings: map[ast.Expr][]*types.Narrowing{},
 	}
-	_, err = cfg.Check(path, fset, sgoFiles, info)
+	_, err := cfg.Check(path, fset, sgoFiles, info)
 	if err != nil {
 		return nil, errors
 	}
 	return info, nil
 }
 
-func translate(info *types.Info, srcs [][]byte, sgoFiles []*ast.File, fset *token.FileSet) [][]byte {
+func translate(info *types.Info, srcs [][]byte, sgoFiles []*ast.File, fset *token.FileSet, opts TranslateOptions) [][]byte {
 	dsts := make([][]byte, 0, len(sgoFiles))
 	for i, sgoFile := range sgoFiles {
-		dsts = append(dsts, convertAST(info, srcs[i], sgoFile, fset))
+		dsts = append(dsts, convertAST(info, srcs[i], sgoFile, fset, opts))
 	}
 	return dsts
 }
@@ -388,7 +523,7 @@ func (v visitorFunc) Visit(node ast.Node) (w ast.Visitor) {
 	return v(node)
 }
 
-func convertAST(info *types.Info, src []byte, sgoAST *ast.File, fset *token.FileSet) []byte {
+func convertAST(info *types.Info, src []byte, sgoAST *ast.File, fset *token.FileSet, opts TranslateOptions) []byte {
 	c := converter{
 		Info:          info,
 		src:           src,
@@ -397,11 +532,16 @@ func convertAST(info *types.Info, src []byte, sgoAST *ast.File, fset *token.File
 		file:          sgoAST,
 		nextIsNewLine: true,
 	}
+	if opts.InsertNilAssertions {
+		c.nilAssertions = nilAssertions(info, fset, sgoAST)
+	}
+	c.forces = newFileForces(info, fset, sgoAST)
 	c.docAnns = c.annotationsFromDocs()
 	autogenComment := []byte("// Autogenerated by SGo. DO NOT EDIT!\n\n")
 	c.putChunks(c.base, nil, autogenComment)
 	c.convertFile(sgoAST)
 	c.putChunks(c.base, src[c.lastChunkEnd:], nil)
+	c.dstChunks = append(c.dstChunks, c.forces.helperDecl())
 	return bytes.Join(c.dstChunks, nil)
 }
 
@@ -426,6 +566,12 @@ type converter struct {
 	// for putSourceMap
 	nextIsNewLine bool
 
+	// for insertNilAssertion
+	nilAssertions map[token.Pos][]byte
+
+	// for convertForceExpr
+	forces fileForces
+
 	fset *token.FileSet
 }
 
@@ -435,6 +581,10 @@ func (c *converter) convertFile(v *ast.File) {
 	}
 	c.annotationFromDocs(v)
 	c.convertIdent(v.Name)
+	if decl := c.forces.importDecl(); decl != nil {
+		end := v.Name.End()
+		c.putChunks(int(end)-1, c.src[c.lastChunkEnd:int(end)-c.base-1], decl)
+	}
 	for _, v := range v.Decls {
 		c.convertDecl(v)
 	}
@@ -558,7 +708,11 @@ func (c *converter) convertFuncDecl(v *ast.FuncDecl) {
 	c.convertFieldList(v.Recv)
 	c.convertFuncType(v.Type)
 	c.convertIdent(v.Name)
-	unset := c.setLastFunc(c.Info.ObjectOf(v.Name).Type().(*types.Signature), v.Type)
+	var sig *types.Signature
+	if obj := c.Info.ObjectOf(v.Name); obj != nil {
+		sig = obj.Type().(*types.Signature)
+	}
+	unset := c.setLastFunc(sig, v.Type)
 	defer unset()
 	c.convertBlockStmt(v.Body)
 }
@@ -778,39 +932,25 @@ func (c *converter) convertReturnStmt(v *ast.ReturnStmt) {
 		return
 	}
 	c.annotationFromDocs(v)
+	if v.Results.EntangledPos == 1 && len(v.Results.List) == 0 {
+		// return \, from func() \ error
+		chunk := []byte("nil")
+		if c.entangledIsBool() {
+			chunk = []byte("true")
+		}
+		retEnd := int(v.End()-1) - c.base
+		backslash := bytes.IndexByte(c.src[retEnd:], '\\') + retEnd
+		c.putChunks(backslash+1+c.base, c.src[c.lastChunkEnd:backslash], chunk)
+		return
+	}
 	if v.Results.EntangledPos == 1 {
 		// return \ err
-		resultsLen := c.lastFunc.Results().Len()
-		results := make([][]byte, 0, resultsLen)
-		for i := 0; i < resultsLen; i++ {
-			typ := c.lastFunc.Results().At(i).Type()
-			switch underlying := typ.Underlying().(type) {
-			case *types.Pointer, *types.Map, *types.Slice, *types.Signature, *types.Interface, *types.Optional:
-				results = append(results, []byte("nil"))
-			case *types.Struct:
-				typ := c.lastFuncAST.Results.List[i].Type
-				buf := &bytes.Buffer{}
-				printer.Fprint(buf, c.fset, typ)
-				results = append(results, append(buf.Bytes(), '{', '}'))
-			case *types.Basic:
-				info := underlying.Info()
-				switch {
-				case info&types.IsBoolean != 0:
-					results = append(results, []byte("false"))
-				case info&types.IsInteger != 0:
-					results = append(results, []byte("0"))
-				case info&types.IsFloat != 0, info&types.IsComplex != 0:
-					results = append(results, []byte("0.0"))
-				case info&types.IsString != 0:
-					results = append(results, []byte(`""`))
-				default:
-					results = append(results, []byte("nil"))
-				}
-			default:
-				panic(fmt.Sprintf("unhandled Type %v", typ))
-			}
+		results := c.zeroResults()
+		resultsLen := len(results)
+		var text []byte
+		if resultsLen > 0 {
+			text = append(bytes.Join(results, []byte(", ")), []byte(", ")...)
 		}
-		text := append(bytes.Join(results, []byte(", ")), []byte(", ")...)
 		c.putChunks(int(v.Results.Pos())-1, c.src[c.lastChunkEnd:int(v.Pos())-c.base-1+len("return ")], text)
 	}
 	for _, v := range v.Results.List {
@@ -819,13 +959,62 @@ func (c *converter) convertReturnStmt(v *ast.ReturnStmt) {
 	if v.Results.EntangledPos > 1 {
 		// return x, y, z \
 		chunk := ", nil"
-		if c.lastFunc.Results().Entangled().Type() == types.Typ[types.Bool] {
+		if c.entangledIsBool() {
 			chunk = ", true"
 		}
 		c.putChunks(int(v.Results.End())+1, c.src[c.lastChunkEnd:int(v.Results.End())-c.base-1], []byte(chunk))
 	}
 }
 
+// zeroResults returns the zero values for the results of the function being
+// converted, before its entangled one.
+func (c *converter) zeroResults() [][]byte {
+	if c.lastFunc == nil {
+		return zeroResultsFromAST(c.fset, c.lastFuncAST.Results)
+	}
+	resultsLen := c.lastFunc.Results().Len()
+	results := make([][]byte, 0, resultsLen)
+	for i := 0; i < resultsLen; i++ {
+		typ := c.lastFunc.Results().At(i).Type()
+		switch underlying := typ.Underlying().(type) {
+		case *types.Pointer, *types.Map, *types.Slice, *types.Signature, *types.Interface, *types.Optional:
+			results = append(results, []byte("nil"))
+		case *types.Struct:
+			typ := c.lastFuncAST.Results.List[i].Type
+			buf := &bytes.Buffer{}
+			printer.Fprint(buf, c.fset, typ)
+			results = append(results, append(buf.Bytes(), '{', '}'))
+		case *types.Basic:
+			info := underlying.Info()
+			switch {
+			case info&types.IsBoolean != 0:
+				results = append(results, []byte("false"))
+			case info&types.IsInteger != 0:
+				results = append(results, []byte("0"))
+			case info&types.IsFloat != 0, info&types.IsComplex != 0:
+				results = append(results, []byte("0.0"))
+			case info&types.IsString != 0:
+				results = append(results, []byte(`""`))
+			default:
+				results = append(results, []byte("nil"))
+			}
+		default:
+			panic(fmt.Sprintf("unhandled Type %v", typ))
+		}
+	}
+	return results
+}
+
+// entangledIsBool reports whether the entangled result of the function being
+// converted is a bool, whose "no error" value is true instead of nil.
+func (c *converter) entangledIsBool() bool {
+	if c.lastFunc == nil {
+		id, ok := c.lastFuncAST.Results.Entangled.Type.(*ast.Ident)
+		return ok && id.Name == "bool"
+	}
+	return c.lastFunc.Results().Entangled().Type() == types.Typ[types.Bool]
+}
+
 func (c *converter) convertBadStmt(v *ast.BadStmt) {
 	if v == nil {
 		return
@@ -865,6 +1054,7 @@ func (c *converter) convertBlockStmt(v *ast.BlockStmt) {
 		return
 	}
 	c.annotationFromDocs(v)
+	c.insertNilAssertion(v.Lbrace + 1)
 	for _, v := range v.List {
 		c.convertStmt(v)
 	}
@@ -1032,6 +1222,7 @@ func (c *converter) convertIfStmt(v *ast.IfStmt) {
 	c.convertExpr(v.Cond)
 	c.convertBlockStmt(v.Body)
 	c.convertStmt(v.Else)
+	c.insertNilAssertion(v.End())
 }
 
 func (c *converter) convertExpr(v ast.Expr) {
@@ -1348,15 +1539,6 @@ func (c *converter) convertOptionalType(v *ast.OptionalType) {
 	c.convertExpr(v.Elt)
 }
 
-func (c *converter) convertForceExpr(v *ast.ForceExpr) {
-	if v == nil {
-		return
-	}
-	c.annotationFromDocs(v)
-	c.convertExpr(v.X)
-	c.putChunks(int(v.End()), c.src[c.lastChunkEnd:int(v.End())-1-c.base], nil)
-}
-
 func (c *converter) convertUnaryExpr(v *ast.UnaryExpr) {
 	if v == nil {
 		return
@@ -1404,7 +1586,13 @@ func (c *converter) convertFieldList(v *ast.FieldList) {
 	for _, v := range v.List {
 		c.convertField(v)
 	}
-	if v.Entangled != nil {
+	if v.Entangled != nil && len(v.List) == 0 {
+		// (\ error) or just \ error
+		entangledPos := int(v.Entangled.Pos()-1) - c.base
+		backslash := bytes.LastIndexByte(c.src[c.lastChunkEnd:entangledPos], '\\') + c.lastChunkEnd
+		c.putChunks(int(v.Entangled.Pos()-1), c.src[c.lastChunkEnd:backslash], nil)
+		c.convertField(v.Entangled)
+	} else if v.Entangled != nil {
 		entangledEnd := int(v.List[len(v.List)-1].End()-1) - c.base
 		c.putChunks(int(v.Entangled.Pos()-1), c.src[c.lastChunkEnd:entangledEnd], []byte{',', ' '})
 		c.convertField(v.Entangled)
@@ -1443,7 +1631,8 @@ func (c *converter) convertFuncLit(v *ast.FuncLit) {
 		return
 	}
 	c.annotationFromDocs(v)
-	unset := c.setLastFunc(c.Info.TypeOf(v).(*types.Signature), v.Type)
+	sig, _ := c.Info.TypeOf(v).(*types.Signature)
+	unset := c.setLastFunc(sig, v.Type)
 	defer unset()
 	c.convertFuncType(v.Type)
 	c.convertBlockStmt(v.Body)
@@ -0,0 +1,554 @@
+package sgo
+
+import (
+	"bytes"
+	"flag"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/importer"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+// TestGolden translates the testdata/*.sgo files and compares the results with
+// the corresponding testdata/*.golden files.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.sgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Translate from the file's name alone, so that the source map
+		// comments don't depend on the directory.
+		translated, errs := TranslateFiles(NamedFile{filepath.Base(path), f})
+		f.Close()
+		if len(errs) > 0 {
+			t.Errorf("%s: unexpected errors: %v", path, errs)
+			continue
+		}
+
+		golden := strings.TrimSuffix(path, ".sgo") + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, translated[0], 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(expected, translated[0]) {
+			t.Errorf("%s: translation doesn't match %s; got:\n%s", path, golden, translated[0])
+		}
+	}
+}
+
+var sourceMapComment = regexp.MustCompile(`^/\* [^:]+:(\d+) \*/ `)
+
+// TestTranslatePreservesBlankLines checks that the translated code has blank
+// lines at the same places as the source, so that both look alike.
+func TestTranslatePreservesBlankLines(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/blanklines.sgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translated, errs := TranslateFiles(NamedFile{"blanklines.sgo", bytes.NewReader(src)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	srcLines := strings.Split(string(src), "\n")
+	outLines := strings.Split(string(translated[0]), "\n")
+	checked := 0
+	for i, l := range outLines {
+		m := sourceMapComment.FindStringSubmatch(l)
+		if m == nil || i == 0 {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		srcBlank := strings.TrimSpace(srcLines[n-2]) == ""
+		outBlank := strings.TrimSpace(outLines[i-1]) == ""
+		if srcBlank != outBlank {
+			t.Errorf("line %d: blank line before it in source is %v, in translation is %v", n, srcBlank, outBlank)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatalf("no source map comments found in translation:\n%s", translated[0])
+	}
+}
+
+type testFakeImporter map[string]*types.Package
+
+func (imp testFakeImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp[path]; ok {
+		return pkg, nil
+	}
+	return nil, &types.Error{Msg: "can't find package " + path}
+}
+
+func TestTranslateWithImporter(t *testing.T) {
+	// A package as if annotated with:
+	//
+	// 	Lookup func(key string) (*int \ error)
+	pkg := types.NewPackage("example.com/fake", "fake")
+	results := types.NewTupleEntangled(
+		types.NewVar(token.NoPos, pkg, "", types.NewPointer(types.Typ[types.Int])),
+		types.NewVar(token.NoPos, pkg, "", types.NewOptional(types.Universe.Lookup("error").Type())),
+	)
+	params := types.NewTuple(types.NewParam(token.NoPos, pkg, "key", types.Typ[types.String]))
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, "Lookup", types.NewSignature(nil, params, results, false)))
+	pkg.MarkComplete()
+
+	opts := TranslateOptions{Importer: testFakeImporter{pkg.Path(): pkg}}
+
+	src := `package example
+
+import "example.com/fake"
+
+func f() int {
+	n \ err := fake.Lookup("answer")
+	if err != nil {
+		return 0
+	}
+	return *n
+}
+`
+	var buf bytes.Buffer
+	errs := TranslateFileWith(opts, func() (io.Writer, error) { return &buf, nil }, strings.NewReader(src), "example.sgo")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !strings.Contains(buf.String(), `n, err := fake.Lookup("answer")`) {
+		t.Errorf("unexpected translation:\n%s", buf.String())
+	}
+
+	_, errs = TranslateFilesWith(opts, NamedFile{"example.sgo", strings.NewReader(strings.Replace(src, "if err != nil", "if false", 1))})
+	if len(errs) == 0 {
+		t.Errorf("expected errors using uncollapsed entangled value")
+	}
+
+	_, errs = TranslateFilesWith(opts, NamedFile{"example.sgo", strings.NewReader(strings.Replace(src, "example.com/fake", "example.com/missing", 1))})
+	if len(errs) == 0 {
+		t.Errorf("expected errors importing missing package")
+	}
+}
+
+func TestTranslateRecordsDependents(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "sgo-dependents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	pkgDir := filepath.Join(gopath, "src", "example.com", "dep")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, "dep.go"), []byte("package dep\n\nfunc Get() *int { return new(int) }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := build.Default
+	ctx.GOPATH = gopath
+	ctx.CgoEnabled = false
+
+	src := `package example
+
+import "example.com/dep"
+
+var n = dep.Get()
+`
+	path := filepath.Join(gopath, "src", "example.com", "user", "user.sgo")
+	_, errs := TranslateFilesWith(TranslateOptions{BuildContext: &ctx}, NamedFile{path, strings.NewReader(src)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := importer.Dependents("example.com/dep"); len(got) != 1 || got[0] != path {
+		t.Errorf("expected %s to be recorded as dependent, got %v", path, got)
+	}
+}
+
+func TestTranslateNilAssertions(t *testing.T) {
+	// lookup returns nil, but SGo believes it doesn't, as it would with a
+	// wrong annotation.
+	src := `package main
+
+import "unsafe"
+
+type T struct {
+	N int
+}
+
+func lookup() (*T \ error) {
+	var zero uintptr
+	return (*T)(unsafe.Pointer(zero)) \
+}
+
+func f(x ?*T) int {
+	if x == nil {
+		return 0
+	}
+	return x.N
+}
+
+func main() {
+	t \ err := lookup()
+	if err == nil {
+		println(f(t))
+	}
+}
+`
+	translate := func(opts TranslateOptions) string {
+		opts.Importer = testFakeImporter{"unsafe": types.Unsafe}
+		gen, errs := TranslateFilesWith(opts, NamedFile{"main.sgo", strings.NewReader(src)})
+		if len(errs) > 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		return string(gen[0])
+	}
+
+	if gen := translate(TranslateOptions{}); strings.Contains(gen, "panic(") {
+		t.Errorf("unexpected nil assertions:\n%s", gen)
+	}
+
+	gen := translate(TranslateOptions{InsertNilAssertions: true})
+	for _, expected := range []string{
+		`}; if x == nil { panic("sgo: x is nil, but the condition at main.sgo:15:5 proved it isn't") }`,
+		`if err == nil { if t == nil { panic("sgo: t is nil, but the condition at main.sgo:23:5 proved it isn't") };`,
+	} {
+		if !strings.Contains(gen, expected) {
+			t.Errorf("expected %q in translation:\n%s", expected, gen)
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	output, err := goRun([]byte(gen))
+	if err == nil || !strings.Contains(string(output), "panic: sgo: t is nil") {
+		t.Errorf("expected the assertion on t to fail; got error %v, output:\n%s", err, output)
+	}
+}
+
+func TestTranslateForce(t *testing.T) {
+	src := `package main
+
+type T struct {
+	N int
+}
+
+func find(ts map[string]*T, key string) ?*T {
+	t \ ok := ts[key]
+	if !ok {
+		return nil
+	}
+	return t
+}
+
+func main() {
+	ts := map[string]*T{"a": &T{N: 1}}
+	a := find(ts, "a")
+	println(a!.N)
+	println(a.N + 1)
+	var f ?func() int = func() int { return 3 }
+	println(f!())
+	println(find(ts, "b")!.N)
+}
+`
+	gen, errs := TranslateFilesWith(TranslateOptions{}, NamedFile{"main.sgo", strings.NewReader(src)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, expected := range []string{
+		`println(func() *T { if v := a; v != nil { return v }; panic("sgo: a is nil at main.sgo:18:10") }().N)`,
+		`println(func() func() int { if v := f; v != nil { return v }; panic("sgo: f is nil at main.sgo:21:10") }()())`,
+	} {
+		if !strings.Contains(string(gen[0]), expected) {
+			t.Errorf("expected %q in translation:\n%s", expected, gen[0])
+		}
+	}
+
+	// A type that can't be written in the file is checked by a helper.
+	pkg := types.NewPackage("example.com/fake", "fake")
+	hidden := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "hidden", nil), types.NewStruct(nil, nil), nil)
+	results := types.NewTuple(types.NewVar(token.NoPos, pkg, "", types.NewOptional(types.NewPointer(hidden))))
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, "Get", types.NewSignature(nil, nil, results, false)))
+	pkg.MarkComplete()
+	hiddenSrc := "package example\n\nimport \"example.com/fake\"\n\nvar h = fake.Get()!\n"
+	hiddenGen, errs := TranslateFilesWith(TranslateOptions{Importer: testFakeImporter{pkg.Path(): pkg}}, NamedFile{"example.sgo", strings.NewReader(hiddenSrc)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, expected := range []string{
+		`package example; import (__sgo_reflect "reflect")`,
+		`var h = __sgo_force_example_sgo(fake.Get(), "sgo: fake.Get() is nil at example.sgo:5:9")`,
+		`func __sgo_force_example_sgo[T any](v T, msg string) T { if __sgo_reflect.ValueOf(&v).Elem().IsNil() { panic(msg) }; return v }`,
+	} {
+		if !strings.Contains(string(hiddenGen[0]), expected) {
+			t.Errorf("expected %q in translation:\n%s", expected, hiddenGen[0])
+		}
+	}
+	_, errs = TranslateFilesWith(TranslateOptions{Importer: testFakeImporter{pkg.Path(): pkg}, GoVersion: "go1.17"}, NamedFile{"example.sgo", strings.NewReader(hiddenSrc)})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "example.sgo:5:19: fake.Get()! requires go1.18 or later, as its type *example.com/fake.hidden can't be written in this file (targeting go1.17)") {
+		t.Errorf("expected an error targeting go1.17, got %v", errs)
+	}
+
+	// Packages that the type names but the file doesn't import are added.
+	// net/http and net/url as if annotated with:
+	//
+	// 	Request struct { URL ?*url.URL }
+	urlPkg := types.NewPackage("net/url", "url")
+	urlType := types.NewNamed(types.NewTypeName(token.NoPos, urlPkg, "URL", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, urlPkg, "Path", types.Typ[types.String], false),
+	}, nil), nil)
+	urlPkg.Scope().Insert(urlType.Obj())
+	urlPkg.MarkComplete()
+	httpPkg := types.NewPackage("net/http", "http")
+	request := types.NewNamed(types.NewTypeName(token.NoPos, httpPkg, "Request", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, httpPkg, "URL", types.NewOptional(types.NewPointer(urlType)), false),
+	}, nil), nil)
+	httpPkg.Scope().Insert(request.Obj())
+	httpPkg.MarkComplete()
+	reqSrc := `package main
+
+import "net/http"
+
+func main() {
+	req := &http.Request{}
+	println(req.URL == nil)
+	println(req.URL!.Path)
+}
+`
+	reqGen, errs := TranslateFilesWith(TranslateOptions{Importer: testFakeImporter{"net/http": httpPkg, "net/url": urlPkg}}, NamedFile{"main.sgo", strings.NewReader(reqSrc)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, expected := range []string{
+		`package main; import (__sgo_url "net/url")`,
+		`println(func() *__sgo_url.URL { if v := req.URL; v != nil { return v }; panic("sgo: req.URL is nil at main.sgo:8:10") }().Path)`,
+	} {
+		if !strings.Contains(string(reqGen[0]), expected) {
+			t.Errorf("expected %q in translation:\n%s", expected, reqGen[0])
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	output, err := goRun(gen[0])
+	if err == nil || !strings.HasPrefix(string(output), "1\n2\n3\npanic: sgo: find(ts, \"b\") is nil at main.sgo:22:10") {
+		t.Errorf("expected the last force to fail; got error %v, output:\n%s", err, output)
+	}
+	output, err = goRun(reqGen[0])
+	if err == nil || !strings.HasPrefix(string(output), "true\npanic: sgo: req.URL is nil at main.sgo:8:10") {
+		t.Errorf("expected the force of req.URL to fail; got error %v, output:\n%s", err, output)
+	}
+}
+
+func TestTranslateGoVersion(t *testing.T) {
+	src := `package example
+
+func count(yield func(int) bool) {
+	for i := 0; i < 3 && yield(i); i++ {
+	}
+}
+
+func sum() int {
+	n := 0
+	for i := range count {
+		n += i
+	}
+	return n
+}
+`
+	for _, c := range []struct {
+		version string
+		err     string
+	}{
+		{"", ""},
+		{"go1.23", ""},
+		{"go1.24.1", ""},
+		{"go1.21", "example.sgo:10:2: range over function requires go1.23 or later (targeting go1.21)"},
+		{"1.21", `invalid Go version "1.21"; expected one like go1.21`},
+		{"go1.x", `invalid Go version "go1.x"; expected one like go1.21`},
+	} {
+		_, errs := TranslateFilesWith(TranslateOptions{GoVersion: c.version}, NamedFile{"example.sgo", strings.NewReader(src)})
+		if c.err == "" {
+			if len(errs) > 0 {
+				t.Errorf("%q: unexpected errors: %v", c.version, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Error() != c.err {
+			t.Errorf("%q: expected error %q, got %v", c.version, c.err, errs)
+		}
+	}
+
+	// SGo has no generics, so they're a syntax error whatever the target.
+	generic := "package example\n\nfunc id[T any](x T) T { return x }\n"
+	if _, errs := TranslateFilesWith(TranslateOptions{GoVersion: "go1.17"}, NamedFile{"example.sgo", strings.NewReader(generic)}); len(errs) == 0 {
+		t.Errorf("expected errors translating generic code")
+	}
+}
+
+func TestTranslateSingleton(t *testing.T) {
+	// A package as if annotated with:
+	//
+	// 	(*Once) {
+	// 		Do (*Once) func(f func())
+	// 	}
+	syncPkg := types.NewPackage("sync", "sync")
+	onceName := types.NewTypeName(token.NoPos, syncPkg, "Once", nil)
+	once := types.NewNamed(onceName, types.NewStruct(nil, nil), nil)
+	recv := types.NewVar(token.NoPos, syncPkg, "o", types.NewPointer(once))
+	params := types.NewTuple(types.NewParam(token.NoPos, syncPkg, "f", types.NewSignature(nil, nil, nil, false)))
+	once.AddMethod(types.NewFunc(token.NoPos, syncPkg, "Do", types.NewSignature(recv, params, nil, false)))
+	syncPkg.Scope().Insert(onceName)
+	syncPkg.MarkComplete()
+
+	src := `package singleton
+
+import "sync"
+
+type Config struct {
+	Name string
+}
+
+func (c *Config) Greeting() string {
+	return "hello, " + c.Name
+}
+
+var (
+	once     sync.Once
+	instance ?*Config
+)
+
+// GetInstance returns the Config, created the first time it's called.
+func GetInstance() *Config {
+	once.Do(func() {
+		instance = &Config{Name: "default"}
+	})
+	if i := instance; i != nil {
+		return i
+	}
+	panic("unreachable: once.Do sets instance")
+}
+
+func Greeting() string {
+	return GetInstance().Greeting()
+}
+`
+	opts := TranslateOptions{Importer: testFakeImporter{"sync": syncPkg}}
+	translated, errs := TranslateFilesWith(opts, NamedFile{"singleton.sgo", strings.NewReader(src)})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	gen := string(translated[0])
+	for _, expected := range []string{
+		"// For SGo: func() *Config\n",
+		"return GetInstance().Greeting()",
+	} {
+		if !strings.Contains(gen, expected) {
+			t.Errorf("expected %q in translation:\n%s", expected, gen)
+		}
+	}
+}
+
+func TestTranslateDualBuild(t *testing.T) {
+	src := `package main
+
+type T struct {
+	N int
+}
+
+func find(ts []*T, n int) (*T \ bool) {
+	for _, t := range ts {
+		if t.N == n {
+			return t \
+		}
+	}
+	return \ false
+}
+
+func main() {
+	var p ?*T
+	t \ ok := find([]*T{{1}, {2}}, 2)
+	if ok {
+		p = t
+	}
+	println(p!.N)
+}
+`
+	var outputs []*bytes.Buffer
+	w := func() (io.Writer, error) {
+		outputs = append(outputs, &bytes.Buffer{})
+		return outputs[len(outputs)-1], nil
+	}
+	errs := TranslateFileWith(TranslateOptions{DualBuild: true}, w, strings.NewReader(src), "main.sgo")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(outputs))
+	}
+	for i, expected := range []string{
+		"//go:build sgo\n// +build sgo\n\n// Autogenerated by SGo. DO NOT EDIT!\n\npackage main\n",
+		"//go:build !sgo\n// +build !sgo\n\n// Autogenerated by SGo. DO NOT EDIT!\n\npackage main\n",
+	} {
+		if !strings.HasPrefix(outputs[i].String(), expected) {
+			t.Errorf("expected file %d to start with %q, got:\n%s", i, expected, outputs[i])
+		}
+	}
+	if gen := outputs[0].String(); !strings.Contains(gen, `panic("sgo: p is nil at main.sgo:22:10")`) {
+		t.Errorf("expected the sgo file to check the force, got:\n%s", gen)
+	}
+	if gen := outputs[1].String(); strings.Contains(gen, "panic(") || !strings.Contains(gen, "/* main.sgo:22 */") {
+		t.Errorf("expected the plain Go file not to check the force, got:\n%s", gen)
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir, err := ioutil.TempDir("", "sgo-dualbuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module dualbuild\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "main_sgo.go"), outputs[0].Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), outputs[1].Bytes(), 0644)
+	for _, tags := range []string{"sgo", ""} {
+		// Build constraints only apply to files found in a directory, not to
+		// those listed on the command line.
+		cmd := exec.Command("go", "run", "-tags", tags, ".")
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil || string(output) != "2\n" {
+			t.Errorf("with tags %q: expected output 2, got error %v, output:\n%s", tags, err, output)
+		}
+	}
+}
+
+func TestWithBuildTag(t *testing.T) {
+	src := "// Package p does things.\n//go:build linux\n// +build linux\n\npackage p\n"
+	expected := "// +build sgo\n\n// Package p does things.\n//go:build sgo && (linux)\n// +build linux\n\npackage p\n"
+	if got := string(withBuildTag("sgo", []byte(src))); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,398 @@
+package sgo
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/importer"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// A PackageCoverage tells which of the symbols of a Go package that SGo code
+// uses are annotated. Only symbols whose types have something that can be
+// nil are counted; the others are the same with or without annotations.
+type PackageCoverage struct {
+	Path string
+	// Covered and Uncovered are the names of the annotated and unannotated
+	// symbols, sorted, as written in annotations: "F", "T.Field", "(*T).M".
+	Covered   []string
+	Uncovered []string
+}
+
+// Percent returns the percentage of the package's used symbols that are
+// annotated.
+func (c PackageCoverage) Percent() float64 {
+	total := len(c.Covered) + len(c.Uncovered)
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(len(c.Covered)) / float64(total)
+}
+
+// AnnotationCoverage reports, for each Go package used by the SGo package in
+// dir or by the SGo packages it imports, directly or not, which of its used
+// symbols have annotations, either built in or in a sgovendor directory. The
+// unannotated ones are converted to SGo conservatively, so their pointers,
+// maps, etc. are all optional.
+//
+// The packages are sorted by path.
+//
+// For SGo: func(dir string) ([]PackageCoverage, []error)
+func AnnotationCoverage(dir string) ([]PackageCoverage, []error) {
+	return AnnotationCoverageWith(TranslateOptions{}, dir)
+}
+
+// AnnotationCoverageWith is like AnnotationCoverage, configured by opts.
+//
+// For SGo: func(opts TranslateOptions, dir string) ([]PackageCoverage, []error)
+func AnnotationCoverageWith(opts TranslateOptions, dir string) ([]PackageCoverage, []error) {
+	ctx := opts.BuildContext
+	if ctx == nil {
+		ctx = &build.Default
+	}
+	w := &coverageWalker{
+		opts:    opts,
+		ctx:     ctx,
+		visited: map[string]bool{},
+		used:    map[string]map[string]string{},
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, []error{err}
+	}
+	if errs := w.walk(dir); len(errs) > 0 {
+		return nil, errs
+	}
+
+	var paths []string
+	for path := range w.used {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var covs []PackageCoverage
+	for _, path := range paths {
+		cov := PackageCoverage{Path: path}
+		names := w.used[path]
+		anns := map[string]*annotations.Annotation{}
+		for name, whence := range names {
+			ann, ok := anns[whence]
+			if !ok {
+				ann, err = importer.Annotations(ctx, path, whence)
+				if err != nil {
+					return nil, []error{err}
+				}
+				anns[whence] = ann
+			}
+			if isAnnotated(ann, name) {
+				cov.Covered = append(cov.Covered, name)
+			} else {
+				cov.Uncovered = append(cov.Uncovered, name)
+			}
+		}
+		sort.Strings(cov.Covered)
+		sort.Strings(cov.Uncovered)
+		covs = append(covs, cov)
+	}
+	return covs, nil
+}
+
+// MissingAnnotations returns the exported symbols of the Go package pkg that
+// have no annotation in a, the package's Annotation, sorted and named as in
+// annotations: "F", "T.Field", "(*T).M". As in AnnotationCoverage, only
+// symbols whose types have something that can be nil are counted.
+//
+// Unlike AnnotationCoverage, it doesn't matter whether the symbols are used.
+// This makes it useful to check that a package stays fully annotated, for
+// example when a new version of it adds functions.
+//
+// For SGo: func(pkg *types.Package, a ?*annotations.Annotation) []string
+func MissingAnnotations(pkg *types.Package, a *annotations.Annotation) []string {
+	owners := map[*types.Package]map[*types.Var]string{}
+	var missing []string
+	add := func(obj types.Object) {
+		if !obj.Exported() || !hasNilable(obj) {
+			return
+		}
+		if name, ok := annotationName(obj, owners); ok && !isAnnotated(a, name) {
+			missing = append(missing, name)
+		}
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func, *types.Var:
+			add(obj)
+		case *types.TypeName:
+			named, ok := obj.Type().(*types.Named)
+			if !ok || !obj.Exported() {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				add(named.Method(i))
+			}
+			switch t := named.Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < t.NumFields(); i++ {
+					add(t.Field(i))
+				}
+			case *types.Interface:
+				for i := 0; i < t.NumExplicitMethods(); i++ {
+					add(t.ExplicitMethod(i))
+				}
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+type coverageWalker struct {
+	opts TranslateOptions
+	ctx  *build.Context
+	// visited holds the directories of the SGo packages walked.
+	visited map[string]bool
+	// used maps the path of each Go package to the names of its used
+	// symbols, each to the directory it's used from, whose sgovendor
+	// directories apply.
+	used map[string]map[string]string
+}
+
+// walk typechecks the SGo package in dir, records the symbols it uses from Go
+// packages, and walks the SGo packages it imports.
+func (w *coverageWalker) walk(dir string) []error {
+	if w.visited[dir] {
+		return nil
+	}
+	w.visited[dir] = true
+
+	paths, err := sgoFilesIn(dir)
+	if err != nil {
+		return []error{err}
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return []error{err}
+		}
+		files = append(files, f)
+	}
+	opts := w.opts
+	opts.BuildContext = w.ctx
+	info, errs := typecheck(dir, fset, dir, opts, nil, files...)
+	if len(errs) > 0 {
+		return []error{makeErrList(fset, errs)}
+	}
+
+	sgoPkgs := map[string]bool{}
+	var imported []string
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			path := strings.Trim(spec.Path.Value, "\"`")
+			pkg, err := w.ctx.Import(path, dir, build.FindOnly)
+			if err != nil {
+				return []error{err}
+			}
+			if sgoPaths, _ := sgoFilesIn(pkg.Dir); len(sgoPaths) > 0 {
+				sgoPkgs[path] = true
+				imported = append(imported, pkg.Dir)
+			}
+		}
+	}
+
+	owners := map[*types.Package]map[*types.Var]string{}
+	for _, obj := range info.Uses {
+		pkg := obj.Pkg()
+		if pkg == nil || pkg.Path() == dir || sgoPkgs[pkg.Path()] {
+			continue
+		}
+		name, ok := annotationName(obj, owners)
+		if !ok || !hasNilable(obj) {
+			continue
+		}
+		if w.used[pkg.Path()] == nil {
+			w.used[pkg.Path()] = map[string]string{}
+		}
+		if _, ok := w.used[pkg.Path()][name]; !ok {
+			w.used[pkg.Path()][name] = dir
+		}
+	}
+
+	for _, dir := range imported {
+		if errs := w.walk(dir); len(errs) > 0 {
+			return errs
+		}
+	}
+	return nil
+}
+
+func sgoFilesIn(dir string) ([]string, error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	fileNames, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, fileName := range fileNames {
+		if filepath.Ext(fileName) == ".sgo" {
+			paths = append(paths, filepath.Join(dir, fileName))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// annotationName returns the name obj is annotated by in its package. It
+// returns false for objects that aren't annotated on their own, like types,
+// constants, or fields of unnamed structs. owners caches the names of the
+// structs that declare each package's fields.
+func annotationName(obj types.Object, owners map[*types.Package]map[*types.Var]string) (string, bool) {
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig, ok := obj.Type().(*types.Signature)
+		if !ok || sig.Recv() == nil {
+			return obj.Name(), true
+		}
+		recv := sig.Recv().Type()
+		if opt, ok := recv.(*types.Optional); ok {
+			recv = opt.Elem()
+		}
+		ptr, isPtr := recv.(*types.Pointer)
+		if isPtr {
+			recv = ptr.Elem()
+		}
+		named, ok := recv.(*types.Named)
+		if !ok {
+			return "", false
+		}
+		if isPtr {
+			return "(*" + named.Obj().Name() + ")." + obj.Name(), true
+		}
+		return named.Obj().Name() + "." + obj.Name(), true
+	case *types.Var:
+		if !obj.IsField() {
+			return obj.Name(), true
+		}
+		fields, ok := owners[obj.Pkg()]
+		if !ok {
+			fields = structFields(obj.Pkg())
+			owners[obj.Pkg()] = fields
+		}
+		name, ok := fields[obj]
+		return name, ok
+	}
+	return "", false
+}
+
+// structFields maps the fields of the named struct types declared in pkg to
+// their names, as "T.Field".
+func structFields(pkg *types.Package) map[*types.Var]string {
+	fields := map[*types.Var]string{}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			fields[st.Field(i)] = name + "." + st.Field(i).Name()
+		}
+	}
+	return fields
+}
+
+// isAnnotated reports whether the symbol with the given name, or the one that
+// declares it, like the type of a field, has a type annotation in ann, or a
+// default for its unannotated members. A method with a value receiver, named
+// "T.M", may also be annotated as "(T).M".
+func isAnnotated(ann *annotations.Annotation, name string) bool {
+	if parts := strings.SplitN(name, ".", 2); len(parts) == 2 && !strings.HasPrefix(name, "(") {
+		if annotatedAs(ann, "("+parts[0]+")."+parts[1]) {
+			return true
+		}
+	}
+	return annotatedAs(ann, name)
+}
+
+func annotatedAs(ann *annotations.Annotation, name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		ann = ann.Lookup(part)
+		if _, ok := ann.Type(); ok {
+			return true
+		}
+		if _, ok := ann.Default(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNilable reports whether obj's type has something that can be nil, so that
+// annotations make a difference for it. For functions, only their parameters
+// and results are considered.
+func hasNilable(obj types.Object) bool {
+	if sig, ok := obj.Type().(*types.Signature); ok {
+		if _, ok := obj.(*types.Func); ok {
+			return canBeNil(sig.Params(), nil) || canBeNil(sig.Results(), nil)
+		}
+	}
+	return canBeNil(obj.Type(), nil)
+}
+
+// canBeNil reports whether a value of type typ, or of a type it's made of,
+// like a function's parameters, can be nil. The fields of named structs
+// aren't considered, since they are annotated on their own.
+func canBeNil(typ types.Type, visited []types.Type) bool {
+	for _, t := range visited {
+		if t == typ {
+			return false
+		}
+	}
+	visited = append(visited, typ)
+
+	switch t := typ.(type) {
+	case *types.Optional, *types.Pointer, *types.Map, *types.Chan, *types.Interface, *types.Signature:
+		return true
+	case *types.Named:
+		if _, ok := t.Underlying().(*types.Struct); ok {
+			return false
+		}
+		return canBeNil(t.Underlying(), visited)
+	case *types.Slice:
+		return canBeNil(t.Elem(), visited)
+	case *types.Array:
+		return canBeNil(t.Elem(), visited)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if canBeNil(t.Field(i).Type(), visited) {
+				return true
+			}
+		}
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if canBeNil(t.At(i).Type(), visited) {
+				return true
+			}
+		}
+		return t.Entangled() != nil
+	}
+	return false
+}
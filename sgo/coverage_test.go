@@ -0,0 +1,180 @@
+package sgo
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestAnnotationCoverage(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "sgo-coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	for path, src := range map[string]string{
+		"example.com/lib/lib.go": `package lib
+
+type File struct {
+	Next *File
+	Size int
+}
+
+func Open(name string) *File { return &File{} }
+
+func (f *File) Close() error { return nil }
+
+func Len(s string) int { return len(s) }
+`,
+		"example.com/other/other.go": `package other
+
+type T struct{}
+
+func Get() *T { return nil }
+`,
+		// An SGo package, with its translation.
+		"example.com/sgolib/sgolib.sgo": `package sgolib
+
+import "example.com/other"
+
+func Get() ?*other.T { return other.Get() }
+`,
+		"example.com/sgolib/sgolib.go": `package sgolib
+
+import "example.com/other"
+
+// For SGo: func() ?*other.T
+func Get() *other.T { return other.Get() }
+`,
+		"example.com/app/main.sgo": `package main
+
+import (
+	"example.com/lib"
+	// Not used here, but imported so that it's found in GOPATH when
+	// importing sgolib, which uses it.
+	_ "example.com/other"
+	"example.com/sgolib"
+)
+
+func main() {
+	f := lib.Open("x")
+	_ = f.Next
+	_ = f.Size
+	_ = f.Close()
+	_ = lib.Len("x")
+	_ = sgolib.Get()
+}
+`,
+		"example.com/app/sgovendor/example.com/lib/lib.sgoann": "Open func(name string) *File\n",
+	} {
+		path = filepath.Join(gopath, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := build.Default
+	ctx.GOPATH = gopath
+	ctx.CgoEnabled = false
+
+	covs, errs := AnnotationCoverageWith(TranslateOptions{BuildContext: &ctx}, filepath.Join(gopath, "src", "example.com", "app"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	expected := []PackageCoverage{
+		{Path: "example.com/lib", Covered: []string{"Open"}, Uncovered: []string{"(*File).Close", "File.Next"}},
+		{Path: "example.com/other", Uncovered: []string{"Get"}},
+	}
+	if !reflect.DeepEqual(covs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, covs)
+	}
+	if p := covs[0].Percent(); p < 33.3 || p > 33.4 {
+		t.Errorf("expected 33.3%% coverage for example.com/lib, got %.1f%%", p)
+	}
+}
+
+func TestMissingAnnotations(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.sgo", `package lib
+
+type File struct {
+	Next *File
+	Size int
+	Meta map[string]string
+	owner *File
+}
+
+func Open(name string) *File { panic("") }
+
+func Create(name string) *File { panic("") }
+
+func Len(s string) int { return len(s) }
+
+func (f *File) Close() error { panic("") }
+
+func (f File) Name() string { return "" }
+
+func (f File) Info() error { panic("") }
+
+func (f File) Stat() error { panic("") }
+
+func (f *File) sync() error { panic("") }
+
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+type Request struct {
+	URL *string
+}
+
+var Default ?*File
+
+const Max = 10
+
+func helper() *File { panic("") }
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{}).Check("example.com/lib", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := annotations.Parse(`Open func(name string) *File
+(*File) {
+	Close (*File) func() \ error
+}
+File {
+	Meta map[string]string
+}
+(File) {
+	Info (File) func() error
+}
+Request default !
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Create", "Default", "File.Next", "File.Stat", "Reader.Read"}
+	if missing := MissingAnnotations(pkg, ann); !reflect.DeepEqual(expected, missing) {
+		t.Errorf("expected %v, got %v", expected, missing)
+	}
+
+	expected = []string{"(*File).Close", "Create", "Default", "File.Info", "File.Meta", "File.Next", "File.Stat", "Open", "Reader.Read", "Request.URL"}
+	if missing := MissingAnnotations(pkg, nil); !reflect.DeepEqual(expected, missing) {
+		t.Errorf("without annotations: expected %v, got %v", expected, missing)
+	}
+}
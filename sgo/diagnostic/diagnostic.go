@@ -0,0 +1,167 @@
+// Package diagnostic formats errors found in SGo code for different consumers:
+// people reading a terminal, tools consuming JSON, or CI systems that
+// understand checkstyle reports.
+package diagnostic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/scanner"
+)
+
+// A Formatter writes a list of errors to w in some format.
+type Formatter interface {
+	Format(w io.Writer, errs scanner.ErrorList) error
+}
+
+// The formatters provided by this package.
+var (
+	// Text writes one error per line, as the go tool does.
+	Text Formatter = textFormatter{}
+	// JSON writes a JSON array with an object for each error, with its
+	// file, line, column and message.
+	JSON Formatter = jsonFormatter{}
+	// Checkstyle writes a checkstyle XML report, grouping the errors by file.
+	Checkstyle Formatter = checkstyleFormatter{}
+)
+
+var formatters = map[string]Formatter{
+	"text":       Text,
+	"json":       JSON,
+	"checkstyle": Checkstyle,
+}
+
+// Names returns the names of the formatters Lookup knows about, sorted.
+func Names() []string {
+	var names []string
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the formatter with the given name, as used in -format flags:
+// "text", "json" or "checkstyle".
+//
+// For SGo: func(name string) (Formatter \ error)
+func Lookup(name string) (Formatter, error) {
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q; must be one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return f, nil
+}
+
+// List makes an ErrorList out of errs. The errors in an ErrorList are added
+// as they are; any other error is added with an unknown position.
+func List(errs ...error) scanner.ErrorList {
+	var list scanner.ErrorList
+	for _, err := range errs {
+		switch err := err.(type) {
+		case nil:
+		case scanner.ErrorList:
+			list = append(list, err...)
+		case *scanner.Error:
+			list = append(list, err)
+		case scanner.Error:
+			list = append(list, &err)
+		default:
+			list = append(list, &scanner.Error{Msg: err.Error()})
+		}
+	}
+	return list
+}
+
+type textFormatter struct{}
+
+func (textFormatter) Format(w io.Writer, errs scanner.ErrorList) error {
+	for _, err := range errs {
+		if _, err := fmt.Fprintln(w, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonDiagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(w io.Writer, errs scanner.ErrorList) error {
+	diags := []jsonDiagnostic{}
+	for _, err := range errs {
+		diags = append(diags, jsonDiagnostic{
+			File:    err.Pos.Filename,
+			Line:    err.Pos.Line,
+			Column:  err.Pos.Column,
+			Message: err.Msg,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(diags)
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type checkstyleFormatter struct{}
+
+func (checkstyleFormatter) Format(w io.Writer, errs scanner.ErrorList) error {
+	report := checkstyleReport{Version: "5.0"}
+	files := map[string]int{}
+	for _, err := range errs {
+		i, ok := files[err.Pos.Filename]
+		if !ok {
+			i = len(report.Files)
+			files[err.Pos.Filename] = i
+			report.Files = append(report.Files, checkstyleFile{Name: err.Pos.Filename})
+		}
+		report.Files[i].Errors = append(report.Files[i].Errors, checkstyleError{
+			Line:     err.Pos.Line,
+			Column:   err.Pos.Column,
+			Severity: "error",
+			Message:  err.Msg,
+			Source:   "sgo",
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
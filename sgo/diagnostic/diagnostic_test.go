@@ -0,0 +1,90 @@
+package diagnostic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/scanner"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+var testErrs = List(
+	scanner.ErrorList{
+		{Pos: token.Position{Filename: "a.sgo", Line: 3, Column: 7}, Msg: "x.N undefined"},
+		{Pos: token.Position{Filename: "b.sgo", Line: 10, Column: 2}, Msg: `cannot use "<nil>" as *T`},
+	},
+	&scanner.Error{Pos: token.Position{Filename: "a.sgo", Line: 5, Column: 1}, Msg: "missing return"},
+	errors.New("no files"),
+)
+
+func TestFormatters(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"text", `a.sgo:3:7: x.N undefined
+b.sgo:10:2: cannot use "<nil>" as *T
+a.sgo:5:1: missing return
+no files
+`},
+		{"json", `[
+	{
+		"file": "a.sgo",
+		"line": 3,
+		"column": 7,
+		"message": "x.N undefined"
+	},
+	{
+		"file": "b.sgo",
+		"line": 10,
+		"column": 2,
+		"message": "cannot use \"<nil>\" as *T"
+	},
+	{
+		"file": "a.sgo",
+		"line": 5,
+		"column": 1,
+		"message": "missing return"
+	},
+	{
+		"message": "no files"
+	}
+]
+`},
+		{"checkstyle", `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="5.0">
+	<file name="a.sgo">
+		<error line="3" column="7" severity="error" message="x.N undefined" source="sgo"></error>
+		<error line="5" column="1" severity="error" message="missing return" source="sgo"></error>
+	</file>
+	<file name="b.sgo">
+		<error line="10" column="2" severity="error" message="cannot use &#34;&lt;nil&gt;&#34; as *T" source="sgo"></error>
+	</file>
+	<file name="">
+		<error line="0" severity="error" message="no files" source="sgo"></error>
+	</file>
+</checkstyle>
+`},
+	}
+	for _, c := range cases {
+		f, err := Lookup(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := f.Format(&buf, testErrs); err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", c.name, c.expected, buf.String())
+		}
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	if _, err := Lookup("yaml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,63 @@
+package sgo
+
+import (
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/diagnostic"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/scanner"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+// A Diagnostic is a problem found in SGo code that prevents translating it.
+type Diagnostic struct {
+	Pos token.Position
+	Msg string
+}
+
+// String implements fmt.Stringer for Diagnostic.
+func (d Diagnostic) String() string {
+	return scanner.Error{Pos: d.Pos, Msg: d.Msg}.Error()
+}
+
+// TranslatePackageDiagnostics checks all the SGo files in dir together, as
+// translating them would, and returns the problems found in any of them,
+// sorted by file and position. The files share a FileSet, so positions in
+// different files can be shown together.
+//
+// Syntax errors are reported for every file; if there are any, the package
+// isn't typechecked. The returned error is for failures to read the files.
+//
+// For SGo: func(dir string) ([]Diagnostic, error)
+func TranslatePackageDiagnostics(dir string) ([]Diagnostic, error) {
+	paths, err := sgoFilesIn(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var errs []error
+	for _, path := range paths {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files = append(files, f)
+	}
+
+	if len(errs) == 0 {
+		_, typeErrs := typecheck(dir, fset, dir, TranslateOptions{}, nil, files...)
+		if len(typeErrs) > 0 {
+			errs = append(errs, makeErrList(fset, typeErrs))
+		}
+	}
+
+	list := diagnostic.List(errs...)
+	list.Sort()
+	var diags []Diagnostic
+	for _, err := range list {
+		diags = append(diags, Diagnostic{Pos: err.Pos, Msg: err.Msg})
+	}
+	return diags, nil
+}
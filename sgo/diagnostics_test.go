@@ -0,0 +1,77 @@
+package sgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranslatePackageDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgo-diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, src := range map[string]string{
+		"a.sgo": `package p
+
+type T struct {
+	N int
+}
+
+var n int = "a"
+`,
+		"b.sgo": `package p
+
+func f(p ?*T) int {
+	return p.N
+}
+`,
+		"c.go": "package p\n\nvar ignored int = \"a\"\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diags, err := TranslatePackageDiagnostics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		file      string
+		line, col int
+	}{
+		{"a.sgo", 7, 13},
+		{"b.sgo", 4, 9},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diags)
+	}
+	for i, e := range expected {
+		pos := diags[i].Pos
+		if pos.Filename != filepath.Join(dir, e.file) || pos.Line != e.line || pos.Column != e.col {
+			t.Errorf("expected diagnostic %d at %s:%d:%d, got %#v", i, e.file, e.line, e.col, diags[i])
+		}
+	}
+
+	// Syntax errors are reported for every file.
+	ioutil.WriteFile(filepath.Join(dir, "a.sgo"), []byte("package p\n\nfunc {\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.sgo"), []byte("package p\n\nvar = 1\n"), 0644)
+	diags, err = TranslatePackageDiagnostics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, d := range diags {
+		files[filepath.Base(d.Pos.Filename)] = true
+	}
+	if !files["a.sgo"] || !files["b.sgo"] || len(files) != 2 {
+		t.Errorf("expected syntax errors in a.sgo and b.sgo, got %v", diags)
+	}
+
+	if _, err := TranslatePackageDiagnostics(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,64 @@
+package sgo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/printer"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+// ExtractInterface returns the source of an SGo interface type with the
+// methods annotated for the type named typeName in the package's Annotation
+// a, with their annotated signatures; for example:
+//
+// 	interface {
+// 		Close() \ error
+// 		Read(b []byte) (n int, err ?error)
+// 	}
+//
+// Methods with pointer and value receivers are both included, so the
+// interface is implemented by the pointer type. Directives, and alternatives
+// gated on build constraints other than the first, are left out.
+//
+// The result can be used to declare an interface, as in
+// "type Reader " + iface, for example to mock the annotated type. It
+// returns an error if typeName has no annotated methods, or if one of their
+// definitions can't be parsed.
+//
+// For SGo: func(typeName string, a ?*annotations.Annotation) (string \ error)
+func ExtractInterface(typeName string, a *annotations.Annotation) (string, error) {
+	var methods []string
+	for _, name := range a.Names() {
+		parts := strings.Split(name, ".")
+		if len(parts) != 2 || parts[0] != typeName && parts[0] != "("+typeName+")" && parts[0] != "(*"+typeName+")" {
+			continue
+		}
+		typ, ok := a.Lookup(parts[0]).Lookup(parts[1]).Type()
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(typ), "(") {
+			// A field.
+			continue
+		}
+		fset := token.NewFileSet()
+		fun, _, err := parser.ParseMethodExprsFrom(fset, "", []byte(typ), 0)
+		if err != nil {
+			return "", fmt.Errorf("%s: invalid method definition %q: %v", name, typ, err)
+		}
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, fun)
+		methods = append(methods, parts[1]+strings.TrimPrefix(buf.String(), "func"))
+	}
+	if len(methods) == 0 {
+		return "", fmt.Errorf("no annotated methods for %s", typeName)
+	}
+	sort.Strings(methods)
+
+	return "interface {\n\t" + strings.Join(methods, "\n\t") + "\n}", nil
+}
@@ -0,0 +1,60 @@
+package sgo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+func TestExtractInterface(t *testing.T) {
+	ann, err := annotations.Parse(`(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+	Stat (*File) func() (FileInfo \ error) @build !windows
+	Stat (*File) func() (?FileInfo \ error) @build windows
+	Sync @nonempty
+}
+File {
+	Name (File) func() string
+	Path *string
+}
+Open func(name string) (*File \ error)
+(*Dir).Close (*Dir) func() error
+(File) {
+	Mode (File) func() ?*Mode
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface, err := ExtractInterface("File", ann)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `interface {
+	Close() \ error
+	Mode() ?*Mode
+	Name() string
+	Read(b []byte) (n int, err ?error)
+	Stat() (FileInfo \ error)
+}`
+	if iface != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, iface)
+	}
+
+	// The result is a valid SGo type.
+	if _, err := Strip("package p\n\ntype FileEr " + iface + "\n"); err != nil {
+		t.Errorf("generated interface doesn't parse: %v", err)
+	}
+
+	if _, err := ExtractInterface("Missing", ann); err == nil || !strings.Contains(err.Error(), "no annotated methods for Missing") {
+		t.Errorf("expected error for a type without methods, got %v", err)
+	}
+
+	bad := annotations.NewAnnotation(map[string]string{"(*T).M": "(*T) func("})
+	if _, err := ExtractInterface("T", bad); err == nil || !strings.Contains(err.Error(), "(*T).M") {
+		t.Errorf("expected error for an invalid definition, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package sgo
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// A force v!, which asserts that v isn't nil, is translated to a function
+// literal that checks it and returns it, or panics if it's nil:
+//
+// 	func() T { if v := v; v != nil { return v }; panic("sgo: v is nil at file.sgo:12:5") }()
+//
+// T is the type of v without the optional. The packages it names that the file
+// doesn't import are imported under private names, like __sgo_url for net/url.
+//
+// If T can't be written in the file, like an unexported type from another
+// package, the force is instead a call to a generic function declared at the
+// end of the file, which needs Go 1.18:
+//
+// 	__sgo_force_file_sgo(v, "sgo: v is nil at file.sgo:12:5")
+
+// fileForces tells how to translate the forces in a file.
+type fileForces struct {
+	// types are the Go types of the forces' values, as written in the file,
+	// or "" for those that can't be written. Forces whose types are unknown,
+	// as when stripping, are left out.
+	types map[*ast.ForceExpr]string
+	// imports maps the paths of the packages to add to the file to their
+	// names.
+	imports map[string]string
+	// helper is the function that checks the forces whose types can't be
+	// written, or "" if there are none.
+	helper string
+}
+
+func newFileForces(info *types.Info, fset *token.FileSet, file *ast.File) fileForces {
+	fs := fileForces{types: map[*ast.ForceExpr]string{}, imports: map[string]string{}}
+	fileScope := info.Scopes[file]
+	if fileScope == nil {
+		return fs
+	}
+	w := typeWriter{pkgScope: fileScope.Parent(), imported: map[*types.Package]string{}, added: fs.imports}
+	for _, spec := range file.Imports {
+		obj := info.Implicits[spec]
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() != "_" {
+			w.imported[pkgName.Imported()] = pkgName.Name()
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		force, ok := n.(*ast.ForceExpr)
+		if !ok {
+			return true
+		}
+		typ := info.TypeOf(force)
+		if typ == nil {
+			return true
+		}
+		if !w.canWrite(typ, nil) {
+			fs.types[force] = ""
+			return true
+		}
+		fs.types[force] = types.TypeString(goType(typ), w.qualify)
+		return true
+	})
+
+	for _, typ := range fs.types {
+		if typ == "" {
+			w.alias("reflect", "reflect")
+			name := filepath.Base(fset.File(file.Pos()).Name())
+			fs.helper = "__sgo_force_" + strings.Map(func(r rune) rune {
+				if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+					return r
+				}
+				return '_'
+			}, name)
+			break
+		}
+	}
+	return fs
+}
+
+// importDecl returns the import declaration that goes right after the package
+// clause, or nil if there's nothing to import.
+func (fs fileForces) importDecl() []byte {
+	if len(fs.imports) == 0 {
+		return nil
+	}
+	var paths []string
+	for path := range fs.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var buf bytes.Buffer
+	buf.WriteString("; import (")
+	for i, path := range paths {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		fmt.Fprintf(&buf, "%s %q", fs.imports[path], path)
+	}
+	buf.WriteString(")")
+	return buf.Bytes()
+}
+
+// helperDecl returns the declaration of the function that checks the forces
+// whose types can't be written, or nil if there's none.
+func (fs fileForces) helperDecl() []byte {
+	if fs.helper == "" {
+		return nil
+	}
+	return []byte(fmt.Sprintf("\n\nfunc %s[T any](v T, msg string) T { if %s.ValueOf(&v).Elem().IsNil() { panic(msg) }; return v }\n", fs.helper, fs.imports["reflect"]))
+}
+
+// A typeWriter tells how to write types in a file, given the scope of its
+// package and the names of the packages it imports. Those that it doesn't
+// import are added, from path to name, as they're written.
+type typeWriter struct {
+	pkgScope *types.Scope
+	imported map[*types.Package]string
+	added    map[string]string
+}
+
+func (w typeWriter) qualify(pkg *types.Package) string {
+	if pkg.Scope() == w.pkgScope || w.imported[pkg] == "." {
+		return ""
+	}
+	if name, ok := w.imported[pkg]; ok {
+		return name
+	}
+	return w.alias(pkg.Path(), pkg.Name())
+}
+
+// alias returns the private name under which the package at path is added to
+// the file, which is based on its name.
+func (w typeWriter) alias(path, name string) string {
+	if alias, ok := w.added[path]; ok {
+		return alias
+	}
+	alias := "__sgo_" + name
+	for i := 2; ; i++ {
+		taken := false
+		for _, other := range w.added {
+			taken = taken || other == alias
+		}
+		if !taken {
+			break
+		}
+		alias = fmt.Sprintf("__sgo_%s%d", name, i)
+	}
+	w.added[path] = alias
+	return alias
+}
+
+// canRefer reports whether an object from pkg can be referred to in the file,
+// importing pkg if needed. Internal packages are never imported, as they may
+// not be visible from the file.
+func (w typeWriter) canRefer(pkg *types.Package, exported bool) bool {
+	if pkg == nil || pkg.Scope() == w.pkgScope {
+		return true
+	}
+	if _, ok := w.imported[pkg]; ok {
+		return exported
+	}
+	path := "/" + pkg.Path() + "/"
+	return exported && !strings.Contains(path, "/internal/") && pkg.Name() != "main"
+}
+
+// canWrite reports whether typ can be written in the file.
+func (w typeWriter) canWrite(typ types.Type, visited []types.Type) bool {
+	for _, t := range visited {
+		if t == typ {
+			return true
+		}
+	}
+	visited = append(visited, typ)
+
+	switch t := typ.(type) {
+	case *types.Named:
+		return w.canRefer(t.Obj().Pkg(), t.Obj().Exported())
+	case *types.Optional:
+		return w.canWrite(t.Elem(), visited)
+	case *types.Pointer:
+		return w.canWrite(t.Elem(), visited)
+	case *types.Slice:
+		return w.canWrite(t.Elem(), visited)
+	case *types.Array:
+		return w.canWrite(t.Elem(), visited)
+	case *types.Chan:
+		return w.canWrite(t.Elem(), visited)
+	case *types.Map:
+		return w.canWrite(t.Key(), visited) && w.canWrite(t.Elem(), visited)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if !w.canRefer(f.Pkg(), f.Exported()) || !w.canWrite(f.Type(), visited) {
+				return false
+			}
+		}
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if !w.canWrite(t.At(i).Type(), visited) {
+				return false
+			}
+		}
+		if v := t.Entangled(); v != nil {
+			return w.canWrite(v.Type(), visited)
+		}
+	case *types.Signature:
+		return w.canWrite(t.Params(), visited) && w.canWrite(t.Results(), visited)
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			if !w.canRefer(m.Pkg(), m.Exported()) || !w.canWrite(m.Type(), visited) {
+				return false
+			}
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if !w.canWrite(t.Embedded(i), visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// goType returns the Go counterpart of typ: optionals are replaced by their
+// element types, and entangled results become plain results.
+func goType(typ types.Type) types.Type {
+	switch t := typ.(type) {
+	case *types.Optional:
+		return goType(t.Elem())
+	case *types.Pointer:
+		return types.NewPointer(goType(t.Elem()))
+	case *types.Slice:
+		return types.NewSlice(goType(t.Elem()))
+	case *types.Array:
+		return types.NewArray(goType(t.Elem()), t.Len())
+	case *types.Chan:
+		return types.NewChan(t.Dir(), goType(t.Elem()))
+	case *types.Map:
+		return types.NewMap(goType(t.Key()), goType(t.Elem()))
+	case *types.Struct:
+		var fields []*types.Var
+		var tags []string
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			fields = append(fields, types.NewField(f.Pos(), f.Pkg(), f.Name(), goType(f.Type()), f.Anonymous()))
+			tags = append(tags, t.Tag(i))
+		}
+		return types.NewStruct(fields, tags)
+	case *types.Signature:
+		return types.NewSignature(nil, goTuple(t.Params()), goTuple(t.Results()), t.Variadic())
+	case *types.Interface:
+		var methods []*types.Func
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			methods = append(methods, types.NewFunc(m.Pos(), m.Pkg(), m.Name(), goType(m.Type()).(*types.Signature)))
+		}
+		var embeddeds []*types.Named
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			embeddeds = append(embeddeds, t.Embedded(i))
+		}
+		return types.NewInterface(methods, embeddeds).Complete()
+	}
+	return typ
+}
+
+func goTuple(tup *types.Tuple) *types.Tuple {
+	if tup == nil {
+		return nil
+	}
+	var vars []*types.Var
+	for i := 0; i < tup.Len(); i++ {
+		v := tup.At(i)
+		vars = append(vars, types.NewVar(v.Pos(), v.Pkg(), v.Name(), goType(v.Type())))
+	}
+	if v := tup.Entangled(); v != nil {
+		vars = append(vars, types.NewVar(v.Pos(), v.Pkg(), v.Name(), goType(v.Type())))
+	}
+	return types.NewTuple(vars...)
+}
+
+// convertForceExpr translates v! to a function literal that checks that v
+// isn't nil, if its type is known.
+func (c *converter) convertForceExpr(v *ast.ForceExpr) {
+	if v == nil {
+		return
+	}
+	c.annotationFromDocs(v)
+	typ, ok := c.forces.types[v]
+	if !ok {
+		// Without types, as when stripping, the force is just dropped.
+		c.convertExpr(v.X)
+		c.putChunks(int(v.End()), c.src[c.lastChunkEnd:int(v.End())-1-c.base], nil)
+		return
+	}
+	pos := v.X.Pos()
+	msg := fmt.Sprintf("sgo: %s is nil at %v", types.ExprString(v.X), c.fset.Position(pos))
+	if typ == "" {
+		c.putChunks(int(pos)-1, c.src[c.lastChunkEnd:int(pos)-c.base-1], []byte(c.forces.helper+"("))
+		c.convertExpr(v.X)
+		c.putChunks(int(v.End()), c.src[c.lastChunkEnd:int(v.End())-1-c.base], []byte(fmt.Sprintf(", %q)", msg)))
+		return
+	}
+	c.putChunks(int(pos)-1, c.src[c.lastChunkEnd:int(pos)-c.base-1], []byte("func() "+typ+" { if v := "))
+	c.convertExpr(v.X)
+	c.putChunks(int(v.End()), c.src[c.lastChunkEnd:int(v.End())-1-c.base], []byte(fmt.Sprintf("; v != nil { return v }; panic(%q) }()", msg)))
+}
@@ -0,0 +1,70 @@
+package sgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/scanner"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// rangeFuncMinor is the minor version of Go 1 that introduced ranging over
+// functions, which SGo translates as is.
+const rangeFuncMinor = 23
+
+// genericsMinor is the minor version of Go 1 that introduced generics, which
+// the translation of forces whose types can't be written uses; see force.go.
+const genericsMinor = 18
+
+// checkGoVersion reports the constructs in files that translate to Go code
+// that needs a Go version later than version, like "go1.21".
+func checkGoVersion(version string, info *types.Info, fset *token.FileSet, files []*ast.File) []error {
+	minor, err := parseGoVersion(version)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs scanner.ErrorList
+	for _, f := range files {
+		forces := newFileForces(info, fset, f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.RangeStmt:
+				if typ := info.TypeOf(n.X); typ != nil && minor < rangeFuncMinor {
+					if _, ok := typ.Underlying().(*types.Signature); ok {
+						errs.Add(fset.Position(n.For), fmt.Sprintf("range over function requires go1.%d or later (targeting %s)", rangeFuncMinor, version))
+					}
+				}
+			case *ast.ForceExpr:
+				if typ, ok := forces.types[n]; ok && typ == "" && minor < genericsMinor {
+					errs.Add(fset.Position(n.Mark), fmt.Sprintf("%s! requires go1.%d or later, as its type %s can't be written in this file (targeting %s)", types.ExprString(n.X), genericsMinor, info.TypeOf(n), version))
+				}
+			}
+			return true
+		})
+	}
+	if len(errs) > 0 {
+		errs.Sort()
+		return []error{errs}
+	}
+	return nil
+}
+
+// parseGoVersion parses a Go version like "go1.21" or "go1.21.3", and returns
+// its minor version.
+func parseGoVersion(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "go1."), ".")
+	minor, err := strconv.Atoi(parts[0])
+	if err != nil || !strings.HasPrefix(version, "go1.") || len(parts) > 2 || minor < 0 {
+		return 0, fmt.Errorf("invalid Go version %q; expected one like go1.21", version)
+	}
+	if len(parts) == 2 {
+		if _, err := strconv.Atoi(parts[1]); err != nil {
+			return 0, fmt.Errorf("invalid Go version %q; expected one like go1.21", version)
+		}
+	}
+	return minor, nil
+}
@@ -0,0 +1,284 @@
+package sgo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// EntanglementGraph typechecks the given SGo source and returns a graph, in
+// Graphviz's DOT language, of how nil guarantees flow through its functions,
+// from the same facts as TranslateFileReport.
+//
+// Variables that can hold nil are drawn as dashed ellipses, and the others as
+// solid ones. Calls with entangled results are boxes, with an edge to each
+// variable their results are assigned to, labeled "result" or "entangled".
+// If conditions are diamonds, with an edge labeled "checked" from each
+// variable they check, and one labeled "unwrapped" or "collapsed" to each
+// variable they make usable.
+//
+// Each function is a cluster. If funcName isn't empty, only the function or
+// method with that name is included.
+//
+// For SGo: func(src string, funcName string) (string \ error)
+func EntanglementGraph(src string, funcName string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "input.sgo", src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	info, typeErrs := typecheck("translate", fset, "", TranslateOptions{}, nil, file)
+	if len(typeErrs) > 0 {
+		return "", makeErrList(fset, typeErrs)
+	}
+
+	g := &flowGraph{
+		src:   src,
+		fset:  fset,
+		file:  fset.File(file.Pos()),
+		info:  info,
+		ids:   map[interface{}]string{},
+		seen:  map[string]bool{},
+		nodes: map[*ast.FuncDecl][]flowNode{},
+	}
+	for _, decl := range file.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && (funcName == "" || fd.Name.Name == funcName) {
+			g.funcs = append(g.funcs, fd)
+		}
+	}
+	if funcName != "" && len(g.funcs) == 0 {
+		return "", fmt.Errorf("no function named %s", funcName)
+	}
+
+	uses := map[token.Position]*types.Var{}
+	for id, obj := range info.Uses {
+		if v, ok := obj.(*types.Var); ok {
+			uses[fset.Position(id.Pos())] = v
+		}
+	}
+	guards := map[token.Position]ast.Expr{}
+	for cond := range info.Narrowings {
+		guards[fset.Position(cond.Pos())] = cond
+	}
+
+	// Entangled calls, in source order.
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if n.Lhs.EntangledPos > 0 && len(n.Rhs.List) == 1 {
+				g.call(n.Rhs.List[0], n.Lhs.List, n.Lhs.EntangledPos)
+			}
+		case *ast.ValueSpec:
+			if n.Names.EntangledPos > 0 && n.Values.Len() == 1 {
+				var lhs []ast.Expr
+				for _, id := range n.Names.List {
+					lhs = append(lhs, id)
+				}
+				g.call(n.Values.List[0], lhs, n.Names.EntangledPos)
+			}
+		}
+		return true
+	})
+
+	// Uses of variables, and the guards that make them usable.
+	for _, fact := range nilabilityFacts(info, fset, file) {
+		v, ok := uses[fact.Pos]
+		if !ok {
+			continue
+		}
+		id := g.varNode(v, g.posOf(fact.Pos))
+		if id == "" || fact.Guard == nil {
+			continue
+		}
+		cond, ok := guards[*fact.Guard]
+		if !ok {
+			continue
+		}
+		g.edge(g.guardNode(cond), id, fact.Reason)
+	}
+
+	// Variables checked by each guard drawn so far.
+	var conds []ast.Expr
+	for _, cond := range guards {
+		if _, ok := g.ids[cond]; ok {
+			conds = append(conds, cond)
+		}
+	}
+	sort.Slice(conds, func(i, j int) bool { return conds[i].Pos() < conds[j].Pos() })
+	for _, cond := range conds {
+		ast.Inspect(cond, func(node ast.Node) bool {
+			id, ok := node.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if v, ok := info.Uses[id].(*types.Var); ok && (isOptional(v.Type()) || types.IsOptionable(v.Type())) {
+				if from := g.varNode(v, id.Pos()); from != "" {
+					g.edge(from, g.ids[cond], "checked")
+				}
+			}
+			return true
+		})
+	}
+
+	return g.String(), nil
+}
+
+type flowGraph struct {
+	src   string
+	fset  *token.FileSet
+	file  *token.File
+	info  *types.Info
+	funcs []*ast.FuncDecl
+	// ids maps the variables, calls and conditions drawn to their node IDs.
+	ids   map[interface{}]string
+	seen  map[string]bool
+	nodes map[*ast.FuncDecl][]flowNode
+	edges []string
+}
+
+type flowNode struct {
+	pos  token.Pos
+	line string
+}
+
+// call adds a node for an entangled call, with edges to the variables in lhs
+// its results are assigned to. entangledPos is as in ast.ExprList.
+func (g *flowGraph) call(call ast.Expr, lhs []ast.Expr, entangledPos int) {
+	if _, ok := call.(*ast.CallExpr); !ok {
+		return
+	}
+	fd := g.funcAt(call.Pos())
+	if fd == nil {
+		return
+	}
+	var from string
+	for i, e := range lhs {
+		id, ok := e.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		obj := g.info.Defs[id]
+		if obj == nil {
+			obj = g.info.Uses[id]
+		}
+		v, ok := obj.(*types.Var)
+		if !ok {
+			continue
+		}
+		to := g.varNode(v, id.Pos())
+		if to == "" {
+			continue
+		}
+		if from == "" {
+			from = g.node(fd, call, call.Pos(), fmt.Sprintf("shape=box, label=%s", dotQuote(g.text(call))))
+		}
+		label := "result"
+		if i >= entangledPos-1 {
+			label = "entangled"
+		}
+		g.edge(from, to, label)
+	}
+}
+
+// varNode returns the ID of the node for v, adding it if needed. It returns an
+// empty string for variables that aren't declared in the included functions.
+// Variables without a position, like those unwrapped by a condition, are
+// placed at the position of their use at.
+func (g *flowGraph) varNode(v *types.Var, at token.Pos) string {
+	if id, ok := g.ids[v]; ok {
+		return id
+	}
+	pos := v.Pos()
+	if g.fset.File(pos) == nil {
+		pos = at
+	}
+	fd := g.funcAt(pos)
+	if fd == nil {
+		return ""
+	}
+	style := "solid"
+	if isOptional(v.Type()) {
+		style = "dashed"
+	}
+	label := v.Name() + " " + types.TypeString(v.Type(), types.RelativeTo(v.Pkg()))
+	return g.node(fd, v, pos, fmt.Sprintf("style=%s, label=%s", style, dotQuote(label)))
+}
+
+func (g *flowGraph) guardNode(cond ast.Expr) string {
+	if id, ok := g.ids[cond]; ok {
+		return id
+	}
+	label := fmt.Sprintf("%s\nline %d", g.text(cond), g.fset.Position(cond.Pos()).Line)
+	return g.node(g.funcAt(cond.Pos()), cond, cond.Pos(), fmt.Sprintf("shape=diamond, label=%s", dotQuote(label)))
+}
+
+func (g *flowGraph) node(fd *ast.FuncDecl, key interface{}, pos token.Pos, attrs string) string {
+	id := fmt.Sprintf("n%d", len(g.ids))
+	g.ids[key] = id
+	g.nodes[fd] = append(g.nodes[fd], flowNode{pos, fmt.Sprintf("%s [%s];", id, attrs)})
+	return id
+}
+
+// posOf returns the token.Pos for a position in the graph's file.
+func (g *flowGraph) posOf(p token.Position) token.Pos {
+	return g.file.Pos(p.Offset)
+}
+
+func (g *flowGraph) edge(from, to, label string) {
+	e := fmt.Sprintf("%s -> %s [label=%s];", from, to, dotQuote(label))
+	if !g.seen[e] {
+		g.seen[e] = true
+		g.edges = append(g.edges, e)
+	}
+}
+
+// funcAt returns the included function whose declaration contains pos, if
+// any.
+func (g *flowGraph) funcAt(pos token.Pos) *ast.FuncDecl {
+	for _, fd := range g.funcs {
+		if fd.Pos() <= pos && pos < fd.End() {
+			return fd
+		}
+	}
+	return nil
+}
+
+func (g *flowGraph) text(node ast.Node) string {
+	return g.src[g.fset.Position(node.Pos()).Offset:g.fset.Position(node.End()).Offset]
+}
+
+func (g *flowGraph) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph entanglement {\n")
+	for i, fd := range g.funcs {
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			name = "(" + g.text(fd.Recv.List[0].Type) + ")." + name
+		}
+		fmt.Fprintf(&buf, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, dotQuote("func "+name))
+		nodes := g.nodes[fd]
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].pos < nodes[j].pos })
+		for _, node := range nodes {
+			fmt.Fprintf(&buf, "\t\t%s\n", node.line)
+		}
+		buf.WriteString("\t}\n")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&buf, "\t%s\n", e)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
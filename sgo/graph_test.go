@@ -0,0 +1,77 @@
+package sgo
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEntanglementGraph(t *testing.T) {
+	src := `package example
+
+func get() (*int \ error) {
+	return new(int) \
+}
+
+func f(x ?*int) {
+	if x != nil {
+		_ = *x
+	}
+	p \ err := get()
+	if err != nil {
+		return
+	}
+	_ = *p
+}
+`
+	graph, err := EntanglementGraph(src, "f")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(graph, "digraph entanglement {\n") || !strings.Contains(graph, `label="func f";`) {
+		t.Fatalf("expected a graph with a cluster for f, got:\n%s", graph)
+	}
+
+	nodeRe := regexp.MustCompile(`(?m)^\t\t(n\d+) \[(.*), label="(.*)"\];$`)
+	edgeRe := regexp.MustCompile(`(?m)^\t(n\d+) -> (n\d+) \[label="(.*)"\];$`)
+
+	labels := map[string]string{}
+	var nodes []string
+	for _, m := range nodeRe.FindAllStringSubmatch(graph, -1) {
+		labels[m[1]] = m[3]
+		nodes = append(nodes, m[2]+" "+m[3])
+	}
+	var edges []string
+	for _, m := range edgeRe.FindAllStringSubmatch(graph, -1) {
+		edges = append(edges, labels[m[1]]+" -"+m[3]+"-> "+labels[m[2]])
+	}
+
+	expectedNodes := []string{
+		`style=dashed x ?*int`,
+		`shape=diamond x != nil\nline 8`,
+		`style=solid x *int`,
+		`style=solid p *int`,
+		`style=dashed err ?error`,
+		`shape=box get()`,
+		`shape=diamond err != nil\nline 12`,
+	}
+	if !reflect.DeepEqual(nodes, expectedNodes) {
+		t.Errorf("expected nodes:\n%s\ngot:\n%s", strings.Join(expectedNodes, "\n"), strings.Join(nodes, "\n"))
+	}
+	expectedEdges := []string{
+		`get() -result-> p *int`,
+		`get() -entangled-> err ?error`,
+		`x != nil\nline 8 -unwrapped-> x *int`,
+		`err != nil\nline 12 -collapsed-> p *int`,
+		`x ?*int -checked-> x != nil\nline 8`,
+		`err ?error -checked-> err != nil\nline 12`,
+	}
+	if !reflect.DeepEqual(edges, expectedEdges) {
+		t.Errorf("expected edges:\n%s\ngot:\n%s", strings.Join(expectedEdges, "\n"), strings.Join(edges, "\n"))
+	}
+
+	if _, err := EntanglementGraph(src, "missing"); err == nil {
+		t.Errorf("expected error for a missing function")
+	}
+}
@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+// annotationFiles caches the .sgoann files read from sgovendor folders, so
+// that repeated translations, like those from watch-mode tools, parse them
+// again only when they change.
+var annotationFiles = &annotationFileCache{}
+
+// An annotationFileCache holds parsed annotation files by path, along with
+// their modification time when they were parsed. It is safe for concurrent
+// use.
+type annotationFileCache struct {
+	mu      sync.Mutex
+	entries map[string]annotationFileEntry
+}
+
+type annotationFileEntry struct {
+	modTime time.Time
+	ann     *annotations.Annotation
+}
+
+// parse returns the Annotation in the file at path, parsing it only if it
+// isn't cached or it was modified since it was.
+func (c *annotationFileCache) parse(path string) (*annotations.Annotation, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) {
+		return entry.ann, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ann, err := annotations.ParseReader(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[string]annotationFileEntry{}
+	}
+	c.entries[path] = annotationFileEntry{modTime: info.ModTime(), ann: ann}
+	c.mu.Unlock()
+	return ann, nil
+}
+
+// packageAnnotationFiles caches the .sgoann files found next to imported
+// packages' .go files, so that importers look for them again only once files
+// are added to or removed from a package's directory.
+var packageAnnotationFiles = &discoveryCache{}
+
+// A discoveryCache holds, by package path, the .sgoann files found in each
+// package's source directory. It is safe for concurrent use.
+type discoveryCache struct {
+	mu      sync.Mutex
+	entries map[string]discoveryEntry
+}
+
+type discoveryEntry struct {
+	dir     string
+	modTime time.Time
+	files   []string
+}
+
+// find returns the sorted paths to the .sgoann files in dir, the source
+// directory of the package with the given path, listing dir again if its
+// modification time changed.
+func (c *discoveryCache) find(path, dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.dir == dir && entry.modTime.Equal(info.ModTime()) {
+		return entry.files, nil
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	fileNames, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fileNames)
+	var files []string
+	for _, fileName := range fileNames {
+		if filepath.Ext(fileName) == ".sgoann" {
+			files = append(files, filepath.Join(dir, fileName))
+		}
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[string]discoveryEntry{}
+	}
+	c.entries[path] = discoveryEntry{dir: dir, modTime: info.ModTime(), files: files}
+	c.mu.Unlock()
+	return files, nil
+}
@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAnnotationFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgo-anncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lib.sgoann")
+	write := func(src string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write("F func() *T\n", modTime)
+
+	cache := &annotationFileCache{}
+
+	// Parse concurrently to let the race detector have a look.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.parse(path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	first, err := cache.parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := first.Lookup("F").Type(); typ != "func() *T" {
+		t.Fatalf("expected F to be annotated as func() *T, got %q", typ)
+	}
+	again, err := cache.parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("expected unchanged file to be cached")
+	}
+
+	// Same mtime: the cached version is still trusted.
+	write("F func() ?*T\n", modTime)
+	again, err = cache.parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("expected file with the same mtime to be cached")
+	}
+
+	// Touched: parsed again.
+	write("F func() ?*T\n", modTime.Add(time.Minute))
+	touched, err := cache.parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := touched.Lookup("F").Type(); typ != "func() ?*T" {
+		t.Errorf("expected touched file to be parsed again, got F as %q", typ)
+	}
+
+	write("F {", modTime.Add(2*time.Minute))
+	if _, err := cache.parse(path); err == nil {
+		t.Errorf("expected parse error")
+	}
+}
@@ -0,0 +1,60 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBufioScanner(t *testing.T) {
+	// Those that name package io are left out, so that it needn't be
+	// imported.
+	anns := map[string]string{}
+	for _, name := range defaultAnnotations["bufio"].Names() {
+		if def, _ := defaultAnnotations["bufio"].Definition(name); !strings.Contains(def, "io.") {
+			anns[name] = def
+		}
+	}
+	lib := testImportLib(t, "bufio", `
+	package bufio
+
+	type Reader struct{}
+
+	type Scanner struct{}
+
+	type SplitFunc func(data []byte, atEOF bool) (advance int, token []byte, err error)
+
+	func (b *Reader) ReadString(delim byte) (string, error) { return "", nil }
+
+	func (b *Reader) ReadRune() (r rune, size int, err error) { return 0, 0, nil }
+
+	func (s *Scanner) Scan() bool { return false }
+
+	func (s *Scanner) Text() string { return "" }
+
+	func (s *Scanner) Err() error { return nil }
+
+	func (s *Scanner) Split(split SplitFunc) {}
+	`, anns)
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "bufio"
+
+	func f(r *bufio.Reader, s *bufio.Scanner) (string, ?error) {
+		for s.Scan() {
+			_ = s.Text()
+		}
+		if err := s.Err(); err != nil {
+			return "", err
+		}
+		var err error = s.Err() // ERROR
+		line, err := r.ReadString('\n') // ERROR
+		s.Split(nil) // ERROR
+		c, _ \ err2 := r.ReadRune()
+		_, _ = c, err2
+		return line, err
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 13, 14, 15)
+}
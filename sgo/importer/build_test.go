@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+func TestTagGatedAnnotations(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "sgo-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	pkgDir := filepath.Join(gopath, "src", "example.com", "gated")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{
+		"gated.go": `package gated
+
+type Value struct{ N int }
+`,
+		"lookup_unix.go": `// +build !windows
+
+package gated
+
+func Lookup(key string) *Value { return nil }
+`,
+		"lookup_windows.go": `package gated
+
+func Lookup(key string, fallback *Value) *Value { return fallback }
+`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(pkgDir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ann := `
+Lookup func(key string) *Value @build !windows
+Lookup func(key string, fallback ?*Value) ?*Value @build windows
+`
+
+	for _, c := range []struct {
+		goos string
+		src  string
+	}{
+		{"linux", `
+		package user
+
+		import "example.com/gated"
+
+		func f() int {
+			return gated.Lookup("k").N
+		}
+		`},
+		{"windows", `
+		package user
+
+		import "example.com/gated"
+
+		func f() int {
+			v := gated.Lookup("k", nil)
+			return v.N // ERROR
+		}
+		`},
+	} {
+		ctx := build.Default
+		ctx.GOPATH = gopath
+		ctx.GOOS = c.goos
+		ctx.CgoEnabled = false
+
+		imp, err := DefaultFromContext(&ctx, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		imp.(*importer).sgovendored["example.com/gated"] = func() (*annotations.Annotation, error) {
+			return annotations.Parse(ann)
+		}
+
+		pkg, err := imp.Import("example.com/gated")
+		if err != nil {
+			t.Fatalf("%s: importing: %v", c.goos, err)
+		}
+
+		errs := testCheckSGo(t, c.src, pkg)
+		if c.goos == "windows" {
+			testExpectErrorLines(t, errs, 8)
+		} else {
+			testExpectErrorLines(t, errs)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package importer
+
+import "testing"
+
+func TestCommaOkAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	func Index() map[string]*T { return nil }
+
+	func Cache() map[string]*T { return nil }
+
+	func Results() chan *T { return nil }
+
+	func Any() interface{} { return nil }
+	`, map[string]string{
+		"Index":   `func() map[string]*T`,
+		"Cache":   `func() map[string]?*T`,
+		"Results": `func() chan *T`,
+		"Any":     `func() interface{}`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func index() int {
+		v \ ok := lib.Index()["a"]
+		if !ok {
+			return 0
+		}
+		return v.N
+	}
+
+	func cache() int {
+		v \ ok := lib.Cache()["a"]
+		if !ok {
+			return 0
+		}
+		return v.N
+	}
+
+	func results() int {
+		v \ ok := <-lib.Results()
+		if !ok {
+			return 0
+		}
+		return v.N
+	}
+
+	func any() int {
+		v \ ok := lib.Any().(*lib.T)
+		if !ok {
+			return 0
+		}
+		return v.N
+	}
+
+	func plain() {
+		v, ok := lib.Index()["a"]
+		w, ok := <-lib.Results()
+		x, ok := lib.Any().(*lib.T)
+		_, _, _, _ = v, w, x, ok
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 19, 39, 40, 41)
+}
@@ -33,14 +33,21 @@ func (c *astConverter) convertAST(node ast.Node, ann *annotations.Annotation, re
 
 	switch n := node.(type) {
 	case *ast.Field:
+		parent := ann
 		if len(n.Names) == 1 {
 			ann = ann.Lookup(n.Names[0].Name)
+		} else if name, ok := embeddedName(n.Type); ok && len(n.Names) == 0 {
+			ann = ann.Lookup(name)
 		} else {
 			ann = nil
 		}
 		if replaced := c.maybeReplace(n, ann, func(e ast.Expr) { n.Type = e }); replaced {
 			return
 		}
+		if policy, _ := parent.Default(); policy == "!" {
+			// The struct's unannotated fields keep their Go types.
+			return
+		}
 		c.convertAST(n.Type, ann, func(e ast.Expr) { n.Type = e })
 
 	case *ast.FieldList:
@@ -92,20 +99,7 @@ func (c *astConverter) convertAST(node ast.Node, ann *annotations.Annotation, re
 		for _, f := range n.Methods.List {
 			name := ""
 			if len(f.Names) == 0 {
-				var id *ast.Ident
-
-				switch t := f.Type.(type) {
-				case *ast.Ident:
-					id = t
-				case *ast.StarExpr:
-					if t, ok := t.X.(*ast.Ident); ok {
-						id = t
-					}
-				}
-
-				if id != nil {
-					name = id.Name
-				}
+				name, _ = embeddedName(f.Type)
 			} else {
 				name = f.Names[0].Name
 			}
@@ -133,7 +127,11 @@ func (c *astConverter) convertAST(node ast.Node, ann *annotations.Annotation, re
 
 	// Declarations
 	case *ast.ValueSpec:
-		if n.Type != nil {
+		if n.Type != nil && initializedSpec(n) != nil {
+			// Initialized with a value that is never nil, so only what's
+			// inside the type is optional.
+			c.convertAST(n.Type, nil, nil)
+		} else if n.Type != nil {
 			c.convertAST(n.Type, nil, func(e ast.Expr) { n.Type = e })
 		}
 
@@ -204,14 +202,27 @@ func (c *astConverter) convertAST(node ast.Node, ann *annotations.Annotation, re
 				c.convertAST(d, ann, nil)
 			case *ast.FuncDecl:
 				name := d.Name.Name
+				recvName := ""
 				if d.Recv != nil && len(d.Recv.List) > 0 {
 					switch t := d.Recv.List[0].Type.(type) {
 					case *ast.StarExpr:
 						if id, ok := t.X.(*ast.Ident); ok {
-							name = "(*" + id.Name + ")." + name
+							recvName = "(*" + id.Name + ")"
 						}
 					case *ast.Ident:
-						name = t.Name + "." + name
+						recvName = valueRecvName(ann, t.Name)
+					}
+				}
+				if recvName != "" {
+					name = recvName + "." + name
+					// With a "default !" for the receiver, unannotated methods
+					// keep their Go types, receiver included.
+					_, annotated := ann.Lookup(name).Type()
+					if _, ok := annFromDoc(d); ok {
+						annotated = true
+					}
+					if policy, _ := ann.Lookup(recvName).Default(); policy == "!" && !annotated {
+						continue
 					}
 				}
 				c.convertAST(d, ann.Lookup(name), func(e ast.Expr) {
@@ -310,3 +321,31 @@ func annFromDoc(node ast.Node) (string, bool) {
 
 	return "", false
 }
+
+// embeddedName returns the name an embedded field or interface with type typ
+// is annotated by: the type's name, qualified by its package if it's from
+// another one, as in "io.Reader".
+func embeddedName(typ ast.Expr) (string, bool) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t.Name, true
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			return pkg.Name + "." + t.Sel.Name, true
+		}
+	}
+	return "", false
+}
+
+// valueRecvName returns the name methods with a value receiver of the type
+// named typeName are annotated under: "(T)" if anything is annotated as such,
+// or else the type's own name.
+func valueRecvName(ann *annotations.Annotation, typeName string) string {
+	if recv := "(" + typeName + ")"; ann.Kind(recv) != annotations.NoKind {
+		return recv
+	}
+	return typeName
+}
@@ -1,137 +1,116 @@
 package importer
 
-var defaultAnnotations = map[string]map[string]string{
-	"os": {
-		"Stdin":         `*File`,
-		"Stdout":        `*File`,
-		"Stderr":        `*File`,
-		"Create":        `func(name string) (*File \ error)`,
-		"Open":          `func(name string) (*File \ error)`,
-		"(*File).Read":  `(*File) func(b []byte) (n int, err ?error)`,
-		"(*File).Write": `(*File) func(b []byte) (n int, err ?error)`,
-	},
-	"io": {
-		"Reader.Read":  `func([]byte) (int, ?error)`,
-		"Writer.Write": `func([]byte) (int, ?error)`,
-	},
-	"os/exec": {
-		"Command": `func (name string, arg ...string) *Cmd`,
-	},
-	"html/template": {
-		"New":             `func(name string) *Template`,
-		"Must":            `func(t ?*Template, err ?error) *Template`,
-		"(*Template).New": `(*Template) func(name string) *Template`,
-	},
-	"text/template": {
-		"New":               `func(name string) *Template`,
-		"Must":              `func(t ?*Template, err ?error) *Template`,
-		"(*Template).New":   `(*Template) func(name string) *Template`,
-		"(*Template).Parse": `(*Template) func(text string) (*Template \ error)`,
-	},
-	"strings": {
-		"NewReader":      `func(s string) *Reader`,
-		"(*Reader).Read": `(*Reader) func(b []byte) (n int, err ?error)`,
-	},
-	"go/scanner": {
-		"ErrorList": `[]*Error`,
-	},
-	"errors": {
-		"New": `func(text string) error`,
-	},
-	"net/http": {
-		"PostForm":             `func(url string, data url.Values) (resp *Response \ err error)`,
-		"HandleFunc":           `func(pattern string, handler func(ResponseWriter, *Request))`,
-		"Request.URL":          `*url.URL`,
-		"ResponseWriter.Write": `func([]byte) (int, ?error)`,
-		"NewRequest":           `func(method, urlStr string, body ?io.Reader) (*Request \ error)`,
-		"(*Client).Do":         `(*Client) func(req *Request) (resp *Response \ err error)`,
-		"FileSystem.Open":      `func(name string) (File \ error)`,
-		"FileServer":           `func(root FileSystem) Handler`,
-		"StripPrefix":          `func(prefix string, h Handler) Handler`,
-		"ProxyFromEnvironment": `func(req *Request) (*url.URL \ error)`,
-		"HandlerFunc":          `func(ResponseWriter, *Request)`,
-		"Handler.ServeHTTP":    `func(ResponseWriter, *Request)`,
-	},
-	"encoding/json": {
-		"NewDecoder":                `func(io.Reader) *Decoder`,
-		"NewEncoder":                `func(io.Writer) *Encoder`,
-		"Marshaler.MarshalJSON":     `func() ([]byte \ error)`,
-		"Unmarshaler.UnmarshalJSON": `func([]byte) ?error`,
-		"Marshal":                   `func(v interface{}) ([]byte \ error)`,
-		"Unmarshal":                 `func(data []byte, v ?interface{}) ?error`,
-	},
-	"flag": {
-		"Bool":         "func(name string, value bool, usage string) *bool",
-		"BoolVar":      "func(p *bool, name string, value bool, usage string)",
-		"Duration":     "func(name string, value time.Duration, usage string) *time.Duration",
-		"DurationVar":  "func(p *time.Duration, name string, value time.Duration, usage string)",
-		"Float64":      "func(name string, value float64, usage string) *float64",
-		"Float64Var":   "func(p *float64, name string, value float64, usage string)",
-		"Int":          "func(name string, value int, usage string) *int",
-		"Int64":        "func(name string, value int64, usage string) *int64",
-		"Int64Var":     "func(p *int64, name string, value int64, usage string)",
-		"IntVar":       "func(p *int, name string, value int, usage string)",
-		"String":       "func(name string, value string, usage string) *string",
-		"StringVar":    "func(p *string, name string, value string, usage string)",
-		"Uint":         "func(name string, value uint, usage string) *uint",
-		"Uint64":       "func(name string, value uint64, usage string) *uint64",
-		"Uint64Var":    "func(p *uint64, name string, value uint64, usage string)",
-		"UintVar":      "func(p *uint, name string, value uint, usage string)",
-		"UnquoteUsage": "func(flag *Flag) (name string, usage string)",
-		"Visit":        "func(fn func(*Flag))",
-		"VisitAll":     "fnuc(fn func(*Flag))",
-		"Usage":        `func()`,
-	},
-	"fmt": {
-		"Errorf": `func(format string, a ...interface{}) error`,
-	},
-	"bytes": {
-		"(*Buffer).Read":  `(*Buffer) func(p []byte) (n int, err ?error)`,
-		"(*Buffer).Write": `(*Buffer) func(p []byte) (n int, err ?error)`,
-	},
-	"time": {
-		"Tick":      `func(Duration) chan Time`,
-		"After":     `func(Duration) chan Time`,
-		"NewTicker": `func(Duration) *Ticker`,
-		"Ticker.C":  `<-chan Time`,
-	},
-	"reflect": {
-		"TypeOf":            `func(interface{}) Type`,
-		"Type.Elem":         `func() Type`,
-		"Type.Key":          `func() Type`,
-		"Value.Interface":   `func() interface{}`,
-		"Value.Type":        `func() Type`,
-		"StructField.Type":  `Type`,
-		"Type.MethodByName": `func(string) (Method \ bool)`,
-	},
-	"strconv": {
-		"Atoi":       `func(s string) (int \ error)`,
-		"ParseUint":  `func(s string, base int, bitSize int) (n uint64 \ err error)`,
-		"ParseInt":   `func(s string, base int, bitSize int) (n int64 \ err error)`,
-		"ParseFloat": `func(s string, bitSize int) (f float64 \ err error)`,
-		"Unquote":    `func(s string) (t string \ err error)`,
-	},
-	"go/token": {
-		"NewFileSet":         `func() *FileSet`,
-		"(*FileSet).AddFile": `(*FileSet) func(filename string, base, size int) *File`,
-	},
-	"go/ast": {
-		"FuncLit.Type":      `*FuncType`,
-		"FuncLit.Body":      `*BlockStmt`,
-		"NewScope":          `func(?*Scope) *Scope`,
-		"NewObj":            `func(kind ObjKind, name string) *Object`,
-		"BlockStmt.List":    `[]Stmt`,
-		"SelectorExpr.Sel":  `*Ident`,
-		"CompositeLit.Elts": `[]Expr`,
-		"FuncType.Params":   `*FieldList`,
-		"FieldList.List":    `[]*Field`,
-		"Field.Names":       `[]*Ident`,
-	},
-	"go/constant": {
-		"MakeFromLiteral": `func(lit string, tok token.Token, zero uint) Value`,
-		"Uint64Val":       `func(x Value) (uint64 \ bool)`,
-	},
-	"go/format": {
-		"Source": `func(src []byte) ([]byte \ error)`,
-	},
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+// stdlibFiles are the built-in annotations for the standard library, laid out
+// like an sgovendor folder.
+//
+//go:embed stdlib
+var stdlibFiles embed.FS
+
+// defaultAnnotations are the built-in annotations, by package path. They're
+// parsed when the program starts, which panics if any of them is malformed.
+var defaultAnnotations = mustParseDefaultAnnotations(stdlibFiles)
+
+func mustParseDefaultAnnotations(files fs.FS) map[string]*annotations.Annotation {
+	anns, err := parseDefaultAnnotations(files)
+	if err != nil {
+		panic(err)
+	}
+	return anns
+}
+
+func parseDefaultAnnotations(files fs.FS) (map[string]*annotations.Annotation, error) {
+	stdlib, err := fs.Sub(files, "stdlib")
+	if err != nil {
+		return nil, err
+	}
+	anns, err := annotations.ParseFS(stdlib)
+	if err != nil {
+		return nil, fmt.Errorf("built-in annotations: %v", err)
+	}
+	return anns, nil
+}
+
+// dirAnnotations are the annotations set with SetAnnotationsDir, by package
+// path, which importers use instead of defaultAnnotations.
+var dirAnnotations struct {
+	sync.Mutex
+	anns map[string]*annotations.Annotation
+}
+
+// LoadAnnotationsDir reads the .sgoann files under dir, each named after the
+// package it annotates: dir/os.sgoann holds the annotations for package os,
+// and dir/net/http.sgoann those for net/http. Other files are ignored.
+func LoadAnnotationsDir(dir string) (map[string]*annotations.Annotation, error) {
+	anns := map[string]*annotations.Annotation{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".sgoann" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ann, err := annotations.Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		anns[strings.TrimSuffix(filepath.ToSlash(rel), ".sgoann")] = ann
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return anns, nil
+}
+
+// SetAnnotationsDir makes importers use the annotations that
+// LoadAnnotationsDir reads from dir instead of the built-in ones, for the
+// packages it has a file for. An empty dir goes back to the built-in ones.
+func SetAnnotationsDir(dir string) error {
+	var anns map[string]*annotations.Annotation
+	if dir != "" {
+		var err error
+		anns, err = LoadAnnotationsDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+	dirAnnotations.Lock()
+	dirAnnotations.anns = anns
+	dirAnnotations.Unlock()
+	return nil
+}
+
+// builtinAnnotations returns the annotations for the package with the given
+// path that importers use before looking in sgovendor folders: those set with
+// SetAnnotationsDir, or else the built-in ones.
+func builtinAnnotations(path string) (*annotations.Annotation, bool) {
+	dirAnnotations.Lock()
+	ann, ok := dirAnnotations.anns[path]
+	dirAnnotations.Unlock()
+	if ok {
+		return ann, true
+	}
+	ann, ok = defaultAnnotations[path]
+	return ann, ok
 }
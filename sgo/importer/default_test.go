@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"go/build"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+func TestDefaultAnnotationsParse(t *testing.T) {
+	n := 0
+	err := fs.WalkDir(stdlibFiles, "stdlib", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if path.Ext(name) != ".sgoann" {
+			t.Errorf("%s: unexpected file in built-in annotations", name)
+			return nil
+		}
+		src, err := fs.ReadFile(stdlibFiles, name)
+		if err != nil {
+			return err
+		}
+		if _, err := annotations.ParseAllWith(annotations.ParseOptions{ValidateTypes: true}, string(src)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatal("no built-in annotations embedded")
+	}
+
+	for _, c := range []struct {
+		pkg, name, typ string
+	}{
+		{"os", "Open", `func(name string) (*File \ error)`},
+		{"os", "(*File).Close", `(*File) func() \ error`},
+		{"net/http", "Request.URL", `*url.URL`},
+		{"embed", "FS.Open", `func(name string) (fs.File \ error)`},
+		{"embed", "FS.ReadDir", `func(name string) ([]fs.DirEntry \ error)`},
+		{"embed", "FS.ReadFile", `func(name string) ([]byte \ error)`},
+		{"io/fs", "ReadDir", `func(fsys FS, name string) ([]DirEntry \ error)`},
+		{"io/fs", "FS.Open", `func(name string) (File \ error)`},
+		{"io/fs", "DirEntry.Info", `func() (FileInfo \ error)`},
+		{"sync", "OnceValue", `func(f func() T) func() T`},
+		{"cmp", "Ordered", `interface{ ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr | ~float32 | ~float64 | ~string }`},
+		{"sync/atomic", "(*Pointer).Load", `func() ?*T`},
+		{"bufio", "NewScanner", `func(r io.Reader) *Scanner`},
+		{"bufio", "(*Scanner).Scan", `(*Scanner) func() bool`},
+		{"bufio", "(*Scanner).Err", `(*Scanner) func() ?error`},
+		{"bufio", "(*Reader).ReadString", `(*Reader) func(delim byte) (string, ?error)`},
+		{"bufio", "(*Reader).ReadLine", `(*Reader) func() (line []byte, isPrefix bool \ err error)`},
+		{"bufio", "(*Writer).Flush", `(*Writer) func() \ error`},
+		{"bytes", "(*Buffer).ReadByte", `(*Buffer) func() (byte \ error)`},
+		{"bytes", "(*Buffer).WriteString", `(*Buffer) func(s string) (n int, err ?error)`},
+		{"strings", "Split", `func(s, sep string) []string`},
+		{"strings", "Map", `func(mapping func(rune) rune, s string) string`},
+		{"strings", "(*Builder).WriteString", `(*Builder) func(s string) (int, ?error)`},
+		{"io", "Copy", `func(dst Writer, src Reader) (written int64, err ?error)`},
+		{"io", "EOF", `error`},
+	} {
+		typ, ok := defaultAnnotations[c.pkg].Lookup(c.name).Type()
+		if !ok || typ != c.typ {
+			t.Errorf("%s.%s: expected %q, got %q", c.pkg, c.name, c.typ, typ)
+		}
+	}
+}
+
+func TestDefaultAnnotationsRoundTrip(t *testing.T) {
+	var paths []string
+	for path := range defaultAnnotations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		ann := defaultAnnotations[path]
+		t.Run(path, func(t *testing.T) {
+			// Each definition is parsed on its own, as an Item written
+			// with Marshal, so that an error names it.
+			for _, name := range ann.Names() {
+				def, _ := ann.Definition(name)
+				src := annotations.NewAnnotation(map[string]string{name: def}).Marshal()
+				parsed, err := annotations.ParseWith(annotations.ParseOptions{ValidateTypes: true}, src)
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+					continue
+				}
+				if got, _ := parsed.Definition(name); got != def || parsed.Len() != 1 {
+					t.Errorf("%s: expected %q after a round trip, got %q", name, def, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultAnnotationsMalformed(t *testing.T) {
+	files := fstest.MapFS{
+		"stdlib/os/os.sgoann": &fstest.MapFile{Data: []byte("Open {\n")},
+	}
+	if _, err := parseDefaultAnnotations(files); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestCheckDefaultAnnotations(t *testing.T) {
+	for _, err := range CheckDefaultAnnotations() {
+		t.Error(err)
+	}
+
+	bad := map[string]*annotations.Annotation{
+		"os": annotations.NewAnnotation(map[string]string{
+			"Open":    `func(name string) (*File \ error)`,
+			"Exit":    `func(code int @noreturn process`,
+			"TempDir": `@nonempty`,
+		}),
+	}
+	errs := checkAnnotations(bad)
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "os: Exit: invalid type") {
+		t.Errorf("expected an error for os.Exit, got %v", errs)
+	}
+}
+
+func TestLoadAnnotationsDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"os.sgoann":       "Getenv func(key string) string @nonempty\n",
+		"net/http.sgoann": "// Comments only.\n",
+		"io/fs.sgoann":    "ReadFile func(fsys FS, name string) ([]byte \\ error)\n",
+		"README":          "Getenv is not an annotation: ",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	anns, err := LoadAnnotationsDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anns) != 3 || anns["net/http"] == nil || anns["net/http"].Len() != 0 {
+		t.Errorf("expected annotations for os, net/http and io/fs, got %v", anns)
+	}
+	if typ, _ := anns["os"].Lookup("Getenv").Type(); typ != "func(key string) string" {
+		t.Errorf("os.Getenv: unexpected type %q", typ)
+	}
+
+	if err := SetAnnotationsDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAnnotationsDir("")
+	for _, c := range []struct {
+		pkg, name, typ string
+	}{
+		{"os", "Getenv", "func(key string) string"},
+		{"os", "Open", ""},
+		{"io/fs", "ReadFile", `func(fsys FS, name string) ([]byte \ error)`},
+		{"embed", "FS.Open", `func(name string) (fs.File \ error)`},
+	} {
+		ann, err := Annotations(&build.Default, c.pkg, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ, _ := ann.Lookup(c.name).Type(); typ != c.typ {
+			t.Errorf("%s.%s: expected %q, got %q", c.pkg, c.name, c.typ, typ)
+		}
+	}
+
+	// Going back to the built-in annotations.
+	if err := SetAnnotationsDir(""); err != nil {
+		t.Fatal(err)
+	}
+	ann, err := Annotations(&build.Default, "os", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := ann.Lookup("Open").Type(); typ != `func(name string) (*File \ error)` {
+		t.Errorf("os.Open: expected the built-in annotation, got %q", typ)
+	}
+
+	// Errors keep the annotations in use as they were.
+	bad := filepath.Join(dir, "bad.sgoann")
+	if err := ioutil.WriteFile(bad, []byte("Open {\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAnnotationsDir(dir); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("expected an error for %s, got %v", bad, err)
+	}
+	if _, err := LoadAnnotationsDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+	if ann, _ := Annotations(&build.Default, "os", ""); !ann.Equal(defaultAnnotations["os"].ForContext(&build.Default)) {
+		t.Error("expected the built-in annotations for os after an error")
+	}
+}
@@ -0,0 +1,57 @@
+package importer
+
+import (
+	"sort"
+	"sync"
+)
+
+// dependents records, for each package path, the files that imported the
+// package, and so consumed its annotations, through an importer made by
+// DefaultForFiles.
+var dependents = struct {
+	sync.Mutex
+	m map[string]map[string]bool
+}{m: map[string]map[string]bool{}}
+
+// Dependents returns, sorted, the paths of the files whose latest translation
+// through an importer made by DefaultForFiles imported the package pkgPath.
+func Dependents(pkgPath string) []string {
+	dependents.Lock()
+	defer dependents.Unlock()
+	var paths []string
+	for path := range dependents.m[pkgPath] {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// forgetDependents removes the files at paths from the dependents of every
+// package, before they're recorded again for a new translation.
+func forgetDependents(paths []string) {
+	dependents.Lock()
+	defer dependents.Unlock()
+	for _, files := range dependents.m {
+		for _, path := range paths {
+			delete(files, path)
+		}
+	}
+}
+
+// addDependents records the files at paths as dependents of the package
+// pkgPath.
+func addDependents(pkgPath string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	dependents.Lock()
+	defer dependents.Unlock()
+	files, ok := dependents.m[pkgPath]
+	if !ok {
+		files = map[string]bool{}
+		dependents.m[pkgPath] = files
+	}
+	for _, path := range paths {
+		files[path] = true
+	}
+}
@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/ast"
+)
+
+func TestDependents(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "sgo-dependents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	for path, src := range map[string]string{
+		"example.com/deps/a": "package a\n\nfunc A() *int { return nil }\n",
+		"example.com/deps/b": "package b\n\nfunc B() *int { return nil }\n",
+	} {
+		pkgDir := filepath.Join(gopath, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(pkgDir, "pkg.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := build.Default
+	ctx.GOPATH = gopath
+	ctx.CgoEnabled = false
+
+	// Each translation typechecks the files of a package together, so all of
+	// them depend on every package imported for any of them.
+	translate := func(paths []string, imports ...string) {
+		var files []*ast.File
+		for range paths {
+			files = append(files, &ast.File{Name: ast.NewIdent("user")})
+		}
+		imp, err := DefaultForFiles(&ctx, files, paths, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range imports {
+			if _, err := imp.Import(pkg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expectDependents := func(pkg string, expected ...string) {
+		if got := Dependents(pkg); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected dependents %v, got %v", pkg, expected, got)
+		}
+	}
+
+	translate([]string{"/src/one/one.sgo"}, "example.com/deps/a")
+	translate([]string{"/src/two/x.sgo", "/src/two/y.sgo"}, "example.com/deps/a", "example.com/deps/b")
+	expectDependents("example.com/deps/a", "/src/one/one.sgo", "/src/two/x.sgo", "/src/two/y.sgo")
+	expectDependents("example.com/deps/b", "/src/two/x.sgo", "/src/two/y.sgo")
+
+	// Translating files again replaces what they depended on.
+	translate([]string{"/src/two/x.sgo", "/src/two/y.sgo"}, "example.com/deps/b")
+	expectDependents("example.com/deps/a", "/src/one/one.sgo")
+	expectDependents("example.com/deps/b", "/src/two/x.sgo", "/src/two/y.sgo")
+
+	// Importers for typechecking other than translations record nothing.
+	imp, err := DefaultFromContext(&ctx, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imp.Import("example.com/deps/b"); err != nil {
+		t.Fatal(err)
+	}
+	expectDependents("example.com/deps/b", "/src/two/x.sgo", "/src/two/y.sgo")
+}
@@ -0,0 +1,247 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// applyDirectives sets on the objects declared in pkg the facts described by
+// the directives in their annotations, as listed in README.md.
+func applyDirectives(pkg *types.Package, ann *annotations.Annotation) error {
+	if ann == nil {
+		return nil
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			err := applyFuncDirectives(obj, ann.Lookup(name))
+			if err != nil {
+				return err
+			}
+		case *types.TypeName:
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				m := named.Method(i)
+				mAnn := ann.Lookup(valueRecvName(ann, name)).Lookup(m.Name())
+				if sig, ok := m.Type().(*types.Signature); ok && sig.Recv() != nil {
+					recv := sig.Recv().Type()
+					// Unless annotated, pointer receivers are optional.
+					if opt, ok := recv.(*types.Optional); ok {
+						recv = opt.Elem()
+					}
+					if _, ok := recv.(*types.Pointer); ok {
+						mAnn = ann.Lookup("(*" + name + ")").Lookup(m.Name())
+					}
+				}
+				err := applyFuncDirectives(m, mAnn)
+				if err != nil {
+					return err
+				}
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				for i := 0; i < iface.NumExplicitMethods(); i++ {
+					m := iface.ExplicitMethod(i)
+					err := applyFuncDirectives(m, ann.Lookup(name).Lookup(m.Name()))
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func applyFuncDirectives(fun *types.Func, ann *annotations.Annotation) error {
+	sig, ok := fun.Type().(*types.Signature)
+	if !ok {
+		return nil
+	}
+	for _, dir := range ann.Directives() {
+		switch dir.Name {
+		case "narrows":
+			params, err := directiveParams(sig, dir, false)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetNarrows(params...)
+		case "invalidates":
+			params, err := directiveParams(sig, dir, true)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetInvalidates(params...)
+		case "noreturn":
+			k, err := directiveNoReturn(dir)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetNoReturn(k)
+		case "nildefault":
+			params, err := directiveParams(sig, dir, false)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			for _, i := range params {
+				p := sig.Params().At(i)
+				if _, ok := p.Type().(*types.Optional); !ok && !types.IsOptionable(p.Type()) {
+					return fmt.Errorf("%s: directive %v: parameter %s can't be nil", fun.Name(), dir, p.Name())
+				}
+			}
+			sig.SetNilDefaults(params...)
+		case "fails":
+			if len(dir.Args) != 0 {
+				return fmt.Errorf("%s: directive %v: expected no arguments", fun.Name(), dir)
+			}
+			if sig.Results().Entangled() == nil {
+				return fmt.Errorf("%s: directive %v: no entangled error result", fun.Name(), dir)
+			}
+			sig.SetFails(true)
+		case "inits":
+			err := checkInits(sig, dir)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetInits(dir.Args...)
+		case "printf":
+			format, args, err := directivePrintf(sig, dir)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetPrintf(format, args)
+		case "nonempty":
+			err := checkNonEmpty(sig, dir)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fun.Name(), err)
+			}
+			sig.SetNonEmpty(true)
+		case "build":
+		default:
+			return fmt.Errorf("%s: unknown directive %v", fun.Name(), dir)
+		}
+	}
+	return nil
+}
+
+// directiveParams parses the $N arguments to a directive as indices of
+// parameters of sig. If recv, $0 is accepted for the receiver of a method, and
+// parsed as -1.
+func directiveParams(sig *types.Signature, dir annotations.Directive, recv bool) ([]int, error) {
+	if len(dir.Args) == 0 {
+		return nil, fmt.Errorf("directive %v: expected parameters", dir)
+	}
+	var params []int
+	for _, arg := range dir.Args {
+		n, err := strconv.Atoi(strings.TrimPrefix(arg, "$"))
+		if err != nil || !strings.HasPrefix(arg, "$") {
+			return nil, fmt.Errorf("directive %v: expected parameter like $1, got %q", dir, arg)
+		}
+		if n == 0 && recv && sig.Recv() != nil {
+			params = append(params, -1)
+			continue
+		}
+		if n < 1 || n > sig.Params().Len() {
+			return nil, fmt.Errorf("directive %v: no parameter %s", dir, arg)
+		}
+		params = append(params, n-1)
+	}
+	return params, nil
+}
+
+// directiveNoReturn parses the argument to a @noreturn directive.
+func directiveNoReturn(dir annotations.Directive) (types.NoReturn, error) {
+	if len(dir.Args) != 1 {
+		return types.Returns, fmt.Errorf("directive %v: expected goroutine or process", dir)
+	}
+	switch dir.Args[0] {
+	case "goroutine":
+		return types.TerminatesGoroutine, nil
+	case "process":
+		return types.TerminatesProcess, nil
+	}
+	return types.Returns, fmt.Errorf("directive %v: expected goroutine or process, got %q", dir, dir.Args[0])
+}
+
+// checkNonEmpty checks that a @nonempty directive has no arguments, and that
+// sig has some result that can be empty.
+func checkNonEmpty(sig *types.Signature, dir annotations.Directive) error {
+	if len(dir.Args) != 0 {
+		return fmt.Errorf("directive %v: expected no arguments", dir)
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		if types.CanBeEmpty(sig.Results().At(i).Type()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("directive %v: no string or slice results", dir)
+}
+
+// directivePrintf parses the arguments to a @printf directive, and checks that
+// they are a string parameter of sig and its final variadic one.
+func directivePrintf(sig *types.Signature, dir annotations.Directive) (format, args int, err error) {
+	params, err := directiveParams(sig, dir, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(params) != 2 {
+		return 0, 0, fmt.Errorf("directive %v: expected format and arguments parameters", dir)
+	}
+	format, args = params[0], params[1]
+	if f := sig.Params().At(format); !types.Identical(f.Type().Underlying(), types.Typ[types.String]) {
+		return 0, 0, fmt.Errorf("directive %v: parameter %s isn't a string", dir, f.Name())
+	}
+	if !sig.Variadic() || args != sig.Params().Len()-1 {
+		return 0, 0, fmt.Errorf("directive %v: parameter %s isn't variadic", dir, sig.Params().At(args).Name())
+	}
+	a := sig.Params().At(args)
+	elem := a.Type().(*types.Slice).Elem()
+	if _, ok := elem.(*types.Optional); !ok && !types.IsOptionable(elem) {
+		return 0, 0, fmt.Errorf("directive %v: parameter %s can't be nil", dir, a.Name())
+	}
+	return format, args, nil
+}
+
+// checkInits checks that the arguments to an @inits directive are optional
+// fields of the receiver of sig.
+func checkInits(sig *types.Signature, dir annotations.Directive) error {
+	if len(dir.Args) == 0 {
+		return fmt.Errorf("directive %v: expected fields", dir)
+	}
+	if sig.Recv() == nil {
+		return fmt.Errorf("directive %v: not a method", dir)
+	}
+	recv := sig.Recv().Type()
+	if opt, ok := recv.(*types.Optional); ok {
+		recv = opt.Elem()
+	}
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	st, ok := recv.Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("directive %v: receiver isn't a struct", dir)
+	}
+	for _, name := range dir.Args {
+		var field *types.Var
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() == name {
+				field = st.Field(i)
+			}
+		}
+		if field == nil {
+			return fmt.Errorf("directive %v: no field %s", dir, name)
+		}
+		if _, ok := field.Type().(*types.Optional); !ok {
+			return fmt.Errorf("directive %v: field %s isn't optional", dir, name)
+		}
+	}
+	return nil
+}
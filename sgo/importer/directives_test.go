@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestNarrowsDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	func IsValid(x *T) bool {
+		return x != nil
+	}
+	`, map[string]string{
+		"IsValid": `func(x ?*T) bool @narrows $1`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(x ?*lib.T) {
+		if lib.IsValid(x) {
+			_ = x.N
+		}
+		_ = x.N // ERROR
+		if !lib.IsValid(x) {
+			return
+		}
+		_ = x.N
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 10)
+}
+
+func TestDirectivesErrors(t *testing.T) {
+	src := `
+	package lib
+
+	func F(x *int) bool {
+		return x != nil
+	}
+	`
+	cases := []string{
+		`func(x ?*int) bool @narrows`,
+		`func(x ?*int) bool @narrows 1`,
+		`func(x ?*int) bool @narrows $2`,
+		`func(x ?*int) bool @nonsense`,
+		`func(x ?*int) bool @noreturn`,
+		`func(x ?*int) bool @noreturn thread`,
+		`@noreturn goroutine process`,
+		`func(x ?*int) bool @invalidates $0`,
+		`func(x ?*int) bool @invalidates $2`,
+		`func(x ?*int) bool @nildefault`,
+		`func(x ?*int) bool @nildefault $2`,
+		`func(x ?*int) bool @nonempty`,
+		`func(x ?*int) bool @nonempty $1`,
+		`func(x ?*int) bool @printf $1`,
+		`func(x ?*int) bool @fails`,
+		`func(x ?*int) (bool \ error) @fails $1`,
+		`func(x ?*int) bool @printf $1 $1`,
+	}
+	for i, c := range cases {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "lib.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imp, _ := newImporter(map[string]struct{}{}, "")
+		_, err = imp.checkFiles("example.com/lib", fset, []*ast.File{f}, annotations.NewAnnotation(map[string]string{"F": c}))
+		if err == nil {
+			t.Errorf("case %d: expected error for %q", i, c)
+		}
+	}
+}
+
+// testImportLib imports a Go package from source, converting it to SGo with the
+// given annotations, as the default importer would.
+func testImportLib(t *testing.T, path, src string, anns map[string]string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path+".go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp, err := newImporter(map[string]struct{}{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := imp.checkFiles(path, fset, []*ast.File{f}, annotations.NewAnnotation(anns))
+	if err != nil {
+		t.Fatalf("importing %s: %v", path, err)
+	}
+	return pkg
+}
+
+type testPkgsImporter map[string]*types.Package
+
+func (imp testPkgsImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp[path]; ok {
+		return pkg, nil
+	}
+	return nil, &types.Error{Msg: "can't find package " + path}
+}
+
+// testCheckSGo typechecks SGo source importing the given packages and returns
+// the errors found.
+func testCheckSGo(t *testing.T, src string, pkgs ...*types.Package) []*types.Error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "user.sgo", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp := testPkgsImporter{}
+	for _, pkg := range pkgs {
+		imp[pkg.Path()] = pkg
+	}
+	var errs []*types.Error
+	cfg := &types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			if err, ok := err.(types.Error); ok {
+				errs = append(errs, &err)
+				return
+			}
+			t.Errorf("unexpected error: %v", err)
+		},
+	}
+	cfg.Check("user", fset, []*ast.File{f}, nil)
+	return errs
+}
+
+func testExpectErrorLines(t *testing.T, errs []*types.Error, lines ...int) {
+	var got []int
+	var msgs []string
+	for _, err := range errs {
+		got = append(got, err.Fset.Position(err.Pos).Line)
+		msgs = append(msgs, err.Error())
+	}
+	if len(got) != len(lines) {
+		t.Errorf("expected errors at lines %v, got: \n%s", lines, strings.Join(msgs, "\n"))
+		return
+	}
+	for i := range lines {
+		if got[i] != lines[i] {
+			t.Errorf("expected errors at lines %v, got: \n%s", lines, strings.Join(msgs, "\n"))
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package importer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/printer"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestEmbeddedFieldAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Base struct {
+		N int
+	}
+
+	type Annotated struct {
+		*Base
+	}
+
+	type Conservative struct {
+		*Base
+	}
+	`, map[string]string{
+		"Annotated.Base": `*Base`,
+	})
+
+	for name, expected := range map[string]string{
+		"Annotated":    `struct{*example.com/lib.Base}`,
+		"Conservative": `struct{?*example.com/lib.Base}`,
+	} {
+		if got := lib.Scope().Lookup(name).Type().Underlying().String(); got != expected {
+			t.Errorf("%s: expected type %s, got %s", name, expected, got)
+		}
+	}
+}
+
+func TestEmbeddedFieldAnnotationsQualified(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", `package lib
+
+type ReadCloser struct {
+	*io.Reader
+	*os.File
+}
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := annotations.Parse("ReadCloser {\n\tio.Reader *io.Reader\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ConvertAST(f, &types.Info{}, ann)
+
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type)
+	expected := "struct {\n\t*io.Reader\n\t?*os.File\n}"
+	if got := buf.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,149 @@
+package importer
+
+import "testing"
+
+func TestErrorOnlyAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type File struct{}
+
+	func (f *File) Close() error { return nil }
+
+	func Remove(name string) error { return nil }
+
+	func Sync() error { return nil }
+	`, map[string]string{
+		"Remove":        `func(name string) \ error`,
+		"Sync":          `func() (\ error)`,
+		"(*File).Close": `(*File) func() \ error`,
+	})
+
+	for name, expected := range map[string]string{
+		"Remove": `func(name string) (\ ?error)`,
+		"Sync":   `func() (\ ?error)`,
+	} {
+		if got := lib.Scope().Lookup(name).Type().String(); got != expected {
+			t.Errorf("%s: expected type %s, got %s", name, expected, got)
+		}
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(file *lib.File) \ error {
+		err := lib.Remove("x")
+		if err != nil {
+			_ = err.Error()
+			return \ err
+		}
+		err = file.Close()
+		_ = err.Error() // ERROR
+		if err := lib.Sync(); err != nil {
+			return err
+		}
+		return \
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 13)
+}
+
+func TestEntangledSliceAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type MX struct {
+		Host string
+	}
+
+	func LookupMX(name string) ([]*MX, error) { return nil, nil }
+
+	func Lookup(name string) ([]*MX, error) { return nil, nil }
+	`, map[string]string{
+		"LookupMX": `func(name string) ([]*MX \ error)`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f() string {
+		mxs \ err := lib.LookupMX("x")
+		if err != nil {
+			return err.Error()
+		}
+		if len(mxs) == 0 {
+			return ""
+		}
+		return mxs[0].Host
+	}
+
+	func g() string {
+		mxs, err := lib.Lookup("x")
+		if err != nil {
+			return err.Error()
+		}
+		return mxs[0].Host // ERROR
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 22)
+}
+
+func TestOptionalEntangledAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	func Get() (*T, error) { return nil, nil }
+
+	func GetOptional() (*T, error) { return nil, nil }
+
+	func Check() error { return nil }
+	`, map[string]string{
+		"Get":         `func() (*T \ error)`,
+		"GetOptional": `func() (*T \ ?error)`,
+		"Check":       `func() \ ?error`,
+	})
+
+	// An entangled error is optional anyway, so both forms are the same.
+	for name, expected := range map[string]string{
+		"Get":         `func() (*example.com/lib.T \ ?error)`,
+		"GetOptional": `func() (*example.com/lib.T \ ?error)`,
+		"Check":       `func() (\ ?error)`,
+	} {
+		if got := lib.Scope().Lookup(name).Type().String(); got != expected {
+			t.Errorf("%s: expected type %s, got %s", name, expected, got)
+		}
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f() int {
+		t \ err := lib.GetOptional()
+		if err != nil {
+			_ = t.N // ERROR
+			return 0
+		}
+		u \ err := lib.Get()
+		if err != nil {
+			return 0
+		}
+		if err := lib.Check(); err != nil {
+			return 0
+		}
+		return t.N + u.N
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 9)
+}
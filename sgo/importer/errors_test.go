@@ -0,0 +1,59 @@
+package importer
+
+import "testing"
+
+func TestErrorsNarrowing(t *testing.T) {
+	anns := map[string]string{}
+	for _, name := range defaultAnnotations["errors"].Names() {
+		anns[name], _ = defaultAnnotations["errors"].Definition(name)
+	}
+	lib := testImportLib(t, "errors", `
+	package errors
+
+	func New(text string) error { return nil }
+
+	func As(err error, target interface{}) bool { return false }
+
+	func Is(err, target error) bool { return false }
+
+	func Unwrap(err error) error { return nil }
+	`, anns)
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "errors"
+
+	type PathError struct {
+		Path string
+	}
+
+	func (e *PathError) Error() string { return e.Path }
+
+	var ErrNotFound = errors.New("not found")
+
+	func as(err ?error) string {
+		var pathErr ?*PathError
+		if errors.As(err, &pathErr) {
+			return pathErr.Path
+		}
+		return pathErr.Path // ERROR
+	}
+
+	func asGuard(err ?error) string {
+		var pathErr ?*PathError
+		if !errors.As(err, &pathErr) {
+			return ""
+		}
+		return pathErr.Path
+	}
+
+	func is(err ?error) string {
+		if errors.Is(err, ErrNotFound) {
+			return err.Error()
+		}
+		return errors.Unwrap(err).Error() // ERROR
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 19, 34)
+}
@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"go/build"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestFailsDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	// Deprecated: Use Load.
+	func LoadLegacy(name string) (*T, error) { return nil, nil }
+
+	func Load(name string) (*T, error) { return nil, nil }
+	`, map[string]string{
+		"LoadLegacy": `func(name string) (*T \ error) @fails`,
+		"Load":       `func(name string) (*T \ error)`,
+	})
+
+	if !lib.Scope().Lookup("LoadLegacy").Type().(*types.Signature).Fails() {
+		t.Errorf("LoadLegacy: expected to always fail")
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func guard() int {
+		x \ err := lib.LoadLegacy("x")
+		if err != nil {
+			return 0
+		}
+		return x.N
+	}
+
+	func branch() int {
+		var x \ err = lib.LoadLegacy("x")
+		if err == nil {
+			return x.N
+		}
+		return 0
+	}
+
+	func reassigned() int {
+		x \ err := lib.LoadLegacy("x")
+		x \ err = lib.Load("x")
+		if err == nil {
+			return x.N
+		}
+		return 0
+	}
+
+	func passed() ?error {
+		_ \ err := lib.LoadLegacy("x")
+		return err
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 8, 16)
+}
+
+func TestFailsDefaultAnnotations(t *testing.T) {
+	for goos, fails := range map[string]bool{"windows": true, "linux": false} {
+		ctx := build.Default
+		ctx.GOOS = goos
+		found := false
+		for _, dir := range defaultAnnotations["os"].ForContext(&ctx).Lookup("Lchown").Directives() {
+			found = found || dir.Name == "fails"
+		}
+		if found != fails {
+			t.Errorf("%s: expected os.Lchown to fail: %v, got %v", goos, fails, found)
+		}
+	}
+}
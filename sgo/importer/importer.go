@@ -11,9 +11,9 @@ import (
 	goconstant "go/constant"
 	goimporter "go/importer"
 	gotypes "go/types"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/tcard/sgo/sgo/annotations"
@@ -39,6 +39,18 @@ func Default(files []*ast.File) types.Importer {
 // DefaultFrom is like Default, with an optional whence argument for the path
 // to the directory from which the importing is done.
 func DefaultFrom(files []*ast.File, whence string) (types.Importer, error) {
+	return DefaultFromContext(&build.Default, files, whence)
+}
+
+// DefaultFromContext is like DefaultFrom, but uses ctx instead of go/build's
+// default context to find the imported packages' files and choose annotations.
+func DefaultFromContext(ctx *build.Context, files []*ast.File, whence string) (types.Importer, error) {
+	return DefaultForFiles(ctx, files, nil, whence)
+}
+
+// DefaultForFiles is like DefaultFromContext, recording the packages imported
+// through it as dependencies of the files at paths; see Dependents.
+func DefaultForFiles(ctx *build.Context, files []*ast.File, paths []string, whence string) (types.Importer, error) {
 	visiblePaths := map[string]struct{}{}
 	for _, file := range files {
 		for _, decl := range file.Decls {
@@ -56,7 +68,14 @@ func DefaultFrom(files []*ast.File, whence string) (types.Importer, error) {
 		}
 	}
 
-	return newImporter(visiblePaths, whence)
+	imp, err := newImporter(visiblePaths, whence)
+	if err != nil {
+		return nil, err
+	}
+	imp.ctx = ctx
+	imp.paths = paths
+	forgetDependents(paths)
+	return imp, nil
 }
 
 type importer struct {
@@ -64,6 +83,8 @@ type importer struct {
 	imported     map[string]*types.Package
 	sgovendored  map[string]func() (*annotations.Annotation, error)
 	whence       string
+	ctx          *build.Context
+	paths        []string // files importing through this importer, for Dependents
 }
 
 func newImporter(visiblePaths map[string]struct{}, whence string) (*importer, error) {
@@ -81,6 +102,7 @@ func newImporter(visiblePaths map[string]struct{}, whence string) (*importer, er
 		imported:     map[string]*types.Package{},
 		sgovendored:  sgovendored,
 		whence:       whence,
+		ctx:          &build.Default,
 	}, nil
 }
 
@@ -108,7 +130,7 @@ func (imp *importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*ty
 		return conv.ret, nil
 	}
 
-	buildPkg, err := build.Import(path, srcDir, build.ImportMode(mode))
+	buildPkg, err := imp.ctx.Import(path, srcDir, build.ImportMode(mode))
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +151,97 @@ func (imp *importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*ty
 		files = append(files, a)
 	}
 
+	ann, err := imp.annotations(path, buildPkg)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := imp.checkFiles(path, fset, files, ann)
+	if err != nil {
+		return nil, err
+	}
+
+	imp.imported[path] = pkg
+	addDependents(path, imp.paths)
+	return pkg, nil
+}
+
+// Annotations returns the annotations that importing the Go package with the
+// given path from whence, with ctx, would convert it to SGo with, or nil. The
+// .sgoann files a package ships are looked for again when its directory's
+// modification time changes.
+func Annotations(ctx *build.Context, path, whence string) (*annotations.Annotation, error) {
+	imp, err := newImporter(nil, whence)
+	if err != nil {
+		return nil, err
+	}
+	imp.ctx = ctx
+	return imp.annotations(path, nil)
+}
+
+// annotations returns the annotations for the package with the given path, as
+// Annotations does. buildPkg is the package as found with imp.ctx, or nil to
+// find it if needed.
+func (imp *importer) annotations(path string, buildPkg *build.Package) (*annotations.Annotation, error) {
+	ann, _ := builtinAnnotations(path)
+	shipped, err := imp.shippedAnnotations(path, buildPkg)
+	if err != nil {
+		return nil, fmt.Errorf("reading SGo annotations for %s: %v", path, err)
+	}
+	if shipped != nil {
+		ann = annotations.MergeAnnotations(ann, shipped)
+	}
+	if a, ok := imp.sgovendored[path]; ok {
+		local, err := a()
+		if err != nil {
+			return nil, fmt.Errorf("reading SGo annotations for %s: %v", path, err)
+		}
+		ann = annotations.MergeAnnotations(ann, local)
+	}
+	return ann.ForContext(imp.ctx), nil
+}
+
+// shippedAnnotations returns the annotations in the .sgoann files next to the
+// .go files of the package with the given path, if it has any. Those in the
+// standard library aren't looked for; its annotations are built in.
+func (imp *importer) shippedAnnotations(path string, buildPkg *build.Package) (*annotations.Annotation, error) {
+	if buildPkg == nil {
+		var err error
+		buildPkg, err = imp.ctx.Import(path, imp.whence, build.FindOnly)
+		if err != nil {
+			// Not found here; importing it would fail anyway.
+			return nil, nil
+		}
+	}
+	if buildPkg.Goroot || buildPkg.Dir == "" {
+		return nil, nil
+	}
+	files, err := packageAnnotationFiles.find(path, buildPkg.Dir)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	var anns []*annotations.Annotation
+	for _, file := range files {
+		ann, err := annotationFiles.parse(file)
+		if err != nil {
+			return nil, err
+		}
+		anns = append(anns, ann)
+	}
+	return annotations.Merge(anns...), nil
+}
+
+// checkFiles typechecks the Go files for package path, converting them to SGo
+// with the given annotations.
+func (imp *importer) checkFiles(path string, fset *token.FileSet, files []*ast.File, ann *annotations.Annotation) (*types.Package, error) {
 	// 1. Typecheck without converting anything; ConvertAST needs to know
-	//    which idents are types to perform the default conversions.
+	//    which idents are types to perform the default conversions. Values
+	//    aren't checked, so variables whose type comes from their value
+	//    get it now, if it can be told from the syntax.
+
+	for _, f := range files {
+		inferVarTypes(f)
+	}
 
 	info := &types.Info{
 		Defs: map[*ast.Ident]types.Object{},
@@ -151,16 +262,6 @@ func (imp *importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*ty
 	//    everything that hasn't been converted explicitly by then with the
 	//    default conversion (wrapping in optionals).
 
-	var ann *annotations.Annotation
-	if a, ok := defaultAnnotations[path]; ok {
-		ann = annotations.NewAnnotation(a)
-	} else if a, ok := imp.sgovendored[path]; ok {
-		ann, err = a()
-		if err != nil {
-			return nil, fmt.Errorf("reading SGo annotations for %s: %v", path, err)
-		}
-	}
-
 	for _, f := range files {
 		ConvertAST(f, info, ann)
 	}
@@ -172,7 +273,14 @@ func (imp *importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*ty
 		return nil, err
 	}
 
-	imp.imported[path] = pkg
+	// 4. Apply the annotations' directives, which can't be expressed in the
+	//    converted AST.
+
+	err = applyDirectives(pkg, ann)
+	if err != nil {
+		return nil, fmt.Errorf("applying SGo annotations for %s: %v", path, err)
+	}
+
 	return pkg, nil
 }
 
@@ -722,21 +830,20 @@ func readSgovendorDir(dirPath string) (*annotations.Annotation, error) {
 		return nil, err
 	}
 
-	var allSrc string
+	sort.Strings(fileNames)
 
+	var anns []*annotations.Annotation
 	for _, fileName := range fileNames {
 		if filepath.Ext(fileName) != ".sgoann" {
 			continue
 		}
 
-		// TODO: Cache this maybe?
-		src, err := ioutil.ReadFile(filepath.Join(dirPath, fileName))
+		ann, err := annotationFiles.parse(filepath.Join(dirPath, fileName))
 		if err != nil {
 			return nil, err
 		}
-
-		allSrc += "\n" + string(src)
+		anns = append(anns, ann)
 	}
 
-	return annotations.Parse(allSrc)
+	return annotations.Merge(anns...), nil
 }
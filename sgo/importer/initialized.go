@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// This file implements the inference of package-level variables initialized
+// with values that are never nil, like var Registry = map[string]*Handler{}.
+// Those are converted to SGo as non-optional, unless annotated otherwise.
+
+// inferVarTypes gives each package-level variable in f declared without a
+// type, but with a never nil initializer, the type the initializer spells out.
+func inferVarTypes(f *ast.File) {
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			if spec.Type != nil {
+				continue
+			}
+			init := initializedSpec(spec)
+			if init == nil {
+				continue
+			}
+			typ, err := parser.ParseExpr(types.ExprString(init))
+			if err != nil {
+				continue
+			}
+			spec.Type = typ
+		}
+	}
+}
+
+// initializedSpec returns, if spec declares a single variable initialized with
+// a value that is never nil, the type of that value as written in it.
+func initializedSpec(spec *ast.ValueSpec) ast.Expr {
+	if len(spec.Names.List) != 1 || spec.Values == nil || len(spec.Values.List) != 1 {
+		return nil
+	}
+	return nonNilType(spec.Values.List[0])
+}
+
+// nonNilType returns, if e is a composite literal, its address, a call to make
+// or new, or a function literal, its type as written in it.
+func nonNilType(e ast.Expr) ast.Expr {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return nonNilType(e.X)
+	case *ast.CompositeLit:
+		return e.Type
+	case *ast.FuncLit:
+		return e.Type
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND && lit.Type != nil {
+			return &ast.StarExpr{X: lit.Type}
+		}
+	case *ast.CallExpr:
+		fun, ok := e.Fun.(*ast.Ident)
+		if !ok || len(e.Args) == 0 {
+			return nil
+		}
+		switch fun.Name {
+		case "make":
+			return e.Args[0]
+		case "new":
+			return &ast.StarExpr{X: e.Args[0]}
+		}
+	}
+	return nil
+}
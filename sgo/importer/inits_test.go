@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestInitsDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Items struct {
+		N int
+	}
+
+	type Cache struct {
+		Items *Items
+	}
+
+	func (c *Cache) GetOrInit() *Items { return nil }
+
+	func (c *Cache) Reset() {}
+
+	func Flush(c *Cache) {}
+	`, map[string]string{
+		"(*Cache).GetOrInit": `@inits Items`,
+	})
+
+	m, _, _ := types.LookupFieldOrMethod(types.NewPointer(lib.Scope().Lookup("Cache").Type()), false, lib, "GetOrInit")
+	if got := m.Type().(*types.Signature).Inits(); len(got) != 1 || got[0] != "Items" {
+		t.Errorf("expected inits [Items], got %v", got)
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func before(c *lib.Cache) int {
+		_ = c.Items.N // ERROR
+		c.GetOrInit()
+		return c.Items.N
+	}
+
+	func block(c *lib.Cache, ok bool) int {
+		if ok {
+			c.GetOrInit()
+			_ = c.Items.N
+		}
+		return c.Items.N // ERROR
+	}
+
+	func invalidated(c *lib.Cache, d *lib.Cache) {
+		c.GetOrInit()
+		c.Reset()
+		_ = c.Items.N // ERROR
+		c.GetOrInit()
+		c.Items = nil
+		_ = c.Items.N // ERROR
+		c.GetOrInit()
+		lib.Flush(c)
+		_ = c.Items.N // ERROR
+		c.GetOrInit()
+		c = d
+		_ = c.Items.N // ERROR
+	}
+
+	func closure(c *lib.Cache) func() int {
+		f := func() int {
+			return c.Items.N // ERROR
+		}
+		c.GetOrInit()
+		return f
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 7, 17, 23, 26, 29, 32, 37)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", "package lib\n\ntype T struct {\n\tP *int\n\tN int\n}\n\nfunc (t *T) Init() {}\n\nfunc Init(t *T) {}\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, anns := range []map[string]string{
+		{"(*T).Init": `@inits`},
+		{"(*T).Init": `@inits Q`},
+		{"(*T).Init": `@inits N`},
+		{"Init": `@inits P`},
+	} {
+		imp, _ := newImporter(map[string]struct{}{}, "")
+		_, err = imp.checkFiles("example.com/lib", fset, []*ast.File{f}, annotations.NewAnnotation(anns))
+		if err == nil {
+			t.Errorf("%v: expected error", anns)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import "testing"
+
+func TestInvalidatesDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Buf struct {
+		N int
+	}
+
+	func New() *Buf { return &Buf{} }
+
+	func Free(b *Buf) {}
+
+	func (b *Buf) Close() error { return nil }
+
+	func Use(b *Buf) {}
+	`, map[string]string{
+		"New":          `func() *Buf`,
+		"Free":         `func(b *Buf) @invalidates $1`,
+		"Use":          `func(b *Buf)`,
+		"(*Buf).Close": `(*Buf) func() \ error @invalidates $0`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func freed() {
+		b := lib.New()
+		lib.Free(b)
+		_ = b.N // ERROR
+		b = lib.New()
+		_ = b.N
+	}
+
+	func closed() {
+		b := lib.New()
+		b.Close()
+		lib.Use(b) // ERROR
+	}
+
+	func deferred() {
+		b := lib.New()
+		defer lib.Free(b)
+		_ = b.N
+	}
+
+	func branches(cond bool) {
+		b := lib.New()
+		if cond {
+			lib.Free(b)
+			return
+		}
+		_ = b.N
+		if cond {
+			lib.Free(b)
+		} else {
+			lib.Use(b)
+		}
+		_ = b.N // ERROR
+		b = lib.New()
+		if cond {
+			lib.Free(b)
+			b = lib.New()
+		}
+		_ = b.N
+	}
+
+	func closure() {
+		b := lib.New()
+		func() {
+			lib.Free(b)
+		}()
+		_ = b.N
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 9, 17, 38)
+}
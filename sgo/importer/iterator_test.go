@@ -0,0 +1,61 @@
+package importer
+
+import "testing"
+
+func TestIteratorAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Elem struct {
+		N int
+	}
+
+	type List struct {
+		elems []*Elem
+	}
+
+	func (l *List) All() func(yield func(*Elem) bool) {
+		return func(yield func(*Elem) bool) {
+			for _, e := range l.elems {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}
+
+	func (l *List) Holes() func(yield func(int, *Elem) bool) {
+		return func(yield func(int, *Elem) bool) {}
+	}
+
+	func (l *List) Unannotated() func(yield func(*Elem) bool) {
+		return l.All()
+	}
+	`, map[string]string{
+		"(*List).All":   `(*List) func() func(yield func(*Elem) bool)`,
+		"(*List).Holes": `(*List) func() func(yield func(int, ?*Elem) bool)`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func sum(l *lib.List) int {
+		n := 0
+		for e := range l.All() {
+			n += e.N
+		}
+		for i, e := range l.Holes() {
+			n += i + e.N
+			if e != nil {
+				n += e.N
+			}
+		}
+		for range l.Unannotated() {
+		}
+		return n
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 12, 17)
+}
@@ -0,0 +1,55 @@
+package importer
+
+import "testing"
+
+func TestMemberDefault(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Value struct {
+		N int
+	}
+
+	type Client struct {
+		Parent *Client
+		Cached *Value
+	}
+
+	func (c *Client) Get(key string) *Value { return &Value{} }
+
+	func (c *Client) Find(key string) *Value { return nil }
+
+	type Other struct {
+		Next *Other
+	}
+
+	func (o *Other) Get() *Value { return &Value{} }
+	`, map[string]string{
+		"(*Client)":      "@default !",
+		"(*Client).Find": "(*Client) func(key string) ?*Value",
+		"Client":         "@default !",
+		"Client.Cached":  "?*Value",
+		"(*Other)":       "@default ?",
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(c *lib.Client, o *lib.Other) {
+		// Unannotated members of Client keep their Go types...
+		_ = c.Get("a").N
+		_ = c.Parent.Parent
+		// ... but explicit annotations override that.
+		_ = c.Find("a").N // ERROR
+		_ = c.Cached.N // ERROR
+
+		// Other's are converted conservatively.
+		_ = o.Get().N // ERROR
+		_ = o.Next.Next // ERROR
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 11, 12, 15, 16)
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestNilDefaultDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Options struct {
+		N int
+	}
+
+	type T struct{}
+
+	func New(name string, opts *Options) *T { return &T{} }
+
+	func Must(opts *Options) *T { return &T{} }
+	`, map[string]string{
+		"New":  `func(name string, opts *Options) *T @nildefault $2`,
+		"Must": `func(opts *Options) *T`,
+	})
+
+	sig := lib.Scope().Lookup("New").Type().(*types.Signature)
+	if got := sig.NilDefaults(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected nil defaults [1], got %v", got)
+	}
+	if _, ok := sig.Params().At(1).Type().(*types.Optional); !ok {
+		t.Errorf("expected optional parameter, got %v", sig.Params().At(1).Type())
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f() {
+		_ = lib.New("a", nil)
+		_ = lib.New("b", &lib.Options{N: 1})
+		_ = lib.Must(nil)
+	}
+
+	func wrap(opts ?*lib.Options) int {
+		_ = lib.New("c", opts)
+		return opts.N
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 9, 14)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", "package lib\n\nfunc Count(n int) int { return n }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp, _ := newImporter(map[string]struct{}{}, "")
+	_, err = imp.checkFiles("example.com/lib", fset, []*ast.File{f}, annotations.NewAnnotation(map[string]string{
+		"Count": `func(n int) int @nildefault $1`,
+	}))
+	if err == nil {
+		t.Errorf("expected error for a parameter that can't be nil")
+	}
+}
@@ -0,0 +1,33 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestNonEmptyDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	func Base(path string) string { return "." }
+
+	func Fields(s string) []string { return nil }
+
+	func Split(s string) (dir, file string) { return ".", s }
+	`, map[string]string{
+		"Base":  `@nonempty`,
+		"Split": `func(s string) (dir, file string) @nonempty`,
+	})
+
+	for name, expected := range map[string]bool{
+		"Base":   true,
+		"Fields": false,
+		"Split":  true,
+	} {
+		sig := lib.Scope().Lookup(name).Type().(*types.Signature)
+		if got := sig.NonEmpty(); got != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+}
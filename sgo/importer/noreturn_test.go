@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestNoReturnDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	func (t *T) Fatal(args ...interface{}) {}
+
+	func Exit(code int) {}
+
+	func Goexit() {}
+
+	func Log(args ...interface{}) {}
+	`, map[string]string{
+		"(*T).Fatal": `@noreturn goroutine`,
+		"Exit":       `func(code int) @noreturn process`,
+		"Goexit":     `func() @noreturn goroutine`,
+	})
+
+	for name, expected := range map[string]types.NoReturn{
+		"Exit":   types.TerminatesProcess,
+		"Goexit": types.TerminatesGoroutine,
+		"Log":    types.Returns,
+	} {
+		sig := lib.Scope().Lookup(name).Type().(*types.Signature)
+		if got := sig.NoReturn(); got != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+	fatal, _, _ := types.LookupFieldOrMethod(types.NewPointer(lib.Scope().Lookup("T").Type()), false, lib, "Fatal")
+	if got := fatal.Type().(*types.Signature).NoReturn(); got != types.TerminatesGoroutine {
+		t.Errorf("(*T).Fatal: expected %v, got %v", types.TerminatesGoroutine, got)
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func process(x ?*lib.T) int {
+		if x == nil {
+			lib.Exit(1)
+		}
+		return x.N
+	}
+
+	func goroutine(x ?*lib.T, t *lib.T) int {
+		if x == nil {
+			t.Fatal("nil")
+		}
+		if x.N == 0 {
+			lib.Goexit()
+		}
+		return x.N
+	}
+
+	func returns(x ?*lib.T) int {
+		if x == nil {
+			lib.Log("nil")
+		}
+		return x.N // ERROR
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 27)
+}
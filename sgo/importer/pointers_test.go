@@ -0,0 +1,67 @@
+package importer
+
+import "testing"
+
+func TestDoublePointerAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type T struct {
+		N int
+	}
+
+	func (t *T) Swap(p **T) {}
+
+	func Default(p **T) {}
+
+	func Outer(p **T) {}
+
+	func Inner(p **T) {}
+
+	func None(p **T) {}
+
+	func Get() **T { return nil }
+	`, map[string]string{
+		"(*T).Swap": `(*T) func(p ?*?*T)`,
+		"Outer":     `func(p ?**T)`,
+		"Inner":     `func(p *?*T)`,
+		"None":      `func(p **T)`,
+		"Get":       `func() *?*T`,
+	})
+
+	for name, expected := range map[string]string{
+		"Default": `func(p ?*?*example.com/lib.T)`,
+		"Outer":   `func(p ?**example.com/lib.T)`,
+		"Inner":   `func(p *?*example.com/lib.T)`,
+		"None":    `func(p **example.com/lib.T)`,
+		"Get":     `func() *?*example.com/lib.T`,
+	} {
+		if got := lib.Scope().Lookup(name).Type().String(); got != expected {
+			t.Errorf("%s: expected type %s, got %s", name, expected, got)
+		}
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(t *lib.T, pp **lib.T, p *lib.T, mp ?*lib.T) {
+		t.Swap(pp) // ERROR
+		t.Swap(&mp)
+		lib.Outer(nil)
+		lib.Inner(nil) // ERROR
+		lib.Inner(&mp)
+		lib.None(&mp) // ERROR
+		lib.None(&p)
+
+		got := lib.Get()
+		_ = (*got).N // ERROR
+		if inner := *got; inner != nil {
+			_ = inner.N
+		}
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 7, 10, 12, 16)
+}
@@ -0,0 +1,67 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestPrintfDirective(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Logger struct{}
+
+	func (l *Logger) Logf(format string, args ...interface{}) {}
+
+	func Errorf(format string, args ...interface{}) error { return nil }
+
+	func Join(sep string, args ...interface{}) string { return "" }
+	`, map[string]string{
+		"(*Logger).Logf": `(*Logger) func(format string, args ...interface{}) @printf $1 $2`,
+		"Errorf":         `func(format string, args ...interface{}) error @printf $1 $2`,
+		"Join":           `func(sep string, args ...interface{}) string`,
+	})
+
+	sig := lib.Scope().Lookup("Errorf").Type().(*types.Signature)
+	if format, args, ok := sig.Printf(); !ok || format != 0 || args != 1 {
+		t.Errorf("expected printf-like with format 0 and args 1, got %v, %v, %v", format, args, ok)
+	}
+	if _, _, ok := lib.Scope().Lookup("Join").Type().(*types.Signature).Printf(); ok {
+		t.Errorf("Join: expected not printf-like")
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(l *lib.Logger, err ?error, p ?*int) {
+		_ = lib.Errorf("failed: %v", err)
+		_ = lib.Errorf("%v %v", nil, p)
+		l.Logf("%v", nil)
+		_ = lib.Join(", ", nil)
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 10)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", "package lib\n\nfunc Sumf(format string, n ...int) int { return 0 }\n\nfunc Wrapf(args []interface{}, format string) string { return \"\" }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, def := range map[string]string{
+		"Sumf":  `func(format string, n ...int) int @printf $1 $2`,
+		"Wrapf": `func(args []interface{}, format string) string @printf $2 $1`,
+	} {
+		imp, _ := newImporter(map[string]struct{}{}, "")
+		_, err = imp.checkFiles("example.com/lib", fset, []*ast.File{f}, annotations.NewAnnotation(map[string]string{name: def}))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
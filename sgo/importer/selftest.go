@@ -0,0 +1,43 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+// CheckDefaultAnnotations checks that the built-in annotations for each package
+// are valid SGo types and survive a round trip through Marshal and Parse.
+func CheckDefaultAnnotations() []error {
+	return checkAnnotations(defaultAnnotations)
+}
+
+func checkAnnotations(anns map[string]*annotations.Annotation) []error {
+	var paths []string
+	for path := range anns {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		ann := anns[path]
+		for _, name := range ann.Names() {
+			def, _ := ann.Definition(name)
+			if _, err := annotations.PrettyType(def); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: invalid type %q: %v", path, name, def, err))
+			}
+		}
+
+		parsed, err := annotations.Parse(ann.Marshal())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: marshaled annotations don't parse: %v", path, err))
+			continue
+		}
+		for _, edit := range annotations.MakePatch(ann, parsed) {
+			errs = append(errs, fmt.Errorf("%s: %v after a round trip through Marshal and Parse", path, edit))
+		}
+	}
+	return errs
+}
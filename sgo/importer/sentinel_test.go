@@ -0,0 +1,48 @@
+package importer
+
+import "testing"
+
+func TestSentinelErrorNarrowing(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type eofError struct{}
+
+	func (eofError) Error() string { return "EOF" }
+
+	var EOF error = eofError{}
+
+	type T struct {
+		N int
+	}
+
+	func Read() (*T, error) {
+		return nil, EOF
+	}
+	`, map[string]string{
+		"EOF":  `error`,
+		"Read": `func() (*T \ error)`,
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f() {
+		t \ err := lib.Read()
+		if err == lib.EOF {
+			_ = err.Error()
+			_ = t.N // ERROR
+			return
+		}
+		if err != nil {
+			_ = err.Error()
+			return
+		}
+		_ = t.N
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 10)
+}
@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestShippedAnnotations(t *testing.T) {
+	gopath := t.TempDir()
+	for path, src := range map[string]string{
+		"example.com/shipped/lib.go": `package shipped
+
+type File struct{}
+
+func Open(name string) *File { return nil }
+
+func Create(name string) *File { return nil }
+
+func Lookup(name string) *File { return nil }
+`,
+		"example.com/shipped/lib.sgoann":                         "Open func(name string) *File\nCreate func(name string) *File\n",
+		"example.com/shipped/README":                             "Lookup is not an annotation: ",
+		"example.com/app/sgovendor/example.com/shipped/x.sgoann": "Create func(name string) ?*File\n",
+	} {
+		path = filepath.Join(gopath, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := build.Default
+	ctx.GOPATH = gopath
+	ctx.CgoEnabled = false
+	app := filepath.Join(gopath, "src", "example.com", "app")
+
+	for _, c := range []struct {
+		whence string
+		defs   map[string]string
+	}{
+		{"", map[string]string{"Open": "func(name string) *File", "Create": "func(name string) *File"}},
+		{app, map[string]string{"Open": "func(name string) *File", "Create": "func(name string) ?*File"}},
+	} {
+		ann, err := Annotations(&ctx, "example.com/shipped", c.whence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ann.Len() != len(c.defs) {
+			t.Errorf("%q: expected %v, got %v", c.whence, c.defs, ann.Names())
+		}
+		for name, def := range c.defs {
+			if got, _ := ann.Definition(name); got != def {
+				t.Errorf("%q: %s: expected %q, got %q", c.whence, name, def, got)
+			}
+		}
+	}
+
+	imp, err := DefaultFromContext(&ctx, nil, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := imp.Import("example.com/shipped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, optional := range map[string]bool{"Open": false, "Create": true, "Lookup": true} {
+		res := pkg.Scope().Lookup(name).Type().(*types.Signature).Results().At(0).Type()
+		if _, ok := res.(*types.Optional); ok != optional {
+			t.Errorf("%s: expected optional result %v, got %v", name, optional, res)
+		}
+	}
+
+	// The package's source directory is listed again once files are added
+	// or removed. Its modification time is set, so that it changes even
+	// where it's coarse.
+	dir := filepath.Join(gopath, "src", "example.com", "shipped")
+	extra := filepath.Join(dir, "extra.sgoann")
+	touch := func(d time.Duration) {
+		if err := os.Chtimes(dir, time.Now().Add(d), time.Now().Add(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(extra, []byte("Lookup func(name string) *File\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	touch(time.Hour)
+	ann, err := Annotations(&ctx, "example.com/shipped", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def, ok := ann.Definition("Lookup"); !ok || def != "func(name string) *File" {
+		t.Errorf("expected Lookup to be annotated by the added file, got %q, %v", def, ok)
+	}
+
+	if err := os.Remove(extra); err != nil {
+		t.Fatal(err)
+	}
+	touch(2 * time.Hour)
+	ann, err = Annotations(&ctx, "example.com/shipped", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def, ok := ann.Definition("Lookup"); ok {
+		t.Errorf("expected Lookup to be unannotated after removing its file, got %q", def)
+	}
+	if def, ok := ann.Definition("Open"); !ok || def != "func(name string) *File" {
+		t.Errorf("expected Open to stay annotated, got %q, %v", def, ok)
+	}
+}
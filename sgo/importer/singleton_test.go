@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+)
+
+func TestSingleton(t *testing.T) {
+	imp, err := newImporter(map[string]struct{}{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range []struct {
+		path, src string
+		anns      map[string]string
+	}{
+		{"sync", `
+		package sync
+
+		type Once struct {
+			done bool
+		}
+
+		func (o *Once) Do(f func()) {
+			if !o.done {
+				o.done = true
+				f()
+			}
+		}
+		`, nil},
+		{"example.com/lib", `
+		package lib
+
+		import "sync"
+
+		type Config struct {
+			logger *Logger
+		}
+
+		func (c *Config) Logger() *Logger { return c.logger }
+
+		type Logger struct {
+			prefix string
+		}
+
+		func (l *Logger) Prefix() string { return l.prefix }
+
+		var (
+			once     sync.Once
+			instance *Config
+		)
+
+		// For SGo: func() *Config
+		func GetInstance() *Config {
+			once.Do(func() {
+				instance = &Config{logger: &Logger{}}
+			})
+			return instance
+		}
+
+		func Current() *Config {
+			return GetInstance()
+		}
+		`, map[string]string{
+			"(*Config).Logger": `(*Config) func() *Logger`,
+		}},
+	} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, pkg.path+".go", pkg.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, err := imp.checkFiles(pkg.path, fset, []*ast.File{f}, annotations.NewAnnotation(pkg.anns))
+		if err != nil {
+			t.Fatalf("importing %s: %v", pkg.path, err)
+		}
+		imp.imported[pkg.path] = imported
+	}
+	lib := imp.imported["example.com/lib"]
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func prefix() string {
+		return lib.GetInstance().Logger().Prefix()
+	}
+
+	func current() string {
+		return lib.Current().Logger().Prefix() // ERROR
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 11)
+}
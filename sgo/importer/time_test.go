@@ -0,0 +1,45 @@
+package importer
+
+import "testing"
+
+func TestTimeChannels(t *testing.T) {
+	anns := map[string]string{}
+	for _, name := range defaultAnnotations["time"].Names() {
+		anns[name], _ = defaultAnnotations["time"].Definition(name)
+	}
+	lib := testImportLib(t, "time", `
+	package time
+
+	type Duration int64
+
+	type Time struct{}
+
+	type Ticker struct {
+		C <-chan Time
+	}
+
+	func After(d Duration) <-chan Time { return nil }
+
+	func Tick(d Duration) <-chan Time { return nil }
+
+	func NewTicker(d Duration) *Ticker { return nil }
+	`, anns)
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "time"
+
+	func f(opt ?<-chan time.Time) {
+		c := time.After(1)
+		<-c
+		c <- time.Time{} // ERROR
+		var r <-chan time.Time = time.Tick(1)
+		var b chan time.Time = time.Tick(1) // ERROR
+		opt = time.After(1)
+		<-time.NewTicker(1).C
+		_, _, _ = r, b, opt
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 9, 11)
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/build"
+	goparser "go/parser"
+	gotoken "go/token"
+	gotypes "go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+// PackageChanges are the changes to the Go declarations annotated for a
+// package.
+type PackageChanges struct {
+	Path    string
+	Changes []annotations.Change
+}
+
+// DiffDefaultAnnotations reports which built-in annotations are for identifiers
+// whose Go declaration changed between the SDKs at oldGoroot and newGoroot.
+func DiffDefaultAnnotations(oldGoroot, newGoroot string) ([]PackageChanges, error) {
+	return diffAnnotations(defaultAnnotations, oldGoroot, newGoroot)
+}
+
+func diffAnnotations(anns map[string]*annotations.Annotation, oldGoroot, newGoroot string) ([]PackageChanges, error) {
+	oldSDK := newSDKImporter(oldGoroot)
+	newSDK := newSDKImporter(newGoroot)
+
+	var paths []string
+	for path := range anns {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ret []PackageChanges
+	for _, path := range paths {
+		ann := anns[path]
+		oldDecls, err := oldSDK.decls(path, ann.Names())
+		if err != nil {
+			return nil, err
+		}
+		newDecls, err := newSDK.decls(path, ann.Names())
+		if err != nil {
+			return nil, err
+		}
+		changes := annotations.Diff(ann, oldDecls, newDecls)
+		if len(changes) > 0 {
+			ret = append(ret, PackageChanges{Path: path, Changes: changes})
+		}
+	}
+	return ret, nil
+}
+
+// sdkImporter imports packages from the source code of a Go SDK, as plain Go.
+type sdkImporter struct {
+	ctx      build.Context
+	fset     *gotoken.FileSet
+	imported map[string]*gotypes.Package
+}
+
+func newSDKImporter(goroot string) *sdkImporter {
+	ctx := build.Default
+	ctx.GOROOT = goroot
+	ctx.GOPATH = ""
+	ctx.CgoEnabled = false
+	return &sdkImporter{
+		ctx:      ctx,
+		fset:     gotoken.NewFileSet(),
+		imported: map[string]*gotypes.Package{},
+	}
+}
+
+func (imp *sdkImporter) Import(path string) (*gotypes.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *sdkImporter) ImportFrom(path, srcDir string, mode gotypes.ImportMode) (*gotypes.Package, error) {
+	if path == "unsafe" {
+		return gotypes.Unsafe, nil
+	}
+	buildPkg, err := imp.ctx.Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := imp.imported[buildPkg.ImportPath]; ok {
+		return pkg, nil
+	}
+
+	var files []*goast.File
+	for _, name := range buildPkg.GoFiles {
+		f, err := goparser.ParseFile(imp.fset, filepath.Join(buildPkg.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	cfg := &gotypes.Config{
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		Importer:         imp,
+		// Declarations are all we need; don't give up on the package
+		// because some function uses compiler magic.
+		Error: func(err error) {},
+	}
+	pkg, _ := cfg.Check(buildPkg.ImportPath, imp.fset, files, nil)
+	imp.imported[buildPkg.ImportPath] = pkg
+	return pkg, nil
+}
+
+// decls returns the Go types for the given identifiers declared in a package,
+// named as in annotations. Identifiers that aren't found are left out, as are
+// all of them if the package doesn't exist in the SDK.
+func (imp *sdkImporter) decls(path string, names []string) (map[string]string, error) {
+	decls := map[string]string{}
+	if _, err := imp.ctx.Import(path, "", build.FindOnly); err != nil {
+		return decls, nil
+	}
+	pkg, err := imp.Import(path)
+	if err != nil {
+		return nil, fmt.Errorf("importing %s from %s: %v", path, imp.ctx.GOROOT, err)
+	}
+	for _, name := range names {
+		if typ, ok := lookupGoDecl(pkg, name); ok {
+			decls[name] = gotypes.TypeString(typ, gotypes.RelativeTo(pkg))
+		}
+	}
+	return decls, nil
+}
+
+// lookupGoDecl finds the type of the Go declaration an annotation name refers
+// to, like "Open", "File.Name", "(*File).Read" or "(File).Name".
+func lookupGoDecl(pkg *gotypes.Package, name string) (gotypes.Type, bool) {
+	ptr := false
+	if strings.HasPrefix(name, "(") {
+		end := strings.Index(name, ")")
+		if end == -1 {
+			return nil, false
+		}
+		ptr = strings.HasPrefix(name, "(*")
+		name = strings.TrimPrefix(name[1:end], "*") + name[end+1:]
+	}
+
+	path := strings.Split(name, ".")
+	obj := pkg.Scope().Lookup(path[0])
+	if obj == nil {
+		return nil, false
+	}
+	typ := obj.Type()
+	if _, ok := obj.(*gotypes.TypeName); ok && len(path) == 1 {
+		typ = typ.Underlying()
+	}
+	for _, sel := range path[1:] {
+		obj, _, _ := gotypes.LookupFieldOrMethod(typ, ptr, pkg, sel)
+		if obj == nil {
+			return nil, false
+		}
+		typ = obj.Type()
+		ptr = false
+	}
+	return typ, true
+}
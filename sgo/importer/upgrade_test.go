@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+func TestDiffAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgo-upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldGoroot := testGoroot(t, filepath.Join(dir, "old"), map[string]string{
+		"example/example.go": `package example
+
+import "example/internal/dep"
+
+type File struct{ Name string }
+
+func (f *File) Read(b []byte) (int, error) { return 0, nil }
+
+func (f File) Stat() (*File, error) { return nil, nil }
+
+func Open(name string) (*File, error) { return nil, nil }
+
+func Create(name string) (*File, error) { return nil, nil }
+
+func Dep() *dep.T { return nil }
+`,
+		"example/internal/dep/dep.go": `package dep
+
+type T struct{}
+`,
+	})
+	newGoroot := testGoroot(t, filepath.Join(dir, "new"), map[string]string{
+		"example/example.go": `package example
+
+import "example/internal/dep"
+
+type File struct{ Name string }
+
+func (f *File) Read(b []byte) (n int, err error) { return 0, nil }
+
+func (f File) Stat() (fi *File, err error) { return nil, nil }
+
+func Open(name string, flags int) (*File, error) { return nil, nil }
+
+func Dep() *dep.T { return nil }
+`,
+		"example/internal/dep/dep.go": `package dep
+
+type T struct{}
+`,
+	})
+
+	anns := map[string]*annotations.Annotation{
+		"example": annotations.NewAnnotation(map[string]string{
+			"File.Name":    `string`,
+			"(*File).Read": `(*File) func(b []byte) (int, ?error)`,
+			"(File).Stat":  `(File) func() (*File \ error)`,
+			"Open":         `func(name string) (*File \ error)`,
+			"Create":       `func(name string) (*File \ error)`,
+			"Dep":          `func() *dep.T`,
+		}),
+		"missing": annotations.NewAnnotation(map[string]string{
+			"F": `func() *int`,
+		}),
+	}
+
+	changes, err := diffAnnotations(anns, oldGoroot, newGoroot)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PackageChanges{{
+		Path: "example",
+		Changes: []annotations.Change{
+			{
+				Name:       "(*File).Read",
+				Annotation: `(*File) func(b []byte) (int, ?error)`,
+				Old:        `func(b []byte) (int, error)`,
+				New:        `func(b []byte) (n int, err error)`,
+			},
+			{
+				Name:       "(File).Stat",
+				Annotation: `(File) func() (*File \ error)`,
+				Old:        `func() (*File, error)`,
+				New:        `func() (fi *File, err error)`,
+			},
+			{
+				Name:       "Create",
+				Annotation: `func(name string) (*File \ error)`,
+				Old:        `func(name string) (*File, error)`,
+			},
+			{
+				Name:       "Open",
+				Annotation: `func(name string) (*File \ error)`,
+				Old:        `func(name string) (*File, error)`,
+				New:        `func(name string, flags int) (*File, error)`,
+			},
+		},
+	}}
+	if !reflect.DeepEqual(expected, changes) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+}
+
+func testGoroot(t *testing.T, goroot string, files map[string]string) string {
+	for name, src := range files {
+		path := filepath.Join(goroot, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return goroot
+}
@@ -0,0 +1,53 @@
+package importer
+
+import "testing"
+
+func TestValueReceiverAnnotations(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Value struct {
+		N int
+	}
+
+	type Reader struct{}
+
+	func (r Reader) Read() *Value { return &Value{} }
+
+	func (r Reader) Peek() *Value { return nil }
+
+	type Writer struct{}
+
+	func (w Writer) Write() *Value { return &Value{} }
+
+	type Trusted struct{}
+
+	func (t Trusted) Get() *Value { return &Value{} }
+
+	func (t Trusted) Find() *Value { return nil }
+	`, map[string]string{
+		"(Reader).Read":  "(Reader) func() *Value",
+		"Writer.Write":   "(Writer) func() *Value",
+		"(Trusted)":      "@default !",
+		"(Trusted).Find": "(Trusted) func() ?*Value",
+	})
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(r lib.Reader, w lib.Writer, t lib.Trusted) {
+		// Annotated under the receiver, or in the type's block.
+		_ = r.Read().N
+		_ = w.Write().N
+		_ = r.Peek().N // ERROR
+
+		// A default for the receiver applies to its methods.
+		_ = t.Get().N
+		_ = t.Find().N // ERROR
+	}
+	`, lib)
+
+	testExpectErrorLines(t, errs, 10, 14)
+}
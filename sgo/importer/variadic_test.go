@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/annotations"
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestVariadicParams(t *testing.T) {
+	command, _ := defaultAnnotations["os/exec"].Definition("Command")
+	if elem, ok := defaultAnnotations["os/exec"].Variadic("Command"); !ok || elem != "string" {
+		t.Errorf("os/exec.Command: expected variadic string, got %q, %v", elem, ok)
+	}
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Cmd struct{}
+
+	type T struct{}
+
+	func Command(name string, arg ...string) *Cmd { return nil }
+
+	func Join(sep string, elems ...*T) string { return "" }
+
+	func All(elems ...*T) bool { return false }
+
+	func Args(args []*T) *Cmd { return nil }
+	`, map[string]string{
+		"Command": command,
+		"Join":    `func(sep string, elems ...?*T) string`,
+		"All":     `func(elems ...*T) bool`,
+		"Args":    `func(args []?*T) *Cmd`,
+	})
+
+	for name, variadic := range map[string]bool{"Command": true, "Join": true, "All": true, "Args": false} {
+		sig := lib.Scope().Lookup(name).Type().(*types.Signature)
+		if sig.Variadic() != variadic {
+			t.Errorf("%s: expected variadic %v, got %v", name, variadic, sig.Variadic())
+		}
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(args []string, p ?*lib.T, ps []?*lib.T) {
+		_ = lib.Command("ls")
+		_ = lib.Command("ls", "-l", "-a")
+		_ = lib.Command("ls", args...)
+		_ = lib.Join(", ", nil, p)
+		_ = lib.Join(", ", ps...)
+		_ = lib.All(&lib.T{}, nil)
+		_ = lib.All(p)
+		_ = lib.Args(ps)
+		_ = lib.Args(nil, p)
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 12, 13, 15)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "lib.go", "package lib\n\ntype Cmd struct{}\n\nfunc Command(name string, arg ...string) *Cmd { return nil }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range []string{
+		`func(arg ...string, name string) *Cmd`,
+		`func(name, arg ...string) *Cmd`,
+	} {
+		imp, _ := newImporter(map[string]struct{}{}, "")
+		_, err = imp.checkFiles("example.com/lib", fset, []*ast.File{f}, annotations.NewAnnotation(map[string]string{"Command": def}))
+		if err == nil {
+			t.Errorf("%q: expected error", def)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package importer
+
+import "testing"
+
+func TestInitializedVars(t *testing.T) {
+	lib := testImportLib(t, "example.com/lib", `
+	package lib
+
+	type Handler struct {
+		N int
+	}
+
+	var Registry = map[string]*Handler{}
+
+	var Handlers map[string]*Handler = map[string]*Handler{}
+
+	var Default = &Handler{}
+
+	var Counts = make(map[string]int)
+
+	var Fallback = new(Handler)
+
+	var Hook = func(h *Handler) {}
+
+	var Reset = map[string]*Handler{}
+
+	var Unset map[string]int
+	`, map[string]string{
+		"Reset": `?map[string]*Handler`,
+	})
+
+	for name, typ := range map[string]string{
+		"Registry": "map[string]?*example.com/lib.Handler",
+		"Handlers": "map[string]?*example.com/lib.Handler",
+		"Default":  "*example.com/lib.Handler",
+		"Counts":   "map[string]int",
+		"Fallback": "*example.com/lib.Handler",
+		"Hook":     "func(h ?*example.com/lib.Handler)",
+		"Reset":    "?map[string]*example.com/lib.Handler",
+		"Unset":    "?map[string]int",
+	} {
+		if got := lib.Scope().Lookup(name).Type().String(); got != typ {
+			t.Errorf("%s: expected type %s, got %s", name, typ, got)
+		}
+	}
+
+	errs := testCheckSGo(t, `
+	package user
+
+	import "example.com/lib"
+
+	func f(h *lib.Handler) int {
+		lib.Registry["a"] = h
+		lib.Counts["a"]++
+		lib.Hook(h)
+		lib.Reset["a"] = h
+		return lib.Default.N + lib.Fallback.N
+	}
+	`, lib)
+	testExpectErrorLines(t, errs, 10)
+}
@@ -0,0 +1,81 @@
+package sgo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// nilAssertions returns the code that checks at run time that the variables
+// that if conditions in file narrow aren't nil, for TranslateOptions'
+// InsertNilAssertions. It is keyed by the position before which it goes:
+// right after the opening brace of the block where a variable is narrowed, or
+// right after an if whose body returns, for the rest of the enclosing block.
+//
+// Narrowings into an else if are left out; there's no block to put them in.
+func nilAssertions(info *types.Info, fset *token.FileSet, file *ast.File) map[token.Pos][]byte {
+	assertions := map[token.Pos][]byte{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		s, ok := n.(*ast.IfStmt)
+		if !ok {
+			return true
+		}
+		narrowings := append([]*types.Narrowing{}, info.Narrowings[s.Cond]...)
+		sort.SliceStable(narrowings, func(i, j int) bool {
+			return narrowings[i].Var.Name() < narrowings[j].Var.Name()
+		})
+		for _, nw := range narrowings {
+			v := nw.Var
+			if v.Name() == "_" || !types.IsOptionable(v.Type()) {
+				continue
+			}
+			if _, ok := v.Type().(*types.Optional); ok {
+				continue
+			}
+
+			var at token.Pos
+			switch {
+			case nw.Pos == s.Body.Pos():
+				at = s.Body.Lbrace + 1
+			case s.Else != nil && nw.Pos == s.Else.Pos():
+				block, ok := s.Else.(*ast.BlockStmt)
+				if !ok {
+					continue
+				}
+				at = block.Lbrace + 1
+			case nw.Pos == s.End():
+				at = s.End()
+			default:
+				continue
+			}
+
+			var buf bytes.Buffer
+			if at == s.End() {
+				buf.WriteString(";")
+			}
+			msg := fmt.Sprintf("sgo: %s is nil, but the condition at %v proved it isn't", v.Name(), fset.Position(s.Cond.Pos()))
+			fmt.Fprintf(&buf, " if %s == nil { panic(%q) }", v.Name(), msg)
+			if at != s.End() {
+				buf.WriteString(";")
+			}
+			assertions[at] = append(assertions[at], buf.Bytes()...)
+		}
+		return true
+	})
+	return assertions
+}
+
+// insertNilAssertion adds to the output the nil assertions that go before pos,
+// if any.
+func (c *converter) insertNilAssertion(pos token.Pos) {
+	text, ok := c.nilAssertions[pos]
+	if !ok {
+		return
+	}
+	delete(c.nilAssertions, pos)
+	c.putChunks(int(pos)-1, c.src[c.lastChunkEnd:int(pos)-c.base-1], text)
+}
@@ -281,14 +281,14 @@ func ParseMethodExprsFrom(fset *token.FileSet, filename string, src interface{},
 		recv = pe.X
 		e = parseSingleExpr(p)
 	} else {
-		assert(false, "expected receiver type")
+		p.errorExpected(e.Pos(), "receiver type")
 	}
 
 	switch e := e.(type) {
 	case *ast.FuncType:
 		fun = e
 	default:
-		assert(false, "expected function type")
+		p.errorExpected(e.Pos(), "function type")
 	}
 
 	// If a semicolon was inserted, consume it;
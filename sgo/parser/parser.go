@@ -577,6 +577,9 @@ func (p *parser) parseExprList(lhs bool) *ast.ExprList {
 	if p.tok == token.BACKSL {
 		list.EntangledPos = 1
 		p.next()
+		if p.tok == token.SEMICOLON || p.tok == token.RBRACE {
+			return list
+		}
 	}
 
 	list.List = append(list.List, p.checkExpr(p.parseExpr(lhs)))
@@ -923,7 +926,7 @@ func (p *parser) parseReturnParams(scope *ast.Scope) *ast.FieldList {
 
 	var params []*ast.Field
 	lparen := p.expect(token.LPAREN)
-	if p.tok != token.RPAREN {
+	if p.tok != token.RPAREN && p.tok != token.BACKSL {
 		params = p.parseParameterList(scope, false)
 	}
 
@@ -955,6 +958,13 @@ func (p *parser) parseResult(scope *ast.Scope) *ast.FieldList {
 		return p.parseReturnParams(scope)
 	}
 
+	if p.tok == token.BACKSL {
+		// Only an entangled result, like in func() \ error.
+		p.next()
+		typ := p.parseType()
+		return &ast.FieldList{Entangled: &ast.Field{Type: typ}}
+	}
+
 	typ := p.tryType()
 	if typ != nil {
 		list := make([]*ast.Field, 1)
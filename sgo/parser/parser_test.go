@@ -129,6 +129,24 @@ func TestParseExpr(t *testing.T) {
 	}
 }
 
+func TestParseMethodExprs(t *testing.T) {
+	src := "(*T) func(x int) error"
+	fun, recv, err := ParseMethodExprs(src)
+	if err != nil {
+		t.Fatalf("ParseMethodExprs(%q): %v", src, err)
+	}
+	if _, ok := recv.(*ast.StarExpr); !ok || fun == nil {
+		t.Errorf("ParseMethodExprs(%q): got %T, %v", src, recv, fun)
+	}
+
+	// a missing receiver or function type is an error, not a crash
+	for _, src := range []string{"A(", "func()", "(T)", "(T) int", "(T) ("} {
+		if _, _, err := ParseMethodExprs(src); err == nil {
+			t.Errorf("ParseMethodExprs(%q): got no error", src)
+		}
+	}
+}
+
 func TestColonEqualsScope(t *testing.T) {
 	f, err := ParseFile(token.NewFileSet(), "", `package p; func f() { x, y, z := x, y, z }`, 0)
 	if err != nil {
@@ -311,7 +311,7 @@ func (p *printer) exprs(prev0 token.Pos, list []ast.Expr, depth int, mode exprLi
 
 func (p *printer) parameters(fields *ast.FieldList) {
 	p.print(fields.Opening, token.LPAREN)
-	if len(fields.List) > 0 {
+	if len(fields.List) > 0 || fields.Entangled != nil {
 		prevLine := p.lineFor(fields.Opening)
 		ws := indent
 		for i, par := range append(fields.List, fields.Entangled) {
@@ -343,12 +343,15 @@ func (p *printer) parameters(fields *ast.FieldList) {
 				} else {
 					p.print(token.COMMA)
 				}
+			} else if par == fields.Entangled {
+				// only an entangled parameter, as in (\ err error)
+				p.print(token.BACKSL)
 			}
 			// separator if needed (linebreak or blank)
 			if needsLinebreak && p.linebreak(parLineBeg, 0, ws, true) {
 				// break line if the opening "(" or previous parameter ended on a different line
 				ws = ignore
-			} else if i > 0 {
+			} else if i > 0 || par == fields.Entangled {
 				p.print(blank)
 			}
 			// parameter names
@@ -393,11 +396,17 @@ func (p *printer) signature(params, result *ast.FieldList) {
 	if n > 0 {
 		// result != nil
 		p.print(blank)
-		if n == 1 && result.List[0].Names == nil {
+		if n == 1 && len(result.List) == 1 && result.List[0].Names == nil {
 			// single anonymous result; no ()'s
 			p.expr(stripParensAlways(result.List[0].Type))
 			return
 		}
+		if n == 1 && len(result.List) == 0 && result.Entangled.Names == nil {
+			// single anonymous entangled result, as in func() \ error; no ()'s
+			p.print(token.BACKSL, blank)
+			p.expr(stripParensAlways(result.Entangled.Type))
+			return
+		}
 		p.parameters(result)
 	}
 }
@@ -607,6 +607,31 @@ func f()
 	}
 }
 
+// TestEntangledResults tests that results entangled with no other results are
+// kept.
+func TestEntangledResults(t *testing.T) {
+	const src = `package p
+
+func f() \ error
+
+func g() (\ err error)
+
+func h() (int \ error)
+`
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != src {
+		t.Fatalf("got:\n%s\nwant:\n%s\n", got, src)
+	}
+}
+
 type limitWriter struct {
 	remaining int
 	errCount  int
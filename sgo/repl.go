@@ -0,0 +1,277 @@
+package sgo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/scanner"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// A Session is a read-eval-print loop session: a sequence of SGo lines
+// entered one at a time, which run as the body of a main function.
+//
+// Each line is checked along with the ones accepted before it, so variables
+// keep their types across lines, including what is known about whether they
+// are nil. Lines are imports, type and function declarations, statements, or
+// expressions, whose values are printed. A line that doesn't translate or run
+// is rejected, and the session is kept as it was before it.
+//
+// The whole session is translated and run again for each line, so its output
+// must be the same each time it runs.
+type Session struct {
+	// Options configures the translation of the session.
+	Options TranslateOptions
+	// Run runs the translated Go source of a main package, and returns its
+	// output. If nil, the source is run with "go run".
+	//
+	// For SGo: ?func(src []byte) (output []byte, err ?error)
+	Run func(src []byte) (output []byte, err error)
+
+	imports []string
+	decls   []string
+	stmts   []string
+	output  []byte
+}
+
+// replFmt is the name under which a session imports fmt to print the values
+// of expressions.
+const replFmt = "replfmt"
+
+// Eval adds line to the session and runs it. It returns the output that
+// running the line added to that of the previous lines. If line doesn't
+// translate or its program fails, the errors are returned, and line isn't
+// added.
+//
+// For SGo: func(line string) (output []byte, errs []error)
+func (s *Session) Eval(line string) (output []byte, errs []error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+
+	next := *s
+	switch firstDeclToken(line) {
+	case token.IMPORT:
+		next.imports = append(s.imports[:len(s.imports):len(s.imports)], line)
+	case token.TYPE, token.FUNC:
+		next.decls = append(s.decls[:len(s.decls):len(s.decls)], line)
+	default:
+		stmts := s.stmts[:len(s.stmts):len(s.stmts)]
+		if _, err := parser.ParseExpr(line); err == nil {
+			next.stmts = append(stmts, replFmt+".Println("+line+")")
+			src, errs := next.translate()
+			if len(errs) == 0 {
+				return s.run(&next, src)
+			}
+			if !usedAsValue(errs) {
+				return nil, errs
+			}
+		}
+		next.stmts = append(stmts, line)
+	}
+
+	src, errs := next.translate()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return s.run(&next, src)
+}
+
+// run runs src, the translation of next, and if it succeeds makes next the
+// session.
+func (s *Session) run(next *Session, src []byte) ([]byte, []error) {
+	run := s.Run
+	if run == nil {
+		run = goRun
+	}
+	output, err := run(src)
+	added := output
+	if bytes.HasPrefix(output, s.output) {
+		added = output[len(s.output):]
+	}
+	if err != nil {
+		return added, []error{err}
+	}
+	next.output = output
+	*s = *next
+	return added, nil
+}
+
+// translate checks the session, and returns it translated to Go.
+//
+// Variables and imports only used by later lines are fine in a session, but
+// not in Go. So, after reporting any other errors, the session is translated
+// again with those imports left out, and a use of each such variable at the
+// end of main. Being there, they may be used before they're initialized,
+// which is allowed just for that translation.
+func (s *Session) translate() ([]byte, []error) {
+	src, lines := s.source(nil, nil)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "repl.sgo", src, parser.ParseComments)
+	if err != nil {
+		return nil, []error{replErrList([]error{err})}
+	}
+	_, typeErrs := typecheck("main", fset, "", s.Options, nil, file)
+
+	var errs []error
+	var unused []string
+	unusedImports := map[int]bool{}
+	for _, err := range typeErrs {
+		terr, ok := err.(types.Error)
+		if !ok || !terr.Soft {
+			errs = append(errs, err)
+			continue
+		}
+		pos := fset.Position(terr.Pos)
+		if name := strings.TrimSuffix(terr.Msg, " declared but not used"); name != terr.Msg && pos.Line >= lines.main {
+			unused = append(unused, name)
+		} else if strings.Contains(terr.Msg, " imported but not used") && pos.Line < lines.decls {
+			unusedImports[pos.Line-lines.imports] = true
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, []error{replErrList(errs)}
+	}
+
+	src, _ = s.source(unused, unusedImports)
+	opts := s.Options
+	opts.allowUseUninitializedVars = true
+	gen, errs := TranslateFilesWith(opts, NamedFile{"repl.sgo", bytes.NewReader(src)})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return gen[0], nil
+}
+
+// replLines are the line numbers at which each part of a session's source
+// starts.
+type replLines struct {
+	imports, decls, main int
+}
+
+// source returns the session as the source of a main package. The imports
+// whose indices are in skipImports are left out, and each variable in uses is
+// used at the end of main. The fmt import for printing counts as index -1.
+func (s *Session) source(uses []string, skipImports map[int]bool) ([]byte, replLines) {
+	var buf bytes.Buffer
+	var lines replLines
+	line := 1
+	writeLine := func(format string, args ...interface{}) {
+		fmt.Fprintf(&buf, format+"\n", args...)
+		line++
+	}
+
+	writeLine("package main")
+	lines.imports = line + 1
+	if !skipImports[-1] {
+		writeLine("import %s %q", replFmt, "fmt")
+	} else {
+		writeLine("")
+	}
+	for i, imp := range s.imports {
+		if !skipImports[i] {
+			writeLine("%s", imp)
+		}
+	}
+	lines.decls = line
+	for _, decl := range s.decls {
+		writeLine("%s", decl)
+	}
+	lines.main = line
+	writeLine("func main() {")
+	for _, stmt := range s.stmts {
+		writeLine("%s", stmt)
+	}
+	for _, name := range uses {
+		writeLine("_ = &%s", name)
+	}
+	writeLine("}")
+	return buf.Bytes(), lines
+}
+
+// replErrList converts errs, found in a session's source, to errors without
+// their positions there, which would be meaningless to the user. Since all
+// earlier lines were fine, the errors are almost always in the last one.
+func replErrList(errs []error) scanner.ErrorList {
+	var list scanner.ErrorList
+	for _, err := range errs {
+		switch err := err.(type) {
+		case types.Error:
+			list.Add(token.Position{}, err.Msg)
+		case scanner.ErrorList:
+			for _, err := range err {
+				list.Add(token.Position{}, err.Msg)
+			}
+		default:
+			list.Add(token.Position{}, err.Error())
+		}
+	}
+	return list
+}
+
+// firstDeclToken returns the token a line starts with if it's a keyword for a
+// declaration entered at package level: imports, types, and named functions.
+// Otherwise, it returns token.ILLEGAL.
+func firstDeclToken(line string) token.Token {
+	fset := token.NewFileSet()
+	var sc scanner.Scanner
+	sc.Init(fset.AddFile("", fset.Base(), len(line)), []byte(line), nil, 0)
+	_, tok, _ := sc.Scan()
+	switch tok {
+	case token.IMPORT, token.TYPE:
+		return tok
+	case token.FUNC:
+		if _, next, _ := sc.Scan(); next == token.IDENT {
+			return tok
+		}
+	}
+	return token.ILLEGAL
+}
+
+// usedAsValue reports whether errs are just because an expression without a
+// value, like a call to a function without results, was used as a value.
+func usedAsValue(errs []error) bool {
+	for _, err := range errs {
+		list, ok := err.(scanner.ErrorList)
+		if !ok {
+			return false
+		}
+		for _, err := range list {
+			if !strings.HasSuffix(err.Msg, "used as value") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// goRun runs src, the source of a main package, with "go run", and returns its
+// combined standard output and error.
+func goRun(src []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "sgorepl")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(path, src, 0644); err != nil {
+		return nil, err
+	}
+	output, err := exec.Command("go", "run", path).CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("program failed: %v", err)
+		}
+	}
+	return output, err
+}
@@ -0,0 +1,62 @@
+package sgo
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestSession(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	// A package as if annotated with:
+	//
+	// 	Println func(a ...interface{}) (n int, err ?error)
+	fmtPkg := types.NewPackage("fmt", "fmt")
+	params := types.NewTuple(types.NewParam(token.NoPos, fmtPkg, "a", types.NewSlice(types.NewInterface(nil, nil).Complete())))
+	results := types.NewTuple(
+		types.NewVar(token.NoPos, fmtPkg, "n", types.Typ[types.Int]),
+		types.NewVar(token.NoPos, fmtPkg, "err", types.NewOptional(types.Universe.Lookup("error").Type())),
+	)
+	fmtPkg.Scope().Insert(types.NewFunc(token.NoPos, fmtPkg, "Println", types.NewSignature(nil, params, results, true)))
+	fmtPkg.MarkComplete()
+
+	s := &Session{Options: TranslateOptions{Importer: testFakeImporter{"fmt": fmtPkg}}}
+
+	for _, c := range []struct {
+		line   string
+		output string
+		err    string
+	}{
+		{line: "x := 1"},
+		{line: "x + 1", output: "2\n"},
+		{line: `m := map[string]*int{"a": &x}`},
+		{line: `p \ ok := m["a"]`},
+		{line: "*p", err: "possibly uninitialized variable: p"},
+		{line: "if !ok { return }"},
+		{line: "*p", output: "1\n"},
+		{line: "y", err: "undeclared name: y"},
+		{line: `panic("boom")`, output: "panic: boom", err: "program failed"},
+		{line: "func double(n int) int { return n * 2 }"},
+		{line: "double(x)", output: "2\n"},
+		{line: "x = double(x)"},
+		{line: "x", output: "2\n"},
+	} {
+		output, errs := s.Eval(c.line)
+		if !strings.HasPrefix(string(output), c.output) {
+			t.Errorf("%s: expected output %q, got %q", c.line, c.output, output)
+		}
+		if c.err == "" {
+			if len(errs) > 0 {
+				t.Errorf("%s: unexpected errors: %v", c.line, errs)
+			}
+		} else if len(errs) != 1 || !strings.Contains(errs[0].Error(), c.err) {
+			t.Errorf("%s: expected error %q, got: %v", c.line, c.err, errs)
+		}
+	}
+}
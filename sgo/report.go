@@ -0,0 +1,147 @@
+package sgo
+
+import (
+	"sort"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// A Report lists what SGo found out about the nilability of the expressions in
+// a SGo file, and why. It is meant to be marshaled to JSON for tooling.
+type Report struct {
+	// Go is the resulting Go code.
+	Go string `json:"go"`
+	// Facts are the nilability facts, in source order.
+	Facts []Fact `json:"facts"`
+}
+
+// A Fact describes whether a use of a variable can be nil, and why.
+type Fact struct {
+	Pos  token.Position `json:"pos"`
+	Expr string         `json:"expr"`
+	Type string         `json:"type"`
+	// Nilable is set if the expression can be nil at that point.
+	Nilable bool `json:"nilable"`
+	// Reason is one of:
+	//
+	// 	"optional":  the variable has optional type, so it can be nil.
+	// 	"unwrapped": the variable is an optional unwrapped by the condition at Guard.
+	// 	"collapsed": the variable is entangled, and collapsed by the condition at Guard.
+	// 	"declared":  the variable is declared with a non-optional type.
+	// 	"imported":  the variable comes from an imported package, and its
+	// 	             non-optional type from its annotations.
+	Reason string `json:"reason"`
+	// Guard is the position of the condition that unwraps or collapses the
+	// variable, if any.
+	Guard *token.Position `json:"guard,omitempty"`
+}
+
+// Fact reasons.
+const (
+	ReasonOptional  = "optional"
+	ReasonUnwrapped = "unwrapped"
+	ReasonCollapsed = "collapsed"
+	ReasonDeclared  = "declared"
+	ReasonImported  = "imported"
+)
+
+// TranslateFileReport translates the given SGo source, and reports the
+// nilability facts it finds for every use of a variable that can hold nil.
+//
+// For SGo: func(src string) (Report \ error)
+func TranslateFileReport(src string) (Report, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "input.sgo", src, parser.ParseComments)
+	if err != nil {
+		return Report{}, err
+	}
+
+	info, typeErrs := typecheck("translate", fset, "", TranslateOptions{}, nil, file)
+	if len(typeErrs) > 0 {
+		return Report{}, makeErrList(fset, typeErrs)
+	}
+
+	report := Report{
+		Go:    string(translate(info, [][]byte{[]byte(src)}, []*ast.File{file}, fset, TranslateOptions{})[0]),
+		Facts: nilabilityFacts(info, fset, file),
+	}
+	return report, nil
+}
+
+func nilabilityFacts(info *types.Info, fset *token.FileSet, file *ast.File) []Fact {
+	var pkgScope *types.Scope
+	if fileScope, ok := info.Scopes[file]; ok {
+		pkgScope = fileScope.Parent()
+	}
+
+	type narrowing struct {
+		*types.Narrowing
+		cond ast.Expr
+	}
+	narrowings := map[*types.Var][]narrowing{}
+	for cond, ns := range info.Narrowings {
+		for _, n := range ns {
+			narrowings[n.Var] = append(narrowings[n.Var], narrowing{n, cond})
+		}
+	}
+
+	var ids []*ast.Ident
+	for id := range info.Uses {
+		ids = append(ids, id)
+	}
+	sort.Sort(identsByPos(ids))
+
+	var facts []Fact
+	for _, id := range ids {
+		v, ok := info.Uses[id].(*types.Var)
+		if !ok || !types.IsOptionable(v.Type()) && !isOptional(v.Type()) {
+			continue
+		}
+		fact := Fact{
+			Pos:  fset.Position(id.Pos()),
+			Expr: id.Name,
+			Type: v.Type().String(),
+		}
+
+		// Look for the innermost narrowing covering this use.
+		var guard *narrowing
+		for i, n := range narrowings[v] {
+			if n.Pos <= id.Pos() && id.Pos() < n.End && (guard == nil || guard.Pos < n.Pos) {
+				guard = &narrowings[v][i]
+			}
+		}
+
+		switch {
+		case guard != nil:
+			fact.Reason = ReasonCollapsed
+			if guard.Unwrapped {
+				fact.Reason = ReasonUnwrapped
+			}
+			pos := fset.Position(guard.cond.Pos())
+			fact.Guard = &pos
+		case isOptional(v.Type()):
+			fact.Nilable = true
+			fact.Reason = ReasonOptional
+		case v.Pkg() != nil && v.Pkg().Scope() != pkgScope:
+			fact.Reason = ReasonImported
+		default:
+			fact.Reason = ReasonDeclared
+		}
+		facts = append(facts, fact)
+	}
+	return facts
+}
+
+func isOptional(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Optional)
+	return ok
+}
+
+type identsByPos []*ast.Ident
+
+func (ids identsByPos) Len() int           { return len(ids) }
+func (ids identsByPos) Less(i, j int) bool { return ids[i].Pos() < ids[j].Pos() }
+func (ids identsByPos) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
@@ -0,0 +1,65 @@
+package sgo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTranslateFileReport(t *testing.T) {
+	src := `package example
+
+func get() (*int \ error) {
+	return new(int) \
+}
+
+func f(x ?*int) {
+	if x != nil {
+		_ = *x
+	}
+	_ = x
+	p \ err := get()
+	if err != nil {
+		return
+	}
+	_ = *p
+}
+`
+	report, err := TranslateFileReport(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type expectedFact struct {
+		line      int
+		expr      string
+		nilable   bool
+		reason    string
+		guardLine int
+	}
+	expected := []expectedFact{
+		{8, "x", true, ReasonOptional, 0},
+		{9, "x", false, ReasonUnwrapped, 8},
+		{11, "x", true, ReasonOptional, 0},
+		{13, "err", true, ReasonOptional, 0},
+		{16, "p", false, ReasonCollapsed, 13},
+	}
+
+	if len(report.Facts) != len(expected) {
+		js, _ := json.MarshalIndent(report.Facts, "", "\t")
+		t.Fatalf("expected %d facts, got %d: %s", len(expected), len(report.Facts), js)
+	}
+	for i, e := range expected {
+		f := report.Facts[i]
+		guardLine := 0
+		if f.Guard != nil {
+			guardLine = f.Guard.Line
+		}
+		if f.Pos.Line != e.line || f.Expr != e.expr || f.Nilable != e.nilable || f.Reason != e.reason || guardLine != e.guardLine {
+			t.Errorf("fact %d: expected %+v, got %+v (guard line %d)", i, e, f, guardLine)
+		}
+	}
+
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("marshaling to JSON: %v", err)
+	}
+}
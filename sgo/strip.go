@@ -0,0 +1,160 @@
+package sgo
+
+import (
+	"bytes"
+
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/printer"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// Strip translates SGo source to Go without typechecking it, so it's faster
+// than TranslateFile and never fails for code that doesn't hold up SGo's
+// guarantees. Only syntax errors are reported.
+//
+// Optional types become their Go counterparts, and entangled results become
+// plain multiple results, as TranslateFile would. But, without types, Strip
+// can't know which type assertions must check for nils, so it doesn't add
+// those checks, and `return \ err` fills in the other results with
+// `*new(T)` unless their zero value is apparent from their type expression.
+// Likewise, forces like x! are translated to just x, without checking that x
+// isn't nil.
+//
+// For SGo: func(src string) (string \ error)
+func Strip(src string) (string, error) {
+	return strip("input.sgo", src)
+}
+
+// strip is like Strip, for src read from filename.
+func strip(filename, src string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	return string(translate(&types.Info{}, [][]byte{[]byte(src)}, []*ast.File{file}, fset, TranslateOptions{})[0]), nil
+}
+
+// zeroResultsFromAST is like (*converter).zeroResults, for the results of a
+// function whose type is only known from its AST.
+func zeroResultsFromAST(fset *token.FileSet, results *ast.FieldList) [][]byte {
+	var zeros [][]byte
+	if results == nil {
+		return zeros
+	}
+	for _, f := range results.List {
+		zero := zeroValueFromAST(fset, f.Type)
+		for i := 0; i < len(f.Names) || i == 0; i++ {
+			zeros = append(zeros, zero)
+		}
+	}
+	return zeros
+}
+
+func zeroValueFromAST(fset *token.FileSet, typ ast.Expr) []byte {
+	print := func() []byte {
+		buf := &bytes.Buffer{}
+		printer.Fprint(buf, fset, goTypeFromAST(typ))
+		return buf.Bytes()
+	}
+
+	switch typ := typ.(type) {
+	case *ast.ParenExpr:
+		return zeroValueFromAST(fset, typ.X)
+	case *ast.StarExpr, *ast.MapType, *ast.FuncType, *ast.InterfaceType, *ast.ChanType, *ast.OptionalType:
+		return []byte("nil")
+	case *ast.ArrayType:
+		if typ.Len == nil {
+			return []byte("nil")
+		}
+		return append(print(), '{', '}')
+	case *ast.StructType:
+		return append(print(), '{', '}')
+	case *ast.Ident:
+		switch typ.Name {
+		case "bool":
+			return []byte("false")
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"byte", "rune":
+			return []byte("0")
+		case "float32", "float64", "complex64", "complex128":
+			return []byte("0.0")
+		case "string":
+			return []byte(`""`)
+		case "error":
+			return []byte("nil")
+		}
+	}
+	// A named type; we don't know what it is.
+	return append(append([]byte("*new("), print()...), ')')
+}
+
+// goTypeFromAST returns the Go counterpart of the type expression typ, as
+// goType does for types: optionals are replaced by their element types, and
+// entangled results become plain results. typ is left as is.
+func goTypeFromAST(typ ast.Expr) ast.Expr {
+	switch t := typ.(type) {
+	case *ast.OptionalType:
+		return goTypeFromAST(t.Elt)
+	case *ast.ParenExpr:
+		c := *t
+		c.X = goTypeFromAST(t.X)
+		return &c
+	case *ast.StarExpr:
+		c := *t
+		c.X = goTypeFromAST(t.X)
+		return &c
+	case *ast.Ellipsis:
+		c := *t
+		c.Elt = goTypeFromAST(t.Elt)
+		return &c
+	case *ast.ArrayType:
+		c := *t
+		c.Elt = goTypeFromAST(t.Elt)
+		return &c
+	case *ast.ChanType:
+		c := *t
+		c.Value = goTypeFromAST(t.Value)
+		return &c
+	case *ast.MapType:
+		c := *t
+		c.Key = goTypeFromAST(t.Key)
+		c.Value = goTypeFromAST(t.Value)
+		return &c
+	case *ast.StructType:
+		c := *t
+		c.Fields = goFieldsFromAST(t.Fields)
+		return &c
+	case *ast.InterfaceType:
+		c := *t
+		c.Methods = goFieldsFromAST(t.Methods)
+		return &c
+	case *ast.FuncType:
+		c := *t
+		c.Params = goFieldsFromAST(t.Params)
+		c.Results = goFieldsFromAST(t.Results)
+		return &c
+	}
+	return typ
+}
+
+func goFieldsFromAST(fields *ast.FieldList) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+	c := *fields
+	c.List = nil
+	for _, f := range append(append([]*ast.Field{}, fields.List...), fields.Entangled) {
+		if f == nil {
+			continue
+		}
+		fc := *f
+		fc.Type = goTypeFromAST(f.Type)
+		c.List = append(c.List, &fc)
+	}
+	c.Entangled = nil
+	return &c
+}
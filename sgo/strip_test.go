@@ -0,0 +1,145 @@
+package sgo
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStripMatchesTranslate(t *testing.T) {
+	src := `package p
+
+type T struct{ N int }
+
+// For SGo: func(x *T) ?*T
+func id(x *T) *T {
+	return x
+}
+
+func f(x ?*T) (*T, int \ error) {
+	if x == nil {
+		return \ nil
+	}
+	var y ?*T = id(x)
+	_ = y
+	return x, x.N \
+}
+
+func g(ok bool) (s string, n int \ ok2 bool) {
+	if !ok {
+		return \ false
+	}
+	return "", 0 \
+}
+
+func h() \ error {
+	return \
+}
+`
+	report, err := TranslateFileReport(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := Strip(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stripped != report.Go {
+		t.Errorf("expected the same Go as TranslateFile:\n%s\n\ngot:\n%s", report.Go, stripped)
+	}
+}
+
+func TestStripIgnoresSemantics(t *testing.T) {
+	src := `package p
+
+type T struct{ N int }
+
+type U T
+
+func f(x ?*T) int {
+	return x.N
+}
+
+func g() (U, []?*T \ error) {
+	return \ nil
+}
+
+func h(x interface{}) *T {
+	return x.(*T)
+}
+`
+	if _, err := TranslateFileReport(src); err == nil {
+		t.Fatal("expected TranslateFile to fail")
+	}
+	stripped, err := Strip(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"func f(x *T) int {",
+		"func g() (U, []*T, error) {",
+		"return *new(U), nil, nil",
+		"return x.(*T)",
+	} {
+		if !strings.Contains(stripped, expected) {
+			t.Errorf("expected %q in:\n%s", expected, stripped)
+		}
+	}
+	if strings.ContainsAny(stripped, `?\`) {
+		t.Errorf("expected no SGo syntax left in:\n%s", stripped)
+	}
+}
+
+func TestStripZeroValues(t *testing.T) {
+	src := `package main
+
+type E struct{}
+
+func (E) Error() string { return "E" }
+
+func a() ([2]?*int \ error) {
+	return \ E{}
+}
+
+func b() (struct{ p ?*int } \ error) {
+	return \ E{}
+}
+
+func c() (map[string]?*int, struct{ f func() (int \ error) } \ error) {
+	return \ E{}
+}
+
+func main() {
+	_ \ err := a()
+	_ \ err = b()
+	_, _ \ err = c()
+	println(err.Error())
+}
+`
+	stripped, err := Strip(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"return [2]*int{}, E{}",
+		"return struct{ p *int }{}, E{}",
+		"return nil, struct{ f func() (int, error) }{}, E{}",
+	} {
+		if !strings.Contains(stripped, expected) {
+			t.Errorf("expected %q in:\n%s", expected, stripped)
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	if output, err := goRun([]byte(stripped)); err != nil || string(output) != "E\n" {
+		t.Errorf("expected the stripped code to run; got error %v, output:\n%s", err, output)
+	}
+}
+
+func TestStripSyntaxError(t *testing.T) {
+	if _, err := Strip("package p\n\nfunc f( {}\n"); err == nil {
+		t.Error("expected syntax error")
+	}
+}
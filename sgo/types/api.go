@@ -246,6 +246,31 @@ type Info struct {
 	//
 	// For SGo: []*Initializer
 	InitOrder []*Initializer
+
+	// Narrowings maps if conditions to the variables they make usable in some
+	// region of code, either because an optional is unwrapped by a nil check,
+	// or because an entangled value is collapsed by a check on the value it's
+	// entangled with.
+	//
+	// For SGo: ?map[ast.Expr][]*Narrowing
+	Narrowings map[ast.Expr][]*Narrowing
+}
+
+// A Narrowing records that a variable is known to be usable in a region of code
+// because of an if condition.
+type Narrowing struct {
+	// Var is the variable that is usable. For unwrapped optionals, it is the
+	// variable with the unwrapped type, which may be a new variable that
+	// shadows the optional one.
+	Var *Var
+	// Unwrapped is set if Var is an unwrapped optional; otherwise, Var was
+	// collapsed from an entangled value.
+	Unwrapped bool
+	// Else is set if the condition is known to be false in the region, and
+	// unset if it's known to be true.
+	Else bool
+	// Pos and End delimit the region of code where Var is usable.
+	Pos, End token.Pos
 }
 
 // TypeOf returns the type of expression e, or nil if not found.
@@ -67,9 +67,34 @@ func (check *Checker) assignment(x *operand, T Type, context string) {
 			check.errorf(x.pos(), "cannot use %s as %s value in %s", x, T, context)
 		}
 		x.mode = invalid
+		return
+	}
+
+	if NilInInterface(x.typ, T) {
+		check.errorf(x.pos(), "cannot use %s as %s value in %s: if it's nil, the interface isn't nil, but calling its methods may panic; check it for nil first", x, T, context)
+		x.mode = invalid
 	}
 }
 
+// NilInInterface reports whether assigning a value of type V to a variable of
+// type T may put a nil value in an interface with methods, which would then
+// not be nil itself: V is optional and T is an interface, or an optional
+// interface, with methods, but V's element type isn't an interface.
+//
+// Interfaces without methods, like interface{}, are excluded, as their
+// values must be type asserted before use, which checks them for nil.
+func NilInInterface(V, T Type) bool {
+	v, ok := V.Underlying().(*Optional)
+	if !ok || IsInterface(v.elem) {
+		return false
+	}
+	if t, ok := T.Underlying().(*Optional); ok {
+		T = t.elem
+	}
+	t, ok := T.Underlying().(*Interface)
+	return ok && !t.Empty()
+}
+
 func (check *Checker) initConst(lhs *Const, x *operand) {
 	if x.mode == invalid || x.typ == Typ[Invalid] || lhs.typ == Typ[Invalid] {
 		if lhs.typ == nil {
@@ -168,6 +193,9 @@ func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
 				v = w
 				v_used = v.used
 				v.usable = true
+				v.invalidatedBy = ""
+				v.failedBy = ""
+				check.forgetInits(v)
 				if debugUsable {
 					fmt.Println("USABLE assignVar:", v.name, fmt.Sprintf("%p", v), v.usable)
 				}
@@ -175,6 +203,8 @@ func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
 		}
 	}
 
+	check.forgetInitedField(lhs)
+
 	var z operand
 	z.lhs = true
 	check.expr(&z, lhs)
@@ -237,6 +267,10 @@ func (check *Checker) checkVars(lhs []*Var, rhs *ast.ExprList, returnPos token.P
 				// a, b, c := f()
 				l = len(lhs)
 			}
+		} else if len(lhs) == 0 && entangledLhs != nil {
+			// return err, from func() \ error
+			l = 1
+			rhsIsEntangled = true
 		} else {
 			// a, b, c := x, y, z
 			l = len(lhs)
@@ -245,20 +279,20 @@ func (check *Checker) checkVars(lhs []*Var, rhs *ast.ExprList, returnPos token.P
 				l += 1
 			}
 		}
-	} else if rhs.EntangledPos == 1 {
-		// a, b \ c := \ z
+	} else if rhs.EntangledPos == len(rhs.List)+1 {
+		// a, b \ c := x, y \
 		if !returnPos.IsValid() {
 			check.error(rhs.List[0].Pos(), "right-hand side cannot be entangled in assignment")
 		}
 		rhsIsEntangled = true
-		l = 1
-	} else if rhs.EntangledPos == len(rhs.List)+1 {
-		// a, b \ c := x, y \
+		l = len(lhs)
+	} else if rhs.EntangledPos == 1 {
+		// a, b \ c := \ z
 		if !returnPos.IsValid() {
 			check.error(rhs.List[0].Pos(), "right-hand side cannot be entangled in assignment")
 		}
 		rhsIsEntangled = true
-		l = len(lhs)
+		l = 1
 	} else if len(rhs.List) > 0 {
 		rhsIsEntangled = true
 		check.error(rhs.List[0].Pos(), "must have values at either side of \\, not both")
@@ -268,11 +302,13 @@ func (check *Checker) checkVars(lhs []*Var, rhs *ast.ExprList, returnPos token.P
 		check.error(lhs[0].Pos(), "expected entangled assignment, but left-hand side is not entangled")
 	}
 
-	allowCommaOk := l == 2 && entangledLhs != nil && !returnPos.IsValid()
+	allowCommaOk := l == 2 && !returnPos.IsValid()
 
+	var commaOkExpr operand
 	get, r, commaOk := unpack(func(x *operand, i int) {
 		if allowCommaOk {
 			check.rhsMultiExpr(x, rhs.List[i])
+			commaOkExpr = *x
 		} else {
 			check.multiExpr(x, rhs.List[i])
 		}
@@ -310,8 +346,12 @@ func (check *Checker) checkVars(lhs []*Var, rhs *ast.ExprList, returnPos token.P
 
 	var x operand
 	if commaOk {
+		if entangledLhs == nil {
+			check.plainCommaOk(&commaOkExpr)
+		} else {
+			lhs = []*Var{lhs[0], entangledLhs}
+		}
 		var a [2]Type
-		lhs := []*Var{lhs[0], entangledLhs}
 		for i := range a {
 			get(&x, i)
 			a[i] = setVar(i, lhs[i], &x, context)
@@ -324,16 +364,31 @@ func (check *Checker) checkVars(lhs []*Var, rhs *ast.ExprList, returnPos token.P
 		if v == nil {
 			continue
 		}
-		if rhs.EntangledPos == 1 && i != len(lhs) {
-			continue
-		} else if rhs.EntangledPos == len(lhs)+1 && i == len(lhs) {
+		j := i
+		if rhs.EntangledPos == 1 && len(rhs.List) > 0 {
+			// Only the entangled value is on the right-hand side.
+			if i != len(lhs) {
+				continue
+			}
+			j = 0
+		} else if rhs.EntangledPos == len(rhs.List)+1 && i == len(lhs) {
 			continue
 		}
-		get(&x, i)
+		get(&x, j)
 		setVar(i, v, &x, context)
 	}
 }
 
+// plainCommaOk checks x, a map index, channel receive or type assertion whose
+// value and boolean are assigned to two variables, as in Go, instead of to an
+// entangled pair. If the operation fails, the value is the zero value of its
+// type, so that's only allowed for types that have one in SGo.
+func (check *Checker) plainCommaOk(x *operand) {
+	if x.mode != invalid && IsOptionable(x.typ) {
+		check.errorf(x.pos(), "%s cannot be used as value directly; requires entangled assignment", x)
+	}
+}
+
 func (check *Checker) assignVars(lhs, rhs *ast.ExprList) {
 	entangledPos := lhs.EntangledPos
 
@@ -380,8 +435,9 @@ func (check *Checker) assignVars(lhs, rhs *ast.ExprList) {
 
 			if entangledLhs != nil {
 				entangledLhs.collapses = lhsVars
+				check.setFailedBy(entangledLhs, rhs)
 				for _, v := range lhsVars {
-					v.usable = false
+					v.usable = zeroOnFailure(v, entangledLhs)
 					if debugUsable {
 						fmt.Println("USABLE assignVars:", v.name, fmt.Sprintf("%p", v), v.usable)
 					}
@@ -392,7 +448,11 @@ func (check *Checker) assignVars(lhs, rhs *ast.ExprList) {
 	}
 
 	l := lhs.Len()
-	get, r, commaOk := unpack(func(x *operand, i int) { check.rhsMultiExpr(x, rhs.List[i]) }, rhs.Len(), l == 2)
+	var commaOkExpr operand
+	get, r, commaOk := unpack(func(x *operand, i int) {
+		check.rhsMultiExpr(x, rhs.List[i])
+		commaOkExpr = *x
+	}, rhs.Len(), l == 2)
 	if get == nil {
 		check.useLHS(lhs.List...)
 		return // error reported by unpack
@@ -405,6 +465,7 @@ func (check *Checker) assignVars(lhs, rhs *ast.ExprList) {
 
 	var x operand
 	if commaOk {
+		check.plainCommaOk(&commaOkExpr)
 		var a [2]Type
 		for i := range a {
 			get(&x, i)
@@ -440,7 +501,7 @@ func (check *Checker) shortVarDecl(pos token.Pos, lhs, rhs *ast.ExprList) {
 			// must use Scope.Lookup here and call Scope.Insert
 			// (via check.declare) later.
 			name := ident.Name
-			if alt := scope.Lookup(name); alt != nil {
+			if alt := scope.Lookup(name); alt != nil && !isNarrowedCopy(alt) {
 				// redeclared object must be a variable
 				if alt, _ := alt.(*Var); alt != nil {
 					obj = alt
@@ -490,11 +551,28 @@ func (check *Checker) shortVarDecl(pos token.Pos, lhs, rhs *ast.ExprList) {
 
 	if entangledLhs != nil {
 		entangledLhs.collapses = lhsVars
+		check.setFailedBy(entangledLhs, rhs)
 		for _, v := range lhsVars {
-			v.usable = false
+			v.usable = zeroOnFailure(v, entangledLhs)
 			if debugUsable {
 				fmt.Println("USABLE shortVarDecl:", v.name, fmt.Sprintf("%p", v), v.usable)
 			}
 		}
 	}
 }
+
+// zeroOnFailure reports whether v, entangled with failed, is usable before
+// failed is checked. By Go convention, a function that returns an error
+// returns the zero value for its other results along with it, so v is usable
+// if its type has a zero value, like an int or a struct without pointers;
+// otherwise, like for a pointer, it's only usable once the error is known to
+// be nil.
+//
+// Values entangled with a bool, like in comma-ok assignments, must still be
+// checked, as they are often meaningless when the bool is false.
+func zeroOnFailure(v, failed *Var) bool {
+	if v.typ == nil || failed.typ == nil || isBoolean(failed.typ) {
+		return false
+	}
+	return hasZeroValue2(v.typ, nil, func([]string) {})
+}
@@ -139,7 +139,16 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 		mode := invalid
 		var typ Type
 		var val constant.Value
-		switch typ = implicitArrayDeref(x.typ.Underlying()); t := typ.(type) {
+		typ = implicitArrayDeref(x.typ.Underlying())
+		// The length and capacity of nil maps and channels are 0, so
+		// optional ones are fine too.
+		if opt, ok := typ.(*Optional); ok {
+			switch elem := opt.elem.Underlying().(type) {
+			case *Map, *Chan:
+				typ = elem
+			}
+		}
+		switch t := typ.(type) {
 		case *Basic:
 			if isString(t) && id == _Len {
 				if x.mode == constant_ {
@@ -60,6 +60,31 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 			x.expr = e
 			return statement
 		}
+		if sig.noReturn != Returns {
+			if check.noReturnCalls == nil {
+				check.noReturnCalls = map[*ast.CallExpr]bool{}
+			}
+			check.noReturnCalls[e] = true
+		}
+		if sig.fails {
+			if check.failingCalls == nil {
+				check.failingCalls = map[*ast.CallExpr]bool{}
+			}
+			check.failingCalls[e] = true
+		}
+
+		for _, i := range sig.narrows {
+			// The function only stores a non-nil value through the pointer,
+			// as errors.As does; see narrowingCallSideEffects.
+			if i < len(e.Args) {
+				if addr, ok := unparen(e.Args[i]).(*ast.UnaryExpr); ok && addr.Op == token.AND {
+					if check.narrowedAddrs == nil {
+						check.narrowedAddrs = map[ast.Expr]bool{}
+					}
+					check.narrowedAddrs[addr] = true
+				}
+			}
+		}
 
 		arg, n, _ := unpack(func(x *operand, i int) { check.multiExpr(x, e.Args[i]) }, len(e.Args), false)
 		if arg != nil {
@@ -67,11 +92,21 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 		} else {
 			x.mode = invalid
 		}
+		if len(sig.invalidates) > 0 && e != check.suspended {
+			check.invalidateArgs(e, sig)
+		}
+		check.recordInits(e, sig)
 
 		// determine result
 		switch sig.results.Len() {
 		case 0:
-			x.mode = novalue
+			if e := sig.results.Entangled(); e != nil {
+				// func() \ error
+				x.mode = value
+				x.typ = e.typ
+			} else {
+				x.mode = novalue
+			}
 		case 1:
 			x.mode = value
 			if sig.results.entangled == nil {
@@ -416,6 +451,9 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 			params:   NewTuple(append([]*Var{NewVar(token.NoPos, check.pkg, "", x.typ)}, params...)...),
 			results:  sig.results,
 			variadic: sig.variadic,
+			noReturn: sig.noReturn,
+			nonEmpty: sig.nonEmpty,
+			fails:    sig.fails,
 		}
 
 		check.addDeclDep(m)
@@ -431,6 +469,9 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 				x.mode = value
 			}
 			x.typ = obj.typ
+			if opt, ok := obj.typ.(*Optional); ok && check.isInitedField(e) {
+				x.typ = opt.elem
+			}
 
 		case *Func:
 			// TODO(gri) If we needed to take into account the receiver's
@@ -92,6 +92,12 @@ type Checker struct {
 	funcs    []funcInfo            // list of functions to type-check
 	delayed  []func()              // delayed checks requiring fully setup types
 
+	noReturnCalls map[*ast.CallExpr]bool // calls to functions that never return
+	failingCalls  map[*ast.CallExpr]bool // calls to functions that always fail
+	inits         []initedField          // optional fields known not to be nil
+	narrowedAddrs map[ast.Expr]bool      // &v arguments to narrowing functions, which don't alias v
+	suspended     *ast.CallExpr          // call in the go or defer statement being checked
+
 	// context within which the current object is type-checked
 	// (valid only for the duration of type-checking a specific object)
 	context
@@ -192,6 +198,10 @@ func (check *Checker) initFiles(files []*ast.File) {
 	check.untyped = nil
 	check.funcs = nil
 	check.delayed = nil
+	check.noReturnCalls = nil
+	check.failingCalls = nil
+	check.inits = nil
+	check.narrowedAddrs = nil
 
 	// determine package name and collect valid files
 	pkg := check.pkg
@@ -349,6 +359,14 @@ func (check *Checker) recordUse(id *ast.Ident, obj Object) {
 	}
 }
 
+func (check *Checker) recordNarrowing(cond ast.Expr, n *Narrowing) {
+	assert(cond != nil)
+	assert(n != nil)
+	if m := check.Narrowings; m != nil {
+		m[cond] = append(m[cond], n)
+	}
+}
+
 func (check *Checker) recordImplicit(node ast.Node, obj Object) {
 	assert(node != nil)
 	assert(obj != nil)
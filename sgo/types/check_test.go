@@ -90,6 +90,17 @@ var tests = [][]string{
 	{"testdata/labels.src"},
 	{"testdata/issues.src"},
 	{"testdata/sgoissues.src"},
+	{"testdata/sgonarrowing.src"},
+	{"testdata/sgoerroronly.src"},
+	{"testdata/sgoslices.src"},
+	{"testdata/sgolen.src"},
+	{"testdata/sgorangefunc.src"},
+	{"testdata/sgocommaok.src"},
+	{"testdata/sgopkgvars.src"},
+	{"testdata/sgoforce.src"},
+	{"testdata/sgonilinterface.src"},
+	{"testdata/sgozeroonerror.src"},
+	{"testdata/sgoguards.src"},
 	{"testdata/blank.src"},
 }
 
@@ -257,7 +268,7 @@ func checkFiles(t *testing.T, testfiles []string) {
 	if len(testfiles) == 1 && testfiles[0] == "testdata/importC.src" {
 		conf.FakeImportC = true
 	}
-	if len(testfiles) == 1 && testfiles[0] == "testdata/sgoissues.src" {
+	if len(testfiles) == 1 && strings.HasPrefix(testfiles[0], "testdata/sgo") {
 		conf.AllowUseUninitializedVars = false
 		conf.AllowUninitializedExprs = false
 	}
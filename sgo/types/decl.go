@@ -27,7 +27,12 @@ func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token
 	// identifier but the declaration does not introduce a new
 	// binding."
 	if obj.Name() != "_" {
-		if alt := scope.Insert(obj); alt != nil {
+		if alt := scope.Insert(obj); isNarrowedCopy(alt) {
+			// The block declares its own variable, shadowing the outer one
+			// it narrowed.
+			scope.elems[obj.Name()] = obj
+			obj.setParent(scope)
+		} else if alt != nil {
 			check.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
 			check.reportAltDecl(alt)
 			return
@@ -233,8 +238,9 @@ func (check *Checker) varDecl(obj *Var, lhs []*Var, entangledLhs *Var, typ, init
 
 	if entangledLhs != nil {
 		entangledLhs.collapses = lhs
+		check.setFailedBy(entangledLhs, &ast.ExprList{List: []ast.Expr{init}})
 		for _, v := range lhs {
-			v.usable = false
+			v.usable = zeroOnFailure(v, entangledLhs)
 			if debugUsable {
 				fmt.Println("USABLE varDecl2:", v.name, fmt.Sprintf("%p", v), v.usable)
 			}
@@ -378,7 +384,7 @@ func (check *Checker) funcDecl(obj *Func, decl *declInfo) {
 	obj.typ = sig // guard against cycles
 	fdecl := decl.fdecl
 	check.funcType(sig, fdecl.Recv, fdecl.Type)
-	if sig.recv == nil && obj.name == "init" && (sig.params.Len() > 0 || sig.results.Len() > 0) {
+	if sig.recv == nil && obj.name == "init" && (sig.params.Len() > 0 || !sig.results.empty()) {
 		check.errorf(fdecl.Pos(), "func init must have no arguments and no return values")
 		// ok to continue
 	}
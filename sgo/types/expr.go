@@ -1506,7 +1506,7 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 		if x.mode == invalid {
 			goto Error
 		}
-		if e.Op == token.AND && x.mode == variable {
+		if e.Op == token.AND && x.mode == variable && !check.narrowedAddrs[e] {
 			_, obj := check.scope.LookupParent(e.X.(*ast.Ident).Name, check.pos)
 			if v, ok := obj.(*Var); ok {
 				v.aliased = true
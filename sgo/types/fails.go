@@ -0,0 +1,18 @@
+package types
+
+import "github.com/tcard/sgo/sgo/ast"
+
+// This file implements the tracking of entangled errors from calls to
+// functions that always fail; see (*Signature).SetFails.
+
+// setFailedBy records in the entangled variable v whether it was just assigned
+// from rhs, a call to a function that always fails.
+func (check *Checker) setFailedBy(v *Var, rhs *ast.ExprList) {
+	v.failedBy = ""
+	if len(rhs.List) != 1 {
+		return
+	}
+	if call, ok := unparen(rhs.List[0]).(*ast.CallExpr); ok && check.failingCalls[call] {
+		v.failedBy = ExprString(call.Fun)
+	}
+}
@@ -0,0 +1,103 @@
+package types
+
+import "github.com/tcard/sgo/sgo/ast"
+
+// This file implements the tracking of optional fields that methods like
+// GetOrInit make non-nil; see (*Signature).SetInits.
+
+// An initedField is an optional field of a local variable known not to be nil
+// in the rest of the block in which it was initialized.
+type initedField struct {
+	v     *Var
+	field string
+	scope *Scope
+}
+
+// recordInits updates which fields are known to be initialized after call, a
+// call to a function with signature sig. Calls might change any field of the
+// local variables they take as receiver or argument, so those are forgotten;
+// then, the fields that sig initializes in the receiver are recorded.
+func (check *Checker) recordInits(call *ast.CallExpr, sig *Signature) {
+	var recv *Var
+	if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok {
+		recv = check.localVar(sel.X)
+		check.forgetInits(recv)
+	}
+	for _, arg := range call.Args {
+		check.forgetInits(check.localVar(arg))
+	}
+	if recv == nil || call == check.suspended {
+		return
+	}
+	for _, field := range sig.inits {
+		check.inits = append(check.inits, initedField{v: recv, field: field, scope: check.scope})
+	}
+}
+
+// forgetInits forgets the fields of v known to be initialized.
+func (check *Checker) forgetInits(v *Var) {
+	if v == nil {
+		return
+	}
+	inits := check.inits[:0]
+	for _, in := range check.inits {
+		if in.v != v {
+			inits = append(inits, in)
+		}
+	}
+	check.inits = inits
+}
+
+// forgetInitedField forgets that the field selected by e, if any, is known to
+// be initialized, as it's about to be assigned.
+func (check *Checker) forgetInitedField(e ast.Expr) {
+	sel, ok := unparen(e).(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	v := check.localVar(sel.X)
+	inits := check.inits[:0]
+	for _, in := range check.inits {
+		if in.v != v || in.field != sel.Sel.Name {
+			inits = append(inits, in)
+		}
+	}
+	check.inits = inits
+}
+
+// isInitedField reports whether the field selected by e is known to be
+// initialized here, because it was in this block or an enclosing one. Function
+// literals are checked after their enclosing function, so fields initialized
+// there don't count in them.
+func (check *Checker) isInitedField(e *ast.SelectorExpr) bool {
+	v := check.localVar(e.X)
+	if v == nil {
+		return false
+	}
+	for _, in := range check.inits {
+		if in.v != v || in.field != e.Sel.Name || in.scope.sig != check.scope.sig {
+			continue
+		}
+		for s := check.scope; s != nil; s = s.parent {
+			if s == in.scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// localVar returns the variable of this package that e refers to, if it's an
+// identifier.
+func (check *Checker) localVar(e ast.Expr) *Var {
+	id, ok := unparen(e).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	_, obj := check.scope.LookupParent(id.Name, check.pos)
+	v, ok := obj.(*Var)
+	if !ok || v.pkg != check.pkg {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package types
+
+import "github.com/tcard/sgo/sgo/ast"
+
+// This file implements the tracking of variables invalidated by calls to
+// functions like Close or Free; see (*Signature).SetInvalidates.
+
+// invalidateArgs marks as invalid the local variables passed to call as
+// arguments that sig invalidates.
+func (check *Checker) invalidateArgs(call *ast.CallExpr, sig *Signature) {
+	for _, i := range sig.invalidates {
+		var arg ast.Expr
+		if i == -1 {
+			sel, ok := unparen(call.Fun).(*ast.SelectorExpr)
+			if !ok {
+				continue
+			}
+			arg = sel.X
+		} else if i < len(call.Args) {
+			arg = call.Args[i]
+		} else {
+			continue
+		}
+		id, ok := unparen(arg).(*ast.Ident)
+		if !ok {
+			continue
+		}
+		scope, obj := check.scope.LookupParent(id.Name, check.pos)
+		v, ok := obj.(*Var)
+		// Variables from enclosing functions may be used before or after the
+		// closure that invalidates them is called; we can't tell.
+		if !ok || v.pkg != check.pkg || scope.sig != check.scope.sig {
+			continue
+		}
+		v.invalidatedBy = ExprString(call.Fun)
+	}
+}
+
+// saveInvalidated returns which of vars are currently invalidated, and by which
+// call.
+func saveInvalidated(vars map[*Var]bool) map[*Var]string {
+	saved := map[*Var]string{}
+	for v := range vars {
+		saved[v] = v.invalidatedBy
+	}
+	return saved
+}
+
+// restoreInvalidated undoes the invalidations done since saved.
+func restoreInvalidated(saved map[*Var]string) {
+	for v, by := range saved {
+		v.invalidatedBy = by
+	}
+}
+
+// mergeInvalidated makes invalid the variables invalidated by any of the
+// given paths through the code, each as saved by saveInvalidated.
+func mergeInvalidated(vars map[*Var]bool, paths []map[*Var]string) {
+	for v := range vars {
+		v.invalidatedBy = ""
+		for _, path := range paths {
+			if by := path[v]; by != "" {
+				v.invalidatedBy = by
+				break
+			}
+		}
+	}
+}
+
+// branchTerminates reports whether the code after s is unreachable from s,
+// because it's a terminating statement or it ends calling a function that
+// doesn't return.
+func (check *Checker) branchTerminates(s ast.Stmt) bool {
+	if check.isTerminating(s, "") {
+		return true
+	}
+	block, ok := s.(*ast.BlockStmt)
+	if !ok || len(block.List) == 0 {
+		return false
+	}
+	last, ok := block.List[len(block.List)-1].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := unparen(last.X).(*ast.CallExpr)
+	return ok && check.isNoReturnCall(call)
+}
@@ -203,6 +203,10 @@ type Var struct {
 	usable    bool // true; but false for refs and left-hand entangled, and then set to true when assigned or collaped
 	aliased   bool // referenced by a pointer, or captured by closure
 	collapses []*Var
+	narrowed  bool // a copy of a variable from an outer scope, narrowed for the rest of a block
+
+	invalidatedBy string // the call that invalidated the variable, if any
+	failedBy      string // the always failing call the entangled variable was assigned from, if any
 }
 
 // NewVar returns a new variable.
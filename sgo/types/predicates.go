@@ -82,6 +82,16 @@ func IsOptionable(typ Type) bool {
 	return IsInterface(typ) || isMap(typ) || isPointer(typ) || isSignature(typ) || isChan(typ)
 }
 
+// CanBeEmpty reports whether typ is a string or slice type, whose values may
+// be empty, as told by a @nonempty annotation directive.
+func CanBeEmpty(typ Type) bool {
+	if isString(typ) {
+		return true
+	}
+	_, ok := typ.Underlying().(*Slice)
+	return ok
+}
+
 func isChan(typ Type) bool {
 	_, ok := typ.Underlying().(*Chan)
 	return ok
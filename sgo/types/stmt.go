@@ -80,7 +80,7 @@ func (check *Checker) funcBody(decl *declInfo, name string, sig *Signature, body
 		check.labels(body)
 	}
 
-	if sig.results.Len() > 0 && !check.isTerminating(body, "") {
+	if !sig.results.empty() && !check.isTerminating(body, "") {
 		check.error(body.Rbrace, "missing return")
 	}
 
@@ -155,6 +155,7 @@ func (check *Checker) stmtList(ctxt stmtContext, list []ast.Stmt) {
 			inner |= fallthroughOk
 		}
 		check.stmt(inner, s)
+		check.forceNarrowings(s)
 	}
 }
 
@@ -203,6 +204,11 @@ func assignOp(op token.Token) token.Token {
 }
 
 func (check *Checker) suspendedCall(keyword string, call *ast.CallExpr) {
+	// The call happens later, so it doesn't invalidate its arguments now.
+	outer := check.suspended
+	check.suspended = call
+	defer func() { check.suspended = outer }()
+
 	var x operand
 	var msg string
 	switch check.rawExpr(&x, call, nil) {
@@ -473,10 +479,14 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 
 	case *ast.ReturnStmt:
 		res := check.sig.results
-		if res.Len() > 0 {
+		if !res.empty() {
 			// function returns results
 			// (if one, say the first, result parameter is named, all of them are named)
-			if len(s.Results.List) == 0 && res.vars[0].name != "" {
+			first := res.entangled
+			if res.Len() > 0 {
+				first = res.vars[0]
+			}
+			if len(s.Results.List) == 0 && s.Results.EntangledPos == 0 && first.name != "" {
 				if res.entangled != nil && isBoolean(res.entangled.typ) {
 					check.errorf(s.Pos(), "empty return statement not allowed with entangled bool return values")
 				}
@@ -505,6 +515,8 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 		} else if len(s.Results.List) > 0 {
 			check.error(s.Results.List[0].Pos(), "no result values expected")
 			check.use(s.Results.List...)
+		} else if s.Results.EntangledPos > 0 {
+			check.error(s.Pos(), "no result values expected")
 		}
 
 	case *ast.BranchStmt:
@@ -564,11 +576,21 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 			sc = sc.Parent()
 		}
 
+		// Variables invalidated in a branch that doesn't terminate stay
+		// invalid after the if.
+		wereInvalidated := saveInvalidated(wereUsable)
+		var invalidatedPaths []map[*Var]string
+
 		check.openScope(&ast.BadStmt{}, "ifBody")
-		collapsed := check.handleEffs(effs, false, check.scope)
+		collapsed := check.handleEffs(effs, false, check.scope, s.Cond, s.Body.Pos(), s.Body.End())
 		check.stmt(inner, s.Body)
 		check.closeScope()
 
+		if !check.branchTerminates(s.Body) {
+			invalidatedPaths = append(invalidatedPaths, saveInvalidated(wereUsable))
+		}
+		restoreInvalidated(wereInvalidated)
+
 		// The parser produces a correct AST but if it was modified
 		// elsewhere the else branch may be invalid. Check again.
 		switch s.Else.(type) {
@@ -600,10 +622,14 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 
 		if s.Else != nil {
 			check.openScope(&ast.BadStmt{}, "elseBody")
-			collapsed = check.handleEffs(effs, true, check.scope)
+			collapsed = check.handleEffs(effs, true, check.scope, s.Cond, s.Else.Pos(), s.Else.End())
 			check.stmt(inner, s.Else)
 			check.closeScope()
 
+			if !check.branchTerminates(s.Else) {
+				invalidatedPaths = append(invalidatedPaths, saveInvalidated(wereUsable))
+			}
+
 			for v, wasUsable := range wereUsable {
 				if !(v.usable && usableAfterBody[v]) {
 					v.usable = wasUsable
@@ -621,6 +647,11 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 			}
 		}
 
+		if s.Else == nil {
+			invalidatedPaths = append(invalidatedPaths, wereInvalidated)
+		}
+		mergeInvalidated(wereUsable, invalidatedPaths)
+
 		if len(s.Body.List) > 0 {
 			lastStmt := s.Body.List[len(s.Body.List)-1]
 			switch lastStmt := lastStmt.(type) {
@@ -628,20 +659,26 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 				if debugUsable {
 					fmt.Println("USABLE if.body returns, so simulate that rest of the statements are in else")
 				}
-				check.handleEffs(effs, true, check.scope.parent)
-			case *ast.ExprStmt:
-				call, ok := lastStmt.X.(*ast.CallExpr)
-				if !ok {
+				check.handleEffs(effs, true, check.scope.parent, s.Cond, s.End(), check.scope.parent.end)
+			case *ast.BranchStmt:
+				// break and continue skip the rest of the enclosing block, like
+				// return. goto might jump to a label further in it.
+				if lastStmt.Tok != token.BREAK && lastStmt.Tok != token.CONTINUE {
 					break
 				}
-				fun, ok := call.Fun.(*ast.Ident)
-				if !ok || fun.Name != "panic" {
+				if debugUsable {
+					fmt.Println("USABLE if.body breaks or continues, so simulate that rest of the statements are in else")
+				}
+				check.handleEffs(effs, true, check.scope.parent, s.Cond, s.End(), check.scope.parent.end)
+			case *ast.ExprStmt:
+				call, ok := lastStmt.X.(*ast.CallExpr)
+				if !ok || !check.isNoReturnCall(call) {
 					break
 				}
 				if debugUsable {
-					fmt.Println("USABLE if.body panics, so simulate that rest of the statements are in else")
+					fmt.Println("USABLE if.body panics or doesn't return, so simulate that rest of the statements are in else")
 				}
-				check.handleEffs(effs, true, check.scope.parent)
+				check.handleEffs(effs, true, check.scope.parent, s.Cond, s.End(), check.scope.parent.end)
 			}
 		}
 
@@ -934,6 +971,32 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 					check.errorf(s.Value.Pos(), "iteration over %s permits only one iteration variable", &x)
 					// ok to continue
 				}
+			case *Signature:
+				// Range over function iterators, as in Go 1.23. The
+				// iteration variables have the types of the yield
+				// function's parameters, so optional ones need to be
+				// checked before using them in the body.
+				yield := rangeFuncYield(typ)
+				if yield == nil {
+					break
+				}
+				key, val = Typ[Invalid], Typ[Invalid]
+				switch yield.params.Len() {
+				case 0:
+					if s.Key != nil {
+						check.errorf(s.Key.Pos(), "iteration over %s permits no iteration variables", &x)
+						// ok to continue
+					}
+				case 1:
+					key = yield.params.vars[0].typ
+					if s.Value != nil {
+						check.errorf(s.Value.Pos(), "iteration over %s permits only one iteration variable", &x)
+						// ok to continue
+					}
+				default:
+					key = yield.params.vars[0].typ
+					val = yield.params.vars[1].typ
+				}
 			}
 		}
 
@@ -1022,10 +1085,28 @@ func (check *Checker) stmt(ctxt stmtContext, s ast.Stmt) {
 	}
 }
 
+// rangeFuncYield returns the type of the yield function that sig takes, if sig
+// is the type of a function iterator: func(yield func(...) bool), where the
+// yield function has at most two parameters.
+func rangeFuncYield(sig *Signature) *Signature {
+	if sig.params.Len() != 1 || sig.results.Len() != 0 || sig.variadic {
+		return nil
+	}
+	yield, ok := sig.params.vars[0].typ.Underlying().(*Signature)
+	if !ok || yield.params.Len() > 2 || yield.results.Len() != 1 || yield.variadic || !isBoolean(yield.results.vars[0].typ) {
+		return nil
+	}
+	return yield
+}
+
 type ifCondSideEffect struct {
 	ident       *ast.Ident
 	typ         Type
 	isNilOrTrue bool
+	// oneWay is set if the condition only tells that the variable is not
+	// nil when it has the corresponding value, but doesn't tell that it is nil
+	// otherwise.
+	oneWay bool
 }
 
 // unwrappedOptionals looks up in a boolean expression all the variables of
@@ -1046,10 +1127,15 @@ func (checker *Checker) ifCondSideEffects(x operand) []ifCondSideEffect {
 				isNilOrTrue: true,
 			})
 		}
+	case *ast.CallExpr:
+		effs = append(effs, checker.narrowingCallSideEffects(v, false)...)
 	case *ast.UnaryExpr:
 		if v.Op != token.NOT {
 			return effs
 		}
+		if call, ok := v.X.(*ast.CallExpr); ok {
+			return append(effs, checker.narrowingCallSideEffects(call, true)...)
+		}
 		id, ok := v.X.(*ast.Ident)
 		if !ok {
 			return effs
@@ -1064,6 +1150,9 @@ func (checker *Checker) ifCondSideEffects(x operand) []ifCondSideEffect {
 			})
 		}
 	case *ast.BinaryExpr:
+		if eff, ok := checker.lenComparisonSideEffect(v); ok {
+			return append(effs, eff)
+		}
 		if v.Op != token.EQL && v.Op != token.NEQ {
 			return effs
 		}
@@ -1073,6 +1162,10 @@ func (checker *Checker) ifCondSideEffects(x operand) []ifCondSideEffect {
 		checker.expr(&xOp, v.X)
 		checker.expr(&yOp, v.Y)
 
+		if eff, ok := checker.nonNilComparisonSideEffect(v, xOp, yOp); ok {
+			return append(effs, eff)
+		}
+
 		xId, ok := v.X.(*ast.Ident)
 		if !ok {
 			return effs
@@ -1106,12 +1199,204 @@ func (checker *Checker) ifCondSideEffects(x operand) []ifCondSideEffect {
 	return effs
 }
 
-func (check *Checker) handleEffs(effs []ifCondSideEffect, inElse bool, sc *Scope) []*Var {
+// nonNilComparisonSideEffect returns the side effect of comparing an optional
+// variable with a value that can't be nil, such as a sentinel error like
+// io.EOF. If they are equal, the variable isn't nil either.
+func (checker *Checker) nonNilComparisonSideEffect(cmp *ast.BinaryExpr, xOp, yOp operand) (ifCondSideEffect, bool) {
+	if xOp.mode == invalid || yOp.mode == invalid {
+		return ifCondSideEffect{}, false
+	}
+	x := cmp.X
+	if !isOptional(xOp.typ) {
+		x, xOp, yOp = cmp.Y, yOp, xOp
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return ifCondSideEffect{}, false
+	}
+	if !isOptional(xOp.typ) || yOp.isNil() || isOptional(yOp.typ) || !IsOptionable(yOp.typ) || checker.isAliasedVar(id) {
+		return ifCondSideEffect{}, false
+	}
+	return ifCondSideEffect{
+		ident:       id,
+		typ:         xOp.typ.Underlying().(*Optional).elem,
+		isNilOrTrue: cmp.Op == token.NEQ,
+		oneWay:      true,
+	}, true
+}
+
+// lenComparisonSideEffect returns the side effect of comparing the length or
+// capacity of an optional map or channel with zero, as in len(m) > 0. If it
+// isn't zero, the variable isn't nil.
+func (checker *Checker) lenComparisonSideEffect(cmp *ast.BinaryExpr) (ifCondSideEffect, bool) {
+	call, zero, op := cmp.X, cmp.Y, cmp.Op
+	if _, ok := unparen(call).(*ast.CallExpr); !ok {
+		call, zero = cmp.Y, cmp.X
+		if op == token.LSS {
+			op = token.GTR
+		} else if op == token.GTR {
+			op = token.LSS
+		}
+	}
+	if op != token.GTR && op != token.NEQ && op != token.EQL {
+		return ifCondSideEffect{}, false
+	}
+
+	c, ok := unparen(call).(*ast.CallExpr)
+	if !ok || len(c.Args) != 1 || !checker.isLenOrCap(c.Fun) {
+		return ifCondSideEffect{}, false
+	}
+	var z operand
+	checker.expr(&z, zero)
+	if z.mode != constant_ || z.val.Kind() != constant.Int || constant.Sign(z.val) != 0 {
+		return ifCondSideEffect{}, false
+	}
+
+	id, ok := unparen(c.Args[0]).(*ast.Ident)
+	if !ok || checker.isAliasedVar(id) {
+		return ifCondSideEffect{}, false
+	}
+	var arg operand
+	checker.expr(&arg, id)
+	if arg.mode == invalid || !isOptional(arg.typ) {
+		return ifCondSideEffect{}, false
+	}
+	return ifCondSideEffect{
+		ident:       id,
+		typ:         arg.typ.Underlying().(*Optional).elem,
+		isNilOrTrue: op == token.EQL,
+		oneWay:      true,
+	}, true
+}
+
+// isLenOrCap reports whether fun refers to the len or cap built-ins.
+func (checker *Checker) isLenOrCap(fun ast.Expr) bool {
+	id, ok := unparen(fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, obj := checker.scope.LookupParent(id.Name, token.NoPos)
+	b, ok := obj.(*Builtin)
+	return ok && (b.id == _Len || b.id == _Cap)
+}
+
+// narrowingCallSideEffects returns the side effects of a call to a function
+// whose signature narrows some of its arguments, ie. the arguments are known
+// not to be nil if the call returns true. If negated, the call is known to have
+// returned false instead.
+func (checker *Checker) narrowingCallSideEffects(call *ast.CallExpr, negated bool) []ifCondSideEffect {
+	var effs []ifCondSideEffect
+	var fun operand
+	checker.expr(&fun, call.Fun)
+	if fun.mode == invalid {
+		return effs
+	}
+	sig, ok := fun.typ.Underlying().(*Signature)
+	if !ok || len(sig.narrows) == 0 {
+		return effs
+	}
+	for _, i := range sig.narrows {
+		if i >= len(call.Args) {
+			continue
+		}
+		e := unparen(call.Args[i])
+		if addr, ok := e.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+			// &v, as the target of errors.As: the function stores a non-nil
+			// value in v if it returns true.
+			e = unparen(addr.X)
+		}
+		id, ok := e.(*ast.Ident)
+		if !ok || checker.isAliasedVar(id) {
+			continue
+		}
+		var arg operand
+		checker.expr(&arg, id)
+		if !isOptional(arg.typ) {
+			continue
+		}
+		effs = append(effs, ifCondSideEffect{
+			ident:       id,
+			typ:         arg.typ.Underlying().(*Optional).elem,
+			isNilOrTrue: negated,
+			oneWay:      true,
+		})
+	}
+	return effs
+}
+
+// forceNarrowings narrows, for the rest of the block, the optional variables
+// that the simple statement s forces with v!, which panics if v is nil. Forces
+// that might not run, in the right operand of && or || or in a function
+// literal, don't count, and neither do those of variables that s assigns to.
+func (check *Checker) forceNarrowings(s ast.Stmt) {
+	switch s.(type) {
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.IncDecStmt, *ast.SendStmt, *ast.DeclStmt, *ast.GoStmt, *ast.DeferStmt:
+	default:
+		return
+	}
+	assigned := map[string]bool{}
+	if s, ok := s.(*ast.AssignStmt); ok {
+		for _, lhs := range s.Lhs.List {
+			if id, ok := unparen(lhs).(*ast.Ident); ok {
+				assigned[id.Name] = true
+			}
+		}
+	}
+
+	var inspect func(n ast.Node) bool
+	inspect = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				ast.Inspect(n.X, inspect)
+				return false
+			}
+		case *ast.ForceExpr:
+			id, ok := unparen(n.X).(*ast.Ident)
+			if !ok || assigned[id.Name] || check.isAliasedVar(id) {
+				break
+			}
+			_, obj := check.scope.LookupParent(id.Name, token.NoPos)
+			if v, ok := obj.(*Var); ok && isOptional(v.typ) {
+				effs := []ifCondSideEffect{{ident: id, typ: v.typ.Underlying().(*Optional).elem}}
+				check.handleEffs(effs, false, check.scope, n, s.End(), check.scope.end)
+			}
+		}
+		return true
+	}
+	ast.Inspect(s, inspect)
+}
+
+// isNoReturnCall reports whether call is known to never return, either
+// because it panics or because its signature says so. Calls that terminate
+// only the current goroutine count too; the code after them is just as
+// unreachable from the current function.
+func (check *Checker) isNoReturnCall(call *ast.CallExpr) bool {
+	if fun, ok := unparen(call.Fun).(*ast.Ident); ok && fun.Name == "panic" {
+		return true
+	}
+	return check.noReturnCalls[call]
+}
+
+// handleEffs makes usable, in the scope sc, the variables unwrapped or
+// collapsed by the side effects of the if condition cond, which is known to be
+// false if inElse, and true otherwise. They are recorded as narrowings in the
+// code from pos to end.
+func (check *Checker) handleEffs(effs []ifCondSideEffect, inElse bool, sc *Scope, cond ast.Expr, pos, end token.Pos) []*Var {
 	var collapsed []*Var
 	for _, eff := range effs {
 		if (!inElse && eff.isNilOrTrue) || (inElse && !eff.isNilOrTrue) {
+			if eff.oneWay {
+				continue
+			}
 			_, v := sc.LookupParent(eff.ident.Name, token.NoPos)
 			if v, ok := v.(*Var); ok {
+				if v.failedBy != "" {
+					check.softErrorf(cond.Pos(), "unreachable code: %s always fails, so %s is never nil", v.failedBy, v.name)
+					v.failedBy = ""
+				}
 				for _, c := range v.collapses {
 					if !c.usable {
 						c.usable = true
@@ -1119,6 +1404,7 @@ func (check *Checker) handleEffs(effs []ifCondSideEffect, inElse bool, sc *Scope
 							fmt.Println("USABLE if-else unwrapped collapses:", fmt.Sprintf("(inElse: %v)", inElse), c.name, fmt.Sprintf("%p", c), c.usable)
 						}
 						collapsed = append(collapsed, c)
+						check.recordNarrowing(cond, &Narrowing{Var: c, Else: inElse, Pos: pos, End: end})
 					}
 				}
 			}
@@ -1129,11 +1415,13 @@ func (check *Checker) handleEffs(effs []ifCondSideEffect, inElse bool, sc *Scope
 				va = v
 			} else {
 				newVar := NewVar(-1, check.pkg, eff.ident.Name, eff.typ)
-				check.scope.Insert(newVar)
+				newVar.narrowed = true
+				sc.Insert(newVar)
 				va = newVar
 			}
 			va.usable = true
 			va.used = true
+			check.recordNarrowing(cond, &Narrowing{Var: va, Unwrapped: true, Else: inElse, Pos: pos, End: end})
 			if debugUsable {
 				fmt.Println("USABLE if-else unwrapped var:", fmt.Sprintf("(inElse: %v)", inElse), va.name, fmt.Sprintf("%p", va), va.usable)
 			}
@@ -1142,6 +1430,13 @@ func (check *Checker) handleEffs(effs []ifCondSideEffect, inElse bool, sc *Scope
 	return collapsed
 }
 
+// isNarrowedCopy reports whether obj is a variable that handleEffs inserted
+// in a block to narrow a variable from an outer scope.
+func isNarrowedCopy(obj Object) bool {
+	v, ok := obj.(*Var)
+	return ok && v.narrowed
+}
+
 func (c *Checker) isCollapserVar(id *ast.Ident) bool {
 	_, v := c.scope.LookupParent(id.Name, token.NoPos)
 	if v, ok := v.(*Var); ok {
@@ -4,7 +4,10 @@
 
 package types
 
-import "sort"
+import (
+	"fmt"
+	"sort"
+)
 
 // A Type represents a type of Go.
 // All types implement the Type interface.
@@ -106,6 +109,11 @@ func (a *Array) Len() int64 { return a.len }
 func (a *Array) Elem() Type { return a.elem }
 
 // An Optional represents an optional type.
+//
+// A non-nil value of optional interface type is known to hold a non-nil
+// value, unlike in Go, where an interface holding a nil pointer isn't nil.
+// That's why a value of optional non-interface type can't be assigned to an
+// interface type with methods; see NilInInterface.
 type Optional struct {
 	elem Type
 }
@@ -210,7 +218,15 @@ func (t *Tuple) Len() int {
 
 // Entangled returns the variable entangled to the rest of the tuple, or nil.
 func (t *Tuple) Entangled() *Var {
-	return t.entangled
+	if t != nil {
+		return t.entangled
+	}
+	return nil
+}
+
+// empty reports whether the tuple has no variables, not even an entangled one.
+func (t *Tuple) empty() bool {
+	return t.Len() == 0 && t.Entangled() == nil
 }
 
 // At returns the i'th variable of tuple t.
@@ -228,6 +244,19 @@ type Signature struct {
 	params   *Tuple // (incoming) parameters from left to right; or nil
 	results  *Tuple // (outgoing) results from left to right; or nil
 	variadic bool   // true if the last parameter's type is of the form ...T (or string, for append built-in only)
+	narrows  []int  // indices of optional parameters known to be non-nil if the function returns true
+	noReturn NoReturn
+	// indices of parameters unusable after a call; -1 is the receiver
+	invalidates []int
+	nonEmpty    bool // true if the string and slice results are never empty
+	fails       bool // true if the entangled error result is never nil
+	// names of the optional receiver fields that are never nil after a call
+	inits []string
+	// indices of parameters for which nil means a default value
+	nilDefaults []int
+	// indices of the format and arguments parameters of a printf-like
+	// function; nil if it isn't one
+	printf []int
 }
 
 // NewSignature returns a new function type for the given receiver, parameters,
@@ -244,7 +273,7 @@ func NewSignature(recv *Var, params, results *Tuple, variadic bool) *Signature {
 			panic("types.NewSignature: variadic parameter must be of unnamed slice type")
 		}
 	}
-	return &Signature{nil, recv, params, results, variadic}
+	return &Signature{scope: nil, recv: recv, params: params, results: results, variadic: variadic}
 }
 
 // Recv returns the receiver of signature s (if a method), or nil if a
@@ -264,6 +293,159 @@ func (s *Signature) Results() *Tuple { return s.results }
 // Variadic reports whether the signature s is variadic.
 func (s *Signature) Variadic() bool { return s.variadic }
 
+// Narrows returns the indices of the optional parameters of signature s that
+// are known not to be nil after a call to it returns true.
+func (s *Signature) Narrows() []int { return s.narrows }
+
+// SetNarrows sets the indices of the optional parameters of signature s that
+// are known not to be nil after a call to it returns true. A call to such a
+// function used as an if condition unwraps those arguments in the if body, as
+// a != nil comparison would. An argument &v, as errors.As's target, unwraps
+// v instead.
+func (s *Signature) SetNarrows(params ...int) { s.narrows = params }
+
+// Invalidates returns the indices of the parameters of signature s that must
+// not be used after being passed to a call to it. The index -1 stands for the
+// receiver.
+func (s *Signature) Invalidates() []int { return s.invalidates }
+
+// SetInvalidates sets the indices of the parameters of signature s that must
+// not be used after being passed to a call to it, as with a Close or Free
+// function. The index -1 stands for the receiver. A variable passed as such
+// an argument is invalid until it's assigned again.
+func (s *Signature) SetInvalidates(params ...int) { s.invalidates = params }
+
+// NoReturn tells whether calls to a function with signature s never return.
+func (s *Signature) NoReturn() NoReturn { return s.noReturn }
+
+// SetNoReturn sets whether calls to a function with signature s never return.
+// A call to such a function as the last statement of an if body is treated
+// like a return statement there: the code after the if is only reached if the
+// condition was false.
+func (s *Signature) SetNoReturn(k NoReturn) { s.noReturn = k }
+
+// NilDefaults returns the indices of the parameters of signature s for which
+// passing nil asks for a default value.
+func (s *Signature) NilDefaults() []int { return s.nilDefaults }
+
+// SetNilDefaults sets the indices of the parameters of signature s for which
+// passing nil asks for a default value, like an options struct. Those
+// parameters are made optional, if they aren't already, so callers can pass
+// nil, and function bodies must handle it. It panics if some of them can't be
+// optional.
+func (s *Signature) SetNilDefaults(params ...int) {
+	for _, i := range params {
+		v := s.params.vars[i]
+		if _, ok := v.typ.(*Optional); ok {
+			continue
+		}
+		if !IsOptionable(v.typ) {
+			panic("parameter " + v.name + " can't be nil")
+		}
+		v.typ = NewOptional(v.typ)
+	}
+	s.nilDefaults = params
+}
+
+// Printf returns the indices of the format string and variadic arguments
+// parameters of signature s, if it's a printf-like function.
+func (s *Signature) Printf() (format, args int, ok bool) {
+	if s.printf == nil {
+		return 0, 0, false
+	}
+	return s.printf[0], s.printf[1], true
+}
+
+// SetPrintf marks signature s as that of a printf-like function, whose
+// arguments parameter args is formatted according to the format parameter.
+// Formatting verbs like %v handle nil, so the arguments are made optional if
+// they aren't already; tools that check format strings, like go vet does, can
+// use the indices. It panics if format isn't a string parameter or args isn't
+// the final variadic one, or if its arguments can't be optional.
+func (s *Signature) SetPrintf(format, args int) {
+	if !s.variadic || args != s.params.Len()-1 {
+		panic("arguments parameter must be the final variadic one")
+	}
+	if !isString(s.params.vars[format].typ) {
+		panic("format parameter must be a string")
+	}
+	v := s.params.vars[args]
+	elem := v.typ.(*Slice).elem
+	if _, ok := elem.(*Optional); !ok {
+		if !IsOptionable(elem) {
+			panic("parameter " + v.name + " can't be nil")
+		}
+		v.typ = NewSlice(NewOptional(elem))
+	}
+	s.printf = []int{format, args}
+}
+
+// Fails reports whether a function with signature s always fails, that is, its
+// entangled error result is never nil.
+func (s *Signature) Fails() bool { return s.fails }
+
+// SetFails sets whether a function with signature s always fails, as a
+// deprecated stub kept only for compatibility might. The code that a call's
+// entangled results are usable in is then unreachable, and reported as such.
+// It panics if s has no entangled result.
+func (s *Signature) SetFails(fails bool) {
+	if fails && s.results.Entangled() == nil {
+		panic("signature has no entangled result")
+	}
+	s.fails = fails
+}
+
+// Inits returns the names of the optional fields of its receiver that a
+// method with signature s makes non-nil.
+func (s *Signature) Inits() []string { return s.inits }
+
+// SetInits sets the names of the optional fields of its receiver that a method
+// with signature s makes non-nil, like a GetOrInit method that lazily
+// initializes them. After a call to it on a local variable, those fields can
+// be used as non-optional in the rest of the block, until the variable or the
+// field is assigned, or the variable is passed to another call.
+func (s *Signature) SetInits(fields ...string) { s.inits = fields }
+
+// NonEmpty reports whether the string and slice results of a function with
+// signature s are known never to be empty.
+func (s *Signature) NonEmpty() bool { return s.nonEmpty }
+
+// SetNonEmpty sets whether the string and slice results of a function with
+// signature s are known never to be empty. This is experimental, and not used
+// by the type checker itself; tools like sgo.Vet use it to find redundant
+// emptiness checks.
+func (s *Signature) SetNonEmpty(nonEmpty bool) { s.nonEmpty = nonEmpty }
+
+// A NoReturn tells what, if anything, a call to a function terminates instead
+// of returning.
+type NoReturn int
+
+const (
+	// Returns is for functions whose calls may return.
+	Returns NoReturn = iota
+	// TerminatesGoroutine is for functions that end the calling goroutine,
+	// like runtime.Goexit or (*testing.T).Fatal. Deferred calls still run,
+	// and other goroutines go on.
+	TerminatesGoroutine
+	// TerminatesProcess is for functions that end the whole program, like
+	// os.Exit. Deferred calls don't run.
+	TerminatesProcess
+)
+
+var noReturnNames = [...]string{
+	Returns:             "returns",
+	TerminatesGoroutine: "terminates goroutine",
+	TerminatesProcess:   "terminates process",
+}
+
+// String implements fmt.Stringer for NoReturn.
+func (k NoReturn) String() string {
+	if 0 <= k && int(k) < len(noReturnNames) {
+		return noReturnNames[k]
+	}
+	return fmt.Sprintf("NoReturn(%d)", int(k))
+}
+
 // An Interface represents an interface type.
 type Interface struct {
 	mset      objset
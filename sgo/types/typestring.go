@@ -279,7 +279,10 @@ func writeTuple(buf *bytes.Buffer, tup *Tuple, variadic bool, qf Qualifier, visi
 			writeType(buf, typ, qf, visited)
 		}
 		if v := tup.entangled; v != nil {
-			buf.WriteString(" \\ ")
+			if len(tup.vars) > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString("\\ ")
 			if v.name != "" {
 				buf.WriteString(v.name)
 				buf.WriteByte(' ')
@@ -302,7 +305,7 @@ func writeSignature(buf *bytes.Buffer, sig *Signature, qf Qualifier, visited []T
 	writeTuple(buf, sig.params, sig.variadic, qf, visited)
 
 	n := sig.results.Len()
-	if n == 0 {
+	if sig.results.empty() {
 		// no result
 		return
 	}
@@ -37,6 +37,9 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, path []*TypeNa
 		if !check.conf.AllowUseUninitializedVars && !v.usable {
 			check.errorf(e.Pos(), "possibly uninitialized variable: %s", e.Name)
 		}
+		if v.invalidatedBy != "" {
+			check.errorf(e.Pos(), "use of %s after it was invalidated by %s", e.Name, v.invalidatedBy)
+		}
 		if scope.sig != check.scope.sig {
 			v.aliased = true
 		}
@@ -445,7 +448,10 @@ func (check *Checker) collectParams(scope *Scope, list *ast.FieldList, variadicO
 		}
 		typ := check.typ(ftype)
 		if isEntangled {
-			if IsOptionable(typ) {
+			if _, ok := typ.(*Optional); ok {
+				// Written as optional already, as in \ ?error; the same as
+				// \ error.
+			} else if IsOptionable(typ) {
 				typ = NewOptional(typ)
 			} else if typ != Typ[Bool] {
 				check.error(field.Pos(), "entangled type must be interface, map, channel, function, pointer or bool")
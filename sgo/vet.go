@@ -0,0 +1,217 @@
+package sgo
+
+import (
+	"github.com/tcard/sgo/sgo/ast"
+	"github.com/tcard/sgo/sgo/constant"
+	"github.com/tcard/sgo/sgo/parser"
+	"github.com/tcard/sgo/sgo/scanner"
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+// Vet typechecks the given SGo source and reports code that, while valid, is
+// likely a mistake or can be simplified. It returns an error if the source
+// doesn't typecheck.
+//
+// For now, it only reports checks for emptiness, like len(s) == 0 or s == "",
+// of strings and slices that can't be empty: those returned by functions
+// annotated with the experimental @nonempty directive, or held by variables
+// assigned only once from them.
+//
+// For SGo: func(src string) (scanner.ErrorList \ error)
+func Vet(src string) (scanner.ErrorList, error) {
+	return VetWith(TranslateOptions{}, src)
+}
+
+// VetWith is like Vet, configured by opts.
+//
+// For SGo: func(opts TranslateOptions, src string) (scanner.ErrorList \ error)
+func VetWith(opts TranslateOptions, src string) (scanner.ErrorList, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "input.sgo", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	info, typeErrs := typecheck("vet", fset, "", opts, nil, file)
+	if len(typeErrs) > 0 {
+		return nil, makeErrList(fset, typeErrs)
+	}
+
+	var findings scanner.ErrorList
+	for _, e := range redundantEmptinessChecks(info, file) {
+		findings.Add(fset.Position(e.Pos()), "redundant emptiness check: "+types.ExprString(e)+" is never empty")
+	}
+	findings.Sort()
+	return findings, nil
+}
+
+// redundantEmptinessChecks returns the expressions that are checked for
+// emptiness in file, but are known not to be empty.
+func redundantEmptinessChecks(info *types.Info, file *ast.File) []ast.Expr {
+	vars := nonEmptyVars(info, file)
+	isNonEmpty := func(e ast.Expr) bool {
+		switch e := unparen(e).(type) {
+		case *ast.Ident:
+			v, ok := info.Uses[e].(*types.Var)
+			return ok && vars[v]
+		case *ast.CallExpr:
+			return isNonEmptyCall(info, e, 1)
+		}
+		return false
+	}
+
+	var checked []ast.Expr
+	ast.Inspect(file, func(n ast.Node) bool {
+		cmp, ok := n.(*ast.BinaryExpr)
+		if !ok {
+			return true
+		}
+		if e, ok := emptinessCheck(info, cmp); ok && isNonEmpty(e) {
+			checked = append(checked, e)
+		}
+		return true
+	})
+	return checked
+}
+
+// nonEmptyVars returns the variables of string or slice type declared in file
+// whose only assignment is the result of a call to a @nonempty function.
+func nonEmptyVars(info *types.Info, file *ast.File) map[*types.Var]bool {
+	vars := map[*types.Var]bool{}
+	reassigned := map[*types.Var]bool{}
+
+	define := func(lhs []*ast.Ident, rhs *ast.ExprList) {
+		for i, id := range lhs {
+			v, ok := info.Defs[id].(*types.Var)
+			if !ok || !types.CanBeEmpty(v.Type()) {
+				continue
+			}
+			var call *ast.CallExpr
+			if rhs.Len() == 1 && len(lhs) > 1 {
+				call, _ = unparen(rhs.List[0]).(*ast.CallExpr)
+				if call == nil || !isNonEmptyCall(info, call, len(lhs)) {
+					continue
+				}
+			} else if rhs.Len() == len(lhs) {
+				call, _ = unparen(rhs.List[i]).(*ast.CallExpr)
+				if call == nil || !isNonEmptyCall(info, call, 1) {
+					continue
+				}
+			} else {
+				continue
+			}
+			vars[v] = true
+		}
+	}
+	assign := func(e ast.Expr) {
+		if id, ok := unparen(e).(*ast.Ident); ok {
+			if v, ok := info.Uses[id].(*types.Var); ok {
+				reassigned[v] = true
+			}
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			define(n.Names.List, n.Values)
+		case *ast.AssignStmt:
+			var lhs []*ast.Ident
+			for _, e := range n.Lhs.List {
+				// Redeclared variables in := are in Uses, so they're
+				// considered reassigned below.
+				assign(e)
+				id, _ := e.(*ast.Ident)
+				lhs = append(lhs, id)
+			}
+			if n.Tok == token.DEFINE {
+				define(lhs, n.Rhs)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				if n.Key != nil {
+					assign(n.Key)
+				}
+				if n.Value != nil {
+					assign(n.Value)
+				}
+			}
+		case *ast.UnaryExpr:
+			// It may be assigned through the pointer.
+			if n.Op == token.AND {
+				assign(n.X)
+			}
+		}
+		return true
+	})
+
+	for v := range reassigned {
+		delete(vars, v)
+	}
+	return vars
+}
+
+// isNonEmptyCall reports whether call is to a @nonempty function with n
+// results.
+func isNonEmptyCall(info *types.Info, call *ast.CallExpr, n int) bool {
+	tv, ok := info.Types[call.Fun]
+	if !ok || tv.Type == nil {
+		return false
+	}
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	return ok && sig.NonEmpty() && sig.Results().Len() == n
+}
+
+// emptinessCheck returns the expression whose emptiness cmp checks, if it's
+// a comparison like len(e) == 0, len(e) > 0 or e == "", or their reversed
+// forms.
+func emptinessCheck(info *types.Info, cmp *ast.BinaryExpr) (ast.Expr, bool) {
+	for _, sides := range [][2]ast.Expr{{cmp.X, cmp.Y}, {cmp.Y, cmp.X}} {
+		e, other := unparen(sides[0]), sides[1]
+		val := info.Types[other].Value
+		if val == nil {
+			continue
+		}
+		reversed := sides[0] == cmp.Y
+
+		if call, ok := e.(*ast.CallExpr); ok && isLenCall(info, call) && val.Kind() == constant.Int && constant.Sign(val) == 0 {
+			switch cmp.Op {
+			case token.EQL, token.NEQ:
+				return call.Args[0], true
+			case token.GTR, token.LEQ:
+				if !reversed {
+					return call.Args[0], true
+				}
+			case token.LSS, token.GEQ:
+				if reversed {
+					return call.Args[0], true
+				}
+			}
+		}
+
+		if val.Kind() == constant.String && constant.StringVal(val) == "" && (cmp.Op == token.EQL || cmp.Op == token.NEQ) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func isLenCall(info *types.Info, call *ast.CallExpr) bool {
+	id, ok := unparen(call.Fun).(*ast.Ident)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "len"
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
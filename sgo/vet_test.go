@@ -0,0 +1,94 @@
+package sgo
+
+import (
+	"testing"
+
+	"github.com/tcard/sgo/sgo/token"
+	"github.com/tcard/sgo/sgo/types"
+)
+
+func TestVetRedundantEmptinessChecks(t *testing.T) {
+	// A package as if annotated with:
+	//
+	// 	Base @nonempty
+	// 	Names @nonempty
+	// 	Split @nonempty
+	pkg := types.NewPackage("example.com/fake", "fake")
+	str := types.Typ[types.String]
+	for _, f := range []struct {
+		name     string
+		results  *types.Tuple
+		nonEmpty bool
+	}{
+		{"Base", types.NewTuple(types.NewVar(token.NoPos, pkg, "", str)), true},
+		{"Names", types.NewTuple(types.NewVar(token.NoPos, pkg, "", types.NewSlice(str))), true},
+		{"Split", types.NewTuple(types.NewVar(token.NoPos, pkg, "dir", str), types.NewVar(token.NoPos, pkg, "file", str)), true},
+		{"Maybe", types.NewTuple(types.NewVar(token.NoPos, pkg, "", str)), false},
+	} {
+		params := types.NewTuple(types.NewParam(token.NoPos, pkg, "s", str))
+		sig := types.NewSignature(nil, params, f.results, false)
+		sig.SetNonEmpty(f.nonEmpty)
+		pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, f.name, sig))
+	}
+	pkg.MarkComplete()
+
+	src := `package example
+
+import "example.com/fake"
+
+func f(p string) bool {
+	b := fake.Base(p)
+	if b == "" {
+		return false
+	}
+	if len(fake.Names(p)) > 0 {
+	}
+	dir, file := fake.Split(p)
+	if 0 == len(dir) || file != "" {
+	}
+
+	m := fake.Maybe(p)
+	if m == "" {
+	}
+	c := fake.Base(p)
+	c = m
+	if c == "" {
+	}
+	var d = fake.Base(p)
+	_ = &d
+	if d == "" {
+	}
+	if len(b) > 1 {
+	}
+	return len(b) != 0
+}
+`
+	findings, err := VetWith(TranslateOptions{Importer: testFakeImporter{pkg.Path(): pkg}}, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		line int
+		msg  string
+	}{
+		{7, "redundant emptiness check: b is never empty"},
+		{10, "redundant emptiness check: fake.Names(p) is never empty"},
+		{13, "redundant emptiness check: dir is never empty"},
+		{13, "redundant emptiness check: file is never empty"},
+		{29, "redundant emptiness check: b is never empty"},
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %d: %v", len(expected), len(findings), findings)
+	}
+	for i, e := range expected {
+		if findings[i].Pos.Line != e.line || findings[i].Msg != e.msg {
+			t.Errorf("finding %d: expected line %d: %q, got line %d: %q", i, e.line, e.msg, findings[i].Pos.Line, findings[i].Msg)
+		}
+	}
+
+	_, err = VetWith(TranslateOptions{Importer: testFakeImporter{pkg.Path(): pkg}}, src+"\nvar x int = \"\"\n")
+	if err == nil {
+		t.Errorf("expected error vetting code that doesn't typecheck")
+	}
+}
@@ -10,200 +10,248 @@ package main
 /* sgoplayground/main.sgo:8 */ 	"fmt"
 /* sgoplayground/main.sgo:9 */ 	"html/template"
 /* sgoplayground/main.sgo:10 */ 	"io"
-/* sgoplayground/main.sgo:11 */ 	"log"
+/* sgoplayground/main.sgo:11 */ 	"log/slog"
 /* sgoplayground/main.sgo:12 */ 	"net/http"
 /* sgoplayground/main.sgo:13 */ 	"net/url"
-/* sgoplayground/main.sgo:14 */ 	"runtime"
-/* sgoplayground/main.sgo:15 */ 	"strings"
-
-/* sgoplayground/main.sgo:17 */ 	"github.com/gorilla/websocket"
-/* sgoplayground/main.sgo:18 */ 	"github.com/tcard/sgo/sgo"
-/* sgoplayground/main.sgo:19 */ 	"github.com/tcard/sgo/sgo/format"
-/* sgoplayground/main.sgo:20 */ 	"github.com/tcard/sgo/sgo/scanner"
-/* sgoplayground/main.sgo:21 */ )
-
-/* sgoplayground/main.sgo:23 */ var (
-/* sgoplayground/main.sgo:24 */ 	httpAddr = flag.String("http", ":5600", "HTTP server address")
-
-/* sgoplayground/main.sgo:26 */ 	upgrader = websocket.Upgrader{}
-/* sgoplayground/main.sgo:27 */ )
-
-/* sgoplayground/main.sgo:29 */ const defaultHost = "fanyare.tcardenas.me:5600"
-
-/* sgoplayground/main.sgo:31 */ func handleMsg(msg msgType) {
-/* sgoplayground/main.sgo:32 */ 	c := msg.c
-/* sgoplayground/main.sgo:33 */ 	if c == nil {
-/* sgoplayground/main.sgo:34 */ 		log.Println("c shouldn't be nil")
-/* sgoplayground/main.sgo:35 */ 		return
-/* sgoplayground/main.sgo:36 */ 	}
-/* sgoplayground/main.sgo:37 */ 	switch msg.Type {
-/* sgoplayground/main.sgo:38 */ 	case "format":
-/* sgoplayground/main.sgo:39 */ 		resp := &msgType{
-/* sgoplayground/main.sgo:40 */ 			Type: "format",
-/* sgoplayground/main.sgo:41 */ 		}
-/* sgoplayground/main.sgo:42 */ 		func() {
-/* sgoplayground/main.sgo:43 */ 			defer func() {
-/* sgoplayground/main.sgo:44 */ 				if r := recover(); r != nil {
-/* sgoplayground/main.sgo:45 */ 					value := fmt.Sprintln(r)
-/* sgoplayground/main.sgo:46 */ 					stack := make([]byte, 99999)
-/* sgoplayground/main.sgo:47 */ 					runtime.Stack(stack, false)
-/* sgoplayground/main.sgo:48 */ 					value += string(stack)
-/* sgoplayground/main.sgo:49 */ 					resp.Value = value
-/* sgoplayground/main.sgo:50 */ 				}
-/* sgoplayground/main.sgo:51 */ 			}()
-/* sgoplayground/main.sgo:52 */ 			formatted, err := format.Source([]byte(msg.Value.(string)))
-/* sgoplayground/main.sgo:53 */ 			if err == nil {
-/* sgoplayground/main.sgo:54 */ 				resp.Value = string(formatted)
-/* sgoplayground/main.sgo:55 */ 			}
-/* sgoplayground/main.sgo:56 */ 		}()
-/* sgoplayground/main.sgo:57 */ 		c.WriteJSON(resp)
-/* sgoplayground/main.sgo:58 */ 	case "translate":
-/* sgoplayground/main.sgo:59 */ 		resp := &msgType{
-/* sgoplayground/main.sgo:60 */ 			Type: "translate",
-/* sgoplayground/main.sgo:61 */ 		}
-/* sgoplayground/main.sgo:62 */ 		func() {
-/* sgoplayground/main.sgo:63 */ 			defer func() {
-/* sgoplayground/main.sgo:64 */ 				if r := recover(); r != nil {
-/* sgoplayground/main.sgo:65 */ 					value := fmt.Sprintln(r)
-/* sgoplayground/main.sgo:66 */ 					stack := make([]byte, 99999)
-/* sgoplayground/main.sgo:67 */ 					runtime.Stack(stack, false)
-/* sgoplayground/main.sgo:68 */ 					value += string(stack)
-/* sgoplayground/main.sgo:69 */ 					resp.Value = value
-/* sgoplayground/main.sgo:70 */ 				}
-/* sgoplayground/main.sgo:71 */ 			}()
-/* sgoplayground/main.sgo:72 */ 			w := &bytes.Buffer{}
-/* sgoplayground/main.sgo:73 */ 			errs := sgo.TranslateFile(func() (io.Writer, error) { return w, nil }, strings.NewReader(msg.Value.(string)), "name")
-/* sgoplayground/main.sgo:74 */ 			if errs != nil {
-/* sgoplayground/main.sgo:75 */ 				var errMsgs []string
-/* sgoplayground/main.sgo:76 */ 				for _, err := range errs {
-/* sgoplayground/main.sgo:77 */ 					if errs, ok := err.(scanner.ErrorList); ok {
-/* sgoplayground/main.sgo:78 */ 						for _, err := range errs {
-/* sgoplayground/main.sgo:79 */ 							errMsgs = append(errMsgs, err.Error())
-/* sgoplayground/main.sgo:80 */ 						}
-/* sgoplayground/main.sgo:81 */ 					} else {
-/* sgoplayground/main.sgo:82 */ 						errMsgs = append(errMsgs, err.Error())
-/* sgoplayground/main.sgo:83 */ 					}
-/* sgoplayground/main.sgo:84 */ 				}
-/* sgoplayground/main.sgo:85 */ 				resp.Value = strings.Join(errMsgs, "\n")
-/* sgoplayground/main.sgo:86 */ 			} else {
-/* sgoplayground/main.sgo:87 */ 				resp.Value = w.String()
-/* sgoplayground/main.sgo:88 */ 			}
-/* sgoplayground/main.sgo:89 */ 		}()
-/* sgoplayground/main.sgo:90 */ 		c.WriteJSON(resp)
-/* sgoplayground/main.sgo:91 */ 	case "execute":
-/* sgoplayground/main.sgo:92 */ 		resp := &msgType{
-/* sgoplayground/main.sgo:93 */ 			Type: "execute",
-/* sgoplayground/main.sgo:94 */ 		}
-/* sgoplayground/main.sgo:95 */ 		body := url.Values{}
-/* sgoplayground/main.sgo:96 */ 		body.Add("version", "2")
-/* sgoplayground/main.sgo:97 */ 		var errs []error
-/* sgoplayground/main.sgo:98 */ 		w := &bytes.Buffer{}
-/* sgoplayground/main.sgo:99 */ 		func() {
-/* sgoplayground/main.sgo:100 */ 			defer func() {
-/* sgoplayground/main.sgo:101 */ 				if r := recover(); r != nil {
-/* sgoplayground/main.sgo:102 */ 					value := fmt.Sprintln(r)
-/* sgoplayground/main.sgo:103 */ 					stack := make([]byte, 99999)
-/* sgoplayground/main.sgo:104 */ 					runtime.Stack(stack, false)
-/* sgoplayground/main.sgo:105 */ 					value += string(stack)
-/* sgoplayground/main.sgo:106 */ 					errs = append(errs, errors.New(value))
-/* sgoplayground/main.sgo:107 */ 				}
-/* sgoplayground/main.sgo:108 */ 			}()
-
-/* sgoplayground/main.sgo:110 */ 			errs = sgo.TranslateFile(func() (io.Writer, error) { return w, nil }, strings.NewReader(msg.Value.(string)), "name")
-/* sgoplayground/main.sgo:111 */ 		}()
-/* sgoplayground/main.sgo:112 */ 		if errs != nil {
-/* sgoplayground/main.sgo:113 */ 			var errMsgs []string
-/* sgoplayground/main.sgo:114 */ 			for _, err := range errs {
-/* sgoplayground/main.sgo:115 */ 				if errs, ok := err.(scanner.ErrorList); ok {
-/* sgoplayground/main.sgo:116 */ 					for _, err := range errs {
-/* sgoplayground/main.sgo:117 */ 						errMsgs = append(errMsgs, err.Error())
-/* sgoplayground/main.sgo:118 */ 					}
-/* sgoplayground/main.sgo:119 */ 				} else {
-/* sgoplayground/main.sgo:120 */ 					errMsgs = append(errMsgs, err.Error())
-/* sgoplayground/main.sgo:121 */ 				}
-/* sgoplayground/main.sgo:122 */ 			}
-/* sgoplayground/main.sgo:123 */ 			resp.Value = strings.Join(errMsgs, "\n")
-/* sgoplayground/main.sgo:124 */ 		} else {
-/* sgoplayground/main.sgo:125 */ 			body.Add("body", w.String())
-/* sgoplayground/main.sgo:126 */ 			postResp, err := http.PostForm("https://play.golang.org/compile", body)
-/* sgoplayground/main.sgo:127 */ 			if err != nil {
-/* sgoplayground/main.sgo:128 */ 				resp.Value = err.Error()
-/* sgoplayground/main.sgo:129 */ 			} else {
-/* sgoplayground/main.sgo:130 */ 				var v interface{}
-/* sgoplayground/main.sgo:131 */ 				err := json.NewDecoder(postResp.Body).Decode(&v)
-/* sgoplayground/main.sgo:132 */ 				postResp.Body.Close()
-/* sgoplayground/main.sgo:133 */ 				if err != nil {
-/* sgoplayground/main.sgo:134 */ 					resp.Value = err.Error()
-/* sgoplayground/main.sgo:135 */ 				} else {
-/* sgoplayground/main.sgo:136 */ 					resp.Value = v
-/* sgoplayground/main.sgo:137 */ 				}
-/* sgoplayground/main.sgo:138 */ 			}
-/* sgoplayground/main.sgo:139 */ 		}
-/* sgoplayground/main.sgo:140 */ 		c.WriteJSON(resp)
-/* sgoplayground/main.sgo:141 */ 	}
-/* sgoplayground/main.sgo:142 */ }
-
-/* sgoplayground/main.sgo:144 */ func main() {
-/* sgoplayground/main.sgo:145 */ 	flag.Parse()
-
-/* sgoplayground/main.sgo:147 */ 	http.HandleFunc("/ws", func(w http.ResponseWriter, req *http.Request) {
-/* sgoplayground/main.sgo:148 */ 		c, err := upgrader.Upgrade(w, req, nil)
-/* sgoplayground/main.sgo:149 */ 		if err != nil {
-/* sgoplayground/main.sgo:150 */ 			log.Println("upgrade:", err)
-/* sgoplayground/main.sgo:151 */ 			return
-/* sgoplayground/main.sgo:152 */ 		}
-/* sgoplayground/main.sgo:153 */ 		defer c.Close()
-/* sgoplayground/main.sgo:154 */ 		for {
-/* sgoplayground/main.sgo:155 */ 			var recvMsg msgType
-/* sgoplayground/main.sgo:156 */ 			err := c.ReadJSON(&recvMsg)
-/* sgoplayground/main.sgo:157 */ 			if err != nil {
-/* sgoplayground/main.sgo:158 */ 				log.Println("read:", err)
-/* sgoplayground/main.sgo:159 */ 				break
-/* sgoplayground/main.sgo:160 */ 			}
-/* sgoplayground/main.sgo:161 */ 			recvMsg.c = c
-/* sgoplayground/main.sgo:162 */ 			handleMsg(recvMsg)
-/* sgoplayground/main.sgo:163 */ 		}
-/* sgoplayground/main.sgo:164 */ 	})
-
-/* sgoplayground/main.sgo:166 */ 	buf := &bytes.Buffer{}
-/* sgoplayground/main.sgo:167 */ 	indexTpl.Execute(buf, map[string]interface{}{
-/* sgoplayground/main.sgo:168 */ 		"Gist":          "",
-/* sgoplayground/main.sgo:169 */ 		"WSURL":         "ws://" + defaultHost + "/ws",
-/* sgoplayground/main.sgo:170 */ 		"PreloadedCode": defaultPreloadedCode,
-/* sgoplayground/main.sgo:171 */ 	})
-/* sgoplayground/main.sgo:172 */ 	preexecutedTpl := buf.Bytes()
-
-/* sgoplayground/main.sgo:174 */ 	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-/* sgoplayground/main.sgo:175 */ 		gist := req.URL.Query().Get("gist")
-/* sgoplayground/main.sgo:176 */ 		if gist == "" && req.Host == defaultHost {
-/* sgoplayground/main.sgo:177 */ 			w.Write(preexecutedTpl)
-/* sgoplayground/main.sgo:178 */ 			return
-/* sgoplayground/main.sgo:179 */ 		}
-
-/* sgoplayground/main.sgo:181 */ 		preloadedCode := ""
-/* sgoplayground/main.sgo:182 */ 		if gist == "" {
-/* sgoplayground/main.sgo:183 */ 			preloadedCode = defaultPreloadedCode
-/* sgoplayground/main.sgo:184 */ 		}
-/* sgoplayground/main.sgo:185 */ 		indexTpl.Execute(w, map[string]interface{}{
-/* sgoplayground/main.sgo:186 */ 			"Gist":          gist,
-/* sgoplayground/main.sgo:187 */ 			"WSURL":         "ws://" + req.Host + "/ws",
-/* sgoplayground/main.sgo:188 */ 			"PreloadedCode": preloadedCode,
-/* sgoplayground/main.sgo:189 */ 		})
-/* sgoplayground/main.sgo:190 */ 	})
-
-/* sgoplayground/main.sgo:192 */ 	fmt.Println("Serving on", *httpAddr)
-/* sgoplayground/main.sgo:193 */ 	log.Fatal(http.ListenAndServe(*httpAddr, nil))
-/* sgoplayground/main.sgo:194 */ }
-
-/* sgoplayground/main.sgo:196 */ type msgType struct {
+/* sgoplayground/main.sgo:14 */ 	"os"
+/* sgoplayground/main.sgo:15 */ 	"runtime"
+/* sgoplayground/main.sgo:16 */ 	"strings"
+/* sgoplayground/main.sgo:17 */ 	"sync/atomic"
+/* sgoplayground/main.sgo:18 */ 	"time"
+
+/* sgoplayground/main.sgo:20 */ 	"github.com/gorilla/websocket"
+/* sgoplayground/main.sgo:21 */ 	"github.com/tcard/sgo/sgo"
+/* sgoplayground/main.sgo:22 */ 	"github.com/tcard/sgo/sgo/format"
+/* sgoplayground/main.sgo:23 */ 	"github.com/tcard/sgo/sgo/scanner"
+/* sgoplayground/main.sgo:24 */ )
+
+/* sgoplayground/main.sgo:26 */ var (
+/* sgoplayground/main.sgo:27 */ 	httpAddr = flag.String("http", ":5600", "HTTP server address")
+/* sgoplayground/main.sgo:28 */ 	logLevel = slog.LevelInfo
+
+/* sgoplayground/main.sgo:30 */ 	upgrader = websocket.Upgrader{}
+
+/* sgoplayground/main.sgo:32 */ 	logger  = slog.Default()
+/* sgoplayground/main.sgo:33 */ 	connIDs atomic.Uint64
+/* sgoplayground/main.sgo:34 */ )
+
+/* sgoplayground/main.sgo:36 */ func init() {
+/* sgoplayground/main.sgo:37 */ 	flag.TextVar(&logLevel, "log-level", logLevel, "minimum level of the logs: debug, info, warn or error")
+/* sgoplayground/main.sgo:38 */ }
+
+/* sgoplayground/main.sgo:40 */ const defaultHost = "fanyare.tcardenas.me:5600"
+
+/* sgoplayground/main.sgo:42 */ func handleMsg(msg msgType) {
+/* sgoplayground/main.sgo:43 */ 	c := msg.c
+/* sgoplayground/main.sgo:44 */ 	if c == nil {
+/* sgoplayground/main.sgo:45 */ 		logger.Error("c shouldn't be nil", "conn", msg.connID, "type", msg.Type)
+/* sgoplayground/main.sgo:46 */ 		return
+/* sgoplayground/main.sgo:47 */ 	}
+/* sgoplayground/main.sgo:48 */ 	logger.Debug("received message", "conn", msg.connID, "type", msg.Type)
+/* sgoplayground/main.sgo:49 */ 	start := time.Now()
+/* sgoplayground/main.sgo:50 */ 	switch msg.Type {
+/* sgoplayground/main.sgo:51 */ 	case "format":
+/* sgoplayground/main.sgo:52 */ 		resp := &msgType{
+/* sgoplayground/main.sgo:53 */ 			Type: "format",
+/* sgoplayground/main.sgo:54 */ 		}
+/* sgoplayground/main.sgo:55 */ 		func() {
+/* sgoplayground/main.sgo:56 */ 			defer func() {
+/* sgoplayground/main.sgo:57 */ 				if r := recover(); r != nil {
+/* sgoplayground/main.sgo:58 */ 					resp.Value = recovered(msg, r)
+/* sgoplayground/main.sgo:59 */ 				}
+/* sgoplayground/main.sgo:60 */ 			}()
+/* sgoplayground/main.sgo:61 */ 			formatted, err := format.Source([]byte(msg.Value.(string)))
+/* sgoplayground/main.sgo:62 */ 			if err == nil {
+/* sgoplayground/main.sgo:63 */ 				resp.Value = string(formatted)
+/* sgoplayground/main.sgo:64 */ 			}
+/* sgoplayground/main.sgo:65 */ 		}()
+/* sgoplayground/main.sgo:66 */ 		c.WriteJSON(resp)
+/* sgoplayground/main.sgo:67 */ 	case "translate":
+/* sgoplayground/main.sgo:68 */ 		resp := &msgType{
+/* sgoplayground/main.sgo:69 */ 			Type: "translate",
+/* sgoplayground/main.sgo:70 */ 		}
+/* sgoplayground/main.sgo:71 */ 		var failure error
+/* sgoplayground/main.sgo:72 */ 		func() {
+/* sgoplayground/main.sgo:73 */ 			defer func() {
+/* sgoplayground/main.sgo:74 */ 				if r := recover(); r != nil {
+/* sgoplayground/main.sgo:75 */ 					value := recovered(msg, r)
+/* sgoplayground/main.sgo:76 */ 					resp.Value = value
+/* sgoplayground/main.sgo:77 */ 					failure = errors.New(value)
+/* sgoplayground/main.sgo:78 */ 				}
+/* sgoplayground/main.sgo:79 */ 			}()
+/* sgoplayground/main.sgo:80 */ 			w := &bytes.Buffer{}
+/* sgoplayground/main.sgo:81 */ 			errs := sgo.TranslateFile(func() (io.Writer, error) { return w, nil }, strings.NewReader(msg.Value.(string)), "name")
+/* sgoplayground/main.sgo:82 */ 			if errs != nil {
+/* sgoplayground/main.sgo:83 */ 				var errMsgs []string
+/* sgoplayground/main.sgo:84 */ 				for _, err := range errs {
+/* sgoplayground/main.sgo:85 */ 					if errs, ok := err.(scanner.ErrorList); ok {
+/* sgoplayground/main.sgo:86 */ 						for _, err := range errs {
+/* sgoplayground/main.sgo:87 */ 							errMsgs = append(errMsgs, err.Error())
+/* sgoplayground/main.sgo:88 */ 						}
+/* sgoplayground/main.sgo:89 */ 					} else {
+/* sgoplayground/main.sgo:90 */ 						errMsgs = append(errMsgs, err.Error())
+/* sgoplayground/main.sgo:91 */ 					}
+/* sgoplayground/main.sgo:92 */ 				}
+/* sgoplayground/main.sgo:93 */ 				errMsg := strings.Join(errMsgs, "\n")
+/* sgoplayground/main.sgo:94 */ 				resp.Value = errMsg
+/* sgoplayground/main.sgo:95 */ 				failure = errors.New(errMsg)
+/* sgoplayground/main.sgo:96 */ 			} else {
+/* sgoplayground/main.sgo:97 */ 				resp.Value = w.String()
+/* sgoplayground/main.sgo:98 */ 			}
+/* sgoplayground/main.sgo:99 */ 		}()
+/* sgoplayground/main.sgo:100 */ 		logHandled(msg, start, failure)
+/* sgoplayground/main.sgo:101 */ 		c.WriteJSON(resp)
+/* sgoplayground/main.sgo:102 */ 	case "execute":
+/* sgoplayground/main.sgo:103 */ 		resp := &msgType{
+/* sgoplayground/main.sgo:104 */ 			Type: "execute",
+/* sgoplayground/main.sgo:105 */ 		}
+/* sgoplayground/main.sgo:106 */ 		body := url.Values{}
+/* sgoplayground/main.sgo:107 */ 		body.Add("version", "2")
+/* sgoplayground/main.sgo:108 */ 		var errs []error
+/* sgoplayground/main.sgo:109 */ 		var failure error
+/* sgoplayground/main.sgo:110 */ 		w := &bytes.Buffer{}
+/* sgoplayground/main.sgo:111 */ 		func() {
+/* sgoplayground/main.sgo:112 */ 			defer func() {
+/* sgoplayground/main.sgo:113 */ 				if r := recover(); r != nil {
+/* sgoplayground/main.sgo:114 */ 					errs = append(errs, errors.New(recovered(msg, r)))
+/* sgoplayground/main.sgo:115 */ 				}
+/* sgoplayground/main.sgo:116 */ 			}()
+
+/* sgoplayground/main.sgo:118 */ 			errs = sgo.TranslateFile(func() (io.Writer, error) { return w, nil }, strings.NewReader(msg.Value.(string)), "name")
+/* sgoplayground/main.sgo:119 */ 		}()
+/* sgoplayground/main.sgo:120 */ 		if errs != nil {
+/* sgoplayground/main.sgo:121 */ 			var errMsgs []string
+/* sgoplayground/main.sgo:122 */ 			for _, err := range errs {
+/* sgoplayground/main.sgo:123 */ 				if errs, ok := err.(scanner.ErrorList); ok {
+/* sgoplayground/main.sgo:124 */ 					for _, err := range errs {
+/* sgoplayground/main.sgo:125 */ 						errMsgs = append(errMsgs, err.Error())
+/* sgoplayground/main.sgo:126 */ 					}
+/* sgoplayground/main.sgo:127 */ 				} else {
+/* sgoplayground/main.sgo:128 */ 					errMsgs = append(errMsgs, err.Error())
+/* sgoplayground/main.sgo:129 */ 				}
+/* sgoplayground/main.sgo:130 */ 			}
+/* sgoplayground/main.sgo:131 */ 			errMsg := strings.Join(errMsgs, "\n")
+/* sgoplayground/main.sgo:132 */ 			resp.Value = errMsg
+/* sgoplayground/main.sgo:133 */ 			failure = errors.New(errMsg)
+/* sgoplayground/main.sgo:134 */ 		} else {
+/* sgoplayground/main.sgo:135 */ 			body.Add("body", w.String())
+/* sgoplayground/main.sgo:136 */ 			postResp, err := http.PostForm("https://play.golang.org/compile", body)
+/* sgoplayground/main.sgo:137 */ 			if err != nil {
+/* sgoplayground/main.sgo:138 */ 				resp.Value = err.Error()
+/* sgoplayground/main.sgo:139 */ 				failure = err
+/* sgoplayground/main.sgo:140 */ 			} else {
+/* sgoplayground/main.sgo:141 */ 				var v interface{}
+/* sgoplayground/main.sgo:142 */ 				err := json.NewDecoder(postResp.Body).Decode(&v)
+/* sgoplayground/main.sgo:143 */ 				postResp.Body.Close()
+/* sgoplayground/main.sgo:144 */ 				if err != nil {
+/* sgoplayground/main.sgo:145 */ 					resp.Value = err.Error()
+/* sgoplayground/main.sgo:146 */ 					failure = err
+/* sgoplayground/main.sgo:147 */ 				} else {
+/* sgoplayground/main.sgo:148 */ 					resp.Value = v
+/* sgoplayground/main.sgo:149 */ 				}
+/* sgoplayground/main.sgo:150 */ 			}
+/* sgoplayground/main.sgo:151 */ 		}
+/* sgoplayground/main.sgo:152 */ 		logHandled(msg, start, failure)
+/* sgoplayground/main.sgo:153 */ 		c.WriteJSON(resp)
+/* sgoplayground/main.sgo:154 */ 	}
+/* sgoplayground/main.sgo:155 */ }
+
+// recovered logs a panic recovered while handling msg, and returns it along
+// with its stack trace, to be sent back in the response.
+/* sgoplayground/main.sgo:159 */ func recovered(msg msgType, r interface{}) string {
+/* sgoplayground/main.sgo:160 */ 	value := fmt.Sprintln(r)
+/* sgoplayground/main.sgo:161 */ 	stack := make([]byte, 99999)
+/* sgoplayground/main.sgo:162 */ 	runtime.Stack(stack, false)
+/* sgoplayground/main.sgo:163 */ 	value += string(stack)
+/* sgoplayground/main.sgo:164 */ 	logger.Error("panic handling message", "conn", msg.connID, "type", msg.Type, "panic", fmt.Sprint(r))
+/* sgoplayground/main.sgo:165 */ 	return value
+/* sgoplayground/main.sgo:166 */ }
+
+// logHandled logs how long it took to handle msg since start, and whether it
+// failed.
+/* sgoplayground/main.sgo:170 */ func logHandled(msg msgType, start time.Time, err error) {
+/* sgoplayground/main.sgo:171 */ 	args := []interface{}{"conn", msg.connID, "type", msg.Type, "duration", time.Since(start)}
+/* sgoplayground/main.sgo:172 */ 	if err != nil {
+/* sgoplayground/main.sgo:173 */ 		args = append(args, "error", err.Error())
+/* sgoplayground/main.sgo:174 */ 	}
+/* sgoplayground/main.sgo:175 */ 	logger.Info("handled message", args...)
+/* sgoplayground/main.sgo:176 */ }
+
+/* sgoplayground/main.sgo:178 */ func main() {
+/* sgoplayground/main.sgo:179 */ 	flag.Parse()
+/* sgoplayground/main.sgo:180 */ 	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+/* sgoplayground/main.sgo:182 */ 	http.HandleFunc("/ws", func(w http.ResponseWriter, req *http.Request) {
+/* sgoplayground/main.sgo:183 */ 		c, err := upgrader.Upgrade(w, req, nil)
+/* sgoplayground/main.sgo:184 */ 		if err != nil {
+/* sgoplayground/main.sgo:185 */ 			logger.Warn("upgrade failed", "remote", req.RemoteAddr, "error", err.Error())
+/* sgoplayground/main.sgo:186 */ 			return
+/* sgoplayground/main.sgo:187 */ 		}
+/* sgoplayground/main.sgo:188 */ 		defer c.Close()
+/* sgoplayground/main.sgo:189 */ 		connID := connIDs.Add(1)
+/* sgoplayground/main.sgo:190 */ 		logger.Info("connection opened", "conn", connID, "remote", req.RemoteAddr)
+/* sgoplayground/main.sgo:191 */ 		for {
+/* sgoplayground/main.sgo:192 */ 			var recvMsg msgType
+/* sgoplayground/main.sgo:193 */ 			err := c.ReadJSON(&recvMsg)
+/* sgoplayground/main.sgo:194 */ 			if err != nil {
+/* sgoplayground/main.sgo:195 */ 				logger.Info("connection closed", "conn", connID, "error", err.Error())
+/* sgoplayground/main.sgo:196 */ 				break
+/* sgoplayground/main.sgo:197 */ 			}
+/* sgoplayground/main.sgo:198 */ 			recvMsg.c = c
+/* sgoplayground/main.sgo:199 */ 			recvMsg.connID = connID
+/* sgoplayground/main.sgo:200 */ 			handleMsg(recvMsg)
+/* sgoplayground/main.sgo:201 */ 		}
+/* sgoplayground/main.sgo:202 */ 	})
+
+/* sgoplayground/main.sgo:204 */ 	buf := &bytes.Buffer{}
+/* sgoplayground/main.sgo:205 */ 	indexTpl.Execute(buf, map[string]interface{}{
+/* sgoplayground/main.sgo:206 */ 		"Gist":          "",
+/* sgoplayground/main.sgo:207 */ 		"WSURL":         "ws://" + defaultHost + "/ws",
+/* sgoplayground/main.sgo:208 */ 		"PreloadedCode": defaultPreloadedCode,
+/* sgoplayground/main.sgo:209 */ 	})
+/* sgoplayground/main.sgo:210 */ 	preexecutedTpl := buf.Bytes()
+
+/* sgoplayground/main.sgo:212 */ 	http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+/* sgoplayground/main.sgo:213 */ 		gist := req.URL.Query().Get("gist")
+/* sgoplayground/main.sgo:214 */ 		if gist == "" && req.Host == defaultHost {
+/* sgoplayground/main.sgo:215 */ 			w.Write(preexecutedTpl)
+/* sgoplayground/main.sgo:216 */ 			return
+/* sgoplayground/main.sgo:217 */ 		}
+
+/* sgoplayground/main.sgo:219 */ 		preloadedCode := ""
+/* sgoplayground/main.sgo:220 */ 		if gist == "" {
+/* sgoplayground/main.sgo:221 */ 			preloadedCode = defaultPreloadedCode
+/* sgoplayground/main.sgo:222 */ 		}
+/* sgoplayground/main.sgo:223 */ 		indexTpl.Execute(w, map[string]interface{}{
+/* sgoplayground/main.sgo:224 */ 			"Gist":          gist,
+/* sgoplayground/main.sgo:225 */ 			"WSURL":         "ws://" + req.Host + "/ws",
+/* sgoplayground/main.sgo:226 */ 			"PreloadedCode": preloadedCode,
+/* sgoplayground/main.sgo:227 */ 		})
+/* sgoplayground/main.sgo:228 */ 	})
+
+/* sgoplayground/main.sgo:230 */ 	logger.Info("serving", "addr", *httpAddr)
+/* sgoplayground/main.sgo:231 */ 	err := http.ListenAndServe(*httpAddr, nil)
+/* sgoplayground/main.sgo:232 */ 	logger.Error("server stopped", "error", err)
+/* sgoplayground/main.sgo:233 */ 	os.Exit(1)
+/* sgoplayground/main.sgo:234 */ }
+
+/* sgoplayground/main.sgo:236 */ type msgType struct {
 	// For SGo: string
-	Type  string       `json:"type"`
+	Type   string       `json:"type"`
 	// For SGo: ?interface{}
-	Value interface{} `json:"value"`
-/* sgoplayground/main.sgo:199 */ 	c     *websocket.Conn
-/* sgoplayground/main.sgo:200 */ }
+	Value  interface{} `json:"value"`
+/* sgoplayground/main.sgo:239 */ 	c      msgConn
+/* sgoplayground/main.sgo:240 */ 	connID uint64
+/* sgoplayground/main.sgo:241 */ }
 
-/* sgoplayground/main.sgo:202 */ const defaultPreloadedCode = `package main
+// A msgConn is where responses to messages are written; usually, a
+// *websocket.Conn.
+/* sgoplayground/main.sgo:245 */ type msgConn interface {
+	// For SGo: func(v ?interface{}) ?error
+	WriteJSON(v interface{}) error
+/* sgoplayground/main.sgo:247 */ }
+
+/* sgoplayground/main.sgo:249 */ const defaultPreloadedCode = `package main
 
 import (
 	"fmt"
@@ -243,7 +291,7 @@ func giveMeSomethingMaybe() (*Something \ error) {
 }
 `
 
-/* sgoplayground/main.sgo:242 */ var indexTpl = template.Must(template.New("index").Parse(`
+/* sgoplayground/main.sgo:289 */ var indexTpl = template.Must(template.New("index").Parse(`
 <!DOCTYPE html>
 <html lang="en">
 
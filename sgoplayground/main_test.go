@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+type recordingConn struct {
+	written []interface{}
+}
+
+func (c *recordingConn) WriteJSON(v interface{}) error {
+	c.written = append(c.written, v)
+	return nil
+}
+
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+func recordAttrs(r slog.Record) map[string]slog.Value {
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	return attrs
+}
+
+func TestTranslateLogs(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{"ok", "package main\n\nfunc main() {}\n", false},
+		{"error", "package main\n\nfunc main() { var p *int = nil }\n", true},
+	} {
+		h := &recordingHandler{}
+		defer func(l *slog.Logger) { logger = l }(logger)
+		logger = slog.New(h)
+
+		conn := &recordingConn{}
+		handleMsg(msgType{Type: "translate", Value: c.src, c: conn, connID: 42})
+
+		if len(conn.written) != 1 {
+			t.Errorf("%s: expected 1 response, got %d", c.name, len(conn.written))
+		}
+
+		var handled []slog.Record
+		for _, r := range h.records {
+			if r.Message == "handled message" {
+				handled = append(handled, r)
+			}
+		}
+		if len(handled) != 1 {
+			t.Fatalf("%s: expected 1 handled message log, got %d: %v", c.name, len(handled), h.records)
+		}
+		r := handled[0]
+		if r.Level != slog.LevelInfo {
+			t.Errorf("%s: expected level %v, got %v", c.name, slog.LevelInfo, r.Level)
+		}
+		attrs := recordAttrs(r)
+		if got := attrs["conn"]; got.Kind() != slog.KindUint64 || got.Uint64() != 42 {
+			t.Errorf("%s: expected conn 42, got %v", c.name, got)
+		}
+		if got := attrs["type"]; got.String() != "translate" {
+			t.Errorf("%s: expected type translate, got %v", c.name, got)
+		}
+		if got := attrs["duration"]; got.Kind() != slog.KindDuration {
+			t.Errorf("%s: expected a duration, got %v", c.name, got)
+		}
+		if _, ok := attrs["error"]; ok != c.wantErr {
+			t.Errorf("%s: expected error field: %v, got %v", c.name, c.wantErr, attrs["error"])
+		}
+	}
+}
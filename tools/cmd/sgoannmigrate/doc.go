@@ -0,0 +1,41 @@
+/*
+
+Command sgoannmigrate upgrades .sgoann annotation files to the current version
+of their grammar.
+
+     $ go get github.com/tcard/sgo/tools/cmd/sgoannmigrate
+
+Usage:
+
+	sgoannmigrate [flags] [path ...]
+
+Each file's grammar version is detected, and the file is rewritten in the
+current one. For example, subidentifiers annotated with their full dotted name,
+as in:
+
+	(*File).Read (*File) func(b []byte) (n int, err ?error)
+
+are moved to a block under their parent:
+
+	(*File) {
+		Read (*File) func(b []byte) (n int, err ?error)
+	}
+
+The result is formatted the way the annotations package marshals annotations:
+items are sorted, and receivers are written as "(*T)". By default, it's written
+to standard output. Without paths, it migrates the source from standard input.
+
+The flags are:
+
+	-w
+		Overwrite each file that changes with its migrated version, instead
+		of writing to standard output.
+	-d
+		Don't write anything; print a diff of the changes to each file
+		instead.
+
+The exit code is 1 if a file can't be read, parsed or written, and 2 if the
+flags are wrong.
+
+*/
+package main // import "github.com/tcard/sgo/tools/cmd/sgoannmigrate"
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/tcard/sgo/sgo/annotations"
+)
+
+func main() {
+	os.Exit(migrateMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// migrateMain runs the command with the given arguments and standard streams,
+// and returns its exit code.
+func migrateMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sgoannmigrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to each file instead of stdout")
+	doDiff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: sgoannmigrate [flags] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths := flags.Args()
+
+	switch {
+	case *write && *doDiff:
+		fmt.Fprintln(stderr, "sgoannmigrate: -w and -d can't be used together")
+		return 2
+	case *write && len(paths) == 0:
+		fmt.Fprintln(stderr, "sgoannmigrate: -w needs files to write to")
+		return 2
+	}
+
+	if len(paths) == 0 {
+		src, err := ioutil.ReadAll(stdin)
+		if err == nil {
+			err = migrate("<standard input>", src, false, *doDiff, stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	code := 0
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = migrate(path, src, *write, *doDiff, stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// migrate migrates src, read from path, and writes the result to stdout, to
+// path if write is set, or a diff to stdout if doDiff is set.
+func migrate(path string, src []byte, write, doDiff bool, stdout io.Writer) error {
+	migrated, err := annotations.Migrate(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	res := []byte(migrated)
+
+	switch {
+	case write:
+		if string(res) == string(src) {
+			return nil
+		}
+		return ioutil.WriteFile(path, res, 0644)
+	case doDiff:
+		if string(res) == string(src) {
+			return nil
+		}
+		data, err := diff(src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		fmt.Fprintf(stdout, "diff %s sgoannmigrate/%s (version %d to %d)\n", path, path, annotations.DetectVersion(string(src)), annotations.CurrentVersion)
+		_, err = stdout.Write(data)
+		return err
+	default:
+		_, err = stdout.Write(res)
+		return err
+	}
+}
+
+func diff(b1, b2 []byte) (data []byte, err error) {
+	f1, err := ioutil.TempFile("", "sgoannmigrate")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f1.Name())
+	defer f1.Close()
+
+	f2, err := ioutil.TempFile("", "sgoannmigrate")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f2.Name())
+	defer f2.Close()
+
+	f1.Write(b1)
+	f2.Write(b2)
+
+	data, err = exec.Command("diff", "-u", f1.Name(), f2.Name()).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testOldSrc is written in the first version of the grammar, with
+// subidentifiers annotated with their full dotted name.
+const testOldSrc = `Open func(name string) (*File \ error)
+( * File ).Read (*File) func(b []byte) (n int, err ?error)
+(*File).Close (*File) func() \ error
+`
+
+const testMigratedSrc = `(*File) {
+	Close (*File) func() \ error
+	Read (*File) func(b []byte) (n int, err ?error)
+}
+Open func(name string) (*File \ error)
+`
+
+func runMigrate(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = migrateMain(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestStdin(t *testing.T) {
+	code, stdout, stderr := runMigrate(t, testOldSrc)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	if stdout != testMigratedSrc {
+		t.Errorf("expected:\n%s\ngot:\n%s", testMigratedSrc, stdout)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgoannmigrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "os.sgoann")
+	ioutil.WriteFile(path, []byte(testOldSrc), 0644)
+
+	code, stdout, stderr := runMigrate(t, "", "-w", path)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output, got:\n%s", stdout)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != testMigratedSrc {
+		t.Errorf("expected:\n%s\ngot:\n%s", testMigratedSrc, got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff command not found")
+	}
+	dir, err := ioutil.TempDir("", "sgoannmigrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := filepath.Join(dir, "old.sgoann")
+	current := filepath.Join(dir, "current.sgoann")
+	ioutil.WriteFile(old, []byte(testOldSrc), 0644)
+	ioutil.WriteFile(current, []byte(testMigratedSrc), 0644)
+
+	code, stdout, stderr := runMigrate(t, "", "-d", old, current)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	for _, want := range []string{
+		"diff " + old + " sgoannmigrate/" + old + " (version 1 to 2)\n",
+		"-(*File).Close (*File) func() \\ error\n",
+		"+(*File) {\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected diff to contain %q, got:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, current) {
+		t.Errorf("expected no diff for a file already migrated, got:\n%s", stdout)
+	}
+
+	got, err := ioutil.ReadFile(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != testOldSrc {
+		t.Errorf("expected -d to leave the file unchanged, got:\n%s", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	code, _, stderr := runMigrate(t, "A {\n")
+	if code != 1 || !strings.Contains(stderr, "<standard input>: unexpected end of file") {
+		t.Errorf("expected exit code 1 and a parse error, got %d; stderr:\n%s", code, stderr)
+	}
+
+	code, _, stderr = runMigrate(t, "", "-w")
+	if code != 2 || !strings.Contains(stderr, "-w needs files") {
+		t.Errorf("expected exit code 2 for -w without files, got %d; stderr:\n%s", code, stderr)
+	}
+
+	code, _, stderr = runMigrate(t, "", "-w", "-d", "x.sgoann")
+	if code != 2 || !strings.Contains(stderr, "can't be used together") {
+		t.Errorf("expected exit code 2 for -w with -d, got %d; stderr:\n%s", code, stderr)
+	}
+}
@@ -9,6 +9,9 @@ It's a drop-in replacement for your editor's sgofmt-on-save hook.
 It has the same command-line interface as sgofmt and formats
 your code in the same way.
 
+Errors are reported as text by default. Use -format=json or
+-format=checkstyle to get them in a format for other tools.
+
 For emacs, make sure you have the latest go-mode.el:
    https://github.com/dominikh/go-mode.el
 Then in your .emacs file:
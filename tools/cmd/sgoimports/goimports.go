@@ -16,6 +16,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/tcard/sgo/sgo/diagnostic"
 	"github.com/tcard/sgo/sgo/scanner"
 
 	"github.com/tcard/sgo/tools/imports"
@@ -27,6 +28,7 @@ var (
 	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
 	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
 	srcdir = flag.String("srcdir", "", "choose imports as if source code is from `dir`")
+	format = flag.String("format", "text", "report errors in `format`: "+strings.Join(diagnostic.Names(), ", "))
 
 	options = &imports.Options{
 		TabWidth:  8,
@@ -35,6 +37,7 @@ var (
 		Fragment:  true,
 	}
 	exitCode = 0
+	errs     scanner.ErrorList
 )
 
 func init() {
@@ -42,7 +45,11 @@ func init() {
 }
 
 func report(err error) {
-	scanner.PrintError(os.Stderr, err)
+	if *format == "text" {
+		scanner.PrintError(os.Stderr, err)
+	} else {
+		errs = append(errs, diagnostic.List(err)...)
+	}
 	exitCode = 2
 }
 
@@ -141,6 +148,11 @@ func main() {
 	// so that it can use defer and have them
 	// run before the exit.
 	gofmtMain()
+	// Only the structured formats collect errors; text prints them as
+	// they're found.
+	if f, err := diagnostic.Lookup(*format); err == nil && len(errs) > 0 {
+		f.Format(os.Stderr, errs)
+	}
 	os.Exit(exitCode)
 }
 
@@ -161,6 +173,12 @@ func gofmtMain() {
 		return
 	}
 
+	if _, err := diagnostic.Lookup(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+		return
+	}
+
 	if len(paths) == 0 {
 		if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
 			report(err)
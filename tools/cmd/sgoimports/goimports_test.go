@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary runs as sgoimports itself when asked to, so that tests
+	// can check what the command writes and how it exits.
+	if os.Getenv("SGOIMPORTS_TEST_MAIN") != "" {
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+func runImports(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "SGOIMPORTS_TEST_MAIN=1")
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatal(err)
+		}
+		code = exitErr.ExitCode()
+	}
+	return code, out.String(), errOut.String()
+}
+
+func TestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgoimports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	c := filepath.Join(dir, "c.go")
+	ioutil.WriteFile(a, []byte("package p\n\nvar = 1\n"), 0644)
+	ioutil.WriteFile(b, []byte("package p\n\nfunc  g() {}\n"), 0644)
+	ioutil.WriteFile(c, []byte("package p\n\nconst = 2\n"), 0644)
+
+	for _, format := range []string{"text", "json", "checkstyle"} {
+		code, stdout, stderr := runImports(t, "-format", format, a, b, c)
+		if code != 2 {
+			t.Errorf("%s: expected exit code 2, got %d", format, code)
+		}
+		// The good file is still processed between the bad ones.
+		if stdout != "package p\n\nfunc g() {}\n" {
+			t.Errorf("%s: unexpected output:\n%s", format, stdout)
+		}
+
+		var files []string
+		switch format {
+		case "text":
+			for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+				files = append(files, strings.SplitN(line, ":", 2)[0])
+			}
+		case "json":
+			var diags []struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			}
+			if err := json.Unmarshal([]byte(stderr), &diags); err != nil {
+				t.Errorf("%s: %v:\n%s", format, err, stderr)
+			}
+			for _, d := range diags {
+				if d.Line != 3 {
+					t.Errorf("%s: expected an error at line 3, got %+v", format, d)
+				}
+				files = append(files, d.File)
+			}
+		case "checkstyle":
+			var report struct {
+				Files []struct {
+					Name string `xml:"name,attr"`
+				} `xml:"file"`
+			}
+			if err := xml.Unmarshal([]byte(stderr), &report); err != nil {
+				t.Errorf("%s: %v:\n%s", format, err, stderr)
+			}
+			for _, f := range report.Files {
+				files = append(files, f.Name)
+			}
+		}
+		if len(files) != 2 || files[0] != a || files[1] != c {
+			t.Errorf("%s: expected errors for %s and %s, got:\n%s", format, a, c, stderr)
+		}
+	}
+
+	code, stdout, stderr := runImports(t, "-format", "yaml", b)
+	if code != 2 || stdout != "" || !strings.Contains(stderr, `unknown format "yaml"`) {
+		t.Errorf("unknown format: expected exit code 2 and a message, got %d, %q, %q", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,30 @@
+/*
+
+Command sgotranslate translates SGo files to Go.
+
+     $ go get github.com/tcard/sgo/tools/cmd/sgotranslate
+
+Usage:
+
+	sgotranslate [flags] [path ...]
+
+The given .sgo files are translated together, as files of the same package,
+and the resulting Go code is written to standard output. Without paths, it
+translates the SGo code from standard input.
+
+The flags are:
+
+	-w
+		Write each result to a .go file next to its .sgo file, instead of
+		to standard output.
+	-o file
+		Write the result to file instead of to standard output. Only valid
+		for a single input.
+	-format format
+		Report errors in format: text, json or checkstyle.
+
+All errors found in the files are reported together. The exit code is 1 if
+the files can't be translated, and 2 if the flags are wrong.
+
+*/
+package main // import "github.com/tcard/sgo/tools/cmd/sgotranslate"
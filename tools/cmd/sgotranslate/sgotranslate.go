@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tcard/sgo/sgo"
+	"github.com/tcard/sgo/sgo/diagnostic"
+)
+
+func main() {
+	os.Exit(translateMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// translateMain runs the command with the given arguments and standard
+// streams, and returns its exit code.
+func translateMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sgotranslate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the .go file next to each .sgo file instead of stdout")
+	output := flags.String("o", "", "write result to `file` instead of stdout; only for a single input")
+	format := flags.String("format", "text", "report errors in `format`: "+strings.Join(diagnostic.Names(), ", "))
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: sgotranslate [flags] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths := flags.Args()
+
+	formatter, err := diagnostic.Lookup(*format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	switch {
+	case *write && *output != "":
+		fmt.Fprintln(stderr, "sgotranslate: -w and -o can't be used together")
+		return 2
+	case *write && len(paths) == 0:
+		fmt.Fprintln(stderr, "sgotranslate: -w needs files to write to")
+		return 2
+	case *output != "" && len(paths) > 1:
+		fmt.Fprintln(stderr, "sgotranslate: -o needs a single input")
+		return 2
+	}
+
+	var named []sgo.NamedFile
+	if len(paths) == 0 {
+		named = append(named, sgo.NamedFile{Path: "<standard input>", File: stdin})
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			formatter.Format(stderr, diagnostic.List(err))
+			return 1
+		}
+		defer f.Close()
+		named = append(named, sgo.NamedFile{Path: path, File: f})
+	}
+
+	translated, errs := sgo.TranslateFilesWith(sgo.TranslateOptions{}, named...)
+	if len(errs) > 0 {
+		list := diagnostic.List(errs...)
+		list.Sort()
+		formatter.Format(stderr, list)
+		return 1
+	}
+
+	for i, src := range translated {
+		switch {
+		case *write:
+			path := paths[i]
+			err = ioutil.WriteFile(path[:len(path)-len(filepath.Ext(path))]+".go", src, 0644)
+		case *output != "":
+			err = ioutil.WriteFile(*output, src, 0644)
+		default:
+			_, err = stdout.Write(src)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		formatter.Format(stderr, diagnostic.List(errs...))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSrc = `package p
+
+func f(x ?*int) int {
+	if x == nil {
+		return 0
+	}
+	return *x
+}
+`
+
+const testBadSrc = `package p
+
+func f(x ?*int) int {
+	return *x
+}
+
+var y *int = nil
+`
+
+func runTranslate(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = translateMain(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestStdin(t *testing.T) {
+	code, stdout, stderr := runTranslate(t, testSrc)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "func f(x *int) int {") {
+		t.Errorf("unexpected translation:\n%s", stdout)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sgotranslate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a.sgo")
+	b := filepath.Join(dir, "b.sgo")
+	ioutil.WriteFile(a, []byte(testSrc), 0644)
+	ioutil.WriteFile(b, []byte("package p\n\nvar g = f(nil)\n"), 0644)
+
+	code, stdout, stderr := runTranslate(t, "", "-w", a, b)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output with -w, got:\n%s", stdout)
+	}
+	for _, path := range []string{"a.go", "b.go"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if !strings.Contains(string(got), "package p") {
+			t.Errorf("%s: unexpected translation:\n%s", path, got)
+		}
+	}
+
+	out := filepath.Join(dir, "out.go")
+	code, _, stderr = runTranslate(t, testSrc, "-o", out)
+	if code != 0 {
+		t.Fatalf("-o: expected exit code 0, got %d; stderr:\n%s", code, stderr)
+	}
+	if got, err := ioutil.ReadFile(out); err != nil || !strings.Contains(string(got), "func f(x *int) int {") {
+		t.Errorf("-o: unexpected translation, error %v:\n%s", err, got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	code, stdout, stderr := runTranslate(t, testBadSrc)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output, got:\n%s", stdout)
+	}
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected both errors reported, got:\n%s", stderr)
+	}
+	if !strings.HasPrefix(lines[0], "<standard input>:4:") || !strings.HasPrefix(lines[1], "<standard input>:7:") {
+		t.Errorf("unexpected errors:\n%s", stderr)
+	}
+
+	code, _, stderr = runTranslate(t, testBadSrc, "-format", "json")
+	if code != 1 {
+		t.Errorf("json: expected exit code 1, got %d", code)
+	}
+	var diags []struct {
+		Line int `json:"line"`
+	}
+	if err := json.Unmarshal([]byte(stderr), &diags); err != nil || len(diags) != 2 {
+		t.Errorf("json: unexpected errors, %v:\n%s", err, stderr)
+	}
+
+	code, _, _ = runTranslate(t, "", "does-not-exist.sgo")
+	if code != 1 {
+		t.Errorf("missing file: expected exit code 1, got %d", code)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-nonsense"},
+		{"-format", "yaml"},
+		{"-w"},
+		{"-w", "-o", "out.go", "a.sgo"},
+		{"-o", "out.go", "a.sgo", "b.sgo"},
+	} {
+		code, _, stderr := runTranslate(t, testSrc, args...)
+		if code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+		if stderr == "" {
+			t.Errorf("%v: expected a message", args)
+		}
+	}
+}